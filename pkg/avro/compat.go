@@ -0,0 +1,217 @@
+package avro
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Incompatibility is a reason data written with one schema cannot be read with another
+type Incompatibility struct {
+	Path    string // location in the reader schema, e.g. Order.items[].price
+	Message string
+}
+
+func (i Incompatibility) String() string {
+	return i.Path + ": " + i.Message
+}
+
+// CheckCompatibility reports why data written with writer cannot be read with
+// reader under the Avro schema resolution rules; none means reader can read it
+func CheckCompatibility(reader, writer *Schema) []Incompatibility {
+	c := &compatChecker{seen: make(map[[2]*Schema]bool)}
+	c.check(reader, writer, rootPath(reader))
+	return c.problems
+}
+
+type compatChecker struct {
+	seen     map[[2]*Schema]bool // named type pairs already checked, for recursive types
+	problems []Incompatibility
+}
+
+func rootPath(s *Schema) string {
+	if s.IsNamed() {
+		return shortName(s.Name)
+	}
+	return s.String()
+}
+
+func (c *compatChecker) add(path, format string, args ...interface{}) {
+	c.problems = append(c.problems, Incompatibility{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *compatChecker) check(reader, writer *Schema, path string) {
+	// A writer union is readable when every branch it may contain is
+	if writer.Type == "union" {
+		for _, branch := range writer.Types {
+			if reader.Type != "union" && !typesMatch(reader, branch) {
+				c.add(path, "%s in the writer's union cannot be read as %s", branch.String(), reader.String())
+				continue
+			}
+			c.check(reader, branch, path)
+		}
+		return
+	}
+	if reader.Type == "union" {
+		branch := resolveUnion(reader, writer)
+		if branch == nil {
+			c.add(path, "%s written by the writer is not in the reader's %s", writer.String(), reader.String())
+			return
+		}
+		c.check(branch, writer, path)
+		return
+	}
+
+	if reader.IsNamed() && writer.IsNamed() {
+		key := [2]*Schema{reader, writer}
+		if c.seen[key] {
+			return
+		}
+		c.seen[key] = true
+	}
+
+	if !typesMatch(reader, writer) {
+		c.add(path, "type changed from %s to %s", writer.String(), reader.String())
+		return
+	}
+	if reader.Type != writer.Type && !isRecord(reader) {
+		return // promoted primitive
+	}
+
+	switch reader.Type {
+	case "record", "error":
+		if !namesMatch(reader, writer) {
+			c.add(path, "record name changed from %s to %s", writer.Name, reader.Name)
+			return
+		}
+		for _, field := range reader.Fields {
+			fieldPath := path + "." + field.Name
+			writerField := findField(writer, field)
+			if writerField == nil {
+				if !field.HasDefault {
+					c.add(fieldPath, "field is missing from the writer schema and has no default")
+				}
+				continue
+			}
+			c.check(field.Type, writerField.Type, fieldPath)
+		}
+	case "enum":
+		if !namesMatch(reader, writer) {
+			c.add(path, "enum name changed from %s to %s", writer.Name, reader.Name)
+			return
+		}
+		if reader.Default != "" {
+			return
+		}
+		symbols := make(map[string]bool, len(reader.Symbols))
+		for _, symbol := range reader.Symbols {
+			symbols[symbol] = true
+		}
+		var missing []string
+		for _, symbol := range writer.Symbols {
+			if !symbols[symbol] {
+				missing = append(missing, symbol)
+			}
+		}
+		if len(missing) > 0 {
+			c.add(path, "enum symbols %s are missing from the reader and it has no default", strings.Join(missing, ", "))
+		}
+	case "fixed":
+		if !namesMatch(reader, writer) {
+			c.add(path, "fixed name changed from %s to %s", writer.Name, reader.Name)
+			return
+		}
+		if reader.Size != writer.Size {
+			c.add(path, "fixed size changed from %d to %d", writer.Size, reader.Size)
+		}
+	case "array":
+		c.check(reader.Items, writer.Items, path+"[]")
+	case "map":
+		c.check(reader.Values, writer.Values, path+"{}")
+	}
+}
+
+// typesMatch reports whether reader and writer are the same kind of type, or
+// the writer's primitive can be promoted to the reader's
+func typesMatch(reader, writer *Schema) bool {
+	if reader.Type == writer.Type || (isRecord(reader) && isRecord(writer)) {
+		return true
+	}
+	return promotable(writer.Type, reader.Type)
+}
+
+func isRecord(s *Schema) bool {
+	return s.Type == "record" || s.Type == "error"
+}
+
+// promotable reports whether a writer's primitive type can be read as the reader's
+func promotable(writer, reader string) bool {
+	switch writer {
+	case "int":
+		return reader == "long" || reader == "float" || reader == "double"
+	case "long":
+		return reader == "float" || reader == "double"
+	case "float":
+		return reader == "double"
+	case "string":
+		return reader == "bytes"
+	case "bytes":
+		return reader == "string"
+	}
+	return false
+}
+
+// resolveUnion picks the reader union branch for a writer type: the first
+// branch of the same type, or failing that the first it can be promoted to
+func resolveUnion(reader, writer *Schema) *Schema {
+	for _, branch := range reader.Types {
+		if branch.Type == writer.Type && (!branch.IsNamed() || namesMatch(branch, writer)) {
+			return branch
+		}
+	}
+	for _, branch := range reader.Types {
+		if promotable(writer.Type, branch.Type) {
+			return branch
+		}
+	}
+	return nil
+}
+
+// namesMatch compares the unqualified names of two named types, also
+// accepting any of the reader's aliases for the writer's name
+func namesMatch(reader, writer *Schema) bool {
+	name := shortName(writer.Name)
+	if shortName(reader.Name) == name {
+		return true
+	}
+	for _, alias := range reader.Aliases {
+		if shortName(alias) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// findField returns the writer field a reader field reads from, by name or
+// failing that by one of the reader field's aliases
+func findField(writer *Schema, field *Field) *Field {
+	for _, candidate := range writer.Fields {
+		if candidate.Name == field.Name {
+			return candidate
+		}
+	}
+	for _, alias := range field.Aliases {
+		for _, candidate := range writer.Fields {
+			if candidate.Name == alias {
+				return candidate
+			}
+		}
+	}
+	return nil
+}
+
+func shortName(name string) string {
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		return name[idx+1:]
+	}
+	return name
+}
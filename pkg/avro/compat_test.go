@@ -0,0 +1,168 @@
+package avro
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	userV1 = `{"type":"record","name":"User","namespace":"com.example","fields":[
+		{"name":"id","type":"long"},
+		{"name":"email","type":"string"}
+	]}`
+	colorABC = `{"type":"enum","name":"Color","symbols":["A","B","C"]}`
+	listNode = `{"type":"record","name":"Node","fields":[
+		{"name":"value","type":"int"},
+		{"name":"next","type":["null","Node"]}
+	]}`
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader string
+		writer string
+		want   []string // incompatibilities, empty when the reader can read the writer's data
+	}{
+		// Primitive promotion
+		{"same type", `"int"`, `"int"`, nil},
+		{"int to long", `"long"`, `"int"`, nil},
+		{"int to float", `"float"`, `"int"`, nil},
+		{"int to double", `"double"`, `"int"`, nil},
+		{"long to float", `"float"`, `"long"`, nil},
+		{"long to double", `"double"`, `"long"`, nil},
+		{"float to double", `"double"`, `"float"`, nil},
+		{"string to bytes", `"bytes"`, `"string"`, nil},
+		{"bytes to string", `"string"`, `"bytes"`, nil},
+		{"long to int", `"int"`, `"long"`, []string{"int: type changed from long to int"}},
+		{"double to float", `"float"`, `"double"`, []string{"float: type changed from double to float"}},
+		{"float to long", `"long"`, `"float"`, []string{"long: type changed from float to long"}},
+		{"string to int", `"int"`, `"string"`, []string{"int: type changed from string to int"}},
+		{"boolean to string", `"string"`, `"boolean"`, []string{"string: type changed from boolean to string"}},
+
+		// Records
+		{"same record", userV1, userV1, nil},
+		{"removed field", `{"type":"record","name":"User","namespace":"com.example","fields":[{"name":"id","type":"long"}]}`, userV1, nil},
+		{"added field with default",
+			`{"type":"record","name":"User","namespace":"com.example","fields":[
+				{"name":"id","type":"long"},{"name":"email","type":"string"},{"name":"age","type":"int","default":0}]}`,
+			userV1, nil},
+		{"added field with a null default",
+			`{"type":"record","name":"User","namespace":"com.example","fields":[
+				{"name":"id","type":"long"},{"name":"email","type":"string"},{"name":"nick","type":["null","string"],"default":null}]}`,
+			userV1, nil},
+		{"added field without default",
+			`{"type":"record","name":"User","namespace":"com.example","fields":[
+				{"name":"id","type":"long"},{"name":"email","type":"string"},{"name":"age","type":"int"}]}`,
+			userV1, []string{"User.age: field is missing from the writer schema and has no default"}},
+		{"promoted field",
+			`{"type":"record","name":"User","namespace":"com.example","fields":[{"name":"id","type":"double"},{"name":"email","type":"bytes"}]}`,
+			userV1, nil},
+		{"narrowed field",
+			`{"type":"record","name":"User","namespace":"com.example","fields":[{"name":"id","type":"int"},{"name":"email","type":"string"}]}`,
+			userV1, []string{"User.id: type changed from long to int"}},
+		{"renamed field with alias",
+			`{"type":"record","name":"User","namespace":"com.example","fields":[
+				{"name":"id","type":"long"},{"name":"mail","type":"string","aliases":["email"]}]}`,
+			userV1, nil},
+		{"renamed field without alias",
+			`{"type":"record","name":"User","namespace":"com.example","fields":[
+				{"name":"id","type":"long"},{"name":"mail","type":"string"}]}`,
+			userV1, []string{"User.mail: field is missing from the writer schema and has no default"}},
+		{"renamed record",
+			`{"type":"record","name":"Customer","namespace":"com.example","fields":[{"name":"id","type":"long"}]}`,
+			userV1, []string{"Customer: record name changed from com.example.User to com.example.Customer"}},
+		{"renamed record with alias",
+			`{"type":"record","name":"Customer","namespace":"com.example","aliases":["User"],"fields":[{"name":"id","type":"long"}]}`,
+			userV1, nil},
+		{"record namespace changed",
+			`{"type":"record","name":"User","namespace":"org.other","fields":[{"name":"id","type":"long"}]}`,
+			userV1, nil},
+		{"record and error", `{"type":"error","name":"User","fields":[{"name":"id","type":"long"}]}`, userV1, nil},
+		{"record to map", `{"type":"map","values":"string"}`, userV1, []string{"map<string>: type changed from com.example.User to map<string>"}},
+		{"recursive record", listNode, listNode, nil},
+		{"recursive record, promoted",
+			`{"type":"record","name":"Node","fields":[{"name":"value","type":"long"},{"name":"next","type":["null","Node"]}]}`,
+			listNode, nil},
+		{"recursive record, narrowed",
+			listNode,
+			`{"type":"record","name":"Node","fields":[{"name":"value","type":"long"},{"name":"next","type":["null","Node"]}]}`,
+			[]string{"Node.value: type changed from long to int"}},
+
+		// Enums
+		{"same enum", colorABC, colorABC, nil},
+		{"added symbol", `{"type":"enum","name":"Color","symbols":["A","B","C","D"]}`, colorABC, nil},
+		{"removed symbol without default", `{"type":"enum","name":"Color","symbols":["A","B"]}`, colorABC,
+			[]string{"Color: enum symbols C are missing from the reader and it has no default"}},
+		{"removed symbols without default", `{"type":"enum","name":"Color","symbols":["A"]}`, colorABC,
+			[]string{"Color: enum symbols B, C are missing from the reader and it has no default"}},
+		{"removed symbol with default", `{"type":"enum","name":"Color","symbols":["A","B"],"default":"A"}`, colorABC, nil},
+		{"renamed enum", `{"type":"enum","name":"Colour","symbols":["A","B","C"]}`, colorABC,
+			[]string{"Colour: enum name changed from Color to Colour"}},
+		{"renamed enum with alias", `{"type":"enum","name":"Colour","aliases":["Color"],"symbols":["A","B","C"]}`, colorABC, nil},
+
+		// Fixed
+		{"same fixed", `{"type":"fixed","name":"Hash","size":16}`, `{"type":"fixed","name":"Hash","size":16}`, nil},
+		{"fixed size changed", `{"type":"fixed","name":"Hash","size":32}`, `{"type":"fixed","name":"Hash","size":16}`,
+			[]string{"Hash: fixed size changed from 16 to 32"}},
+		{"fixed renamed", `{"type":"fixed","name":"Digest","size":16}`, `{"type":"fixed","name":"Hash","size":16}`,
+			[]string{"Digest: fixed name changed from Hash to Digest"}},
+
+		// Arrays and maps
+		{"array items promoted", `{"type":"array","items":"long"}`, `{"type":"array","items":"int"}`, nil},
+		{"array items changed", `{"type":"array","items":"int"}`, `{"type":"array","items":"string"}`,
+			[]string{"array<int>[]: type changed from string to int"}},
+		{"map values promoted", `{"type":"map","values":"double"}`, `{"type":"map","values":"float"}`, nil},
+		{"map values changed", `{"type":"map","values":"int"}`, `{"type":"map","values":"long"}`,
+			[]string{"map<int>{}: type changed from long to int"}},
+		{"nested path",
+			`{"type":"record","name":"Order","fields":[{"name":"items","type":{"type":"array","items":
+				{"type":"record","name":"Item","fields":[{"name":"price","type":"float"}]}}}]}`,
+			`{"type":"record","name":"Order","fields":[{"name":"items","type":{"type":"array","items":
+				{"type":"record","name":"Item","fields":[{"name":"price","type":"double"}]}}}]}`,
+			[]string{"Order.items[].price: type changed from double to float"}},
+
+		// Unions
+		{"value into a union", `["null","string"]`, `"string"`, nil},
+		{"value promoted into a union", `["null","long"]`, `"int"`, nil},
+		{"exact branch before promotion", `["long","int"]`, `"int"`, nil},
+		{"value not in the union", `["null","int"]`, `"string"`,
+			[]string{"union[null,int]: string written by the writer is not in the reader's union[null,int]"}},
+		{"union to a value", `"string"`, `["null","string"]`,
+			[]string{"string: null in the writer's union cannot be read as string"}},
+		{"union to a promoted value", `"double"`, `["int","float"]`, nil},
+		{"union to a wider union", `["null","string","long"]`, `["null","string"]`, nil},
+		{"union to a narrower union", `["null","string"]`, `["null","string","long"]`,
+			[]string{"union[null,string]: long written by the writer is not in the reader's union[null,string]"}},
+		{"union branches promoted", `["null","long"]`, `["null","int"]`, nil},
+		{"named branch matched by name", `["null",` + userV1 + `]`, userV1, nil},
+		{"named branch with another name",
+			`["null",{"type":"record","name":"Customer","fields":[{"name":"id","type":"long"}]}]`,
+			userV1, []string{"union[null,Customer]: com.example.User written by the writer is not in the reader's union[null,Customer]"}},
+		{"named branch matched by alias",
+			`["null",{"type":"record","name":"Customer","aliases":["User"],"fields":[{"name":"id","type":"long"}]}]`,
+			userV1, nil},
+		{"enum branch missing symbol",
+			`["null",{"type":"enum","name":"Color","symbols":["A","B"]}]`, colorABC,
+			[]string{"union[null,Color]: enum symbols C are missing from the reader and it has no default"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := Parse(tt.reader)
+			if err != nil {
+				t.Fatalf("reader: %v", err)
+			}
+			writer, err := Parse(tt.writer)
+			if err != nil {
+				t.Fatalf("writer: %v", err)
+			}
+			var got []string
+			for _, problem := range CheckCompatibility(reader, writer) {
+				got = append(got, problem.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package avro
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// Record is a decoded record that keeps the schema's field order when marshalled to JSON
+type Record []RecordField
+
+// RecordField is one field of a decoded record
+type RecordField struct {
+	Name  string
+	Value interface{}
+}
+
+// MarshalJSON writes the record as a JSON object in field order
+func (r Record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Decode decodes Avro binary data written with the schema; records become
+// Record values, maps map[string]interface{}, and bytes and fixed values strings
+func Decode(schema *Schema, data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+	value, err := d.decode(schema)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("%d trailing bytes after Avro value", len(d.data)-d.pos)
+	}
+	return value, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) decode(schema *Schema) (interface{}, error) {
+	switch schema.Type {
+	case "null":
+		return nil, nil
+	case "boolean":
+		b, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		return b != 0, nil
+	case "int", "long":
+		return d.long()
+	case "float":
+		raw, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(raw)), nil
+	case "double":
+		raw, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(raw)), nil
+	case "bytes":
+		raw, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return bytesString(raw), nil
+	case "string":
+		raw, err := d.bytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(raw), nil
+	case "fixed":
+		raw, err := d.next(schema.Size)
+		if err != nil {
+			return nil, err
+		}
+		return bytesString(raw), nil
+	case "enum":
+		index, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || int(index) >= len(schema.Symbols) {
+			return nil, fmt.Errorf("enum %s index %d out of range", schema.Name, index)
+		}
+		return schema.Symbols[index], nil
+	case "union":
+		index, err := d.long()
+		if err != nil {
+			return nil, err
+		}
+		if index < 0 || int(index) >= len(schema.Types) {
+			return nil, fmt.Errorf("union index %d out of range", index)
+		}
+		return d.decode(schema.Types[index])
+	case "record", "error":
+		record := make(Record, 0, len(schema.Fields))
+		for _, field := range schema.Fields {
+			value, err := d.decode(field.Type)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", schema.Name, field.Name, err)
+			}
+			record = append(record, RecordField{Name: field.Name, Value: value})
+		}
+		return record, nil
+	case "array":
+		items := []interface{}{}
+		err := d.blocks(func() error {
+			item, err := d.decode(schema.Items)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+			return nil
+		})
+		return items, err
+	case "map":
+		values := map[string]interface{}{}
+		err := d.blocks(func() error {
+			key, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			value, err := d.decode(schema.Values)
+			if err != nil {
+				return err
+			}
+			values[string(key)] = value
+			return nil
+		})
+		return values, err
+	}
+	return nil, fmt.Errorf("cannot decode type %s", schema.Type)
+}
+
+// blocks reads the blocks of an array or map, calling item for each entry
+func (d *decoder) blocks(item func() error) error {
+	for {
+		count, err := d.long()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// A negative count is followed by the block size in bytes
+			count = -count
+			if _, err := d.long(); err != nil {
+				return err
+			}
+		}
+		for i := int64(0); i < count; i++ {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, fmt.Errorf("unexpected end of Avro data")
+	}
+	raw := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return raw, nil
+}
+
+func (d *decoder) byte() (byte, error) {
+	raw, err := d.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return raw[0], nil
+}
+
+// long reads a zig-zag encoded variable-length integer
+func (d *decoder) long() (int64, error) {
+	value, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid Avro varint")
+	}
+	d.pos += n
+	return int64(value>>1) ^ -int64(value&1), nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	length, err := d.long()
+	if err != nil {
+		return nil, err
+	}
+	if length > int64(len(d.data)) {
+		return nil, fmt.Errorf("unexpected end of Avro data")
+	}
+	return d.next(int(length))
+}
+
+// bytesString renders bytes as text when they are valid UTF-8 and as escaped code points otherwise, as Avro JSON does
+func bytesString(raw []byte) string {
+	if utf8.Valid(raw) {
+		return string(raw)
+	}
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
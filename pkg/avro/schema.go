@@ -0,0 +1,251 @@
+package avro
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Schema is a parsed Avro schema
+type Schema struct {
+	Type        string // null, boolean, int, long, float, double, bytes, string, record, error, enum, array, map, fixed, union
+	Name        string // full name of named types
+	Aliases     []string
+	Doc         string
+	Fields      []*Field  // record
+	Symbols     []string  // enum
+	Default     string    // enum default symbol
+	Items       *Schema   // array
+	Values      *Schema   // map
+	Size        int       // fixed
+	Types       []*Schema // union
+	LogicalType string
+	Precision   int
+	Scale       int
+}
+
+// Field is a field of a record schema
+type Field struct {
+	Name       string
+	Type       *Schema
+	Doc        string
+	Default    interface{}
+	HasDefault bool
+	Aliases    []string
+	Order      string
+}
+
+var primitiveTypes = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// IsNamed reports whether the schema is a named type (record, enum or fixed)
+func (s *Schema) IsNamed() bool {
+	switch s.Type {
+	case "record", "error", "enum", "fixed":
+		return true
+	}
+	return false
+}
+
+// String returns the type name, or the full name of named types
+func (s *Schema) String() string {
+	if s.IsNamed() {
+		return s.Name
+	}
+	switch s.Type {
+	case "array":
+		return "array<" + s.Items.String() + ">"
+	case "map":
+		return "map<" + s.Values.String() + ">"
+	case "union":
+		names := make([]string, len(s.Types))
+		for i, t := range s.Types {
+			names[i] = t.String()
+		}
+		return "union[" + strings.Join(names, ",") + "]"
+	}
+	return s.Type
+}
+
+// Parse parses an Avro schema from its JSON form
+func Parse(schemaJSON string) (*Schema, error) {
+	var raw interface{}
+	if err := json.Unmarshal([]byte(schemaJSON), &raw); err != nil {
+		return nil, fmt.Errorf("invalid Avro schema JSON: %w", err)
+	}
+
+	p := &parser{named: make(map[string]*Schema)}
+	return p.parse(raw, "")
+}
+
+// parser tracks named types so later references resolve to them
+type parser struct {
+	named map[string]*Schema
+}
+
+func (p *parser) parse(raw interface{}, namespace string) (*Schema, error) {
+	switch v := raw.(type) {
+	case string:
+		return p.reference(v, namespace)
+	case []interface{}:
+		union := &Schema{Type: "union"}
+		for _, item := range v {
+			member, err := p.parse(item, namespace)
+			if err != nil {
+				return nil, err
+			}
+			if member.Type == "union" {
+				return nil, fmt.Errorf("unions may not immediately contain other unions")
+			}
+			union.Types = append(union.Types, member)
+		}
+		return union, nil
+	case map[string]interface{}:
+		return p.parseComplex(v, namespace)
+	}
+	return nil, fmt.Errorf("invalid schema: %v", raw)
+}
+
+// reference resolves a primitive type or a previously defined named type
+func (p *parser) reference(name, namespace string) (*Schema, error) {
+	if primitiveTypes[name] {
+		return &Schema{Type: name}, nil
+	}
+	if schema, ok := p.named[fullName(name, namespace)]; ok {
+		return schema, nil
+	}
+	if schema, ok := p.named[name]; ok {
+		return schema, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", name)
+}
+
+func (p *parser) parseComplex(m map[string]interface{}, namespace string) (*Schema, error) {
+	typeName, ok := m["type"].(string)
+	if !ok {
+		// {"type": {...}} or {"type": [...]} wraps another schema
+		if nested, exists := m["type"]; exists {
+			return p.parse(nested, namespace)
+		}
+		return nil, fmt.Errorf("schema object has no type")
+	}
+
+	schema := &Schema{Type: typeName}
+	schema.LogicalType, _ = m["logicalType"].(string)
+	schema.Doc, _ = m["doc"].(string)
+	if precision, ok := m["precision"].(float64); ok {
+		schema.Precision = int(precision)
+	}
+	if scale, ok := m["scale"].(float64); ok {
+		schema.Scale = int(scale)
+	}
+
+	switch typeName {
+	case "record", "error", "enum", "fixed":
+		name, _ := m["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("%s schema has no name", typeName)
+		}
+		if ns, ok := m["namespace"].(string); ok && !strings.Contains(name, ".") {
+			namespace = ns
+		}
+		schema.Name = fullName(name, namespace)
+		if idx := strings.LastIndex(schema.Name, "."); idx >= 0 {
+			namespace = schema.Name[:idx]
+		} else {
+			namespace = ""
+		}
+		if _, exists := p.named[schema.Name]; exists {
+			return nil, fmt.Errorf("type %s is defined twice", schema.Name)
+		}
+		p.named[schema.Name] = schema
+		for _, alias := range stringList(m["aliases"]) {
+			schema.Aliases = append(schema.Aliases, fullName(alias, namespace))
+		}
+	}
+
+	switch typeName {
+	case "record", "error":
+		fields, ok := m["fields"].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("record %s has no fields", schema.Name)
+		}
+		seen := make(map[string]bool)
+		for _, rawField := range fields {
+			fieldMap, ok := rawField.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("record %s has an invalid field", schema.Name)
+			}
+			field := &Field{Aliases: stringList(fieldMap["aliases"])}
+			field.Name, _ = fieldMap["name"].(string)
+			field.Doc, _ = fieldMap["doc"].(string)
+			field.Order, _ = fieldMap["order"].(string)
+			if field.Name == "" {
+				return nil, fmt.Errorf("record %s has a field without a name", schema.Name)
+			}
+			if seen[field.Name] {
+				return nil, fmt.Errorf("record %s has duplicate field %s", schema.Name, field.Name)
+			}
+			seen[field.Name] = true
+
+			fieldType, err := p.parse(fieldMap["type"], namespace)
+			if err != nil {
+				return nil, fmt.Errorf("field %s.%s: %w", schema.Name, field.Name, err)
+			}
+			field.Type = fieldType
+			field.Default, field.HasDefault = fieldMap["default"]
+			schema.Fields = append(schema.Fields, field)
+		}
+	case "enum":
+		schema.Symbols = stringList(m["symbols"])
+		if len(schema.Symbols) == 0 {
+			return nil, fmt.Errorf("enum %s has no symbols", schema.Name)
+		}
+		schema.Default, _ = m["default"].(string)
+	case "fixed":
+		size, ok := m["size"].(float64)
+		if !ok || size < 0 {
+			return nil, fmt.Errorf("fixed %s has no valid size", schema.Name)
+		}
+		schema.Size = int(size)
+	case "array":
+		items, err := p.parse(m["items"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("array items: %w", err)
+		}
+		schema.Items = items
+	case "map":
+		values, err := p.parse(m["values"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("map values: %w", err)
+		}
+		schema.Values = values
+	default:
+		if !primitiveTypes[typeName] {
+			// A named type reference written as {"type": "com.example.Name"}
+			return p.reference(typeName, namespace)
+		}
+	}
+
+	return schema, nil
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func stringList(raw interface{}) []string {
+	items, _ := raw.([]interface{})
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
+}
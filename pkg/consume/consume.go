@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -14,6 +15,9 @@ import (
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/fieldcrypt"
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
 )
 
 // Config holds configuration for Kafka consumer
@@ -31,6 +35,24 @@ type Config struct {
 	ShowOffset    bool
 	ShowTimestamp bool
 	Verbose       bool
+	Auth          *kafkautils.AuthConfig
+	TLS           *kafkautils.TLSConfig
+
+	// Exec mode
+	Exec             string // command run via sh -c for each message or batch
+	ExecBatch        int
+	ExecBatchTimeout time.Duration
+	ExecTimeout      time.Duration
+	Retries          int
+	RetryBackoff     time.Duration
+	DLQFile          string
+	DLQTopic         string
+
+	// Field decryption
+	Decrypt bool
+	KeyFile string
+
+	keyring *fieldcrypt.Keyring
 }
 
 // DefaultConfig returns default consumer configuration
@@ -48,6 +70,11 @@ func DefaultConfig() Config {
 		ShowOffset:    false,
 		ShowTimestamp: false,
 		Verbose:       false,
+
+		ExecBatch:        1,
+		ExecBatchTimeout: time.Second,
+		Retries:          3,
+		RetryBackoff:     time.Second,
 	}
 }
 
@@ -64,14 +91,23 @@ type MessageOutput struct {
 
 // Consumer represents a Kafka consumer
 type Consumer struct {
-	config Config
-	client sarama.ConsumerGroup
-	ready  chan bool
+	config   Config
+	client   sarama.ConsumerGroup
+	ready    chan bool
+	executor *executor
+	cancel   context.CancelFunc
+
+	errMu sync.Mutex
+	err   error
 }
 
 // Run is the main entry point for consume functionality
 func Run(args []string) error {
 	config := DefaultConfig()
+
+	if err := applyContext(args, &config); err != nil {
+		return err
+	}
 	
 	if err := parseArgs(args, &config); err != nil {
 		return err
@@ -85,6 +121,28 @@ func Run(args []string) error {
 	return startConsumer(config)
 }
 
+// applyContext loads brokers, credentials and group from the active kafka context
+func applyContext(args []string, config *Config) error {
+	kctx, err := kafkacontext.FromArgs(args)
+	if err != nil || kctx == nil {
+		return err
+	}
+
+	brokers, auth, tlsConfig, err := kctx.ClientSettings()
+	if err != nil {
+		return err
+	}
+	if len(brokers) > 0 {
+		config.Brokers = brokers
+	}
+	config.Auth, config.TLS = auth, tlsConfig
+	if kctx.ConsumerGroup != "" {
+		config.ConsumerGroup = kctx.ConsumerGroup
+	}
+
+	return nil
+}
+
 func parseArgs(args []string, config *Config) error {
 	// Check for help first
 	for _, arg := range args {
@@ -93,40 +151,51 @@ func parseArgs(args []string, config *Config) error {
 		}
 	}
 	
-	for i, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch arg {
 		case "--brokers", "-b":
 			if i+1 < len(args) {
 				config.Brokers = strings.Split(args[i+1], ",")
+				i++
 			}
 		case "--topic", "-t":
 			if i+1 < len(args) {
 				config.Topic = args[i+1]
+				i++
 			}
 		case "--group", "-g":
 			if i+1 < len(args) {
 				config.ConsumerGroup = args[i+1]
+				i++
 			}
 		case "--offset", "-o":
 			if i+1 < len(args) {
 				config.Offset = args[i+1]
+				i++
 			}
 		case "--max-messages", "-m":
 			if i+1 < len(args) {
 				if count, err := strconv.Atoi(args[i+1]); err == nil {
 					config.MaxMessages = count
 				}
+				i++
 			}
 		case "--timeout":
 			if i+1 < len(args) {
 				if duration, err := time.ParseDuration(args[i+1]); err == nil {
 					config.Timeout = duration
 				}
+				i++
 			}
 		case "--format", "-f":
 			if i+1 < len(args) {
 				config.Format = args[i+1]
+				i++
 			}
+		case "--context":
+			// Handled by applyContext
+			i++
 		case "--show-key", "-k":
 			config.ShowKey = true
 		case "--show-headers":
@@ -137,6 +206,73 @@ func parseArgs(args []string, config *Config) error {
 			config.ShowOffset = true
 		case "--show-timestamp":
 			config.ShowTimestamp = true
+		case "--exec", "-e":
+			if i+1 < len(args) {
+				config.Exec = args[i+1]
+				i++
+			}
+		case "--exec-batch":
+			if i+1 < len(args) {
+				count, err := strconv.Atoi(args[i+1])
+				if err != nil || count < 1 {
+					return fmt.Errorf("invalid --exec-batch: %s", args[i+1])
+				}
+				config.ExecBatch = count
+				i++
+			}
+		case "--exec-batch-timeout":
+			if i+1 < len(args) {
+				duration, err := time.ParseDuration(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid --exec-batch-timeout: %s", args[i+1])
+				}
+				config.ExecBatchTimeout = duration
+				i++
+			}
+		case "--exec-timeout":
+			if i+1 < len(args) {
+				duration, err := time.ParseDuration(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid --exec-timeout: %s", args[i+1])
+				}
+				config.ExecTimeout = duration
+				i++
+			}
+		case "--retries":
+			if i+1 < len(args) {
+				count, err := strconv.Atoi(args[i+1])
+				if err != nil || count < 0 {
+					return fmt.Errorf("invalid --retries: %s", args[i+1])
+				}
+				config.Retries = count
+				i++
+			}
+		case "--retry-backoff":
+			if i+1 < len(args) {
+				duration, err := time.ParseDuration(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid --retry-backoff: %s", args[i+1])
+				}
+				config.RetryBackoff = duration
+				i++
+			}
+		case "--dlq-file":
+			if i+1 < len(args) {
+				config.DLQFile = args[i+1]
+				i++
+			}
+		case "--dlq-topic":
+			if i+1 < len(args) {
+				config.DLQTopic = args[i+1]
+				i++
+			}
+		case "--decrypt":
+			config.Decrypt = true
+		case "--key-file":
+			if i+1 < len(args) {
+				config.KeyFile = args[i+1]
+				i++
+			}
 		case "--verbose", "-v":
 			config.Verbose = true
 		case "-h", "--help":
@@ -157,6 +293,7 @@ func printHelp() error {
 Consume messages from a Kafka topic and output to stdout.
 
 Options:
+  --context NAME            Kafka context from ~/.config/dimutils/kafka.yaml
   --brokers, -b BROKERS     Comma-separated list of brokers (default: localhost:9092)
   --topic, -t TOPIC         Topic to consume from
   --group, -g GROUP         Consumer group ID (default: dimutils-consumer)
@@ -172,11 +309,40 @@ Options:
   --verbose, -v             Verbose output
   -h, --help                Show this help message
 
+Exec mode:
+  --exec, -e CMD            Run CMD (via sh -c) for each message instead of printing it.
+                            The offset is only committed after CMD exits 0
+  --exec-batch N            Pass N messages per run, one per line in --format (default: 1)
+  --exec-batch-timeout DUR  Run a partial batch after waiting this long (default: 1s)
+  --exec-timeout DUR        Kill CMD if it runs longer than this (default: no limit)
+  --retries COUNT           Retries after a failed run (default: 3)
+  --retry-backoff DUR       Delay before the first retry, doubled for each retry (default: 1s)
+  --dlq-file FILE           Append messages that still fail to FILE as JSON lines
+  --dlq-topic TOPIC         Produce messages that still fail to TOPIC
+                            Without a dead-letter target, consume stops with an error
+
+  With a single message, CMD gets the raw value on stdin and these variables:
+  KAFKA_TOPIC, KAFKA_PARTITION, KAFKA_OFFSET, KAFKA_KEY, KAFKA_TIMESTAMP,
+  KAFKA_HEADERS (JSON object) and KAFKA_HEADER_<NAME> for each header.
+  Batches get KAFKA_TOPIC, KAFKA_PARTITION, KAFKA_BATCH_SIZE,
+  KAFKA_FIRST_OFFSET and KAFKA_LAST_OFFSET.
+
+Field decryption:
+  --decrypt                 Decrypt fields encrypted by produce --encrypt-fields
+  --key-file FILE           Keyring file with the master keys
+
+  Decrypted messages are printed or passed to --exec without the dimutils.enc.*
+  headers; messages without them are passed through. Dead-lettered messages
+  keep their encrypted fields. Without --decrypt the ciphertext is shown as is.
+
 Examples:
   consume my-topic
   consume --brokers broker1:9092,broker2:9092 --group my-group my-topic
   consume --format json --show-key --show-offset my-topic
-  consume --offset earliest --max-messages 100 my-topic`
+  consume --offset earliest --max-messages 100 my-topic
+  consume --exec './handle-order.sh' --retries 5 --dlq-file failed.ndjson orders
+  consume --exec './load-batch.sh' --exec-batch 500 --format json events
+  consume --decrypt --key-file keys.yaml --offset earliest orders`
 
 	fmt.Println(help)
 	return nil
@@ -193,6 +359,32 @@ func startConsumer(config Config) error {
 	saramaConfig.Consumer.Offsets.Initial = getOffsetMode(config.Offset)
 	saramaConfig.Consumer.Group.Session.Timeout = config.Timeout
 	saramaConfig.Consumer.Return.Errors = true
+	saramaConfig.Producer.Return.Successes = true // for the dead-letter topic
+
+	if err := kafkautils.ConfigureSecurity(saramaConfig, config.Auth, config.TLS); err != nil {
+		return err
+	}
+
+	if config.Decrypt {
+		if config.KeyFile == "" {
+			return fmt.Errorf("--decrypt requires --key-file")
+		}
+		keyring, err := fieldcrypt.LoadKeyring(config.KeyFile)
+		if err != nil {
+			return err
+		}
+		config.keyring = keyring
+	}
+
+	var cmdExecutor *executor
+	if config.Exec != "" {
+		var err error
+		cmdExecutor, err = newExecutor(config, saramaConfig)
+		if err != nil {
+			return err
+		}
+		defer cmdExecutor.Close()
+	}
 
 	// Create consumer group
 	client, err := sarama.NewConsumerGroup(config.Brokers, config.ConsumerGroup, saramaConfig)
@@ -201,15 +393,17 @@ func startConsumer(config Config) error {
 	}
 	defer client.Close()
 
-	consumer := &Consumer{
-		config: config,
-		client: client,
-		ready:  make(chan bool),
-	}
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	consumer := &Consumer{
+		config:   config,
+		client:   client,
+		ready:    make(chan bool),
+		executor: cmdExecutor,
+		cancel:   cancel,
+	}
+
 	// Handle consumer group errors
 	go func() {
 		for err := range client.Errors() {
@@ -255,7 +449,23 @@ func startConsumer(config Config) error {
 	cancel()
 	wg.Wait()
 
-	return nil
+	return consumer.failure()
+}
+
+// fail records the first fatal error and stops the consumer
+func (consumer *Consumer) fail(err error) {
+	consumer.errMu.Lock()
+	if consumer.err == nil {
+		consumer.err = err
+	}
+	consumer.errMu.Unlock()
+	consumer.cancel()
+}
+
+func (consumer *Consumer) failure() error {
+	consumer.errMu.Lock()
+	defer consumer.errMu.Unlock()
+	return consumer.err
 }
 
 // Setup implements sarama.ConsumerGroupHandler
@@ -271,6 +481,10 @@ func (consumer *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
 
 // ConsumeClaim implements sarama.ConsumerGroupHandler
 func (consumer *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	if consumer.executor != nil {
+		return consumer.consumeWithExec(session, claim)
+	}
+
 	messageCount := 0
 
 	for {
@@ -280,7 +494,13 @@ func (consumer *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 				return nil
 			}
 
-			if err := consumer.outputMessage(message); err != nil {
+			decrypted, err := decryptMessage(consumer.config.keyring, message)
+			if err != nil {
+				log.Printf("Error decrypting message at %s/%d@%d: %v", message.Topic, message.Partition, message.Offset, err)
+				continue
+			}
+
+			if err := consumer.outputMessage(os.Stdout, decrypted); err != nil {
 				log.Printf("Error outputting message: %v", err)
 				continue
 			}
@@ -301,18 +521,18 @@ func (consumer *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 	}
 }
 
-func (consumer *Consumer) outputMessage(message *sarama.ConsumerMessage) error {
+func (consumer *Consumer) outputMessage(w io.Writer, message *sarama.ConsumerMessage) error {
 	switch consumer.config.Format {
 	case "json":
-		return consumer.outputJSON(message)
+		return consumer.outputJSON(w, message)
 	case "kv":
-		return consumer.outputKeyValue(message)
+		return consumer.outputKeyValue(w, message)
 	default: // raw
-		return consumer.outputRaw(message)
+		return consumer.outputRaw(w, message)
 	}
 }
 
-func (consumer *Consumer) outputJSON(message *sarama.ConsumerMessage) error {
+func (consumer *Consumer) outputJSON(w io.Writer, message *sarama.ConsumerMessage) error {
 	output := MessageOutput{
 		Value: string(message.Value),
 	}
@@ -346,11 +566,11 @@ func (consumer *Consumer) outputJSON(message *sarama.ConsumerMessage) error {
 		return err
 	}
 
-	fmt.Println(string(jsonData))
+	fmt.Fprintln(w, string(jsonData))
 	return nil
 }
 
-func (consumer *Consumer) outputKeyValue(message *sarama.ConsumerMessage) error {
+func (consumer *Consumer) outputKeyValue(w io.Writer, message *sarama.ConsumerMessage) error {
 	var parts []string
 
 	if consumer.config.ShowTimestamp {
@@ -371,11 +591,11 @@ func (consumer *Consumer) outputKeyValue(message *sarama.ConsumerMessage) error
 
 	parts = append(parts, fmt.Sprintf("value=%s", string(message.Value)))
 
-	fmt.Println(strings.Join(parts, " "))
+	fmt.Fprintln(w, strings.Join(parts, " "))
 	return nil
 }
 
-func (consumer *Consumer) outputRaw(message *sarama.ConsumerMessage) error {
+func (consumer *Consumer) outputRaw(w io.Writer, message *sarama.ConsumerMessage) error {
 	var output strings.Builder
 
 	if consumer.config.ShowKey && message.Key != nil {
@@ -384,7 +604,7 @@ func (consumer *Consumer) outputRaw(message *sarama.ConsumerMessage) error {
 	}
 
 	output.WriteString(string(message.Value))
-	fmt.Println(output.String())
+	fmt.Fprintln(w, output.String())
 	return nil
 }
 
@@ -0,0 +1,41 @@
+package consume
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/fieldcrypt"
+)
+
+// decryptMessage returns a copy of an envelope-encrypted message with its fields
+// decrypted and the envelope headers removed; other messages are returned as is
+func decryptMessage(keyring *fieldcrypt.Keyring, message *sarama.ConsumerMessage) (*sarama.ConsumerMessage, error) {
+	if keyring == nil {
+		return message, nil
+	}
+
+	envelope, err := fieldcrypt.ParseEnvelope(func(key string) (string, bool) {
+		for _, header := range message.Headers {
+			if string(header.Key) == key {
+				return string(header.Value), true
+			}
+		}
+		return "", false
+	})
+	if err != nil || envelope == nil {
+		return message, err
+	}
+
+	value, err := keyring.Decrypt(message.Value, envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	decrypted := *message
+	decrypted.Value = value
+	decrypted.Headers = make([]*sarama.RecordHeader, 0, len(message.Headers))
+	for _, header := range message.Headers {
+		if !fieldcrypt.IsHeader(string(header.Key)) {
+			decrypted.Headers = append(decrypted.Headers, header)
+		}
+	}
+	return &decrypted, nil
+}
@@ -0,0 +1,317 @@
+package consume
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
+)
+
+// maxRetryBackoff caps the doubling retry delay
+const maxRetryBackoff = time.Minute
+
+// executor runs the --exec command for messages and dead-letters those that keep failing
+type executor struct {
+	config Config
+	format func(w *bytes.Buffer, message *sarama.ConsumerMessage) error
+	dlq    *kafkautils.DeadLetterSink
+}
+
+// DeadLetter is the JSON line written to the --dlq-file for a failed message
+type DeadLetter struct {
+	Topic     string            `json:"topic"`
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	Key       string            `json:"key,omitempty"`
+	Value     string            `json:"value"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Command   string            `json:"command"`
+	Error     string            `json:"error"`
+	Attempts  int               `json:"attempts"`
+	FailedAt  time.Time         `json:"failed_at"`
+}
+
+func newExecutor(config Config, saramaConfig *sarama.Config) (*executor, error) {
+	e := &executor{config: config}
+
+	formatter := &Consumer{config: config}
+	e.format = func(w *bytes.Buffer, message *sarama.ConsumerMessage) error {
+		return formatter.outputMessage(w, message)
+	}
+
+	dlq, err := kafkautils.NewDeadLetterSink(config.DLQFile, config.DLQTopic, config.Brokers, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	e.dlq = dlq
+
+	return e, nil
+}
+
+// Close releases the dead-letter file and producer
+func (e *executor) Close() error {
+	return e.dlq.Close()
+}
+
+// consumeWithExec runs the command for each message or batch and only marks
+// offsets once the command succeeded or the messages were dead-lettered
+func (consumer *Consumer) consumeWithExec(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	config := consumer.config
+	ctx := session.Context()
+
+	batch := make([]*sarama.ConsumerMessage, 0, config.ExecBatch)
+	messageCount := 0
+
+	timer := time.NewTimer(config.ExecBatchTimeout)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		timer.Stop()
+
+		if err := consumer.executor.process(ctx, batch); err != nil {
+			if ctx.Err() == nil {
+				consumer.fail(err)
+			}
+			// Unmarked messages are delivered again to the next session
+			return false
+		}
+
+		session.MarkMessage(batch[len(batch)-1], "")
+		session.Commit()
+		messageCount += len(batch)
+		batch = batch[:0]
+		return true
+	}
+
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+
+			batch = append(batch, message)
+			if len(batch) == 1 && config.ExecBatch > 1 {
+				timer.Reset(config.ExecBatchTimeout)
+			}
+
+			limitReached := config.MaxMessages > 0 && messageCount+len(batch) >= config.MaxMessages
+			if len(batch) >= config.ExecBatch || limitReached {
+				if !flush() {
+					return nil
+				}
+			}
+			if limitReached {
+				if config.Verbose {
+					log.Printf("Reached max messages limit (%d)", config.MaxMessages)
+				}
+				return nil
+			}
+
+		case <-timer.C:
+			if !flush() {
+				return nil
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// process runs the command with retries, dead-lettering the messages when it keeps failing
+func (e *executor) process(ctx context.Context, batch []*sarama.ConsumerMessage) error {
+	var lastErr error
+	attempts := 0
+
+	for attempt := 0; attempt <= e.config.Retries; attempt++ {
+		if attempt > 0 {
+			backoff := e.config.RetryBackoff << (attempt - 1)
+			if backoff > maxRetryBackoff || backoff <= 0 {
+				backoff = maxRetryBackoff
+			}
+			log.Printf("Retrying %s in %s (attempt %d of %d)", describeBatch(batch), backoff, attempt+1, e.config.Retries+1)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		attempts++
+		lastErr = e.run(ctx, batch)
+		if lastErr == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Command failed for %s: %v", describeBatch(batch), lastErr)
+	}
+
+	if !e.dlq.Enabled() {
+		return fmt.Errorf("command failed for %s after %d attempt(s): %w", describeBatch(batch), attempts, lastErr)
+	}
+
+	if err := e.deadLetter(batch, lastErr, attempts); err != nil {
+		return fmt.Errorf("error dead-lettering %s: %w", describeBatch(batch), err)
+	}
+	log.Printf("Dead-lettered %s after %d attempt(s)", describeBatch(batch), attempts)
+	return nil
+}
+
+// run executes the command once for a message or batch
+func (e *executor) run(ctx context.Context, batch []*sarama.ConsumerMessage) error {
+	if e.config.ExecTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.config.ExecTimeout)
+		defer cancel()
+	}
+
+	// Dead-lettering uses the original messages, so decrypted values never leave the command
+	decrypted := make([]*sarama.ConsumerMessage, len(batch))
+	for i, message := range batch {
+		var err error
+		if decrypted[i], err = decryptMessage(e.config.keyring, message); err != nil {
+			return fmt.Errorf("decrypting %s: %w", describeBatch(batch[i:i+1]), err)
+		}
+	}
+	batch = decrypted
+
+	var stdin bytes.Buffer
+	if len(batch) == 1 {
+		stdin.Write(batch[0].Value)
+	} else {
+		for _, message := range batch {
+			if err := e.format(&stdin, message); err != nil {
+				return err
+			}
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", e.config.Exec)
+	cmd.Env = append(os.Environ(), messageEnv(batch)...)
+	cmd.Stdin = &stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if e.config.Verbose {
+		log.Printf("Running command for %s", describeBatch(batch))
+	}
+	return cmd.Run()
+}
+
+// messageEnv builds the KAFKA_* environment variables for a command run
+func messageEnv(batch []*sarama.ConsumerMessage) []string {
+	first := batch[0]
+	last := batch[len(batch)-1]
+
+	env := []string{
+		"KAFKA_TOPIC=" + first.Topic,
+		"KAFKA_PARTITION=" + strconv.Itoa(int(first.Partition)),
+		"KAFKA_BATCH_SIZE=" + strconv.Itoa(len(batch)),
+		"KAFKA_FIRST_OFFSET=" + strconv.FormatInt(first.Offset, 10),
+		"KAFKA_LAST_OFFSET=" + strconv.FormatInt(last.Offset, 10),
+	}
+	if len(batch) > 1 {
+		return env
+	}
+
+	env = append(env,
+		"KAFKA_OFFSET="+strconv.FormatInt(first.Offset, 10),
+		"KAFKA_KEY="+string(first.Key),
+		"KAFKA_TIMESTAMP="+first.Timestamp.Format(time.RFC3339Nano),
+	)
+
+	headers := make(map[string]string)
+	for _, header := range first.Headers {
+		headers[string(header.Key)] = string(header.Value)
+		env = append(env, "KAFKA_HEADER_"+envName(string(header.Key))+"="+string(header.Value))
+	}
+	headersJSON, _ := json.Marshal(headers)
+	env = append(env, "KAFKA_HEADERS="+string(headersJSON))
+
+	return env
+}
+
+// envName turns a header key into an environment variable suffix, e.g. "trace-id" becomes "TRACE_ID"
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// deadLetter writes failed messages to the dead-letter file and/or topic
+func (e *executor) deadLetter(batch []*sarama.ConsumerMessage, cause error, attempts int) error {
+	now := time.Now()
+	letters := make([]kafkautils.DeadLetter, 0, len(batch))
+	for _, message := range batch {
+		envelope := DeadLetter{
+			Topic:     message.Topic,
+			Partition: message.Partition,
+			Offset:    message.Offset,
+			Timestamp: message.Timestamp,
+			Value:     string(message.Value),
+			Command:   e.config.Exec,
+			Error:     cause.Error(),
+			Attempts:  attempts,
+			FailedAt:  now,
+		}
+		if message.Key != nil {
+			envelope.Key = string(message.Key)
+		}
+
+		headers := make([]sarama.RecordHeader, 0, len(message.Headers)+5)
+		if len(message.Headers) > 0 {
+			envelope.Headers = make(map[string]string)
+			for _, header := range message.Headers {
+				envelope.Headers[string(header.Key)] = string(header.Value)
+				headers = append(headers, *header)
+			}
+		}
+		headers = append(headers,
+			sarama.RecordHeader{Key: []byte("dlq.source.topic"), Value: []byte(message.Topic)},
+			sarama.RecordHeader{Key: []byte("dlq.source.partition"), Value: []byte(strconv.Itoa(int(message.Partition)))},
+			sarama.RecordHeader{Key: []byte("dlq.source.offset"), Value: []byte(strconv.FormatInt(message.Offset, 10))},
+			sarama.RecordHeader{Key: []byte("dlq.error"), Value: []byte(cause.Error())},
+			sarama.RecordHeader{Key: []byte("dlq.attempts"), Value: []byte(strconv.Itoa(attempts))},
+		)
+
+		letters = append(letters, kafkautils.DeadLetter{
+			Envelope: envelope,
+			Key:      message.Key,
+			Value:    message.Value,
+			Headers:  headers,
+		})
+	}
+	return e.dlq.Send(letters...)
+}
+
+func describeBatch(batch []*sarama.ConsumerMessage) string {
+	first := batch[0]
+	if len(batch) == 1 {
+		return fmt.Sprintf("%s/%d@%d", first.Topic, first.Partition, first.Offset)
+	}
+	return fmt.Sprintf("%s/%d@%d-%d", first.Topic, first.Partition, first.Offset, batch[len(batch)-1].Offset)
+}
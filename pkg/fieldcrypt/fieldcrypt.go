@@ -0,0 +1,415 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Header names carrying the envelope next to the encrypted message
+const (
+	HeaderKeyID   = "dimutils.enc.key-id"
+	HeaderDataKey = "dimutils.enc.data-key"
+	HeaderFields  = "dimutils.enc.fields"
+)
+
+// ciphertextPrefix marks an encrypted field value; the version allows the format to change
+const ciphertextPrefix = "enc:v1:"
+
+// dataKeySize is the size of the per-message AES-256 data key
+const dataKeySize = 32
+
+// Envelope describes how a message was encrypted: the master key that wrapped
+// its data key, the wrapped data key and the paths of the fields encrypted with it
+type Envelope struct {
+	KeyID      string
+	WrappedKey []byte
+	Fields     [][]string
+}
+
+// Header is a message header produced for an envelope
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Headers returns the message headers that carry the envelope; the fields are
+// a JSON array of paths, each an array of segments, so names may hold any character
+func (e *Envelope) Headers() []Header {
+	fields, _ := json.Marshal(e.Fields)
+	return []Header{
+		{Key: HeaderKeyID, Value: e.KeyID},
+		{Key: HeaderDataKey, Value: base64.StdEncoding.EncodeToString(e.WrappedKey)},
+		{Key: HeaderFields, Value: string(fields)},
+	}
+}
+
+// IsHeader reports whether a header key belongs to the envelope
+func IsHeader(key string) bool {
+	return key == HeaderKeyID || key == HeaderDataKey || key == HeaderFields
+}
+
+// ParseEnvelope reads the envelope from message headers; it returns nil when the message is not encrypted
+func ParseEnvelope(header func(key string) (string, bool)) (*Envelope, error) {
+	keyID, ok := header(HeaderKeyID)
+	if !ok {
+		return nil, nil
+	}
+
+	wrapped, ok := header(HeaderDataKey)
+	if !ok {
+		return nil, fmt.Errorf("message has %s but no %s header", HeaderKeyID, HeaderDataKey)
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", HeaderDataKey, err)
+	}
+
+	envelope := &Envelope{KeyID: keyID, WrappedKey: wrappedKey}
+	if fields, ok := header(HeaderFields); ok && fields != "" {
+		if err := json.Unmarshal([]byte(fields), &envelope.Fields); err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", HeaderFields, err)
+		}
+	}
+	return envelope, nil
+}
+
+// ParsePaths splits a comma-separated list of dotted field paths such as
+// "customer.email,cards.*.number"; "*" matches every array element or object
+// member, and a backslash escapes a ".", "," or "\" in a field name
+func ParsePaths(spec string) ([][]string, error) {
+	var paths [][]string
+	for _, path := range splitEscaped(spec, ',') {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		segments := splitEscaped(path, '.')
+		for i, segment := range segments {
+			if segment == "" {
+				return nil, fmt.Errorf("invalid field path %q: empty path segment", path)
+			}
+			segments[i] = unescapePath(segment)
+		}
+		paths = append(paths, segments)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no field paths given")
+	}
+	return paths, nil
+}
+
+// splitEscaped splits s at each sep that is not escaped with a backslash,
+// keeping the escapes
+func splitEscaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapePath removes the backslash escapes from a path segment
+func unescapePath(segment string) string {
+	if !strings.Contains(segment, `\`) {
+		return segment
+	}
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		if segment[i] == '\\' && i+1 < len(segment) {
+			i++
+		}
+		b.WriteByte(segment[i])
+	}
+	return b.String()
+}
+
+// pathName shows a path in messages
+func pathName(path []string) string {
+	return strings.Join(path, ".")
+}
+
+// pathAAD is the additional data a field is encrypted with; it authenticates
+// the path so a ciphertext cannot be moved to another field
+func pathAAD(path []string) []byte {
+	data, _ := json.Marshal(path)
+	return data
+}
+
+// Encrypt encrypts the fields of a JSON document matching paths with a fresh
+// data key wrapped by the named master key. Paths missing from the document
+// are skipped; when nothing matches the value is returned unchanged with a nil envelope
+func (k *Keyring) Encrypt(value []byte, paths [][]string, keyID string) ([]byte, *Envelope, error) {
+	if keyID == "" {
+		keyID = k.Current
+	}
+	masterKey, ok := k.keys[keyID]
+	if !ok {
+		return nil, nil, fmt.Errorf("key %s is not in the keyring", keyID)
+	}
+
+	doc, err := decodeJSON(value)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot encrypt fields of a non-JSON value: %w", err)
+	}
+
+	var matched [][]string
+	for _, pattern := range paths {
+		expand(doc, pattern, nil, &matched)
+	}
+	if len(matched) == 0 {
+		return value, nil, nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	fieldCipher, err := newGCM(dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envelope := &Envelope{KeyID: keyID}
+	seen := make(map[string]bool)
+	for _, path := range matched {
+		aad := pathAAD(path)
+		if seen[string(aad)] {
+			continue
+		}
+		seen[string(aad)] = true
+
+		field, found := lookup(doc, path)
+		if !found {
+			// Inside a field that was already encrypted as a whole
+			continue
+		}
+		plaintext, err := encodeJSON(field)
+		if err != nil {
+			return nil, nil, err
+		}
+		sealed, err := seal(fieldCipher, plaintext, aad)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !assign(doc, path, ciphertextPrefix+base64.StdEncoding.EncodeToString(sealed)) {
+			return nil, nil, fmt.Errorf("failed to replace field %s", pathName(path))
+		}
+		envelope.Fields = append(envelope.Fields, path)
+	}
+
+	keyCipher, err := newGCM(masterKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	envelope.WrappedKey, err = seal(keyCipher, dataKey, []byte(keyID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encrypted, err := encodeJSON(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encrypted, envelope, nil
+}
+
+// Decrypt restores the fields listed in the envelope to their original values
+func (k *Keyring) Decrypt(value []byte, envelope *Envelope) ([]byte, error) {
+	masterKey, ok := k.keys[envelope.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("key %s is not in the keyring", envelope.KeyID)
+	}
+
+	keyCipher, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := open(keyCipher, envelope.WrappedKey, []byte(envelope.KeyID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with key %s: %w", envelope.KeyID, err)
+	}
+	fieldCipher, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := decodeJSON(value)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted value is not JSON: %w", err)
+	}
+
+	// Reverse order restores fields nested inside other encrypted fields
+	for i := len(envelope.Fields) - 1; i >= 0; i-- {
+		path := envelope.Fields[i]
+		name := pathName(path)
+		field, found := lookup(doc, path)
+		if !found {
+			return nil, fmt.Errorf("encrypted field %s not found", name)
+		}
+		text, ok := field.(string)
+		if !ok || !strings.HasPrefix(text, ciphertextPrefix) {
+			return nil, fmt.Errorf("field %s is not encrypted", name)
+		}
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(text, ciphertextPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: invalid ciphertext: %w", name, err)
+		}
+		plaintext, err := open(fieldCipher, sealed, pathAAD(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt field %s: %w", name, err)
+		}
+		original, err := decodeJSON(plaintext)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		assign(doc, path, original)
+	}
+
+	return encodeJSON(doc)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext, returning the random nonce followed by the ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+// decodeJSON decodes a document, keeping numbers exact
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return doc, nil
+}
+
+// encodeJSON encodes a document without escaping HTML characters or adding a newline
+func encodeJSON(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// expand appends the concrete paths in doc that match pattern
+func expand(doc interface{}, pattern, prefix []string, out *[][]string) {
+	if len(pattern) == 0 {
+		*out = append(*out, append([]string(nil), prefix...))
+		return
+	}
+
+	segment, rest := pattern[0], pattern[1:]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if segment == "*" {
+			for key, child := range node {
+				expand(child, rest, append(prefix, key), out)
+			}
+		} else if child, ok := node[segment]; ok {
+			expand(child, rest, append(prefix, segment), out)
+		}
+	case []interface{}:
+		if segment == "*" {
+			for index, child := range node {
+				expand(child, rest, append(prefix, strconv.Itoa(index)), out)
+			}
+		} else if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(node) {
+			expand(node[index], rest, append(prefix, segment), out)
+		}
+	}
+}
+
+// lookup resolves a dotted path through objects and arrays
+func lookup(doc interface{}, path []string) (interface{}, bool) {
+	current := doc
+	for _, part := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// assign replaces the value at path, reporting whether the path exists
+func assign(doc interface{}, path []string, value interface{}) bool {
+	if len(path) == 0 {
+		return false
+	}
+	parent, found := lookup(doc, path[:len(path)-1])
+	if !found {
+		return false
+	}
+
+	last := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := node[last]; !ok {
+			return false
+		}
+		node[last] = value
+		return true
+	case []interface{}:
+		index, err := strconv.Atoi(last)
+		if err != nil || index < 0 || index >= len(node) {
+			return false
+		}
+		node[index] = value
+		return true
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testKey returns a base64 AES-256 key filled with b
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func testKeyring(t *testing.T, yaml string) *Keyring {
+	t.Helper()
+	ring, err := ParseKeyring([]byte(yaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func mustPaths(t *testing.T, spec string) [][]string {
+	t.Helper()
+	paths, err := ParsePaths(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return paths
+}
+
+// transmit passes an envelope through message headers, as produce and consume do
+func transmit(t *testing.T, envelope *Envelope) *Envelope {
+	t.Helper()
+	headers := make(map[string]string)
+	for _, header := range envelope.Headers() {
+		headers[header.Key] = header.Value
+	}
+	parsed, err := ParseEnvelope(func(key string) (string, bool) {
+		value, ok := headers[key]
+		return value, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func assertJSONEqual(t *testing.T, want, got []byte) {
+	t.Helper()
+	var a, b interface{}
+	if err := json.Unmarshal(want, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(got, &b); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	ring := testKeyring(t, "keys:\n  k1: "+testKey(1)+"\n")
+	original := []byte(`{"id":7,"price":12.50,"customer":{"email":"a@example.com","name":"Ann"},"cards":[{"number":"4111"},{"number":"5500"}],"tags":["x"]}`)
+
+	encrypted, envelope, err := ring.Encrypt(original, mustPaths(t, "customer.email,cards.*.number,tags,missing.field"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope == nil || envelope.KeyID != "k1" {
+		t.Fatalf("unexpected envelope %+v", envelope)
+	}
+	want := [][]string{{"customer", "email"}, {"cards", "0", "number"}, {"cards", "1", "number"}, {"tags"}}
+	if !reflect.DeepEqual(envelope.Fields, want) {
+		t.Fatalf("expected fields %v, got %v", want, envelope.Fields)
+	}
+	for _, secret := range []string{"a@example.com", "4111", "5500"} {
+		if bytes.Contains(encrypted, []byte(secret)) {
+			t.Fatalf("%s is still readable in %s", secret, encrypted)
+		}
+	}
+	if !bytes.Contains(encrypted, []byte(`"name":"Ann"`)) || !bytes.Contains(encrypted, []byte(`"price":12.50`)) {
+		t.Fatalf("other fields should be unchanged: %s", encrypted)
+	}
+
+	decrypted, err := ring.Decrypt(encrypted, transmit(t, envelope))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, original, decrypted)
+	// Numbers are kept exactly as written
+	if !bytes.Contains(decrypted, []byte(`12.50`)) {
+		t.Fatalf("expected the price to keep its form: %s", decrypted)
+	}
+}
+
+func TestEncryptWithoutMatchesLeavesValue(t *testing.T) {
+	ring := testKeyring(t, "keys:\n  k1: "+testKey(1)+"\n")
+	value := []byte(`{"a":1}`)
+	encrypted, envelope, err := ring.Encrypt(value, mustPaths(t, "b"), "")
+	if err != nil || envelope != nil || !bytes.Equal(encrypted, value) {
+		t.Fatalf("expected the value unchanged, got %s, %+v, %v", encrypted, envelope, err)
+	}
+	if _, _, err := ring.Encrypt([]byte("not json"), mustPaths(t, "a"), ""); err == nil {
+		t.Fatal("expected an error for a non-JSON value")
+	}
+}
+
+func TestFieldNamesWithSeparators(t *testing.T) {
+	paths := mustPaths(t, `a\.b.c\,d, back\\slash, plain.x`)
+	want := [][]string{{"a.b", "c,d"}, {`back\slash`}, {"plain", "x"}}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("expected %q, got %q", want, paths)
+	}
+
+	ring := testKeyring(t, "keys:\n  k1: "+testKey(1)+"\n")
+	// "a.b" and "a"."b" must stay distinct fields
+	original := []byte(`{"a.b":{"c,d":"secret","e":1},"a":{"b":"other"},"back\\slash":"s","plain":{"x":"y"}}`)
+	encrypted, envelope, err := ring.Encrypt(original, paths, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(encrypted, []byte(`"b":"other"`)) || bytes.Contains(encrypted, []byte("secret")) {
+		t.Fatalf("the wrong field was encrypted: %s", encrypted)
+	}
+
+	received := transmit(t, envelope)
+	if !reflect.DeepEqual(received.Fields, want) {
+		t.Fatalf("expected the header to keep the paths %q, got %q", want, received.Fields)
+	}
+	decrypted, err := ring.Decrypt(encrypted, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, original, decrypted)
+}
+
+func TestDecryptFailures(t *testing.T) {
+	ring := testKeyring(t, "current: k1\nkeys:\n  k1: "+testKey(1)+"\n  k2: "+testKey(2)+"\n")
+	original := []byte(`{"a":"secret","b":"other secret"}`)
+	encrypted, envelope, err := ring.Encrypt(original, mustPaths(t, "a,b"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]string
+	json.Unmarshal(encrypted, &doc)
+	reencode := func(doc map[string]string) []byte {
+		data, _ := json.Marshal(doc)
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		value    []byte
+		envelope func() *Envelope
+		keyring  *Keyring
+		want     string
+	}{
+		{
+			name: "tampered ciphertext",
+			value: func() []byte {
+				sealed, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(doc["a"], ciphertextPrefix))
+				sealed[len(sealed)-1] ^= 1
+				tampered := map[string]string{"a": ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed), "b": doc["b"]}
+				return reencode(tampered)
+			}(),
+			want: "failed to decrypt field a",
+		},
+		{
+			name:  "ciphertext moved to another field",
+			value: reencode(map[string]string{"a": doc["b"], "b": doc["a"]}),
+			want:  "failed to decrypt field",
+		},
+		{
+			name:  "invalid ciphertext",
+			value: reencode(map[string]string{"a": ciphertextPrefix + "!!!", "b": doc["b"]}),
+			want:  "field a: invalid ciphertext",
+		},
+		{
+			name:  "field not encrypted",
+			value: reencode(map[string]string{"a": "plain", "b": doc["b"]}),
+			want:  "field a is not encrypted",
+		},
+		{
+			name:  "field missing",
+			value: reencode(map[string]string{"a": doc["a"]}),
+			want:  "encrypted field b not found",
+		},
+		{
+			name: "wrong key id",
+			envelope: func() *Envelope {
+				wrong := *envelope
+				wrong.KeyID = "k2"
+				return &wrong
+			},
+			want: "failed to unwrap data key with key k2",
+		},
+		{
+			name: "unknown key id",
+			envelope: func() *Envelope {
+				wrong := *envelope
+				wrong.KeyID = "k9"
+				return &wrong
+			},
+			want: "key k9 is not in the keyring",
+		},
+		{
+			name:    "wrong master key",
+			keyring: testKeyring(t, "keys:\n  k1: "+testKey(9)+"\n"),
+			want:    "failed to unwrap data key with key k1",
+		},
+		{
+			name: "field list does not match the encryption",
+			envelope: func() *Envelope {
+				wrong := *envelope
+				wrong.Fields = [][]string{{"b"}, {"a"}}
+				return &wrong
+			},
+			value: reencode(map[string]string{"a": doc["b"], "b": doc["a"]}),
+			want:  "failed to decrypt field",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, env, keys := encrypted, envelope, ring
+			if tt.value != nil {
+				value = tt.value
+			}
+			if tt.envelope != nil {
+				env = tt.envelope()
+			}
+			if tt.keyring != nil {
+				keys = tt.keyring
+			}
+			_, err := keys.Decrypt(value, env)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	// The untouched message still decrypts
+	decrypted, err := ring.Decrypt(encrypted, envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, original, decrypted)
+}
+
+func TestEncryptWithNamedKey(t *testing.T) {
+	ring := testKeyring(t, "current: k1\nkeys:\n  k1: "+testKey(1)+"\n  k2: "+testKey(2)+"\n")
+	encrypted, envelope, err := ring.Encrypt([]byte(`{"a":"x"}`), mustPaths(t, "a"), "k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if envelope.KeyID != "k2" {
+		t.Fatalf("expected key k2, got %s", envelope.KeyID)
+	}
+	// Only k2 unwraps the data key
+	onlyK1 := testKeyring(t, "keys:\n  k1: "+testKey(1)+"\n")
+	if _, err := onlyK1.Decrypt(encrypted, envelope); err == nil {
+		t.Fatal("expected decryption without k2 to fail")
+	}
+	if _, _, err := ring.Encrypt([]byte(`{"a":"x"}`), mustPaths(t, "a"), "k3"); err == nil {
+		t.Fatal("expected an error for an unknown key")
+	}
+}
+
+func TestParseEnvelope(t *testing.T) {
+	headers := map[string]string{}
+	lookup := func(key string) (string, bool) {
+		value, ok := headers[key]
+		return value, ok
+	}
+	if envelope, err := ParseEnvelope(lookup); envelope != nil || err != nil {
+		t.Fatalf("expected no envelope without headers, got %+v, %v", envelope, err)
+	}
+
+	headers[HeaderKeyID] = "k1"
+	if _, err := ParseEnvelope(lookup); err == nil {
+		t.Fatal("expected an error without the data key header")
+	}
+	headers[HeaderDataKey] = "not base64!"
+	if _, err := ParseEnvelope(lookup); err == nil {
+		t.Fatal("expected an error for an invalid data key")
+	}
+	headers[HeaderDataKey] = base64.StdEncoding.EncodeToString([]byte("key"))
+	headers[HeaderFields] = "a.b,c"
+	if _, err := ParseEnvelope(lookup); err == nil || !strings.Contains(err.Error(), HeaderFields) {
+		t.Fatalf("expected an error for a fields header that is not JSON, got %v", err)
+	}
+	headers[HeaderFields] = `[["a.b","c"]]`
+	envelope, err := ParseEnvelope(lookup)
+	if err != nil || !reflect.DeepEqual(envelope.Fields, [][]string{{"a.b", "c"}}) {
+		t.Fatalf("unexpected envelope %+v, %v", envelope, err)
+	}
+}
+
+func TestParsePathsErrors(t *testing.T) {
+	for _, spec := range []string{"", " , ", "a..b", "a.", ".a"} {
+		if _, err := ParsePaths(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
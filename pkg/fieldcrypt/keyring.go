@@ -0,0 +1,97 @@
+package fieldcrypt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"gopkg.in/yaml.v2"
+)
+
+// Keyring holds the named master keys that wrap per-message data keys
+type Keyring struct {
+	Current string
+	keys    map[string][]byte
+}
+
+// keyringFile is the on-disk keyring layout
+type keyringFile struct {
+	Current string                         `yaml:"current"`
+	Keys    map[string]kafkacontext.Secret `yaml:"keys"`
+}
+
+// LoadKeyring reads a keyring file
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	ring, err := ParseKeyring(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return ring, nil
+}
+
+// ParseKeyring parses keyring YAML; each key is a base64-encoded 16, 24 or 32 byte AES key
+func ParseKeyring(data []byte) (*Keyring, error) {
+	var file keyringFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Keys) == 0 {
+		return nil, fmt.Errorf("no keys defined")
+	}
+
+	ring := &Keyring{Current: file.Current, keys: make(map[string][]byte)}
+	for id, secret := range file.Keys {
+		if id == "" || strings.ContainsAny(id, ",\n") {
+			return nil, fmt.Errorf("invalid key id %q", id)
+		}
+		encoded, err := secret.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", id, err)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not valid base64: %w", id, err)
+		}
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, fmt.Errorf("key %s must be 16, 24 or 32 bytes, got %d", id, len(key))
+		}
+		ring.keys[id] = key
+	}
+
+	if ring.Current == "" {
+		if len(ring.keys) > 1 {
+			return nil, fmt.Errorf("current must name the key used for encryption")
+		}
+		ring.Current = ring.KeyIDs()[0]
+	}
+	if _, ok := ring.keys[ring.Current]; !ok {
+		return nil, fmt.Errorf("current key %s is not defined", ring.Current)
+	}
+
+	return ring, nil
+}
+
+// KeyIDs returns the ids of all keys in the keyring, sorted
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// HasKey reports whether the keyring contains the given key id
+func (k *Keyring) HasKey(id string) bool {
+	_, ok := k.keys[id]
+	return ok
+}
@@ -0,0 +1,114 @@
+package jsonschema
+
+import (
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// formats are the format assertions that are checked; other formats are
+// annotations only, as the specification allows
+var formats = map[string]func(string) bool{
+	"date-time":             isDateTime,
+	"date":                  isDate,
+	"time":                  isTime,
+	"duration":              isDuration,
+	"email":                 isEmail,
+	"idn-email":             isEmail,
+	"hostname":              isHostname,
+	"ipv4":                  isIPv4,
+	"ipv6":                  isIPv6,
+	"uri":                   isURI,
+	"iri":                   isURI,
+	"uri-reference":         isURIReference,
+	"iri-reference":         isURIReference,
+	"uuid":                  uuidPattern.MatchString,
+	"regex":                 isRegex,
+	"json-pointer":          isJSONPointer,
+	"relative-json-pointer": relativePointerPattern.MatchString,
+}
+
+var (
+	durationPattern        = regexp.MustCompile(`^P(\d+W|(\d+Y)?(\d+M)?(\d+D)?(T(\d+H)?(\d+M)?(\d+S)?)?)$`)
+	uuidPattern            = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	relativePointerPattern = regexp.MustCompile(`^(0|[1-9][0-9]*)(#|(/([^~/]|~[01])*)*)$`)
+	hostnameLabel          = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+)
+
+func isDateTime(s string) bool {
+	_, err := time.Parse(time.RFC3339Nano, strings.ToUpper(s))
+	return err == nil
+}
+
+func isDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+func isTime(s string) bool {
+	_, err := time.Parse("15:04:05.999999999Z07:00", strings.ToUpper(s))
+	return err == nil
+}
+
+func isDuration(s string) bool {
+	return durationPattern.MatchString(s) && s != "P" && !strings.HasSuffix(s, "T")
+}
+
+func isEmail(s string) bool {
+	address, err := mail.ParseAddress(s)
+	return err == nil && address.Address == s
+}
+
+func isHostname(s string) bool {
+	s = strings.TrimSuffix(s, ".")
+	if s == "" || len(s) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(s, ".") {
+		if !hostnameLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIPv4(s string) bool {
+	return !strings.Contains(s, ":") && net.ParseIP(s) != nil
+}
+
+func isIPv6(s string) bool {
+	return strings.Contains(s, ":") && net.ParseIP(s) != nil
+}
+
+func isURI(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.IsAbs()
+}
+
+func isURIReference(s string) bool {
+	_, err := url.Parse(s)
+	return err == nil
+}
+
+func isRegex(s string) bool {
+	_, err := regexp.Compile(s)
+	return err == nil
+}
+
+func isJSONPointer(s string) bool {
+	if s == "" {
+		return true
+	}
+	if !strings.HasPrefix(s, "/") {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] == '~' && (i+1 == len(s) || (s[i+1] != '0' && s[i+1] != '1')) {
+			return false
+		}
+	}
+	return true
+}
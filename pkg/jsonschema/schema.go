@@ -0,0 +1,620 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is a compiled JSON Schema (draft 2020-12, with the draft-07 spellings
+// items-as-array, additionalItems, definitions and dependencies accepted too)
+type Schema struct {
+	node *node
+}
+
+// node is one compiled schema or subschema
+type node struct {
+	location string // absolute keyword location, e.g. file:///x.json#/properties/a
+	base     *url.URL
+	boolean  *bool // true or false schema
+
+	ref        string
+	refNode    *node
+	types      []string
+	enum       []interface{}
+	constValue interface{}
+	hasConst   bool
+	format     string
+
+	multipleOf       *float64
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	prefixItems []*node
+	items       *node
+	contains    *node
+	minContains *int
+	maxContains *int
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	properties           map[string]*node
+	patternProperties    []patternNode
+	additionalProperties *node
+	propertyNames        *node
+	required             []string
+	dependentRequired    map[string][]string
+	dependentSchemas     map[string]*node
+	minProperties        *int
+	maxProperties        *int
+
+	allOf []*node
+	anyOf []*node
+	oneOf []*node
+	not   *node
+	ifs   *node
+	then  *node
+	elses *node
+
+	unevaluatedProperties *node
+	unevaluatedItems      *node
+}
+
+type patternNode struct {
+	pattern *regexp.Regexp
+	node    *node
+}
+
+// compiler builds nodes and resolves references between them
+type compiler struct {
+	nodes     map[string]*node       // by absolute location, $id and $anchor
+	resources map[string]interface{} // raw documents and embedded resources by URI
+	pending   []*node                // nodes with unresolved $ref
+}
+
+// Compile compiles a schema document
+func Compile(data []byte) (*Schema, error) {
+	return compileDocument(data, &url.URL{})
+}
+
+// Load reads and compiles a schema file; relative $refs to other files are
+// resolved against its directory
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema file: %w", err)
+	}
+	base, err := fileURL(path)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := compileDocument(data, base)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling schema %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+func compileDocument(data []byte, base *url.URL) (*Schema, error) {
+	raw, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiler{nodes: make(map[string]*node), resources: make(map[string]interface{})}
+	root, err := c.compileResource(raw, base)
+	if err != nil {
+		return nil, err
+	}
+	// Resolving a $ref may compile more nodes, which may have their own
+	for len(c.pending) > 0 {
+		n := c.pending[0]
+		c.pending = c.pending[1:]
+		if n.refNode, err = c.resolve(n.base, n.ref); err != nil {
+			return nil, fmt.Errorf("%s: %w", n.location, err)
+		}
+	}
+	return &Schema{node: root}, nil
+}
+
+// decode parses a schema document
+func decode(data []byte) (interface{}, error) {
+	var raw interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("error parsing schema: %w", err)
+	}
+	return raw, nil
+}
+
+func fileURL(path string) (*url.URL, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}, nil
+}
+
+// compileResource compiles a document whose base URI is base, registering it
+// so pointer references into it can be resolved
+func (c *compiler) compileResource(raw interface{}, base *url.URL) (*node, error) {
+	return c.compile(raw, base, "")
+}
+
+func (c *compiler) compile(raw interface{}, base *url.URL, pointer string) (*node, error) {
+	switch value := raw.(type) {
+	case bool:
+		n := &node{base: base, boolean: &value, location: locationOf(base, pointer)}
+		c.nodes[n.location] = n
+		return n, nil
+	case map[string]interface{}:
+		return c.compileObject(value, base, pointer)
+	}
+	return nil, fmt.Errorf("%s: a schema must be an object or a boolean", locationOf(base, pointer))
+}
+
+func (c *compiler) compileObject(m map[string]interface{}, base *url.URL, pointer string) (*node, error) {
+	if id, ok := m["$id"].(string); ok && !strings.HasPrefix(id, "#") {
+		ref, err := url.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid $id %q: %w", locationOf(base, pointer), id, err)
+		}
+		outer := locationOf(base, pointer)
+		base = base.ResolveReference(ref)
+		base.Fragment = ""
+		pointer = ""
+		c.resources[base.String()] = m
+		// An embedded resource is reachable from its parent's pointers too
+		defer func(outer string) {
+			c.nodes[outer] = c.nodes[locationOf(base, "")]
+		}(outer)
+	} else if pointer == "" {
+		c.resources[withoutFragment(base)] = m
+	}
+
+	n := &node{base: base, location: locationOf(base, pointer)}
+	c.nodes[n.location] = n
+	for _, key := range []string{"$anchor", "$dynamicAnchor"} {
+		if anchor, ok := m[key].(string); ok {
+			c.nodes[withoutFragment(base)+"#"+anchor] = n
+		}
+	}
+	if id, ok := m["$id"].(string); ok && strings.HasPrefix(id, "#") && len(id) > 1 {
+		// draft-07 plain-name fragment
+		c.nodes[withoutFragment(base)+id] = n
+	}
+
+	kc := keywordCompiler{c: c, m: m, base: base, pointer: pointer}
+	kc.ref(n)
+	kc.types(n)
+	kc.values(n)
+	kc.numbers(n)
+	kc.strings(n)
+	kc.arrays(n)
+	kc.objects(n)
+	kc.combinators(n)
+	kc.definitions()
+	if kc.err != nil {
+		return nil, kc.err
+	}
+	return n, nil
+}
+
+// keywordCompiler compiles the keywords of one schema object, keeping the
+// first error
+type keywordCompiler struct {
+	c       *compiler
+	m       map[string]interface{}
+	base    *url.URL
+	pointer string
+	err     error
+}
+
+func (kc *keywordCompiler) fail(keyword, format string, args ...interface{}) {
+	if kc.err == nil {
+		kc.err = fmt.Errorf("%s: %s", locationOf(kc.base, kc.pointer+"/"+escape(keyword)), fmt.Sprintf(format, args...))
+	}
+}
+
+// sub compiles the subschema at a keyword (and optional key or index)
+func (kc *keywordCompiler) sub(raw interface{}, path ...string) *node {
+	pointer := kc.pointer
+	for _, part := range path {
+		pointer += "/" + escape(part)
+	}
+	n, err := kc.c.compile(raw, kc.base, pointer)
+	if err != nil && kc.err == nil {
+		kc.err = err
+	}
+	return n
+}
+
+func (kc *keywordCompiler) schema(keyword string) *node {
+	raw, ok := kc.m[keyword]
+	if !ok {
+		return nil
+	}
+	return kc.sub(raw, keyword)
+}
+
+func (kc *keywordCompiler) schemaList(keyword string) []*node {
+	raw, ok := kc.m[keyword]
+	if !ok {
+		return nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		kc.fail(keyword, "must be a non-empty array of schemas")
+		return nil
+	}
+	nodes := make([]*node, len(list))
+	for i, item := range list {
+		nodes[i] = kc.sub(item, keyword, strconv.Itoa(i))
+	}
+	return nodes
+}
+
+func (kc *keywordCompiler) schemaMap(keyword string) map[string]*node {
+	raw, ok := kc.m[keyword]
+	if !ok {
+		return nil
+	}
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		kc.fail(keyword, "must be an object of schemas")
+		return nil
+	}
+	nodes := make(map[string]*node, len(m))
+	for key, value := range m {
+		nodes[key] = kc.sub(value, keyword, key)
+	}
+	return nodes
+}
+
+func (kc *keywordCompiler) number(keyword string) *float64 {
+	raw, ok := kc.m[keyword]
+	if !ok {
+		return nil
+	}
+	value, ok := raw.(float64)
+	if !ok {
+		kc.fail(keyword, "must be a number")
+		return nil
+	}
+	return &value
+}
+
+func (kc *keywordCompiler) count(keyword string) *int {
+	raw, ok := kc.m[keyword]
+	if !ok {
+		return nil
+	}
+	value, ok := raw.(float64)
+	if !ok || value < 0 || value != float64(int(value)) {
+		kc.fail(keyword, "must be a non-negative integer")
+		return nil
+	}
+	count := int(value)
+	return &count
+}
+
+func (kc *keywordCompiler) regexp(keyword, pattern string) *regexp.Regexp {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		kc.fail(keyword, "unsupported pattern %q: %v", pattern, err)
+	}
+	return re
+}
+
+func (kc *keywordCompiler) ref(n *node) {
+	for _, keyword := range []string{"$ref", "$dynamicRef"} {
+		if ref, ok := kc.m[keyword].(string); ok {
+			// $dynamicRef is resolved statically, like $ref
+			n.ref = ref
+			kc.c.pending = append(kc.c.pending, n)
+			return
+		}
+	}
+}
+
+func (kc *keywordCompiler) types(n *node) {
+	switch t := kc.m["type"].(type) {
+	case nil:
+	case string:
+		n.types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				kc.fail("type", "must be a string or an array of strings")
+				return
+			}
+			n.types = append(n.types, name)
+		}
+	default:
+		kc.fail("type", "must be a string or an array of strings")
+	}
+	for _, name := range n.types {
+		switch name {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			kc.fail("type", "unknown type %q", name)
+		}
+	}
+}
+
+func (kc *keywordCompiler) values(n *node) {
+	if raw, ok := kc.m["enum"]; ok {
+		list, ok := raw.([]interface{})
+		if !ok {
+			kc.fail("enum", "must be an array")
+		}
+		n.enum = list
+		if n.enum == nil {
+			n.enum = []interface{}{}
+		}
+	}
+	if value, ok := kc.m["const"]; ok {
+		n.constValue, n.hasConst = value, true
+	}
+	if format, ok := kc.m["format"].(string); ok {
+		n.format = format
+	}
+}
+
+func (kc *keywordCompiler) numbers(n *node) {
+	n.multipleOf = kc.number("multipleOf")
+	if n.multipleOf != nil && *n.multipleOf <= 0 {
+		kc.fail("multipleOf", "must be greater than 0")
+	}
+	n.minimum = kc.number("minimum")
+	n.maximum = kc.number("maximum")
+	// draft-04 boolean exclusive bounds modify minimum and maximum
+	for _, bound := range []struct {
+		keyword string
+		limit   *float64
+		target  **float64
+	}{
+		{"exclusiveMinimum", n.minimum, &n.exclusiveMinimum},
+		{"exclusiveMaximum", n.maximum, &n.exclusiveMaximum},
+	} {
+		if exclusive, ok := kc.m[bound.keyword].(bool); ok {
+			if exclusive && bound.limit != nil {
+				*bound.target = bound.limit
+			}
+			continue
+		}
+		*bound.target = kc.number(bound.keyword)
+	}
+	if n.exclusiveMinimum != nil && n.exclusiveMinimum == n.minimum {
+		n.minimum = nil
+	}
+	if n.exclusiveMaximum != nil && n.exclusiveMaximum == n.maximum {
+		n.maximum = nil
+	}
+}
+
+func (kc *keywordCompiler) strings(n *node) {
+	n.minLength = kc.count("minLength")
+	n.maxLength = kc.count("maxLength")
+	if raw, ok := kc.m["pattern"]; ok {
+		pattern, ok := raw.(string)
+		if !ok {
+			kc.fail("pattern", "must be a string")
+			return
+		}
+		n.pattern = kc.regexp("pattern", pattern)
+	}
+}
+
+func (kc *keywordCompiler) arrays(n *node) {
+	if list, ok := kc.m["items"].([]interface{}); ok {
+		// draft-07 tuple form
+		for i, item := range list {
+			n.prefixItems = append(n.prefixItems, kc.sub(item, "items", strconv.Itoa(i)))
+		}
+		n.items = kc.schema("additionalItems")
+	} else {
+		n.prefixItems = kc.schemaList("prefixItems")
+		n.items = kc.schema("items")
+	}
+	n.contains = kc.schema("contains")
+	n.minContains = kc.count("minContains")
+	n.maxContains = kc.count("maxContains")
+	n.minItems = kc.count("minItems")
+	n.maxItems = kc.count("maxItems")
+	n.uniqueItems, _ = kc.m["uniqueItems"].(bool)
+	n.unevaluatedItems = kc.schema("unevaluatedItems")
+}
+
+func (kc *keywordCompiler) objects(n *node) {
+	n.properties = kc.schemaMap("properties")
+	if patterns := kc.schemaMap("patternProperties"); patterns != nil {
+		keys := make([]string, 0, len(patterns))
+		for pattern := range patterns {
+			keys = append(keys, pattern)
+		}
+		sort.Strings(keys)
+		for _, pattern := range keys {
+			n.patternProperties = append(n.patternProperties, patternNode{
+				pattern: kc.regexp("patternProperties", pattern),
+				node:    patterns[pattern],
+			})
+		}
+	}
+	n.additionalProperties = kc.schema("additionalProperties")
+	n.propertyNames = kc.schema("propertyNames")
+	n.unevaluatedProperties = kc.schema("unevaluatedProperties")
+	n.minProperties = kc.count("minProperties")
+	n.maxProperties = kc.count("maxProperties")
+
+	if raw, ok := kc.m["required"]; ok {
+		n.required = kc.stringList("required", raw)
+	}
+	n.dependentSchemas = kc.schemaMap("dependentSchemas")
+	if raw, ok := kc.m["dependentRequired"].(map[string]interface{}); ok {
+		n.dependentRequired = make(map[string][]string, len(raw))
+		for key, value := range raw {
+			n.dependentRequired[key] = kc.stringList("dependentRequired", value)
+		}
+	}
+	// draft-07 dependencies holds both forms
+	if raw, ok := kc.m["dependencies"].(map[string]interface{}); ok {
+		for key, value := range raw {
+			if _, isList := value.([]interface{}); isList {
+				if n.dependentRequired == nil {
+					n.dependentRequired = make(map[string][]string)
+				}
+				n.dependentRequired[key] = kc.stringList("dependencies", value)
+				continue
+			}
+			if n.dependentSchemas == nil {
+				n.dependentSchemas = make(map[string]*node)
+			}
+			n.dependentSchemas[key] = kc.sub(value, "dependencies", key)
+		}
+	}
+}
+
+func (kc *keywordCompiler) stringList(keyword string, raw interface{}) []string {
+	list, ok := raw.([]interface{})
+	if !ok {
+		kc.fail(keyword, "must be an array of strings")
+		return nil
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		value, ok := item.(string)
+		if !ok {
+			kc.fail(keyword, "must be an array of strings")
+			return nil
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func (kc *keywordCompiler) combinators(n *node) {
+	n.allOf = kc.schemaList("allOf")
+	n.anyOf = kc.schemaList("anyOf")
+	n.oneOf = kc.schemaList("oneOf")
+	n.not = kc.schema("not")
+	n.ifs = kc.schema("if")
+	if n.ifs != nil {
+		n.then = kc.schema("then")
+		n.elses = kc.schema("else")
+	}
+}
+
+// definitions compiles $defs so anchors and $ids inside them are known
+func (kc *keywordCompiler) definitions() {
+	kc.schemaMap("$defs")
+	kc.schemaMap("definitions")
+}
+
+// resolve finds the node a reference points to, loading files it names
+func (c *compiler) resolve(base *url.URL, ref string) (*node, error) {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+	target := base.ResolveReference(parsed)
+	if n, ok := c.nodes[target.String()]; ok {
+		return n, nil
+	}
+	fragment := target.Fragment
+	target.Fragment = ""
+	document := target.String()
+	if n, ok := c.nodes[document+"#"+fragment]; ok {
+		return n, nil
+	}
+
+	raw, ok := c.resources[document]
+	if !ok {
+		if target.Scheme != "file" {
+			return nil, fmt.Errorf("cannot resolve $ref %q: only local and file references are supported", ref)
+		}
+		data, err := os.ReadFile(filepath.FromSlash(target.Path))
+		if err != nil {
+			return nil, fmt.Errorf("cannot resolve $ref %q: %w", ref, err)
+		}
+		if raw, err = decode(data); err != nil {
+			return nil, fmt.Errorf("cannot resolve $ref %q: %w", ref, err)
+		}
+		if _, err := c.compileResource(raw, target); err != nil {
+			return nil, err
+		}
+		if n, ok := c.nodes[document+"#"+fragment]; ok {
+			return n, nil
+		}
+	}
+
+	if fragment != "" && !strings.HasPrefix(fragment, "/") {
+		return nil, fmt.Errorf("cannot resolve $ref %q: no $anchor %q", ref, fragment)
+	}
+	// A pointer to a location that isn't a known subschema, e.g. under an unknown keyword
+	value, err := walkPointer(raw, fragment)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve $ref %q: %w", ref, err)
+	}
+	return c.compile(value, target, fragment)
+}
+
+func walkPointer(raw interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return raw, nil
+	}
+	for _, part := range strings.Split(pointer[1:], "/") {
+		part = unescape(part)
+		switch value := raw.(type) {
+		case map[string]interface{}:
+			next, ok := value[part]
+			if !ok {
+				return nil, fmt.Errorf("%s not found", pointer)
+			}
+			raw = next
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(value) {
+				return nil, fmt.Errorf("%s not found", pointer)
+			}
+			raw = value[index]
+		default:
+			return nil, fmt.Errorf("%s not found", pointer)
+		}
+	}
+	return raw, nil
+}
+
+func locationOf(base *url.URL, pointer string) string {
+	return withoutFragment(base) + "#" + pointer
+}
+
+func withoutFragment(u *url.URL) string {
+	copied := *u
+	copied.Fragment = ""
+	copied.RawFragment = ""
+	return copied.String()
+}
+
+// escape encodes a JSON pointer reference token
+func escape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func unescape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+}
@@ -0,0 +1,96 @@
+package jsonschema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"not JSON", `{"type":`, "error parsing schema"},
+		{"not a schema", `[]`, "a schema must be an object or a boolean"},
+		{"unknown type", `{"type":"float"}`, `unknown type "float"`},
+		{"bad type list", `{"type":["string",1]}`, "must be a string or an array of strings"},
+		{"negative minLength", `{"minLength":-1}`, "#/minLength: must be a non-negative integer"},
+		{"fractional maxItems", `{"maxItems":1.5}`, "must be a non-negative integer"},
+		{"zero multipleOf", `{"multipleOf":0}`, "must be greater than 0"},
+		{"string minimum", `{"minimum":"1"}`, "#/minimum: must be a number"},
+		{"bad pattern", `{"pattern":"("}`, "unsupported pattern"},
+		{"bad patternProperties", `{"patternProperties":{"(":{}}}`, "unsupported pattern"},
+		{"empty allOf", `{"allOf":[]}`, "must be a non-empty array of schemas"},
+		{"bad subschema", `{"properties":{"a":1}}`, "#/properties/a: a schema must be an object or a boolean"},
+		{"bad required", `{"required":"a"}`, "must be an array of strings"},
+		{"missing $defs entry", `{"$ref":"#/$defs/missing"}`, "/$defs/missing not found"},
+		{"missing anchor", `{"$ref":"#nowhere"}`, `no $anchor "nowhere"`},
+		{"remote $ref", `{"$ref":"https://example.com/schema.json"}`, "only local and file references are supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile([]byte(tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadResolvesFileRefs(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"order.json":           `{"properties":{"customer":{"$ref":"common/customer.json"},"total":{"$ref":"common/money.json#/$defs/amount"}}}`,
+		"common/customer.json": `{"required":["id"],"properties":{"id":{"type":"string"},"address":{"$ref":"address.json"}}}`,
+		"common/address.json":  `{"required":["city"]}`,
+		"common/money.json":    `{"$defs":{"amount":{"type":"number","minimum":0}}}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schema, err := Load(filepath.Join(dir, "order.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := map[string]interface{}{
+		"customer": map[string]interface{}{"id": "c1", "address": map[string]interface{}{"city": "Wellington"}},
+		"total":    12.5,
+	}
+	if err := schema.Validate(valid); err != nil {
+		t.Fatalf("expected the order to be valid: %v", err)
+	}
+
+	invalid := map[string]interface{}{
+		"customer": map[string]interface{}{"address": map[string]interface{}{}},
+		"total":    -1.0,
+	}
+	got := map[string]string{}
+	for _, e := range schema.Errors(invalid) {
+		got[e.InstancePath] = e.Keyword
+	}
+	want := map[string]string{"/customer": "required", "/customer/address": "required", "/total": "minimum"}
+	for path, keyword := range want {
+		if got[path] != keyword {
+			t.Errorf("expected a %s error at %s, got %v", keyword, path, got)
+		}
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatal("expected an error for a missing schema file")
+	}
+	broken := filepath.Join(dir, "broken.json")
+	os.WriteFile(broken, []byte(`{"$ref":"nowhere.json"}`), 0644)
+	if _, err := Load(broken); err == nil || !strings.Contains(err.Error(), "cannot resolve $ref") {
+		t.Fatalf("expected an unresolvable $ref error, got %v", err)
+	}
+}
@@ -0,0 +1,563 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDepth bounds schema recursion, for $ref cycles that don't consume the value
+const maxDepth = 512
+
+// Error is one way a value fails a schema
+type Error struct {
+	InstancePath string `json:"instancePath"` // JSON pointer to the value, "" for the whole document
+	SchemaPath   string `json:"schemaPath"`   // keyword location, e.g. #/properties/price/minimum
+	Keyword      string `json:"keyword"`
+	Message      string `json:"message"`
+}
+
+func (e Error) Error() string {
+	path := e.InstancePath
+	if path == "" {
+		path = "(root)"
+	}
+	return path + ": " + e.Message
+}
+
+// ValidationError lists every way a value fails a schema
+type ValidationError struct {
+	Errors []Error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Validate checks a decoded JSON value (as produced by encoding/json) against
+// the schema, returning a *ValidationError when it fails
+func (s *Schema) Validate(value interface{}) error {
+	if errs := s.Errors(value); len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// Errors returns every way a decoded JSON value fails the schema
+func (s *Schema) Errors(value interface{}) []Error {
+	errs, _ := validate(s.node, normalize(value), "", 0)
+	return errs
+}
+
+// evaluated records the properties and items that keywords of a schema (and
+// its valid in-place subschemas) looked at, for unevaluatedProperties/Items
+type evaluated struct {
+	properties map[string]bool
+	items      map[int]bool
+	allItems   bool
+}
+
+func (e *evaluated) addProperty(name string) {
+	if e.properties == nil {
+		e.properties = make(map[string]bool)
+	}
+	e.properties[name] = true
+}
+
+func (e *evaluated) addItem(index int) {
+	if e.items == nil {
+		e.items = make(map[int]bool)
+	}
+	e.items[index] = true
+}
+
+func (e *evaluated) merge(other *evaluated) {
+	if other == nil {
+		return
+	}
+	for name := range other.properties {
+		e.addProperty(name)
+	}
+	for index := range other.items {
+		e.addItem(index)
+	}
+	e.allItems = e.allItems || other.allItems
+}
+
+// state collects the errors and annotations of one schema against one value
+type state struct {
+	n     *node
+	value interface{}
+	path  string
+	depth int
+	errs  []Error
+	eval  evaluated
+}
+
+func (st *state) fail(keyword, format string, args ...interface{}) {
+	st.failAt(st.path, keyword, format, args...)
+}
+
+func (st *state) failAt(path, keyword, format string, args ...interface{}) {
+	st.errs = append(st.errs, Error{
+		InstancePath: path,
+		SchemaPath:   st.n.location + "/" + escape(keyword),
+		Keyword:      keyword,
+		Message:      fmt.Sprintf(format, args...),
+	})
+}
+
+// apply validates the value in place against a subschema, keeping its errors
+// and, when it passes, its annotations
+func (st *state) apply(n *node) bool {
+	errs, eval := validate(n, st.value, st.path, st.depth+1)
+	st.errs = append(st.errs, errs...)
+	if len(errs) == 0 {
+		st.eval.merge(eval)
+	}
+	return len(errs) == 0
+}
+
+// try validates the value in place against a subschema without reporting errors
+func (st *state) try(n *node) ([]Error, *evaluated) {
+	return validate(n, st.value, st.path, st.depth+1)
+}
+
+func validate(n *node, value interface{}, path string, depth int) ([]Error, *evaluated) {
+	st := &state{n: n, value: value, path: path, depth: depth}
+	if n.boolean != nil {
+		if !*n.boolean {
+			st.errs = append(st.errs, Error{InstancePath: path, SchemaPath: n.location, Keyword: "false", Message: "no value is allowed here"})
+		}
+		return st.errs, &st.eval
+	}
+	if depth > maxDepth {
+		st.fail("$ref", "schema recursion is too deep")
+		return st.errs, &st.eval
+	}
+
+	if n.refNode != nil {
+		st.apply(n.refNode)
+	}
+	st.checkType()
+	st.checkValues()
+	switch v := value.(type) {
+	case float64:
+		st.checkNumber(v)
+	case string:
+		st.checkString(v)
+	case []interface{}:
+		st.checkArray(v)
+	case map[string]interface{}:
+		st.checkObject(v)
+	}
+	st.checkCombinators()
+
+	// unevaluated* see the annotations of everything above
+	switch v := value.(type) {
+	case []interface{}:
+		st.checkUnevaluatedItems(v)
+	case map[string]interface{}:
+		st.checkUnevaluatedProperties(v)
+	}
+	return st.errs, &st.eval
+}
+
+func (st *state) checkType() {
+	if len(st.n.types) == 0 {
+		return
+	}
+	actual := typeOf(st.value)
+	for _, allowed := range st.n.types {
+		if allowed == actual || (allowed == "number" && actual == "integer") {
+			return
+		}
+	}
+	st.fail("type", "expected %s, got %s", strings.Join(st.n.types, " or "), actual)
+}
+
+func (st *state) checkValues() {
+	if st.n.enum != nil {
+		found := false
+		for _, allowed := range st.n.enum {
+			if equal(allowed, st.value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			st.fail("enum", "%s is not one of %s", show(st.value), show(st.n.enum))
+		}
+	}
+	if st.n.hasConst && !equal(st.n.constValue, st.value) {
+		st.fail("const", "%s is not %s", show(st.value), show(st.n.constValue))
+	}
+	if s, ok := st.value.(string); ok && st.n.format != "" {
+		if check, known := formats[st.n.format]; known && !check(s) {
+			st.fail("format", "%s is not a valid %s", show(s), st.n.format)
+		}
+	}
+}
+
+func (st *state) checkNumber(v float64) {
+	n := st.n
+	if n.minimum != nil && v < *n.minimum {
+		st.fail("minimum", "%s is less than the minimum %s", number(v), number(*n.minimum))
+	}
+	if n.maximum != nil && v > *n.maximum {
+		st.fail("maximum", "%s is greater than the maximum %s", number(v), number(*n.maximum))
+	}
+	if n.exclusiveMinimum != nil && v <= *n.exclusiveMinimum {
+		st.fail("exclusiveMinimum", "%s must be greater than %s", number(v), number(*n.exclusiveMinimum))
+	}
+	if n.exclusiveMaximum != nil && v >= *n.exclusiveMaximum {
+		st.fail("exclusiveMaximum", "%s must be less than %s", number(v), number(*n.exclusiveMaximum))
+	}
+	if n.multipleOf != nil && !isMultiple(v, *n.multipleOf) {
+		st.fail("multipleOf", "%s is not a multiple of %s", number(v), number(*n.multipleOf))
+	}
+}
+
+// isMultiple allows for binary rounding, so 0.3 is a multiple of 0.1
+func isMultiple(v, of float64) bool {
+	quotient := v / of
+	if math.IsInf(quotient, 0) {
+		return false
+	}
+	return math.Abs(quotient-math.Round(quotient)) < 1e-9
+}
+
+func (st *state) checkString(v string) {
+	n := st.n
+	length := utf8.RuneCountInString(v)
+	if n.minLength != nil && length < *n.minLength {
+		st.fail("minLength", "length %d is less than the minimum %d", length, *n.minLength)
+	}
+	if n.maxLength != nil && length > *n.maxLength {
+		st.fail("maxLength", "length %d is greater than the maximum %d", length, *n.maxLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(v) {
+		st.fail("pattern", "%s does not match the pattern %s", show(v), n.pattern.String())
+	}
+}
+
+func (st *state) checkArray(items []interface{}) {
+	n := st.n
+	if n.minItems != nil && len(items) < *n.minItems {
+		st.fail("minItems", "has %d items, fewer than the minimum %d", len(items), *n.minItems)
+	}
+	if n.maxItems != nil && len(items) > *n.maxItems {
+		st.fail("maxItems", "has %d items, more than the maximum %d", len(items), *n.maxItems)
+	}
+	if n.uniqueItems {
+	unique:
+		for i := range items {
+			for j := 0; j < i; j++ {
+				if equal(items[i], items[j]) {
+					st.fail("uniqueItems", "items %d and %d are equal", j, i)
+					break unique
+				}
+			}
+		}
+	}
+
+	for i, item := range items {
+		itemPath := st.path + "/" + strconv.Itoa(i)
+		var sub *node
+		switch {
+		case i < len(n.prefixItems):
+			sub = n.prefixItems[i]
+		case n.items != nil:
+			sub = n.items
+		default:
+			continue
+		}
+		errs, _ := validate(sub, item, itemPath, st.depth+1)
+		st.errs = append(st.errs, errs...)
+		st.eval.addItem(i)
+	}
+	if n.items != nil {
+		st.eval.allItems = true
+	}
+
+	if n.contains != nil {
+		matches := 0
+		for i, item := range items {
+			if errs, _ := validate(n.contains, item, st.path+"/"+strconv.Itoa(i), st.depth+1); len(errs) == 0 {
+				matches++
+				st.eval.addItem(i)
+			}
+		}
+		minContains := 1
+		if n.minContains != nil {
+			minContains = *n.minContains
+		}
+		if matches < minContains {
+			st.fail("contains", "has %d items matching contains, fewer than %d", matches, minContains)
+		}
+		if n.maxContains != nil && matches > *n.maxContains {
+			st.fail("maxContains", "has %d items matching contains, more than %d", matches, *n.maxContains)
+		}
+	}
+}
+
+func (st *state) checkObject(obj map[string]interface{}) {
+	n := st.n
+	if n.minProperties != nil && len(obj) < *n.minProperties {
+		st.fail("minProperties", "has %d properties, fewer than the minimum %d", len(obj), *n.minProperties)
+	}
+	if n.maxProperties != nil && len(obj) > *n.maxProperties {
+		st.fail("maxProperties", "has %d properties, more than the maximum %d", len(obj), *n.maxProperties)
+	}
+	for _, name := range n.required {
+		if _, ok := obj[name]; !ok {
+			st.fail("required", "missing required property %s", show(name))
+		}
+	}
+	for _, name := range sortedKeys(n.dependentRequired) {
+		if _, ok := obj[name]; !ok {
+			continue
+		}
+		for _, required := range n.dependentRequired[name] {
+			if _, ok := obj[required]; !ok {
+				st.fail("dependentRequired", "missing property %s, required when %s is present", show(required), show(name))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(obj) {
+		value := obj[name]
+		propertyPath := st.path + "/" + escape(name)
+		matched := false
+		if sub, ok := n.properties[name]; ok {
+			matched = true
+			st.property(sub, value, propertyPath, name)
+		}
+		for _, pp := range n.patternProperties {
+			if pp.pattern.MatchString(name) {
+				matched = true
+				st.property(pp.node, value, propertyPath, name)
+			}
+		}
+		if !matched && n.additionalProperties != nil {
+			if isFalse(n.additionalProperties) {
+				st.failAt(propertyPath, "additionalProperties", "property %s is not allowed", show(name))
+			} else {
+				st.property(n.additionalProperties, value, propertyPath, name)
+			}
+		}
+		if n.propertyNames != nil {
+			if errs, _ := validate(n.propertyNames, name, propertyPath, st.depth+1); len(errs) > 0 {
+				st.failAt(propertyPath, "propertyNames", "property name %s is not allowed: %s", show(name), errs[0].Message)
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(n.dependentSchemas) {
+		if _, ok := obj[name]; ok {
+			st.apply(n.dependentSchemas[name])
+		}
+	}
+}
+
+// property validates one property's value and marks it evaluated
+func (st *state) property(sub *node, value interface{}, path, name string) {
+	errs, _ := validate(sub, value, path, st.depth+1)
+	st.errs = append(st.errs, errs...)
+	st.eval.addProperty(name)
+}
+
+func (st *state) checkCombinators() {
+	n := st.n
+	for _, sub := range n.allOf {
+		st.apply(sub)
+	}
+
+	if n.anyOf != nil {
+		var closest []Error
+		matched := false
+		for _, sub := range n.anyOf {
+			errs, eval := st.try(sub)
+			if len(errs) == 0 {
+				matched = true
+				st.eval.merge(eval)
+			} else if closest == nil || len(errs) < len(closest) {
+				closest = errs
+			}
+		}
+		if !matched {
+			st.fail("anyOf", "does not match any of the anyOf schemas (closest: %s)", closest[0].Error())
+		}
+	}
+
+	if n.oneOf != nil {
+		var matches []string
+		var closest []Error
+		for i, sub := range n.oneOf {
+			errs, eval := st.try(sub)
+			if len(errs) == 0 {
+				matches = append(matches, strconv.Itoa(i))
+				st.eval.merge(eval)
+			} else if closest == nil || len(errs) < len(closest) {
+				closest = errs
+			}
+		}
+		switch {
+		case len(matches) == 0:
+			st.fail("oneOf", "does not match any of the oneOf schemas (closest: %s)", closest[0].Error())
+		case len(matches) > 1:
+			st.fail("oneOf", "matches oneOf schemas %s, but must match exactly one", strings.Join(matches, " and "))
+		}
+	}
+
+	if n.not != nil {
+		if errs, _ := st.try(n.not); len(errs) == 0 {
+			st.fail("not", "must not match the schema in not")
+		}
+	}
+
+	if n.ifs != nil {
+		errs, eval := st.try(n.ifs)
+		if len(errs) == 0 {
+			st.eval.merge(eval)
+			if n.then != nil {
+				st.apply(n.then)
+			}
+		} else if n.elses != nil {
+			st.apply(n.elses)
+		}
+	}
+}
+
+func (st *state) checkUnevaluatedItems(items []interface{}) {
+	n := st.n
+	if n.unevaluatedItems == nil || st.eval.allItems {
+		return
+	}
+	for i, item := range items {
+		if st.eval.items[i] {
+			continue
+		}
+		itemPath := st.path + "/" + strconv.Itoa(i)
+		if isFalse(n.unevaluatedItems) {
+			st.failAt(itemPath, "unevaluatedItems", "item %d is not allowed", i)
+			continue
+		}
+		errs, _ := validate(n.unevaluatedItems, item, itemPath, st.depth+1)
+		st.errs = append(st.errs, errs...)
+	}
+	st.eval.allItems = true
+}
+
+func (st *state) checkUnevaluatedProperties(obj map[string]interface{}) {
+	n := st.n
+	if n.unevaluatedProperties == nil {
+		return
+	}
+	for _, name := range sortedKeys(obj) {
+		if st.eval.properties[name] {
+			continue
+		}
+		propertyPath := st.path + "/" + escape(name)
+		if isFalse(n.unevaluatedProperties) {
+			st.failAt(propertyPath, "unevaluatedProperties", "property %s is not allowed", show(name))
+			continue
+		}
+		st.property(n.unevaluatedProperties, obj[name], propertyPath, name)
+	}
+}
+
+func isFalse(n *node) bool {
+	return n.boolean != nil && !*n.boolean
+}
+
+// typeOf names the JSON type of a decoded value; integral numbers are integers
+func typeOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// normalize converts the numbers other decoders produce to float64
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = normalize(item)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			out[key] = normalize(item)
+		}
+		return out
+	}
+	return value
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// show formats a value for a message as JSON
+func show(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	if len(encoded) > 80 {
+		return string(encoded[:77]) + "..."
+	}
+	return string(encoded)
+}
+
+func number(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,332 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// validationCase validates data against schema; keyword is the keyword of an
+// expected error, empty when the data is valid
+type validationCase struct {
+	name    string
+	schema  string
+	data    string
+	keyword string
+}
+
+func runValidationCases(t *testing.T, tests []validationCase) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := Compile([]byte(tt.schema))
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.data), &value); err != nil {
+				t.Fatalf("bad test data: %v", err)
+			}
+			errs := schema.Errors(value)
+			if tt.keyword == "" {
+				if len(errs) > 0 {
+					t.Fatalf("expected %s to be valid, got %v", tt.data, errs)
+				}
+				return
+			}
+			for _, e := range errs {
+				if e.Keyword == tt.keyword {
+					return
+				}
+			}
+			t.Fatalf("expected a %s error for %s, got %v", tt.keyword, tt.data, errs)
+		})
+	}
+}
+
+func TestTypes(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{"integer", `{"type":"integer"}`, `1`, ""},
+		{"integer written as a float", `{"type":"integer"}`, `1.0`, ""},
+		{"integer with an exponent", `{"type":"integer"}`, `1e3`, ""},
+		{"fraction is not an integer", `{"type":"integer"}`, `1.5`, "type"},
+		{"string is not an integer", `{"type":"integer"}`, `"1"`, "type"},
+		{"integer is a number", `{"type":"number"}`, `1`, ""},
+		{"type list allows null", `{"type":["string","null"]}`, `null`, ""},
+		{"type list rejects others", `{"type":["string","null"]}`, `1`, "type"},
+		{"boolean", `{"type":"boolean"}`, `false`, ""},
+		{"object is not an array", `{"type":"array"}`, `{}`, "type"},
+		{"array is not an object", `{"type":"object"}`, `[]`, "type"},
+		{"true schema", `true`, `{"anything":1}`, ""},
+		{"false schema", `false`, `1`, "false"},
+	})
+}
+
+func TestValues(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{"enum", `{"enum":["a",1,null]}`, `"a"`, ""},
+		{"enum number written as a float", `{"enum":["a",1,null]}`, `1.0`, ""},
+		{"enum null", `{"enum":["a",1,null]}`, `null`, ""},
+		{"not in enum", `{"enum":["a",1,null]}`, `"b"`, "enum"},
+		{"enum of objects", `{"enum":[{"a":[1]}]}`, `{"a":[1]}`, ""},
+		{"const", `{"const":{"a":[1]}}`, `{"a":[1]}`, ""},
+		{"not const", `{"const":{"a":[1]}}`, `{"a":[2]}`, "const"},
+		{"const null", `{"const":null}`, `0`, "const"},
+	})
+}
+
+func TestNumbers(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{"minimum is inclusive", `{"minimum":5}`, `5`, ""},
+		{"below minimum", `{"minimum":5}`, `4.9`, "minimum"},
+		{"maximum is inclusive", `{"maximum":5}`, `5`, ""},
+		{"above maximum", `{"maximum":5}`, `5.1`, "maximum"},
+		{"exclusiveMinimum", `{"exclusiveMinimum":5}`, `5`, "exclusiveMinimum"},
+		{"above exclusiveMinimum", `{"exclusiveMinimum":5}`, `5.01`, ""},
+		{"exclusiveMaximum", `{"exclusiveMaximum":5}`, `5`, "exclusiveMaximum"},
+		{"draft-04 exclusiveMinimum", `{"minimum":5,"exclusiveMinimum":true}`, `5`, "exclusiveMinimum"},
+		{"draft-04 exclusiveMaximum off", `{"maximum":5,"exclusiveMaximum":false}`, `5`, ""},
+		{"multipleOf integer", `{"multipleOf":2}`, `8`, ""},
+		{"not a multipleOf integer", `{"multipleOf":2}`, `7`, "multipleOf"},
+		{"multipleOf 0.1", `{"multipleOf":0.1}`, `0.3`, ""},
+		{"multipleOf 0.01", `{"multipleOf":0.01}`, `19.99`, ""},
+		{"not a multipleOf 0.1", `{"multipleOf":0.1}`, `0.35`, "multipleOf"},
+		{"negative multipleOf", `{"multipleOf":0.5}`, `-2.5`, ""},
+		{"number keywords ignore strings", `{"minimum":5}`, `"1"`, ""},
+	})
+}
+
+func TestStrings(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{"minLength", `{"minLength":2}`, `"ab"`, ""},
+		{"below minLength", `{"minLength":3}`, `"ab"`, "minLength"},
+		{"minLength counts runes", `{"minLength":3}`, `"日本"`, "minLength"},
+		{"maxLength counts runes", `{"maxLength":2}`, `"日本"`, ""},
+		{"above maxLength", `{"maxLength":2}`, `"abc"`, "maxLength"},
+		{"pattern", `{"pattern":"^a+$"}`, `"aaa"`, ""},
+		{"pattern mismatch", `{"pattern":"^a+$"}`, `"ab"`, "pattern"},
+		{"pattern is not anchored", `{"pattern":"b"}`, `"abc"`, ""},
+		{"string keywords ignore numbers", `{"minLength":3}`, `1`, ""},
+	})
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		format, valid, invalid string
+	}{
+		{"date-time", "2024-01-02T03:04:05.123+01:00", "2024-01-02 03:04:05"},
+		{"date", "2024-02-29", "2023-02-29"},
+		{"time", "03:04:05Z", "25:00:00Z"},
+		{"duration", "P1DT2H", "PT"},
+		{"email", "a@example.com", "not an email"},
+		{"idn-email", "a@example.com", "A <a@example.com>"},
+		{"hostname", "api.example.com", "-bad.example.com"},
+		{"ipv4", "192.168.0.1", "256.1.1.1"},
+		{"ipv6", "2001:db8::1", "192.168.0.1"},
+		{"uri", "https://example.com/x?y=1", "/relative"},
+		{"iri", "urn:isbn:0451450523", "relative/path"},
+		{"uri-reference", "/relative", "%zz"},
+		{"iri-reference", "#fragment", "%zz"},
+		{"uuid", "123e4567-e89b-12d3-a456-426614174000", "123e4567"},
+		{"regex", "^a+$", "("},
+		{"json-pointer", "/a/b~0c", "a/b"},
+		{"relative-json-pointer", "1/a", "/a"},
+	}
+	var cases []validationCase
+	for _, tt := range tests {
+		schema := `{"format":"` + tt.format + `"}`
+		valid, _ := json.Marshal(tt.valid)
+		invalid, _ := json.Marshal(tt.invalid)
+		cases = append(cases,
+			validationCase{tt.format, schema, string(valid), ""},
+			validationCase{"invalid " + tt.format, schema, string(invalid), "format"},
+		)
+	}
+	cases = append(cases,
+		validationCase{"bad duration", `{"format":"duration"}`, `"P"`, "format"},
+		validationCase{"bad json-pointer escape", `{"format":"json-pointer"}`, `"/a~2"`, "format"},
+		validationCase{"unknown formats are annotations", `{"format":"credit-card"}`, `"anything"`, ""},
+		validationCase{"formats ignore other types", `{"format":"date"}`, `5`, ""},
+	)
+	runValidationCases(t, cases)
+}
+
+func TestArrays(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{"items", `{"items":{"type":"integer"}}`, `[1,2]`, ""},
+		{"items mismatch", `{"items":{"type":"integer"}}`, `[1,"2"]`, "type"},
+		{"prefixItems then items", `{"prefixItems":[{"type":"string"}],"items":{"type":"integer"}}`, `["a",1]`, ""},
+		{"prefixItems mismatch", `{"prefixItems":[{"type":"string"}],"items":{"type":"integer"}}`, `[1,1]`, "type"},
+		{"items after prefixItems", `{"prefixItems":[{"type":"string"}],"items":{"type":"integer"}}`, `["a","b"]`, "type"},
+		{"draft-07 tuple", `{"items":[{"type":"string"}],"additionalItems":false}`, `["a"]`, ""},
+		{"draft-07 additionalItems", `{"items":[{"type":"string"}],"additionalItems":false}`, `["a",1]`, "false"},
+		{"minItems", `{"minItems":2}`, `[1]`, "minItems"},
+		{"maxItems", `{"maxItems":1}`, `[1,2]`, "maxItems"},
+		{"uniqueItems", `{"uniqueItems":true}`, `[1,2,3]`, ""},
+		{"duplicate items", `{"uniqueItems":true}`, `[1,2,1]`, "uniqueItems"},
+		{"duplicate items written differently", `{"uniqueItems":true}`, `[1,1.0]`, "uniqueItems"},
+		{"unique objects", `{"uniqueItems":true}`, `[{"a":1},{"a":2}]`, ""},
+		{"duplicate objects", `{"uniqueItems":true}`, `[{"a":1,"b":[2]},{"b":[2],"a":1}]`, "uniqueItems"},
+		{"uniqueItems false", `{"uniqueItems":false}`, `[1,1]`, ""},
+		{"contains", `{"contains":{"type":"string"}}`, `[1,"a"]`, ""},
+		{"does not contain", `{"contains":{"type":"string"}}`, `[1,2]`, "contains"},
+		{"minContains", `{"contains":{"type":"string"},"minContains":2}`, `["a",1]`, "contains"},
+		{"minContains 0", `{"contains":{"type":"string"},"minContains":0}`, `[1]`, ""},
+		{"maxContains", `{"contains":{"type":"string"},"maxContains":1}`, `["a","b"]`, "maxContains"},
+	})
+}
+
+func TestObjects(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{"properties", `{"properties":{"a":{"type":"string"}}}`, `{"a":"x","b":1}`, ""},
+		{"property mismatch", `{"properties":{"a":{"type":"string"}}}`, `{"a":1}`, "type"},
+		{"required", `{"required":["a"]}`, `{"a":null}`, ""},
+		{"missing required", `{"required":["a","b"]}`, `{"a":1}`, "required"},
+		{"additionalProperties false", `{"properties":{"a":{}},"additionalProperties":false}`, `{"a":1,"b":2}`, "additionalProperties"},
+		{"additionalProperties schema", `{"properties":{"a":{}},"additionalProperties":{"type":"integer"}}`, `{"a":"x","b":"y"}`, "type"},
+		{"patternProperties", `{"patternProperties":{"^x-":{"type":"string"}},"additionalProperties":false}`, `{"x-a":"1"}`, ""},
+		{"patternProperties mismatch", `{"patternProperties":{"^x-":{"type":"string"}}}`, `{"x-a":1}`, "type"},
+		{"patternProperties leaves others additional", `{"patternProperties":{"^x-":{}},"additionalProperties":false}`, `{"y":1}`, "additionalProperties"},
+		{"propertyNames", `{"propertyNames":{"maxLength":3}}`, `{"abc":1}`, ""},
+		{"bad propertyName", `{"propertyNames":{"maxLength":3}}`, `{"abcd":1}`, "propertyNames"},
+		{"minProperties", `{"minProperties":2}`, `{"a":1}`, "minProperties"},
+		{"maxProperties", `{"maxProperties":1}`, `{"a":1,"b":2}`, "maxProperties"},
+		{"dependentRequired", `{"dependentRequired":{"card":["cvv"]}}`, `{"card":1,"cvv":2}`, ""},
+		{"missing dependentRequired", `{"dependentRequired":{"card":["cvv"]}}`, `{"card":1}`, "dependentRequired"},
+		{"dependentRequired absent trigger", `{"dependentRequired":{"card":["cvv"]}}`, `{"cvv":1}`, ""},
+		{"dependentSchemas", `{"dependentSchemas":{"card":{"required":["cvv"]}}}`, `{"card":1}`, "required"},
+		{"draft-07 dependencies list", `{"dependencies":{"card":["cvv"]}}`, `{"card":1}`, "dependentRequired"},
+		{"draft-07 dependencies schema", `{"dependencies":{"card":{"required":["cvv"]}}}`, `{"card":1}`, "required"},
+		{"object keywords ignore arrays", `{"required":["a"]}`, `[]`, ""},
+	})
+}
+
+func TestCombinators(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{"allOf", `{"allOf":[{"type":"integer"},{"minimum":1}]}`, `2`, ""},
+		{"allOf mismatch", `{"allOf":[{"type":"integer"},{"minimum":1}]}`, `0`, "minimum"},
+		{"anyOf", `{"anyOf":[{"type":"string"},{"minimum":1}]}`, `2`, ""},
+		{"anyOf both", `{"anyOf":[{"type":"integer"},{"minimum":1}]}`, `2`, ""},
+		{"anyOf none", `{"anyOf":[{"type":"string"},{"minimum":1}]}`, `0`, "anyOf"},
+		{"oneOf", `{"oneOf":[{"type":"string"},{"minimum":1}]}`, `2`, ""},
+		{"oneOf none", `{"oneOf":[{"type":"string"},{"minimum":1}]}`, `0`, "oneOf"},
+		{"oneOf both", `{"oneOf":[{"type":"integer"},{"minimum":1}]}`, `2`, "oneOf"},
+		{"not", `{"not":{"type":"string"}}`, `1`, ""},
+		{"not mismatch", `{"not":{"type":"string"}}`, `"a"`, "not"},
+		{"if then", `{"if":{"properties":{"kind":{"const":"card"}}},"then":{"required":["cvv"]},"else":{"required":["iban"]}}`, `{"kind":"card","cvv":1}`, ""},
+		{"if then mismatch", `{"if":{"properties":{"kind":{"const":"card"}}},"then":{"required":["cvv"]},"else":{"required":["iban"]}}`, `{"kind":"card","iban":1}`, "required"},
+		{"else", `{"if":{"properties":{"kind":{"const":"card"}}},"then":{"required":["cvv"]},"else":{"required":["iban"]}}`, `{"kind":"bank","iban":1}`, ""},
+		{"else mismatch", `{"if":{"properties":{"kind":{"const":"card"}}},"then":{"required":["cvv"]},"else":{"required":["iban"]}}`, `{"kind":"bank","cvv":1}`, "required"},
+		{"if without then", `{"if":{"type":"string"}}`, `1`, ""},
+		{"then without if", `{"then":{"type":"string"}}`, `1`, ""},
+	})
+}
+
+func TestUnevaluated(t *testing.T) {
+	runValidationCases(t, []validationCase{
+		{"properties from allOf are evaluated", `{"properties":{"a":{}},"allOf":[{"properties":{"b":{}}}],"unevaluatedProperties":false}`, `{"a":1,"b":2}`, ""},
+		{"unevaluated property", `{"properties":{"a":{}},"allOf":[{"properties":{"b":{}}}],"unevaluatedProperties":false}`, `{"a":1,"c":3}`, "unevaluatedProperties"},
+		{"properties from a failed anyOf branch are not evaluated",
+			`{"anyOf":[{"properties":{"a":{"type":"string"}},"required":["a"]},{"properties":{"b":{}},"required":["b"]}],"unevaluatedProperties":false}`,
+			`{"a":1,"b":2}`, "unevaluatedProperties"},
+		{"properties from if are evaluated", `{"if":{"properties":{"a":{"const":1}}},"then":{"properties":{"b":{}}},"unevaluatedProperties":false}`, `{"a":1,"b":2}`, ""},
+		{"properties from a $ref are evaluated", `{"$defs":{"base":{"properties":{"a":{}}}},"$ref":"#/$defs/base","properties":{"b":{}},"unevaluatedProperties":false}`, `{"a":1,"b":2}`, ""},
+		{"patternProperties are evaluated", `{"patternProperties":{"^x":{}},"unevaluatedProperties":false}`, `{"xa":1}`, ""},
+		{"unevaluatedProperties schema", `{"unevaluatedProperties":{"type":"integer"}}`, `{"a":"x"}`, "type"},
+		{"additionalProperties evaluates everything", `{"additionalProperties":true,"unevaluatedProperties":false}`, `{"a":1}`, ""},
+		{"unevaluated item", `{"prefixItems":[{}],"unevaluatedItems":false}`, `[1,2]`, "unevaluatedItems"},
+		{"items evaluate every item", `{"items":{},"unevaluatedItems":false}`, `[1,2]`, ""},
+		{"items from allOf are evaluated", `{"allOf":[{"prefixItems":[{},{}]}],"unevaluatedItems":false}`, `[1,2]`, ""},
+		{"contains evaluates matching items", `{"contains":{"type":"string"},"unevaluatedItems":false}`, `["a","b"]`, ""},
+		{"contains leaves other items", `{"contains":{"type":"string"},"unevaluatedItems":false}`, `["a",1]`, "unevaluatedItems"},
+	})
+}
+
+func TestRefs(t *testing.T) {
+	tree := `{
+		"$defs": {"node": {"type": "object", "properties": {
+			"value": {"type": "integer"},
+			"children": {"type": "array", "items": {"$ref": "#/$defs/node"}}
+		}}},
+		"$ref": "#/$defs/node"
+	}`
+	runValidationCases(t, []validationCase{
+		{"$defs", `{"$defs":{"pos":{"type":"integer","minimum":0}},"properties":{"n":{"$ref":"#/$defs/pos"}}}`, `{"n":1}`, ""},
+		{"$defs mismatch", `{"$defs":{"pos":{"type":"integer","minimum":0}},"properties":{"n":{"$ref":"#/$defs/pos"}}}`, `{"n":-1}`, "minimum"},
+		{"draft-07 definitions", `{"definitions":{"s":{"type":"string"}},"$ref":"#/definitions/s"}`, `1`, "type"},
+		{"$ref beside other keywords", `{"$defs":{"s":{"type":"string"}},"$ref":"#/$defs/s","minLength":2}`, `"a"`, "minLength"},
+		{"$anchor", `{"$defs":{"x":{"$anchor":"pos","minimum":0}},"$ref":"#pos"}`, `-1`, "minimum"},
+		{"draft-07 plain-name $id", `{"definitions":{"x":{"$id":"#pos","minimum":0}},"$ref":"#pos"}`, `-1`, "minimum"},
+		{"embedded $id", `{"$defs":{"a":{"$id":"http://example.com/a.json","type":"string"}},"$ref":"http://example.com/a.json"}`, `1`, "type"},
+		{"escaped pointer", `{"$defs":{"a/b":{"type":"string"}},"$ref":"#/$defs/a~1b"}`, `1`, "type"},
+		{"pointer to an unknown keyword", `{"x-types":{"s":{"type":"string"}},"$ref":"#/x-types/s"}`, `1`, "type"},
+		{"recursive", tree, `{"value":1,"children":[{"value":2,"children":[{"value":3}]}]}`, ""},
+		{"recursive mismatch", tree, `{"value":1,"children":[{"value":2,"children":[{"value":"3"}]}]}`, "type"},
+		{"self reference without progress", `{"$ref":"#"}`, `1`, "$ref"},
+	})
+}
+
+func TestErrorLocations(t *testing.T) {
+	schema, err := Compile([]byte(`{
+		"$defs": {"price": {"type": "number", "minimum": 0}},
+		"properties": {"items": {"type": "array", "items": {"properties": {"a/b": {"$ref": "#/$defs/price"}}}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value interface{}
+	json.Unmarshal([]byte(`{"items":[{"a/b":1},{"a/b":-2}]}`), &value)
+
+	err = schema.Validate(value)
+	validationErr, ok := err.(*ValidationError)
+	if !ok || len(validationErr.Errors) != 1 {
+		t.Fatalf("expected one validation error, got %v", err)
+	}
+	got := validationErr.Errors[0]
+	want := Error{
+		InstancePath: "/items/1/a~1b",
+		SchemaPath:   "#/$defs/price/minimum",
+		Keyword:      "minimum",
+		Message:      "-2 is less than the minimum 0",
+	}
+	if got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if err.Error() != "/items/1/a~1b: -2 is less than the minimum 0" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+
+	errs := mustCompile(t, `{"type":"string","minLength":5}`).Errors(3)
+	if len(errs) != 1 || errs[0].Error() != "(root): expected string, got integer" {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+}
+
+func TestValidateAcceptsOtherNumberTypes(t *testing.T) {
+	schema := mustCompile(t, `{"properties":{"n":{"type":"integer","maximum":10}},"items":{"type":"integer"}}`)
+
+	decoder := json.NewDecoder(strings.NewReader(`{"n":3}`))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(value); err != nil {
+		t.Fatalf("json.Number: %v", err)
+	}
+	if err := schema.Validate(map[string]interface{}{"n": 11}); err == nil {
+		t.Fatal("expected an int above the maximum to fail")
+	}
+	if err := schema.Validate([]interface{}{int64(1), float32(2)}); err != nil {
+		t.Fatalf("int64 and float32: %v", err)
+	}
+}
+
+func mustCompile(t *testing.T, schema string) *Schema {
+	t.Helper()
+	compiled, err := Compile([]byte(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compiled
+}
@@ -5,6 +5,11 @@ import (
 
 	"github.com/og-dim9/dimutils/pkg/consume"
 	"github.com/og-dim9/dimutils/pkg/kafkaadmin"
+	"github.com/og-dim9/dimutils/pkg/kafkabrowse"
+	"github.com/og-dim9/dimutils/pkg/kafkaconnect"
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"github.com/og-dim9/dimutils/pkg/kafkasearch"
+	"github.com/og-dim9/dimutils/pkg/mockbroker"
 	"github.com/og-dim9/dimutils/pkg/produce"
 )
 
@@ -24,6 +29,16 @@ func Run(args []string) error {
 		return produce.Run(subArgs)
 	case "admin", "a":
 		return kafkaadmin.Run(subArgs)
+	case "search", "s":
+		return kafkasearch.Run(subArgs)
+	case "browse", "b":
+		return kafkabrowse.Run(subArgs)
+	case "connect", "cn":
+		return kafkaconnect.Run(subArgs)
+	case "context", "ctx":
+		return kafkacontext.Run(subArgs)
+	case "mock-broker", "mock":
+		return mockbroker.Run(subArgs)
 	case "help", "-h", "--help":
 		return printHelp()
 	default:
@@ -40,9 +55,16 @@ Subcommands:
   consume, c        Consume messages from Kafka topics
   produce, p        Produce messages to Kafka topics  
   admin, a          Administer Kafka topics and consumer groups
+  search, s         Search a topic's partitions in parallel for matching messages
+  browse, b         Browse topics and messages interactively in the terminal
+  connect, cn       Manage Kafka Connect connectors (list, status, apply, ...)
+  context, ctx      Manage named connection profiles (list, use, show)
+  mock-broker, mock Run an in-memory Kafka broker for offline development
   help              Show this help message
 
 Global Options:
+  --context NAME            Kafka context from ~/.config/dimutils/kafka.yaml
+                            (default: $DIMUTILS_KAFKA_CONTEXT, then current-context)
   --brokers, -b BROKERS     Comma-separated list of brokers (default: localhost:9092)
   --verbose, -v             Verbose output
 
@@ -51,9 +73,14 @@ Examples:
   kafka produce my-topic --key mykey < data.txt
   kafka admin list-topics
   kafka admin create-topic my-topic --partitions 3
+  kafka search orders --filter order.id=12345 --from-time 2h
+  kafka browse orders
+  kafka connect apply connectors/ --dry-run
+  kafka context use prod
+  kafka mock-broker --port 19092 --topics orders:3
 
 Use 'kafka <subcommand> --help' for detailed help on each subcommand.`
 
 	fmt.Println(help)
 	return nil
-}
\ No newline at end of file
+}
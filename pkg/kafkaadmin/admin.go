@@ -3,11 +3,14 @@ package kafkaadmin
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
 )
 
 // Config holds configuration for Kafka admin operations
@@ -15,6 +18,8 @@ type Config struct {
 	Brokers []string
 	Timeout time.Duration
 	Verbose bool
+	Auth    *kafkautils.AuthConfig
+	TLS     *kafkautils.TLSConfig
 }
 
 // DefaultConfig returns default admin configuration
@@ -28,8 +33,9 @@ func DefaultConfig() Config {
 
 // AdminClient wraps Kafka admin operations
 type AdminClient struct {
-	config Config
-	client sarama.ClusterAdmin
+	config  Config
+	client  sarama.ClusterAdmin
+	offsets sarama.Client // created on first use for offset lookups
 }
 
 // TopicDetails represents detailed topic information
@@ -65,6 +71,30 @@ type ConsumerGroupMember struct {
 	ClientHost string
 }
 
+// TopicSummary describes a topic with the offset range of each partition
+type TopicSummary struct {
+	Name       string
+	Internal   bool
+	Partitions []PartitionOffsets
+}
+
+// PartitionOffsets holds the earliest and latest (next) offset of a partition
+type PartitionOffsets struct {
+	Partition int32
+	Leader    int32
+	Earliest  int64
+	Latest    int64
+}
+
+// Messages returns the number of messages retained in the topic
+func (t TopicSummary) Messages() int64 {
+	var total int64
+	for _, partition := range t.Partitions {
+		total += partition.Latest - partition.Earliest
+	}
+	return total
+}
+
 // TopicPartitionOffset represents offset information
 type TopicPartitionOffset struct {
 	Topic     string
@@ -84,6 +114,19 @@ func Run(args []string) error {
 	subcommand := args[0]
 	subArgs := args[1:]
 
+	if subcommand == "help" || subcommand == "-h" || subcommand == "--help" {
+		return printHelp()
+	}
+
+	brokers, auth, tlsConfig, err := kafkacontext.ApplyClient(subArgs)
+	if err != nil {
+		return err
+	}
+	if len(brokers) > 0 {
+		config.Brokers = brokers
+	}
+	config.Auth, config.TLS = auth, tlsConfig
+
 	// Parse global flags
 	for i, arg := range subArgs {
 		switch arg {
@@ -140,6 +183,7 @@ func printHelp() error {
 Kafka administration utility for managing topics, consumer groups, and configurations.
 
 Global Options:
+  --context NAME            Kafka context from ~/.config/dimutils/kafka.yaml
   --brokers, -b BROKERS     Comma-separated list of brokers (default: localhost:9092)
   --timeout DURATION        Operation timeout (default: 30s)
   --verbose, -v             Verbose output
@@ -177,12 +221,26 @@ Examples:
 	return nil
 }
 
-// NewAdminClient creates a new Kafka admin client
-func NewAdminClient(config Config) (*AdminClient, error) {
+// newSaramaConfig creates a Sarama configuration with the admin security settings applied
+func newSaramaConfig(config Config) (*sarama.Config, error) {
 	saramaConfig := sarama.NewConfig()
 	saramaConfig.Version = sarama.V2_6_0_0
 	saramaConfig.Admin.Timeout = config.Timeout
 
+	if err := kafkautils.ConfigureSecurity(saramaConfig, config.Auth, config.TLS); err != nil {
+		return nil, err
+	}
+
+	return saramaConfig, nil
+}
+
+// NewAdminClient creates a new Kafka admin client
+func NewAdminClient(config Config) (*AdminClient, error) {
+	saramaConfig, err := newSaramaConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
 	client, err := sarama.NewClusterAdmin(config.Brokers, saramaConfig)
 	if err != nil {
 		return nil, err
@@ -196,9 +254,106 @@ func NewAdminClient(config Config) (*AdminClient, error) {
 
 // Close closes the admin client
 func (ac *AdminClient) Close() error {
+	if ac.offsets != nil {
+		ac.offsets.Close()
+	}
 	return ac.client.Close()
 }
 
+// DescribeTopicOffsets returns the partitions and offset ranges of the given
+// topics, or of all topics when none are given, sorted by name
+func (ac *AdminClient) DescribeTopicOffsets(topics []string) ([]TopicSummary, error) {
+	metadata, err := ac.client.DescribeTopics(topics)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe topics: %w", err)
+	}
+
+	if ac.offsets == nil {
+		saramaConfig, err := newSaramaConfig(ac.config)
+		if err != nil {
+			return nil, err
+		}
+		client, err := sarama.NewClient(ac.config.Brokers, saramaConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Kafka: %w", err)
+		}
+		ac.offsets = client
+	}
+
+	summaries := make([]TopicSummary, 0, len(metadata))
+	for _, topic := range metadata {
+		if topic.Err != sarama.ErrNoError {
+			return nil, fmt.Errorf("topic %s: %w", topic.Name, topic.Err)
+		}
+		summary := TopicSummary{Name: topic.Name, Internal: topic.IsInternal}
+		for _, partition := range topic.Partitions {
+			summary.Partitions = append(summary.Partitions, PartitionOffsets{
+				Partition: partition.ID,
+				Leader:    partition.Leader,
+			})
+		}
+		sort.Slice(summary.Partitions, func(i, j int) bool {
+			return summary.Partitions[i].Partition < summary.Partitions[j].Partition
+		})
+		summaries = append(summaries, summary)
+	}
+
+	if err := ac.fillOffsets(summaries); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+	return summaries, nil
+}
+
+// fillOffsets looks up the earliest and latest offsets with one request per leader and bound
+func (ac *AdminClient) fillOffsets(summaries []TopicSummary) error {
+	type ref struct{ topic, partition int }
+	brokers := make(map[int32]*sarama.Broker)
+	byLeader := make(map[int32][]ref)
+	for t := range summaries {
+		for p, partition := range summaries[t].Partitions {
+			broker, err := ac.offsets.Leader(summaries[t].Name, partition.Partition)
+			if err != nil {
+				return fmt.Errorf("no leader for %s/%d: %w", summaries[t].Name, partition.Partition, err)
+			}
+			brokers[broker.ID()] = broker
+			byLeader[broker.ID()] = append(byLeader[broker.ID()], ref{t, p})
+		}
+	}
+
+	for leader, refs := range byLeader {
+		broker := brokers[leader]
+		for _, bound := range []int64{sarama.OffsetOldest, sarama.OffsetNewest} {
+			request := &sarama.OffsetRequest{Version: 1}
+			for _, r := range refs {
+				request.AddBlock(summaries[r.topic].Name, summaries[r.topic].Partitions[r.partition].Partition, bound, 1)
+			}
+			response, err := broker.GetAvailableOffsets(request)
+			if err != nil {
+				return fmt.Errorf("failed to get offsets from broker %d: %w", leader, err)
+			}
+			for _, r := range refs {
+				partition := &summaries[r.topic].Partitions[r.partition]
+				block := response.GetBlock(summaries[r.topic].Name, partition.Partition)
+				if block == nil {
+					return fmt.Errorf("no offsets for %s/%d", summaries[r.topic].Name, partition.Partition)
+				}
+				if block.Err != sarama.ErrNoError {
+					return fmt.Errorf("offsets for %s/%d: %w", summaries[r.topic].Name, partition.Partition, block.Err)
+				}
+				if bound == sarama.OffsetOldest {
+					partition.Earliest = block.Offset
+				} else {
+					partition.Latest = block.Offset
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 // ListTopics lists all topics in the cluster
 func (ac *AdminClient) ListTopics(args []string) error {
 	metadata, err := ac.client.DescribeTopics(nil)
@@ -483,47 +638,21 @@ func (ac *AdminClient) GetTopicOffsets(args []string) error {
 	}
 
 	topicName := args[0]
-	
-	// Get topic metadata to know partitions
-	metadata, err := ac.client.DescribeTopics([]string{topicName})
+	summaries, err := ac.DescribeTopicOffsets([]string{topicName})
 	if err != nil {
-		return fmt.Errorf("failed to get topic metadata: %w", err)
+		return fmt.Errorf("failed to get topic offsets: %w", err)
 	}
-
-	var topicFound *sarama.TopicMetadata
-	for _, topic := range metadata {
-		if topic.Name == topicName {
-			topicFound = topic
-			break
-		}
+	if len(summaries) == 0 {
+		return fmt.Errorf("topic %s not found", topicName)
 	}
 
-	if topicFound != nil {
-		// Create a consumer to get offsets
-		saramaConfig := sarama.NewConfig()
-		consumer, err := sarama.NewConsumer(ac.config.Brokers, saramaConfig)
-		if err != nil {
-			return fmt.Errorf("failed to create consumer: %w", err)
-		}
-		defer consumer.Close()
-
-		fmt.Printf("Topic: %s\n", topicName)
-		fmt.Printf("%-10s %-15s %-15s %s\n", "PARTITION", "EARLIEST", "LATEST", "LAG")
-		fmt.Println(strings.Repeat("-", 60))
-
-		for _, partition := range topicFound.Partitions {
-			partitionConsumer, err := consumer.ConsumePartition(topicName, partition.ID, sarama.OffsetOldest)
-			if err != nil {
-				log.Printf("Failed to create partition consumer for partition %d: %v", partition.ID, err)
-				continue
-			}
+	fmt.Printf("Topic: %s\n", topicName)
+	fmt.Printf("%-10s %-15s %-15s %s\n", "PARTITION", "EARLIEST", "LATEST", "MESSAGES")
+	fmt.Println(strings.Repeat("-", 60))
 
-			// This is a simplified approach - in practice you'd use a different method to get offsets
-			fmt.Printf("%-10d %-15s %-15s %s\n", partition.ID, "N/A", "N/A", "N/A")
-			partitionConsumer.Close()
-		}
-	} else {
-		return fmt.Errorf("topic %s not found", topicName)
+	for _, partition := range summaries[0].Partitions {
+		fmt.Printf("%-10d %-15d %-15d %d\n",
+			partition.Partition, partition.Earliest, partition.Latest, partition.Latest-partition.Earliest)
 	}
 
 	return nil
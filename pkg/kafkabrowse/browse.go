@@ -0,0 +1,255 @@
+package kafkabrowse
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
+	"github.com/og-dim9/dimutils/pkg/schemaregistry"
+)
+
+// Config holds configuration for the topic browser
+type Config struct {
+	Brokers      []string
+	Topic        string
+	Timeout      time.Duration
+	FetchTimeout time.Duration
+	PageSize     int
+	Start        string // latest, earliest
+	ShowInternal bool
+	Registry     *schemaregistry.Config
+	Auth         *kafkautils.AuthConfig
+	TLS          *kafkautils.TLSConfig
+}
+
+// DefaultConfig returns default browser configuration
+func DefaultConfig() Config {
+	return Config{
+		Brokers:      []string{"localhost:9092"},
+		Timeout:      30 * time.Second,
+		FetchTimeout: 2 * time.Second,
+		PageSize:     100,
+		Start:        "latest",
+	}
+}
+
+// Run is the main entry point for the browse command
+func Run(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			return printHelp()
+		}
+	}
+
+	config := DefaultConfig()
+
+	if err := applyContext(args, &config); err != nil {
+		return err
+	}
+
+	if err := parseArgs(args, &config); err != nil {
+		return err
+	}
+
+	return browse(config)
+}
+
+// applyContext loads brokers, credentials and the schema registry from the active kafka context
+func applyContext(args []string, config *Config) error {
+	kctx, err := kafkacontext.FromArgs(args)
+	if err != nil || kctx == nil {
+		return err
+	}
+
+	brokers, auth, tlsConfig, err := kctx.ClientSettings()
+	if err != nil {
+		return err
+	}
+	if len(brokers) > 0 {
+		config.Brokers = brokers
+	}
+	config.Auth, config.TLS = auth, tlsConfig
+
+	registry, err := kctx.RegistryConfig()
+	if err != nil {
+		return err
+	}
+	config.Registry = registry
+
+	return nil
+}
+
+func parseArgs(args []string, config *Config) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		value := ""
+		switch arg {
+		case "--brokers", "-b", "--context", "--registry-url", "--page-size", "--from", "--timeout":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s requires a value", arg)
+			}
+			value = args[i+1]
+			i++
+		}
+
+		switch arg {
+		case "--brokers", "-b":
+			config.Brokers = strings.Split(value, ",")
+		case "--context":
+			// Handled by applyContext
+		case "--registry-url":
+			registry := schemaregistry.DefaultConfig()
+			if config.Registry != nil {
+				registry = *config.Registry
+			}
+			registry.URL = value
+			config.Registry = &registry
+		case "--page-size":
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return fmt.Errorf("invalid --page-size: %s", value)
+			}
+			config.PageSize = size
+		case "--from":
+			if value != "latest" && value != "earliest" {
+				return fmt.Errorf("invalid --from: %s (use latest or earliest)", value)
+			}
+			config.Start = value
+		case "--timeout":
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid --timeout: %s", value)
+			}
+			config.FetchTimeout = duration
+		case "--internal":
+			config.ShowInternal = true
+		default:
+			if kafkacontext.IsInlineFlag(arg) {
+				// Handled by applyContext
+				continue
+			}
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			if config.Topic != "" {
+				return fmt.Errorf("unexpected argument: %s", arg)
+			}
+			config.Topic = arg
+		}
+	}
+	return nil
+}
+
+// browse connects to Kafka and runs the interactive browser until the user quits
+func browse(config Config) error {
+	src, err := newKafkaSource(config)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var registry *schemaregistry.Client
+	if config.Registry != nil {
+		registry = schemaregistry.NewClient(*config.Registry)
+	}
+
+	b := newBrowser(config, src, newFormatter(registry))
+	b.refreshTopics()
+	if config.Topic != "" {
+		found := false
+		for _, topic := range b.topics {
+			if topic.Name == config.Topic {
+				b.openTopic(topic)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("topic %s not found", config.Topic)
+		}
+	}
+
+	term, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer term.Close()
+
+	resize := make(chan os.Signal, 1)
+	signal.Notify(resize, syscall.SIGWINCH)
+	defer signal.Stop(resize)
+
+	draw := func() {
+		b.width, b.height = term.Size()
+		term.Draw(b.render())
+	}
+
+	for !b.quit {
+		draw()
+		if b.runPending() {
+			continue
+		}
+
+		select {
+		case k, ok := <-term.keys:
+			if !ok {
+				return nil
+			}
+			b.handleKey(k)
+		case <-resize:
+		}
+	}
+	return nil
+}
+
+func printHelp() error {
+	help := `Usage: kafka browse [options] [topic]
+
+Browse topics and messages interactively in the terminal. The topic list shows
+partition counts and message totals; opening a topic pages through one
+partition at a time without joining a consumer group. Values are pretty-printed
+as JSON, or decoded as Avro when they use the schema registry wire format.
+
+Options:
+  --context NAME            Kafka context from ~/.config/dimutils/kafka.yaml
+  --brokers, -b BROKERS     Comma-separated list of brokers (default: localhost:9092)
+  --registry-url URL        Schema registry for decoding Avro values (default: from context)
+  --page-size NUM           Messages loaded per page (default: 100)
+  --from POSITION           Where to open a partition: latest, earliest (default: latest)
+  --timeout DURATION        Stop waiting for more messages of a page after this long (default: 2s)
+  --internal                Show internal topics such as __consumer_offsets
+  -h, --help                Show this help message
+
+Topic list keys:
+  Up/Down, j/k              Move
+  Enter                     Open the topic
+  /                         Filter topics by name (Esc clears)
+  r                         Refresh offsets
+  q                         Quit
+
+Message keys:
+  Up/Down, PgUp/PgDn        Move within the loaded window
+  Enter                     Show key, headers and the full value
+  Right/Space, Left/b       Next / previous page
+  g, G                      Earliest / latest page
+  Tab, Shift-Tab, p         Next / previous / chosen partition
+  o                         Jump to an offset
+  t                         Jump to a time (RFC 3339, YYYY-MM-DD, epoch millis or a duration ago such as 2h)
+  /, n, N                   Search the loaded window, next / previous match
+  r                         Reload the window
+  q, Esc                    Back to the topic list
+
+Examples:
+  kafka browse
+  kafka browse --context prod orders
+  kafka browse --registry-url http://localhost:8081 --from earliest payments
+`
+	fmt.Print(help)
+	return nil
+}
@@ -0,0 +1,130 @@
+package kafkabrowse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/og-dim9/dimutils/pkg/avro"
+	"github.com/og-dim9/dimutils/pkg/schemaregistry"
+)
+
+// formatted is a message value rendered for display
+type formatted struct {
+	Kind    string   // json, avro, text, binary, ...
+	Preview string   // single line for the message list
+	Lines   []string // pretty-printed value for the detail view
+}
+
+// formatter renders message values, decoding Confluent wire-format values with the schema registry
+type formatter struct {
+	registry *schemaregistry.Client
+	schemas  map[int]*registeredSchema
+}
+
+type registeredSchema struct {
+	Type string
+	Avro *avro.Schema
+	Err  error
+}
+
+func newFormatter(registry *schemaregistry.Client) *formatter {
+	return &formatter{registry: registry, schemas: make(map[int]*registeredSchema)}
+}
+
+// Format renders a message value
+func (f *formatter) Format(value []byte) formatted {
+	if value == nil {
+		return formatted{Kind: "null", Preview: "(null)", Lines: []string{"(null)"}}
+	}
+
+	// Confluent wire format: magic byte 0 followed by a 4-byte schema id
+	if len(value) >= 5 && value[0] == 0 {
+		id := int(binary.BigEndian.Uint32(value[1:5]))
+		if result, ok := f.formatRegistered(id, value[5:]); ok {
+			return result
+		}
+	}
+
+	if json.Valid(value) {
+		return jsonFormatted("json", value)
+	}
+	if utf8.Valid(value) {
+		text := string(value)
+		return formatted{Kind: "text", Preview: text, Lines: strings.Split(text, "\n")}
+	}
+	return binaryFormatted("binary", value, "")
+}
+
+// formatRegistered decodes a schema-registry encoded payload; it reports false when the id is unknown
+func (f *formatter) formatRegistered(id int, payload []byte) (formatted, bool) {
+	if f.registry == nil {
+		return formatted{}, false
+	}
+
+	schema, ok := f.schemas[id]
+	if !ok {
+		schema = &registeredSchema{}
+		registered, err := f.registry.GetSchemaByID(id)
+		if err != nil {
+			schema.Err = err
+		} else {
+			schema.Type = strings.ToUpper(registered.Type)
+			if schema.Type == "" || schema.Type == "AVRO" {
+				schema.Type = "AVRO"
+				schema.Avro, schema.Err = avro.Parse(registered.Schema)
+			}
+		}
+		f.schemas[id] = schema
+	}
+
+	kind := fmt.Sprintf("%s, schema %d", strings.ToLower(schema.Type), id)
+	if schema.Err != nil {
+		if schema.Type == "" {
+			// Not a registry payload after all, or the registry does not know the id
+			return formatted{}, false
+		}
+		return binaryFormatted(kind, payload, schema.Err.Error()), true
+	}
+
+	switch schema.Type {
+	case "AVRO":
+		decoded, err := avro.Decode(schema.Avro, payload)
+		if err != nil {
+			return binaryFormatted(kind, payload, err.Error()), true
+		}
+		data, err := json.Marshal(decoded)
+		if err != nil {
+			return binaryFormatted(kind, payload, err.Error()), true
+		}
+		return jsonFormatted(kind, data), true
+	case "JSON":
+		if json.Valid(payload) {
+			return jsonFormatted(kind, payload), true
+		}
+	}
+	return binaryFormatted(kind, payload, ""), true
+}
+
+func jsonFormatted(kind string, data []byte) formatted {
+	var compact, pretty bytes.Buffer
+	json.Compact(&compact, data)
+	json.Indent(&pretty, data, "", "  ")
+	return formatted{Kind: kind, Preview: compact.String(), Lines: strings.Split(pretty.String(), "\n")}
+}
+
+func binaryFormatted(kind string, data []byte, note string) formatted {
+	lines := strings.Split(strings.TrimRight(hex.Dump(data), "\n"), "\n")
+	if note != "" {
+		lines = append([]string{"(" + note + ")"}, lines...)
+	}
+	preview := hex.EncodeToString(data)
+	if len(preview) > 256 {
+		preview = preview[:256]
+	}
+	return formatted{Kind: kind, Preview: preview, Lines: lines}
+}
@@ -0,0 +1,784 @@
+package kafkabrowse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/kafkaadmin"
+	"github.com/og-dim9/dimutils/pkg/kafkasearch"
+)
+
+type view int
+
+const (
+	viewTopics view = iota
+	viewMessages
+	viewDetail
+)
+
+// message is a loaded message with its rendered value
+type message struct {
+	*sarama.ConsumerMessage
+	Value formatted
+}
+
+// prompt is a line of input being typed at the bottom of the screen
+type prompt struct {
+	Label  string
+	Input  string
+	Submit func(input string)
+}
+
+// browser holds the state of the interactive browser; it is driven by key
+// presses and renders into lines, so it does not depend on the terminal
+type browser struct {
+	config    Config
+	src       source
+	formatter *formatter
+	width     int
+	height    int
+	view      view
+	status    string
+	prompt    *prompt
+	pending   func() // slow operation run after the screen shows "Loading..."
+	quit      bool
+
+	// Topic list
+	topics      []kafkaadmin.TopicSummary
+	topicFilter string
+	topicCursor int
+	topicScroll int
+
+	// Message window
+	topic      kafkaadmin.TopicSummary
+	partition  int // index into topic.Partitions
+	window     []*message
+	earliest   int64
+	latest     int64
+	cursor     int
+	scroll     int
+	search     string
+	matches    []int
+	matchIndex int
+
+	// Detail view
+	detail       []string
+	detailScroll int
+}
+
+func newBrowser(config Config, src source, f *formatter) *browser {
+	return &browser{config: config, src: src, formatter: f, width: 80, height: 24}
+}
+
+// load runs fn after the screen has been redrawn with a loading notice
+func (b *browser) load(description string, fn func()) {
+	b.status = description + "..."
+	b.pending = fn
+}
+
+// runPending runs a queued slow operation, reporting whether there was one
+func (b *browser) runPending() bool {
+	if b.pending == nil {
+		return false
+	}
+	fn := b.pending
+	b.pending = nil
+	b.status = ""
+	fn()
+	return true
+}
+
+func (b *browser) setError(err error) {
+	b.status = "Error: " + err.Error()
+}
+
+// refreshTopics reloads the topic list, keeping the selected topic when it still exists
+func (b *browser) refreshTopics() {
+	topics, err := b.src.Topics()
+	if err != nil {
+		b.setError(err)
+		return
+	}
+
+	selected := ""
+	if visible := b.visibleTopics(); b.topicCursor < len(visible) {
+		selected = visible[b.topicCursor].Name
+	}
+
+	b.topics = topics
+	b.topicCursor = 0
+	for i, topic := range b.visibleTopics() {
+		if topic.Name == selected {
+			b.topicCursor = i
+		}
+	}
+	b.status = fmt.Sprintf("%d topics", len(b.visibleTopics()))
+}
+
+// visibleTopics returns the topics matching the filter, hiding internal topics unless configured
+func (b *browser) visibleTopics() []kafkaadmin.TopicSummary {
+	var visible []kafkaadmin.TopicSummary
+	for _, topic := range b.topics {
+		if !b.config.ShowInternal && (topic.Internal || strings.HasPrefix(topic.Name, "__")) {
+			continue
+		}
+		if b.topicFilter != "" && !strings.Contains(strings.ToLower(topic.Name), strings.ToLower(b.topicFilter)) {
+			continue
+		}
+		visible = append(visible, topic)
+	}
+	return visible
+}
+
+// openTopic switches to the message view of a topic
+func (b *browser) openTopic(topic kafkaadmin.TopicSummary) {
+	if len(topic.Partitions) == 0 {
+		b.status = "Topic has no partitions"
+		return
+	}
+	b.topic = topic
+	b.partition = 0
+	b.view = viewMessages
+	b.window = nil
+	b.clearSearch()
+	if b.config.Start == "earliest" {
+		b.load("Loading", func() { b.loadAt(0, -1) })
+	} else {
+		b.load("Loading", b.loadLast)
+	}
+}
+
+func (b *browser) currentPartition() int32 {
+	return b.topic.Partitions[b.partition].Partition
+}
+
+// loadAt loads a window starting at offset and selects the message at focus (or the first one)
+func (b *browser) loadAt(offset int64, focus int64) {
+	result, err := b.src.Fetch(b.topic.Name, b.currentPartition(), offset, b.config.PageSize)
+	if err != nil {
+		b.setError(err)
+		return
+	}
+
+	b.earliest, b.latest = result.Earliest, result.Latest
+	b.window = make([]*message, len(result.Messages))
+	for i, m := range result.Messages {
+		b.window[i] = &message{ConsumerMessage: m, Value: b.formatter.Format(m.Value)}
+	}
+
+	b.cursor, b.scroll = 0, 0
+	if focus >= 0 {
+		for i, m := range b.window {
+			if m.Offset >= focus {
+				b.cursor = i
+				break
+			}
+		}
+	}
+	b.findMatches()
+	if len(b.window) == 0 {
+		b.status = fmt.Sprintf("No messages at offset %d (partition holds %d..%d)", offset, b.earliest, b.latest)
+	}
+}
+
+// loadLast loads the most recent window of the partition
+func (b *browser) loadLast() {
+	result, err := b.src.Fetch(b.topic.Name, b.currentPartition(), 0, 0)
+	if err != nil {
+		b.setError(err)
+		return
+	}
+	start := result.Latest - int64(b.config.PageSize)
+	b.loadAt(start, result.Latest-1)
+}
+
+func (b *browser) nextPage() {
+	start := b.latest
+	if len(b.window) > 0 {
+		start = b.window[len(b.window)-1].Offset + 1
+	}
+	if start >= b.latest && len(b.window) > 0 {
+		// At the end: reload in case new messages arrived
+		b.load("Loading", func() { b.loadAt(b.window[0].Offset, b.window[len(b.window)-1].Offset) })
+		return
+	}
+	b.load("Loading", func() { b.loadAt(start, -1) })
+}
+
+func (b *browser) previousPage() {
+	if len(b.window) == 0 {
+		b.load("Loading", b.loadLast)
+		return
+	}
+	first := b.window[0].Offset
+	if first <= b.earliest {
+		b.status = "Already at the earliest offset"
+		return
+	}
+	b.load("Loading", func() {
+		b.loadAt(first-int64(b.config.PageSize), first-1)
+	})
+}
+
+func (b *browser) switchPartition(delta int) {
+	count := len(b.topic.Partitions)
+	b.partition = ((b.partition+delta)%count + count) % count
+	b.clearSearch()
+	b.load("Loading", b.loadLast)
+}
+
+func (b *browser) jumpToOffset(input string) {
+	offset, err := strconv.ParseInt(strings.TrimSpace(input), 10, 64)
+	if err != nil || offset < 0 {
+		b.status = "Invalid offset: " + input
+		return
+	}
+	b.load("Loading", func() {
+		b.loadAt(offset, offset)
+		if len(b.window) == 0 && b.latest > b.earliest {
+			b.loadLast()
+			b.status = fmt.Sprintf("Offset %d is past the end; showing the latest messages", offset)
+		}
+	})
+}
+
+func (b *browser) jumpToTime(input string) {
+	t, err := kafkasearch.ParseTime(strings.TrimSpace(input), time.Now())
+	if err != nil {
+		b.setError(err)
+		return
+	}
+	b.load("Loading", func() {
+		offset, err := b.src.OffsetForTime(b.topic.Name, b.currentPartition(), t)
+		if err != nil {
+			b.setError(err)
+			return
+		}
+		b.loadAt(offset, offset)
+		if len(b.window) == 0 {
+			b.status = "No messages at or after " + t.Format(time.RFC3339)
+		}
+	})
+}
+
+func (b *browser) choosePartition(input string) {
+	id, err := strconv.Atoi(strings.TrimSpace(input))
+	if err != nil {
+		b.status = "Invalid partition: " + input
+		return
+	}
+	for i, partition := range b.topic.Partitions {
+		if int(partition.Partition) == id {
+			b.partition = i
+			b.clearSearch()
+			b.load("Loading", b.loadLast)
+			return
+		}
+	}
+	b.status = fmt.Sprintf("Topic %s has no partition %d", b.topic.Name, id)
+}
+
+func (b *browser) clearSearch() {
+	b.search = ""
+	b.matches = nil
+	b.matchIndex = 0
+}
+
+// setSearch searches the loaded window and moves to the first match after the cursor
+func (b *browser) setSearch(input string) {
+	b.search = input
+	b.findMatches()
+	if b.search == "" {
+		return
+	}
+	if len(b.matches) == 0 {
+		b.status = fmt.Sprintf("No matches for %q in the loaded window", b.search)
+		return
+	}
+	b.matchIndex = 0
+	for i, index := range b.matches {
+		if index >= b.cursor {
+			b.matchIndex = i
+			break
+		}
+	}
+	b.cursor = b.matches[b.matchIndex]
+	b.status = fmt.Sprintf("Match %d of %d", b.matchIndex+1, len(b.matches))
+}
+
+func (b *browser) findMatches() {
+	b.matches = nil
+	if b.search == "" {
+		return
+	}
+	needle := strings.ToLower(b.search)
+	for i, m := range b.window {
+		if messageContains(m, needle) {
+			b.matches = append(b.matches, i)
+		}
+	}
+}
+
+// messageContains reports whether the key, headers or rendered value contain needle (lower case)
+func messageContains(m *message, needle string) bool {
+	if strings.Contains(strings.ToLower(string(m.Key)), needle) {
+		return true
+	}
+	for _, header := range m.Headers {
+		if strings.Contains(strings.ToLower(string(header.Key)+": "+string(header.Value)), needle) {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(m.Value.Preview), needle)
+}
+
+func (b *browser) nextMatch(delta int) {
+	if len(b.matches) == 0 {
+		if b.search == "" {
+			b.status = "No search; press / to search the loaded window"
+		} else {
+			b.status = fmt.Sprintf("No matches for %q", b.search)
+		}
+		return
+	}
+	b.matchIndex = ((b.matchIndex+delta)%len(b.matches) + len(b.matches)) % len(b.matches)
+	b.cursor = b.matches[b.matchIndex]
+	b.status = fmt.Sprintf("Match %d of %d", b.matchIndex+1, len(b.matches))
+	if b.view == viewDetail {
+		b.openDetail()
+	}
+}
+
+func (b *browser) openDetail() {
+	if b.cursor >= len(b.window) {
+		return
+	}
+	m := b.window[b.cursor]
+	b.view = viewDetail
+	b.detailScroll = 0
+
+	lines := []string{
+		fmt.Sprintf("Topic:     %s", m.Topic),
+		fmt.Sprintf("Partition: %d", m.Partition),
+		fmt.Sprintf("Offset:    %d", m.Offset),
+		fmt.Sprintf("Timestamp: %s", formatTimestamp(m.Timestamp)),
+	}
+	if m.Key == nil {
+		lines = append(lines, "Key:       (null)")
+	} else {
+		lines = append(lines, "Key:       "+string(m.Key))
+	}
+	if len(m.Headers) > 0 {
+		lines = append(lines, "Headers:")
+		for _, header := range m.Headers {
+			lines = append(lines, fmt.Sprintf("  %s: %s", header.Key, header.Value))
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Value (%s, %d bytes):", m.Value.Kind, len(m.ConsumerMessage.Value)))
+	for _, line := range m.Value.Lines {
+		lines = append(lines, "  "+line)
+	}
+	b.detail = lines
+}
+
+// handleKey updates the state for a key press
+func (b *browser) handleKey(k key) {
+	if k.Name == "ctrl-c" {
+		b.quit = true
+		return
+	}
+	if b.prompt != nil {
+		b.handlePromptKey(k)
+		return
+	}
+	b.status = ""
+
+	switch b.view {
+	case viewTopics:
+		b.handleTopicsKey(k)
+	case viewMessages:
+		b.handleMessagesKey(k)
+	case viewDetail:
+		b.handleDetailKey(k)
+	}
+}
+
+func (b *browser) handlePromptKey(k key) {
+	switch k.Name {
+	case "esc":
+		b.prompt = nil
+	case "enter":
+		p := b.prompt
+		b.prompt = nil
+		p.Submit(p.Input)
+	case "backspace":
+		if input := []rune(b.prompt.Input); len(input) > 0 {
+			b.prompt.Input = string(input[:len(input)-1])
+		}
+	case "":
+		b.prompt.Input += string(k.Rune)
+	}
+}
+
+func (b *browser) ask(label, initial string, submit func(string)) {
+	b.prompt = &prompt{Label: label, Input: initial, Submit: submit}
+}
+
+// move changes a cursor by delta within count items
+func move(cursor, delta, count int) int {
+	cursor += delta
+	if cursor >= count {
+		cursor = count - 1
+	}
+	if cursor < 0 {
+		cursor = 0
+	}
+	return cursor
+}
+
+func (b *browser) handleTopicsKey(k key) {
+	visible := b.visibleTopics()
+	rows := b.topicRows()
+
+	switch {
+	case k.Name == "up" || k.Rune == 'k':
+		b.topicCursor = move(b.topicCursor, -1, len(visible))
+	case k.Name == "down" || k.Rune == 'j':
+		b.topicCursor = move(b.topicCursor, 1, len(visible))
+	case k.Name == "pgup":
+		b.topicCursor = move(b.topicCursor, -rows, len(visible))
+	case k.Name == "pgdn":
+		b.topicCursor = move(b.topicCursor, rows, len(visible))
+	case k.Name == "home" || k.Rune == 'g':
+		b.topicCursor = 0
+	case k.Name == "end" || k.Rune == 'G':
+		b.topicCursor = move(len(visible), -1, len(visible))
+	case k.Name == "enter" || k.Name == "right" || k.Rune == 'l':
+		if b.topicCursor < len(visible) {
+			b.openTopic(visible[b.topicCursor])
+		}
+	case k.Rune == '/':
+		b.ask("Filter topics: ", b.topicFilter, func(input string) {
+			b.topicFilter = input
+			b.topicCursor = 0
+		})
+	case k.Rune == 'r':
+		b.load("Refreshing", b.refreshTopics)
+	case k.Name == "esc":
+		if b.topicFilter != "" {
+			b.topicFilter = ""
+			b.topicCursor = 0
+		}
+	case k.Rune == 'q':
+		b.quit = true
+	}
+}
+
+func (b *browser) handleMessagesKey(k key) {
+	rows := b.messageRows()
+
+	switch {
+	case k.Name == "up" || k.Rune == 'k':
+		b.cursor = move(b.cursor, -1, len(b.window))
+	case k.Name == "down" || k.Rune == 'j':
+		b.cursor = move(b.cursor, 1, len(b.window))
+	case k.Name == "pgup":
+		b.cursor = move(b.cursor, -rows, len(b.window))
+	case k.Name == "pgdn":
+		b.cursor = move(b.cursor, rows, len(b.window))
+	case k.Name == "home":
+		b.cursor = 0
+	case k.Name == "end":
+		b.cursor = move(len(b.window), -1, len(b.window))
+	case k.Name == "enter":
+		b.openDetail()
+	case k.Name == "right" || k.Rune == ' ' || k.Rune == ']':
+		b.nextPage()
+	case k.Name == "left" || k.Rune == 'b' || k.Rune == '[':
+		b.previousPage()
+	case k.Rune == 'g':
+		b.load("Loading", func() { b.loadAt(0, -1) })
+	case k.Rune == 'G':
+		b.load("Loading", b.loadLast)
+	case k.Name == "tab":
+		b.switchPartition(1)
+	case k.Name == "backtab":
+		b.switchPartition(-1)
+	case k.Rune == 'p':
+		b.ask("Partition: ", "", b.choosePartition)
+	case k.Rune == 'o':
+		b.ask("Jump to offset: ", "", b.jumpToOffset)
+	case k.Rune == 't':
+		b.ask("Jump to time: ", "", b.jumpToTime)
+	case k.Rune == '/':
+		b.ask("Search loaded window: ", b.search, b.setSearch)
+	case k.Rune == 'n':
+		b.nextMatch(1)
+	case k.Rune == 'N':
+		b.nextMatch(-1)
+	case k.Rune == 'r':
+		if len(b.window) > 0 {
+			first, focus := b.window[0].Offset, b.window[b.cursor].Offset
+			b.load("Reloading", func() { b.loadAt(first, focus) })
+		} else {
+			b.load("Reloading", b.loadLast)
+		}
+	case k.Name == "esc" || k.Rune == 'q' || k.Rune == 'h':
+		b.view = viewTopics
+		b.load("Refreshing", b.refreshTopics)
+	}
+}
+
+func (b *browser) handleDetailKey(k key) {
+	rows := b.height - 3
+	maxScroll := len(b.detail) - rows
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+
+	switch {
+	case k.Name == "up" || k.Rune == 'k':
+		b.detailScroll = move(b.detailScroll, -1, maxScroll+1)
+	case k.Name == "down" || k.Rune == 'j':
+		b.detailScroll = move(b.detailScroll, 1, maxScroll+1)
+	case k.Name == "pgup":
+		b.detailScroll = move(b.detailScroll, -rows, maxScroll+1)
+	case k.Name == "pgdn" || k.Rune == ' ':
+		b.detailScroll = move(b.detailScroll, rows, maxScroll+1)
+	case k.Name == "home" || k.Rune == 'g':
+		b.detailScroll = 0
+	case k.Name == "end" || k.Rune == 'G':
+		b.detailScroll = maxScroll
+	case k.Name == "left":
+		if b.cursor > 0 {
+			b.cursor--
+			b.openDetail()
+		} else {
+			b.status = "First message in the loaded window"
+		}
+	case k.Name == "right":
+		if b.cursor < len(b.window)-1 {
+			b.cursor++
+			b.openDetail()
+		} else {
+			b.status = "Last message in the loaded window"
+		}
+	case k.Rune == 'n':
+		b.nextMatch(1)
+	case k.Rune == 'N':
+		b.nextMatch(-1)
+	case k.Name == "esc" || k.Rune == 'q' || k.Name == "enter" || k.Rune == 'h':
+		b.view = viewMessages
+	}
+}
+
+// topicRows is the number of topic rows that fit above the partition table
+func (b *browser) topicRows() int {
+	rows := b.height - 4 - b.partitionTableRows()
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+func (b *browser) partitionTableRows() int {
+	if b.height < 16 {
+		return 0
+	}
+	return b.height / 3
+}
+
+func (b *browser) messageRows() int {
+	rows := b.height - 4
+	if rows < 1 {
+		rows = 1
+	}
+	return rows
+}
+
+// render draws the current view into exactly height lines
+func (b *browser) render() []string {
+	var lines []string
+	switch b.view {
+	case viewTopics:
+		lines = b.renderTopics()
+	case viewMessages:
+		lines = b.renderMessages()
+	case viewDetail:
+		lines = b.renderDetail()
+	}
+
+	body := b.height - 2
+	if body < 0 {
+		body = 0
+	}
+	for len(lines) < body {
+		lines = append(lines, "")
+	}
+	lines = lines[:body]
+
+	for i, line := range lines {
+		lines[i] = fit(line, b.width)
+	}
+	return append(lines, b.statusLine(), b.helpLine())
+}
+
+func (b *browser) renderTopics() []string {
+	visible := b.visibleTopics()
+	title := fmt.Sprintf("kafka browse  %s  %d topics", strings.Join(b.config.Brokers, ","), len(visible))
+	if b.topicFilter != "" {
+		title += fmt.Sprintf("  filter %q", b.topicFilter)
+	}
+	lines := []string{bold(title), fmt.Sprintf("  %-40s %10s %14s", "TOPIC", "PARTITIONS", "MESSAGES")}
+
+	rows := b.topicRows()
+	b.topicScroll = scrollFor(b.topicCursor, b.topicScroll, rows)
+	for i := b.topicScroll; i < len(visible) && i < b.topicScroll+rows; i++ {
+		topic := visible[i]
+		line := fmt.Sprintf("  %-40s %10d %14d", topic.Name, len(topic.Partitions), topic.Messages())
+		if i == b.topicCursor {
+			line = selected(fit(line, b.width))
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < rows+2 {
+		lines = append(lines, "")
+	}
+
+	if table := b.partitionTableRows(); table > 0 && b.topicCursor < len(visible) {
+		topic := visible[b.topicCursor]
+		lines = append(lines, bold(fmt.Sprintf("  %-10s %-8s %14s %14s %14s   %s", "PARTITION", "LEADER", "EARLIEST", "LATEST", "MESSAGES", topic.Name)))
+		for i, partition := range topic.Partitions {
+			if i == table-2 && len(topic.Partitions) > table-1 {
+				lines = append(lines, fmt.Sprintf("  ... %d more partitions", len(topic.Partitions)-i))
+				break
+			}
+			lines = append(lines, fmt.Sprintf("  %-10d %-8d %14d %14d %14d",
+				partition.Partition, partition.Leader, partition.Earliest, partition.Latest, partition.Latest-partition.Earliest))
+		}
+	}
+	return lines
+}
+
+func (b *browser) renderMessages() []string {
+	partition := b.currentPartition()
+	title := fmt.Sprintf("%s  partition %d (%d of %d)  offsets %d..%d",
+		b.topic.Name, partition, b.partition+1, len(b.topic.Partitions), b.earliest, b.latest)
+	if len(b.window) > 0 {
+		title += fmt.Sprintf("  window %d..%d", b.window[0].Offset, b.window[len(b.window)-1].Offset)
+	}
+	if b.search != "" {
+		title += fmt.Sprintf("  search %q: %d", b.search, len(b.matches))
+	}
+
+	lines := []string{bold(title), fmt.Sprintf("  %-10s %-19s %-16s %s", "OFFSET", "TIMESTAMP", "KEY", "VALUE")}
+
+	matched := make(map[int]bool, len(b.matches))
+	for _, index := range b.matches {
+		matched[index] = true
+	}
+
+	rows := b.messageRows()
+	b.scroll = scrollFor(b.cursor, b.scroll, rows)
+	for i := b.scroll; i < len(b.window) && i < b.scroll+rows; i++ {
+		m := b.window[i]
+		marker := " "
+		if matched[i] {
+			marker = "*"
+		}
+		key := "(null)"
+		if m.Key != nil {
+			key = string(m.Key)
+		}
+		line := fmt.Sprintf("%s %-10d %-19s %-16s %s", marker, m.Offset, formatTimestamp(m.Timestamp),
+			fit(sanitize(key), 16), sanitize(m.Value.Preview))
+		if i == b.cursor {
+			line = selected(fit(line, b.width))
+		}
+		lines = append(lines, line)
+	}
+	if len(b.window) == 0 && b.pending == nil {
+		lines = append(lines, "  (no messages loaded)")
+	}
+	return lines
+}
+
+func (b *browser) renderDetail() []string {
+	rows := b.height - 3
+	lines := []string{bold(fmt.Sprintf("%s / %d @ %d  (%d of %d in window)",
+		b.topic.Name, b.currentPartition(), b.window[b.cursor].Offset, b.cursor+1, len(b.window)))}
+	for i := b.detailScroll; i < len(b.detail) && i < b.detailScroll+rows; i++ {
+		lines = append(lines, sanitize(b.detail[i]))
+	}
+	return lines
+}
+
+func (b *browser) statusLine() string {
+	if b.prompt != nil {
+		return fit(b.prompt.Label+b.prompt.Input+"_", b.width)
+	}
+	return fit(sanitize(b.status), b.width)
+}
+
+func (b *browser) helpLine() string {
+	var help string
+	switch b.view {
+	case viewTopics:
+		help = "↑↓ move  Enter open  / filter  r refresh  q quit"
+	case viewMessages:
+		help = "↑↓ move  Enter view  ←→ page  Tab/p partition  o offset  t time  g/G ends  / search  n/N  r reload  q back"
+	case viewDetail:
+		help = "↑↓/PgUp/PgDn scroll  ←/→ prev/next message  n/N match  q back"
+	}
+	return dim(fit(help, b.width))
+}
+
+// scrollFor keeps the cursor within the visible rows
+func scrollFor(cursor, scroll, rows int) int {
+	if cursor < scroll {
+		return cursor
+	}
+	if cursor >= scroll+rows {
+		return cursor - rows + 1
+	}
+	return scroll
+}
+
+func formatTimestamp(t time.Time) string {
+	if t.IsZero() || t.Unix() <= 0 {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// sanitize replaces control characters so they cannot move the cursor
+func sanitize(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\t':
+			return ' '
+		case r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0):
+			return '·'
+		}
+		return r
+	}, text)
+}
+
+// fit truncates or pads text to width runes
+func fit(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) > width {
+		if width > 1 {
+			return string(runes[:width-1]) + "…"
+		}
+		return string(runes[:width])
+	}
+	return text
+}
+
+func bold(text string) string     { return "\033[1m" + text + "\033[0m" }
+func dim(text string) string      { return "\033[2m" + text + "\033[0m" }
+func selected(text string) string { return "\033[7m" + text + "\033[0m" }
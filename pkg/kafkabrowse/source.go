@@ -0,0 +1,143 @@
+package kafkabrowse
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/kafkaadmin"
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
+)
+
+// page is a window of messages read from one partition
+type page struct {
+	Messages []*sarama.ConsumerMessage
+	Earliest int64
+	Latest   int64
+}
+
+// source is where the browser reads topics and messages from
+type source interface {
+	Topics() ([]kafkaadmin.TopicSummary, error)
+	Fetch(topic string, partition int32, start int64, limit int) (*page, error)
+	OffsetForTime(topic string, partition int32, t time.Time) (int64, error)
+	Close() error
+}
+
+// kafkaSource reads metadata through the admin client and messages with a group-less partition consumer
+type kafkaSource struct {
+	config   Config
+	admin    *kafkaadmin.AdminClient
+	client   sarama.Client
+	consumer sarama.Consumer
+}
+
+func newKafkaSource(config Config) (*kafkaSource, error) {
+	admin, err := kafkaadmin.NewAdminClient(kafkaadmin.Config{
+		Brokers: config.Brokers,
+		Timeout: config.Timeout,
+		Auth:    config.Auth,
+		TLS:     config.TLS,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin client: %w", err)
+	}
+
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.Version = sarama.V2_6_0_0
+	saramaConfig.Consumer.Return.Errors = true
+	if err := kafkautils.ConfigureSecurity(saramaConfig, config.Auth, config.TLS); err != nil {
+		admin.Close()
+		return nil, err
+	}
+
+	client, err := sarama.NewClient(config.Brokers, saramaConfig)
+	if err != nil {
+		admin.Close()
+		return nil, fmt.Errorf("failed to connect to Kafka: %w", err)
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		client.Close()
+		admin.Close()
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	return &kafkaSource{config: config, admin: admin, client: client, consumer: consumer}, nil
+}
+
+// Topics lists all topics with their partition offsets
+func (s *kafkaSource) Topics() ([]kafkaadmin.TopicSummary, error) {
+	return s.admin.DescribeTopicOffsets(nil)
+}
+
+// Fetch reads up to limit messages from start, clamped to the partition's offset range
+func (s *kafkaSource) Fetch(topic string, partition int32, start int64, limit int) (*page, error) {
+	earliest, err := s.client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get earliest offset: %w", err)
+	}
+	latest, err := s.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest offset: %w", err)
+	}
+
+	result := &page{Earliest: earliest, Latest: latest}
+	if start < earliest {
+		start = earliest
+	}
+	if start >= latest || limit <= 0 {
+		return result, nil
+	}
+
+	pc, err := s.consumer.ConsumePartition(topic, partition, start)
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume %s/%d: %w", topic, partition, err)
+	}
+	defer pc.Close()
+
+	// Compacted topics and transaction markers leave gaps, so stop at the
+	// end offset or when nothing arrives for a while
+	idle := time.NewTimer(s.config.FetchTimeout)
+	defer idle.Stop()
+	for len(result.Messages) < limit {
+		select {
+		case message, ok := <-pc.Messages():
+			if !ok {
+				return result, nil
+			}
+			result.Messages = append(result.Messages, message)
+			if message.Offset >= latest-1 {
+				return result, nil
+			}
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(s.config.FetchTimeout)
+		case err := <-pc.Errors():
+			return nil, err
+		case <-idle.C:
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+// OffsetForTime returns the first offset at or after t, or the latest offset when there is none
+func (s *kafkaSource) OffsetForTime(topic string, partition int32, t time.Time) (int64, error) {
+	offset, err := s.client.GetOffset(topic, partition, t.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return s.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	}
+	return offset, nil
+}
+
+// Close releases the consumer and clients
+func (s *kafkaSource) Close() error {
+	s.consumer.Close()
+	s.client.Close()
+	return s.admin.Close()
+}
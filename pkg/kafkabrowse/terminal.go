@@ -0,0 +1,190 @@
+package kafkabrowse
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// key is a decoded key press; Name is set for special keys and Rune for printable ones
+type key struct {
+	Name string
+	Rune rune
+}
+
+// terminal puts the controlling terminal into raw mode on the alternate screen
+type terminal struct {
+	tty   *os.File
+	saved string
+	keys  chan key
+}
+
+func openTerminal() (*terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("kafka browse needs an interactive terminal: %w", err)
+	}
+
+	t := &terminal{tty: tty, keys: make(chan key, 16)}
+	saved, err := t.stty("-g")
+	if err != nil {
+		tty.Close()
+		return nil, fmt.Errorf("failed to read terminal settings: %w", err)
+	}
+	t.saved = strings.TrimSpace(saved)
+	if _, err := t.stty("raw", "-echo"); err != nil {
+		tty.Close()
+		return nil, fmt.Errorf("failed to set raw mode: %w", err)
+	}
+
+	// Alternate screen, hidden cursor
+	fmt.Fprint(tty, "\033[?1049h\033[?25l")
+	go t.readKeys()
+	return t, nil
+}
+
+func (t *terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.tty
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Close restores the terminal
+func (t *terminal) Close() {
+	fmt.Fprint(t.tty, "\033[?25h\033[?1049l")
+	t.stty(t.saved)
+	t.tty.Close()
+}
+
+// Size returns the terminal width and height
+func (t *terminal) Size() (int, int) {
+	out, err := t.stty("size")
+	if err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return cols, rows
+		}
+	}
+	return 80, 24
+}
+
+// Draw replaces the screen contents with the given lines
+func (t *terminal) Draw(lines []string) {
+	var buf strings.Builder
+	buf.WriteString("\033[H")
+	for i, line := range lines {
+		if i > 0 {
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString(line)
+		buf.WriteString("\033[0m\033[K")
+	}
+	buf.WriteString("\033[J")
+	t.tty.WriteString(buf.String())
+}
+
+// readKeys decodes key presses from the terminal until it is closed
+func (t *terminal) readKeys() {
+	raw := make(chan byte, 64)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := t.tty.Read(buf)
+			if err != nil {
+				close(raw)
+				return
+			}
+			for _, b := range buf[:n] {
+				raw <- b
+			}
+		}
+	}()
+
+	// next waits briefly for the rest of an escape sequence
+	next := func() (byte, bool) {
+		select {
+		case b, ok := <-raw:
+			return b, ok
+		case <-time.After(30 * time.Millisecond):
+			return 0, false
+		}
+	}
+
+	for b := range raw {
+		switch {
+		case b == 0x1b:
+			t.keys <- readEscape(next)
+		case b == '\r' || b == '\n':
+			t.keys <- key{Name: "enter"}
+		case b == '\t':
+			t.keys <- key{Name: "tab"}
+		case b == 0x7f || b == 0x08:
+			t.keys <- key{Name: "backspace"}
+		case b == 0x03:
+			t.keys <- key{Name: "ctrl-c"}
+		case b < 0x20:
+			// Other control characters are ignored
+		default:
+			buf := []byte{b}
+			for !utf8.FullRune(buf) && len(buf) < utf8.UTFMax {
+				c, ok := next()
+				if !ok {
+					break
+				}
+				buf = append(buf, c)
+			}
+			r, _ := utf8.DecodeRune(buf)
+			t.keys <- key{Rune: r}
+		}
+	}
+	close(t.keys)
+}
+
+// readEscape decodes the key for an escape sequence; a lone escape is the Esc key
+func readEscape(next func() (byte, bool)) key {
+	b, ok := next()
+	if !ok {
+		return key{Name: "esc"}
+	}
+	if b != '[' && b != 'O' {
+		return key{Name: "esc"}
+	}
+
+	var seq []byte
+	for {
+		c, ok := next()
+		if !ok {
+			return key{Name: "esc"}
+		}
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+
+	switch string(seq) {
+	case "A":
+		return key{Name: "up"}
+	case "B":
+		return key{Name: "down"}
+	case "C":
+		return key{Name: "right"}
+	case "D":
+		return key{Name: "left"}
+	case "H", "1~", "7~":
+		return key{Name: "home"}
+	case "F", "4~", "8~":
+		return key{Name: "end"}
+	case "5~":
+		return key{Name: "pgup"}
+	case "6~":
+		return key{Name: "pgdn"}
+	case "Z":
+		return key{Name: "backtab"}
+	}
+	return key{Name: "unknown"}
+}
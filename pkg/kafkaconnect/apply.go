@@ -0,0 +1,336 @@
+package kafkaconnect
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Definition is a connector declared in a JSON or YAML file
+type Definition struct {
+	Name   string
+	Config map[string]string
+	File   string
+}
+
+// Change is a difference between a deployed and a desired config key
+type Change struct {
+	Key string
+	Old string
+	New string
+	Op  string // "+" added, "-" removed, "~" changed
+}
+
+// Action is what apply does to one connector
+type Action struct {
+	Name       string
+	Op         string // create, update, unchanged, delete
+	Changes    []Change
+	Definition *Definition
+}
+
+// LoadDefinitions reads the connector definitions from a file or from every
+// .json, .yaml and .yml file in a directory
+func LoadDefinitions(path string) ([]Definition, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch strings.ToLower(filepath.Ext(entry.Name())) {
+			case ".json", ".yaml", ".yml":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no connector definitions (*.json, *.yaml, *.yml) in %s", path)
+		}
+	}
+
+	seen := make(map[string]string)
+	definitions := make([]Definition, 0, len(files))
+	for _, file := range files {
+		definition, err := ReadDefinition(file)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[definition.Name]; ok {
+			return nil, fmt.Errorf("connector %s is defined in both %s and %s", definition.Name, other, file)
+		}
+		seen[definition.Name] = file
+		definitions = append(definitions, definition)
+	}
+
+	sort.Slice(definitions, func(i, j int) bool { return definitions[i].Name < definitions[j].Name })
+	return definitions, nil
+}
+
+// ReadDefinition reads one connector definition; "-" reads stdin
+func ReadDefinition(path string) (Definition, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return Definition{}, fmt.Errorf("failed to read connector definition: %w", err)
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if path == "-" {
+		name = ""
+	}
+	definition, err := ParseDefinition(data, name)
+	if err != nil {
+		return Definition{}, fmt.Errorf("%s: %w", path, err)
+	}
+	definition.File = path
+	return definition, nil
+}
+
+// ParseDefinition parses a connector definition, either in the Connect create
+// format {"name": ..., "config": {...}} or as a flat config with a "name" key.
+// defaultName is used when the definition does not name the connector
+func ParseDefinition(data []byte, defaultName string) (Definition, error) {
+	// YAML is a superset of JSON, so one parser handles both
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return Definition{}, err
+	}
+	if len(raw) == 0 {
+		return Definition{}, fmt.Errorf("empty connector definition")
+	}
+
+	fields := raw
+	name, _ := raw["name"].(string)
+	if nested, ok := raw["config"]; ok {
+		converted, ok := toStringMap(nested)
+		if !ok {
+			return Definition{}, fmt.Errorf("config must be a mapping")
+		}
+		fields = converted
+	}
+
+	config := make(map[string]string, len(fields))
+	for key, value := range fields {
+		text, err := scalarString(value)
+		if err != nil {
+			return Definition{}, fmt.Errorf("config %s: %w", key, err)
+		}
+		config[key] = text
+	}
+
+	if name == "" {
+		name = config["name"]
+	}
+	if name == "" {
+		name = defaultName
+	}
+	if name == "" {
+		return Definition{}, fmt.Errorf("connector name is missing")
+	}
+	if configName, ok := config["name"]; ok && configName != name {
+		return Definition{}, fmt.Errorf("config name %q does not match connector name %q", configName, name)
+	}
+	if config["connector.class"] == "" {
+		return Definition{}, fmt.Errorf("connector.class is required")
+	}
+	config["name"] = name
+
+	return Definition{Name: name, Config: config}, nil
+}
+
+func toStringMap(value interface{}) (map[string]interface{}, bool) {
+	switch m := value.(type) {
+	case map[string]interface{}:
+		return m, true
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(m))
+		for key, v := range m {
+			converted[fmt.Sprint(key)] = v
+		}
+		return converted, true
+	}
+	return nil, false
+}
+
+// scalarString renders a config value the way Connect stores it
+func scalarString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		// Connect list values are comma-separated
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			text, err := scalarString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, text)
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("value must be a string, number, boolean or list")
+}
+
+// DiffConfig compares a deployed config with the desired one, sorted by key
+func DiffConfig(deployed, desired map[string]string) []Change {
+	var changes []Change
+	for key, value := range desired {
+		old, ok := deployed[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Key: key, New: value, Op: "+"})
+		case old != value:
+			changes = append(changes, Change{Key: key, Old: old, New: value, Op: "~"})
+		}
+	}
+	for key, value := range deployed {
+		if _, ok := desired[key]; !ok {
+			changes = append(changes, Change{Key: key, Old: value, Op: "-"})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// Plan works out the actions that bring the deployed connectors in line with
+// the definitions; with prune, connectors without a definition are deleted
+func (c *Client) Plan(definitions []Definition, prune bool) ([]Action, error) {
+	deployed, err := c.ListConnectors()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(deployed))
+	for _, name := range deployed {
+		exists[name] = true
+	}
+
+	var actions []Action
+	defined := make(map[string]bool, len(definitions))
+	for i := range definitions {
+		definition := &definitions[i]
+		defined[definition.Name] = true
+
+		if !exists[definition.Name] {
+			actions = append(actions, Action{
+				Name:       definition.Name,
+				Op:         "create",
+				Changes:    DiffConfig(nil, definition.Config),
+				Definition: definition,
+			})
+			continue
+		}
+
+		current, err := c.GetConnectorConfig(definition.Name)
+		if err != nil {
+			return nil, err
+		}
+		changes := DiffConfig(current, definition.Config)
+		op := "update"
+		if len(changes) == 0 {
+			op = "unchanged"
+		}
+		actions = append(actions, Action{Name: definition.Name, Op: op, Changes: changes, Definition: definition})
+	}
+
+	if prune {
+		for _, name := range deployed {
+			if !defined[name] {
+				actions = append(actions, Action{Name: name, Op: "delete"})
+			}
+		}
+	}
+
+	return actions, nil
+}
+
+// Apply carries out a single planned action
+func (c *Client) Apply(action Action) error {
+	switch action.Op {
+	case "create", "update":
+		_, err := c.PutConnectorConfig(action.Name, action.Definition.Config)
+		return err
+	case "delete":
+		return c.DeleteConnector(action.Name)
+	}
+	return nil
+}
+
+// isSecretKey reports whether a config value should not be printed
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "password") || strings.Contains(key, "secret") || strings.Contains(key, "credentials")
+}
+
+// displayValue masks secret values, leaving config provider references such as ${file:...} readable
+func displayValue(key, value string) string {
+	if isSecretKey(key) && value != "" && !strings.HasPrefix(value, "${") {
+		return "<hidden>"
+	}
+	return value
+}
+
+// printPlan writes the actions as a diff with a summary line
+func printPlan(w io.Writer, actions []Action, verbose bool) {
+	counts := make(map[string]int)
+	for _, action := range actions {
+		counts[action.Op]++
+
+		switch action.Op {
+		case "create":
+			fmt.Fprintf(w, "+ %s (create)\n", action.Name)
+		case "update":
+			fmt.Fprintf(w, "~ %s (update)\n", action.Name)
+		case "delete":
+			fmt.Fprintf(w, "- %s (delete)\n", action.Name)
+			continue
+		default:
+			if verbose {
+				fmt.Fprintf(w, "= %s (unchanged)\n", action.Name)
+			}
+			continue
+		}
+
+		for _, change := range action.Changes {
+			switch change.Op {
+			case "+":
+				fmt.Fprintf(w, "    + %s = %s\n", change.Key, displayValue(change.Key, change.New))
+			case "-":
+				fmt.Fprintf(w, "    - %s = %s\n", change.Key, displayValue(change.Key, change.Old))
+			default:
+				if displayValue(change.Key, change.New) != change.New {
+					fmt.Fprintf(w, "    ~ %s (changed)\n", change.Key)
+				} else {
+					fmt.Fprintf(w, "    ~ %s: %s -> %s\n", change.Key, change.Old, change.New)
+				}
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete, %d unchanged\n",
+		counts["create"], counts["update"], counts["delete"], counts["unchanged"])
+}
@@ -0,0 +1,325 @@
+package kafkaconnect
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/og-dim9/dimutils/pkg/restclient"
+)
+
+// Client represents a Kafka Connect REST API client
+type Client struct {
+	rest *restclient.Client
+}
+
+// AuthConfig holds authentication configuration for the Connect REST API
+type AuthConfig = restclient.AuthConfig
+
+// Config holds Kafka Connect client configuration
+type Config struct {
+	URL     string
+	Timeout time.Duration
+	Auth    *AuthConfig
+}
+
+// DefaultConfig returns default Kafka Connect configuration
+func DefaultConfig() Config {
+	return Config{
+		URL:     "http://localhost:8083",
+		Timeout: 30 * time.Second,
+	}
+}
+
+// ConnectorInfo represents a connector and its configuration
+type ConnectorInfo struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+	Tasks  []TaskID          `json:"tasks"`
+	Type   string            `json:"type,omitempty"`
+}
+
+// TaskID identifies a connector task
+type TaskID struct {
+	Connector string `json:"connector"`
+	Task      int    `json:"task"`
+}
+
+// ConnectorStatus represents the state of a connector and its tasks
+type ConnectorStatus struct {
+	Name      string       `json:"name"`
+	Connector State        `json:"connector"`
+	Tasks     []TaskStatus `json:"tasks"`
+	Type      string       `json:"type,omitempty"`
+}
+
+// State is the state of a connector on a worker
+type State struct {
+	State    string `json:"state"`
+	WorkerID string `json:"worker_id"`
+	Trace    string `json:"trace,omitempty"`
+}
+
+// TaskStatus is the state of a single task
+type TaskStatus struct {
+	ID       int    `json:"id"`
+	State    string `json:"state"`
+	WorkerID string `json:"worker_id"`
+	Trace    string `json:"trace,omitempty"`
+}
+
+// FailedTasks returns the tasks in the FAILED state
+func (s *ConnectorStatus) FailedTasks() []TaskStatus {
+	var failed []TaskStatus
+	for _, task := range s.Tasks {
+		if task.State == "FAILED" {
+			failed = append(failed, task)
+		}
+	}
+	return failed
+}
+
+// Healthy reports whether neither the connector nor any of its tasks failed
+func (s *ConnectorStatus) Healthy() bool {
+	return s.Connector.State != "FAILED" && len(s.FailedTasks()) == 0
+}
+
+// ValidationResult is the response of a connector config validation
+type ValidationResult struct {
+	Name       string             `json:"name"`
+	ErrorCount int                `json:"error_count"`
+	Groups     []string           `json:"groups"`
+	Configs    []ConfigValidation `json:"configs"`
+}
+
+// ConfigValidation holds the validation result for one config key
+type ConfigValidation struct {
+	Value ConfigValue `json:"value"`
+}
+
+// ConfigValue is the validated value of a config key
+type ConfigValue struct {
+	Name    string   `json:"name"`
+	Value   *string  `json:"value"`
+	Errors  []string `json:"errors"`
+	Visible bool     `json:"visible"`
+}
+
+// ServerInfo describes the Connect worker
+type ServerInfo struct {
+	Version        string `json:"version"`
+	Commit         string `json:"commit"`
+	KafkaClusterID string `json:"kafka_cluster_id"`
+}
+
+// APIError is an error response from the Connect REST API
+type APIError = restclient.APIError
+
+// IsNotFound reports whether err is a 404 from the Connect REST API
+func IsNotFound(err error) bool {
+	return restclient.IsNotFound(err)
+}
+
+// NewClient creates a new Kafka Connect client. Requests are not retried, as
+// creating a connector or restarting it is not safe to repeat.
+func NewClient(config Config) *Client {
+	return &Client{
+		rest: restclient.New(restclient.Config{
+			URL:     config.URL,
+			Timeout: config.Timeout,
+			Auth:    config.Auth,
+		}),
+	}
+}
+
+// ServerInfo returns the worker version, also serving as a health check
+func (c *Client) ServerInfo() (*ServerInfo, error) {
+	var info ServerInfo
+	if err := c.doJSON("GET", "/", nil, &info); err != nil {
+		return nil, fmt.Errorf("failed to get server info: %w", err)
+	}
+	return &info, nil
+}
+
+// ListConnectors returns the names of all connectors, sorted
+func (c *Client) ListConnectors() ([]string, error) {
+	var names []string
+	if err := c.doJSON("GET", "/connectors", nil, &names); err != nil {
+		return nil, fmt.Errorf("failed to list connectors: %w", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GetConnector returns a connector's configuration and tasks
+func (c *Client) GetConnector(name string) (*ConnectorInfo, error) {
+	var info ConnectorInfo
+	if err := c.doJSON("GET", connectorPath(name, ""), nil, &info); err != nil {
+		return nil, fmt.Errorf("failed to get connector %s: %w", name, err)
+	}
+	return &info, nil
+}
+
+// GetConnectorConfig returns a connector's configuration
+func (c *Client) GetConnectorConfig(name string) (map[string]string, error) {
+	var config map[string]string
+	if err := c.doJSON("GET", connectorPath(name, "/config"), nil, &config); err != nil {
+		return nil, fmt.Errorf("failed to get config of connector %s: %w", name, err)
+	}
+	return config, nil
+}
+
+// GetConnectorStatus returns the state of a connector and its tasks
+func (c *Client) GetConnectorStatus(name string) (*ConnectorStatus, error) {
+	var status ConnectorStatus
+	if err := c.doJSON("GET", connectorPath(name, "/status"), nil, &status); err != nil {
+		return nil, fmt.Errorf("failed to get status of connector %s: %w", name, err)
+	}
+	return &status, nil
+}
+
+// CreateConnector creates a connector, failing if it already exists
+func (c *Client) CreateConnector(name string, config map[string]string) (*ConnectorInfo, error) {
+	payload := map[string]interface{}{
+		"name":   name,
+		"config": config,
+	}
+
+	var info ConnectorInfo
+	if err := c.doJSON("POST", "/connectors", payload, &info); err != nil {
+		return nil, fmt.Errorf("failed to create connector %s: %w", name, err)
+	}
+	return &info, nil
+}
+
+// PutConnectorConfig creates a connector or replaces the configuration of an existing one
+func (c *Client) PutConnectorConfig(name string, config map[string]string) (*ConnectorInfo, error) {
+	var info ConnectorInfo
+	if err := c.doJSON("PUT", connectorPath(name, "/config"), config, &info); err != nil {
+		return nil, fmt.Errorf("failed to update connector %s: %w", name, err)
+	}
+	return &info, nil
+}
+
+// DeleteConnector deletes a connector
+func (c *Client) DeleteConnector(name string) error {
+	if err := c.doJSON("DELETE", connectorPath(name, ""), nil, nil); err != nil {
+		return fmt.Errorf("failed to delete connector %s: %w", name, err)
+	}
+	return nil
+}
+
+// PauseConnector pauses a connector and its tasks
+func (c *Client) PauseConnector(name string) error {
+	if err := c.doJSON("PUT", connectorPath(name, "/pause"), nil, nil); err != nil {
+		return fmt.Errorf("failed to pause connector %s: %w", name, err)
+	}
+	return nil
+}
+
+// ResumeConnector resumes a paused connector
+func (c *Client) ResumeConnector(name string) error {
+	if err := c.doJSON("PUT", connectorPath(name, "/resume"), nil, nil); err != nil {
+		return fmt.Errorf("failed to resume connector %s: %w", name, err)
+	}
+	return nil
+}
+
+// RestartConnector restarts a connector, optionally with its (failed) tasks;
+// workers older than Kafka 3.0 ignore includeTasks and onlyFailed
+func (c *Client) RestartConnector(name string, includeTasks, onlyFailed bool) error {
+	query := url.Values{}
+	if includeTasks {
+		query.Set("includeTasks", "true")
+	}
+	if onlyFailed {
+		query.Set("onlyFailed", "true")
+	}
+	path := connectorPath(name, "/restart")
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	if err := c.doJSON("POST", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to restart connector %s: %w", name, err)
+	}
+	return nil
+}
+
+// RestartTask restarts a single task of a connector
+func (c *Client) RestartTask(name string, task int) error {
+	path := connectorPath(name, fmt.Sprintf("/tasks/%d/restart", task))
+	if err := c.doJSON("POST", path, nil, nil); err != nil {
+		return fmt.Errorf("failed to restart task %d of connector %s: %w", task, name, err)
+	}
+	return nil
+}
+
+// ValidateConfig validates a connector configuration against its plugin
+func (c *Client) ValidateConfig(config map[string]string) (*ValidationResult, error) {
+	class := config["connector.class"]
+	if class == "" {
+		return nil, fmt.Errorf("connector.class is required to validate a config")
+	}
+	// The plugin is addressed by its simple class name
+	plugin := class[strings.LastIndex(class, ".")+1:]
+
+	path := fmt.Sprintf("/connector-plugins/%s/config/validate", url.PathEscape(plugin))
+	var result ValidationResult
+	if err := c.doJSON("PUT", path, config, &result); err != nil {
+		return nil, fmt.Errorf("failed to validate config: %w", err)
+	}
+	return &result, nil
+}
+
+// Errors returns the validation errors by config key
+func (r *ValidationResult) Errors() map[string][]string {
+	errs := make(map[string][]string)
+	for _, config := range r.Configs {
+		if len(config.Value.Errors) > 0 {
+			errs[config.Value.Name] = config.Value.Errors
+		}
+	}
+	return errs
+}
+
+func connectorPath(name, suffix string) string {
+	return "/connectors/" + url.PathEscape(name) + suffix
+}
+
+// doJSON sends payload as JSON and decodes a successful response into result
+func (c *Client) doJSON(method, path string, payload, result interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		body = bytes.NewReader(payloadBytes)
+	}
+
+	resp, err := c.rest.Do(context.Background(), method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return restclient.NewAPIError(resp)
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,343 @@
+package kafkaconnect
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeConnect is an in-memory Connect worker that records the requests it serves
+type fakeConnect struct {
+	mu         sync.Mutex
+	connectors map[string]map[string]string
+	states     map[string]string
+	requests   []string // "METHOD /path?query"
+	url        string
+}
+
+func newFakeConnect(t *testing.T) (*fakeConnect, *Client) {
+	t.Helper()
+	fake := &fakeConnect{
+		connectors: make(map[string]map[string]string),
+		states:     make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /connectors", func(w http.ResponseWriter, r *http.Request) {
+		names := []string{}
+		for name := range fake.connectors {
+			names = append(names, name)
+		}
+		writeJSON(w, http.StatusOK, names)
+	})
+	mux.HandleFunc("GET /connectors/{name}/config", func(w http.ResponseWriter, r *http.Request) {
+		if config, ok := fake.find(w, r); ok {
+			writeJSON(w, http.StatusOK, config)
+		}
+	})
+	mux.HandleFunc("PUT /connectors/{name}/config", func(w http.ResponseWriter, r *http.Request) {
+		var config map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{"error_code": 400, "message": err.Error()})
+			return
+		}
+		name := r.PathValue("name")
+		status := http.StatusOK
+		if _, ok := fake.connectors[name]; !ok {
+			status = http.StatusCreated
+			fake.states[name] = "RUNNING"
+		}
+		fake.connectors[name] = config
+		writeJSON(w, status, ConnectorInfo{Name: name, Config: config})
+	})
+	mux.HandleFunc("DELETE /connectors/{name}", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := fake.find(w, r); ok {
+			delete(fake.connectors, r.PathValue("name"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("GET /connectors/{name}/status", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := fake.find(w, r); ok {
+			name := r.PathValue("name")
+			writeJSON(w, http.StatusOK, ConnectorStatus{
+				Name:      name,
+				Connector: State{State: fake.states[name], WorkerID: "worker-1"},
+				Tasks: []TaskStatus{
+					{ID: 0, State: "RUNNING", WorkerID: "worker-1"},
+					{ID: 1, State: "FAILED", WorkerID: "worker-1", Trace: "boom"},
+				},
+			})
+		}
+	})
+	mux.HandleFunc("PUT /connectors/{name}/pause", func(w http.ResponseWriter, r *http.Request) {
+		fake.setState(w, r, "PAUSED")
+	})
+	mux.HandleFunc("PUT /connectors/{name}/resume", func(w http.ResponseWriter, r *http.Request) {
+		fake.setState(w, r, "RUNNING")
+	})
+	mux.HandleFunc("POST /connectors/{name}/restart", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := fake.find(w, r); ok {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("POST /connectors/{name}/tasks/{task}/restart", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := fake.find(w, r); ok {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	mux.HandleFunc("GET /broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "worker is rebalancing", http.StatusInternalServerError)
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "admin" || password != "secret" {
+			writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"error_code": 401, "message": "Unauthorized"})
+			return
+		}
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.requests = append(fake.requests, r.Method+" "+r.URL.RequestURI())
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	fake.url = server.URL
+
+	config := DefaultConfig()
+	config.URL = server.URL + "/"
+	config.Auth = &AuthConfig{Username: "admin", Password: "secret"}
+	return fake, NewClient(config)
+}
+
+func (f *fakeConnect) find(w http.ResponseWriter, r *http.Request) (map[string]string, bool) {
+	name := r.PathValue("name")
+	config, ok := f.connectors[name]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"error_code": 404, "message": "Connector " + name + " not found"})
+	}
+	return config, ok
+}
+
+func (f *fakeConnect) setState(w http.ResponseWriter, r *http.Request, state string) {
+	if _, ok := f.find(w, r); ok {
+		f.states[r.PathValue("name")] = state
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (f *fakeConnect) lastRequest() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func sinkConfig(name, topics string) map[string]string {
+	return map[string]string{"name": name, "connector.class": "io.example.Sink", "topics": topics}
+}
+
+func TestListConnectors(t *testing.T) {
+	fake, client := newFakeConnect(t)
+	fake.connectors["orders-sink"] = sinkConfig("orders-sink", "orders")
+	fake.connectors["audit-sink"] = sinkConfig("audit-sink", "audit")
+
+	names, err := client.ListConnectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "audit-sink,orders-sink" {
+		t.Fatalf("expected sorted connector names, got %v", names)
+	}
+	if got := fake.lastRequest(); got != "GET /connectors" {
+		t.Fatalf("unexpected request %q", got)
+	}
+}
+
+func TestGetConnectorStatus(t *testing.T) {
+	fake, client := newFakeConnect(t)
+	fake.connectors["orders-sink"] = sinkConfig("orders-sink", "orders")
+	fake.states["orders-sink"] = "RUNNING"
+
+	status, err := client.GetConnectorStatus("orders-sink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Connector.State != "RUNNING" || len(status.Tasks) != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if failed := status.FailedTasks(); len(failed) != 1 || failed[0].ID != 1 || failed[0].Trace != "boom" {
+		t.Fatalf("expected task 1 to have failed, got %+v", failed)
+	}
+	if status.Healthy() {
+		t.Fatal("expected a connector with a failed task to be unhealthy")
+	}
+}
+
+func TestPauseResumeRestart(t *testing.T) {
+	fake, client := newFakeConnect(t)
+	fake.connectors["orders sink"] = sinkConfig("orders sink", "orders")
+
+	if err := client.PauseConnector("orders sink"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.states["orders sink"] != "PAUSED" {
+		t.Fatalf("expected the connector to be paused, got %q", fake.states["orders sink"])
+	}
+	if err := client.ResumeConnector("orders sink"); err != nil {
+		t.Fatal(err)
+	}
+	if fake.states["orders sink"] != "RUNNING" {
+		t.Fatalf("expected the connector to be running, got %q", fake.states["orders sink"])
+	}
+
+	tests := []struct {
+		restart func() error
+		request string
+	}{
+		{func() error { return client.RestartConnector("orders sink", false, false) }, "POST /connectors/orders%20sink/restart"},
+		{func() error { return client.RestartConnector("orders sink", true, true) }, "POST /connectors/orders%20sink/restart?includeTasks=true&onlyFailed=true"},
+		{func() error { return client.RestartTask("orders sink", 2) }, "POST /connectors/orders%20sink/tasks/2/restart"},
+	}
+	for _, tt := range tests {
+		if err := tt.restart(); err != nil {
+			t.Fatal(err)
+		}
+		if got := fake.lastRequest(); got != tt.request {
+			t.Errorf("expected %q, got %q", tt.request, got)
+		}
+	}
+}
+
+func TestPlanAndApply(t *testing.T) {
+	fake, client := newFakeConnect(t)
+	fake.connectors["orders-sink"] = sinkConfig("orders-sink", "orders")
+	fake.connectors["audit-sink"] = sinkConfig("audit-sink", "audit")
+	fake.connectors["legacy-sink"] = sinkConfig("legacy-sink", "legacy")
+
+	definitions := []Definition{
+		{Name: "audit-sink", Config: sinkConfig("audit-sink", "audit")},
+		{Name: "orders-sink", Config: sinkConfig("orders-sink", "orders,returns")},
+		{Name: "users-sink", Config: sinkConfig("users-sink", "users")},
+	}
+	actions, err := client.Plan(definitions, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := make(map[string]string)
+	for _, action := range actions {
+		ops[action.Name] = action.Op
+	}
+	want := map[string]string{"audit-sink": "unchanged", "orders-sink": "update", "users-sink": "create", "legacy-sink": "delete"}
+	for name, op := range want {
+		if ops[name] != op {
+			t.Errorf("expected %s to be %s, got %q", name, op, ops[name])
+		}
+	}
+
+	for _, action := range actions {
+		if err := client.Apply(action); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var names []string
+	for name := range fake.connectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "audit-sink,orders-sink,users-sink" {
+		t.Fatalf("unexpected connectors after apply: %v", names)
+	}
+	if fake.connectors["orders-sink"]["topics"] != "orders,returns" {
+		t.Fatalf("expected orders-sink to be updated, got %v", fake.connectors["orders-sink"])
+	}
+
+	// A second plan has nothing left to do
+	actions, err = client.Plan(definitions, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range actions {
+		if action.Op != "unchanged" {
+			t.Errorf("expected %s to be unchanged, got %s", action.Name, action.Op)
+		}
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	fake, client := newFakeConnect(t)
+
+	_, err := client.GetConnectorStatus("missing")
+	if !IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "Connector missing not found") {
+		t.Fatalf("expected the worker's message, got %v", err)
+	}
+
+	err = client.doJSON("GET", "/broken", nil, nil)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusInternalServerError || apiErr.Message != "worker is rebalancing" {
+		t.Fatalf("expected a 500 with the plain text body, got %#v", err)
+	}
+
+	unauthenticated := NewClient(Config{URL: fake.url})
+	if _, err := unauthenticated.ListConnectors(); err == nil || !strings.Contains(err.Error(), "HTTP 401: Unauthorized") {
+		t.Fatalf("expected a 401, got %v", err)
+	}
+}
+
+func TestParseArgsContextForms(t *testing.T) {
+	for _, args := range [][]string{
+		{"list", "--context", "prod"},
+		{"list", "--context=prod"},
+	} {
+		var opts options
+		if err := parseArgs(args, &opts); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if strings.Join(opts.Args, " ") != "list" {
+			t.Errorf("%v: expected only the subcommand as an argument, got %v", args, opts.Args)
+		}
+	}
+}
+
+func TestApplyCommand(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir()) // no kafka contexts
+	fake, _ := newFakeConnect(t)
+	fake.connectors["legacy-sink"] = sinkConfig("legacy-sink", "legacy")
+
+	dir := t.TempDir()
+	definition := "name: orders-sink\nconfig:\n  connector.class: io.example.Sink\n  topics: [orders, returns]\n"
+	if err := os.WriteFile(filepath.Join(dir, "orders-sink.yaml"), []byte(definition), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"apply", dir, "--prune", "--url", fake.url, "--username", "admin", "--password", "secret"}
+
+	if err := Run(append(args, "--dry-run")); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.connectors["orders-sink"]; ok || len(fake.connectors) != 1 {
+		t.Fatalf("expected a dry run to change nothing, got %v", fake.connectors)
+	}
+
+	if err := Run(args); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fake.connectors["legacy-sink"]; ok {
+		t.Fatal("expected --prune to delete legacy-sink")
+	}
+	if got := fake.connectors["orders-sink"]["topics"]; got != "orders,returns" {
+		t.Fatalf("expected orders-sink to be created with its topics, got %q", got)
+	}
+}
@@ -0,0 +1,785 @@
+package kafkaconnect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+)
+
+// options holds the command line settings for the connect subcommands
+type options struct {
+	Client       Config
+	Output       string // table, json
+	Verbose      bool
+	Watch        bool
+	Interval     time.Duration
+	IncludeTasks bool
+	OnlyFailed   bool
+	Task         int
+	DryRun       bool
+	Prune        bool
+	Validate     bool
+	Args         []string // positional arguments after the subcommand
+}
+
+// Run is the main entry point for the kafka connect subcommand
+func Run(args []string) error {
+	if len(args) == 0 || args[0] == "help" {
+		return printHelp()
+	}
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			return printHelp()
+		}
+	}
+
+	opts := options{Client: DefaultConfig(), Output: "table", Interval: 5 * time.Second, Task: -1}
+	if err := applyContext(args, &opts.Client); err != nil {
+		return err
+	}
+	if err := parseArgs(args, &opts); err != nil {
+		return err
+	}
+	if len(opts.Args) == 0 {
+		return printHelp()
+	}
+	if opts.Output != "table" && opts.Output != "json" {
+		return fmt.Errorf("invalid output format %q: use table or json", opts.Output)
+	}
+
+	// Global options may come before or after the subcommand
+	subcommand := opts.Args[0]
+	opts.Args = opts.Args[1:]
+
+	client := NewClient(opts.Client)
+
+	switch subcommand {
+	case "list", "ls":
+		return listConnectors(client, opts)
+	case "status":
+		return showStatus(client, opts)
+	case "get":
+		return getConnector(client, opts)
+	case "create":
+		return createConnector(client, opts)
+	case "update":
+		return updateConnector(client, opts)
+	case "delete", "rm":
+		return forEachConnector(opts, "Deleted", client.DeleteConnector)
+	case "pause":
+		return forEachConnector(opts, "Paused", client.PauseConnector)
+	case "resume":
+		return forEachConnector(opts, "Resumed", client.ResumeConnector)
+	case "restart":
+		return restartConnector(client, opts)
+	case "validate":
+		return validateConnector(client, opts)
+	case "apply":
+		return applyDefinitions(client, opts)
+	case "info":
+		return showInfo(client, opts)
+	default:
+		return fmt.Errorf("unknown connect subcommand: %s. Use 'kafka connect help' to see available commands", subcommand)
+	}
+}
+
+// applyContext loads the Connect URL and credentials from the active kafka context
+func applyContext(args []string, config *Config) error {
+	kctx, err := kafkacontext.FromArgs(args)
+	if err != nil || kctx == nil || kctx.Connect == nil {
+		return err
+	}
+
+	if kctx.Connect.URL != "" {
+		config.URL = kctx.Connect.URL
+	}
+	if kctx.Connect.Username != "" {
+		password, err := kctx.Connect.Password.Resolve()
+		if err != nil {
+			return fmt.Errorf("context %s: connect password: %w", kctx.Name, err)
+		}
+		config.Auth = &AuthConfig{Username: kctx.Connect.Username, Password: password}
+	}
+
+	return nil
+}
+
+func parseArgs(args []string, opts *options) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--url", "-u":
+			if i+1 < len(args) {
+				opts.Client.URL = args[i+1]
+				i++
+			}
+		case "--username":
+			if i+1 < len(args) {
+				if opts.Client.Auth == nil {
+					opts.Client.Auth = &AuthConfig{}
+				}
+				opts.Client.Auth.Username = args[i+1]
+				i++
+			}
+		case "--password":
+			if i+1 < len(args) {
+				if opts.Client.Auth == nil {
+					opts.Client.Auth = &AuthConfig{}
+				}
+				opts.Client.Auth.Password = args[i+1]
+				i++
+			}
+		case "--timeout":
+			if i+1 < len(args) {
+				duration, err := time.ParseDuration(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid timeout: %w", err)
+				}
+				opts.Client.Timeout = duration
+				i++
+			}
+		case "--output", "-o":
+			if i+1 < len(args) {
+				opts.Output = args[i+1]
+				i++
+			}
+		case "--watch", "-w":
+			opts.Watch = true
+		case "--interval":
+			if i+1 < len(args) {
+				duration, err := time.ParseDuration(args[i+1])
+				if err != nil || duration <= 0 {
+					return fmt.Errorf("invalid interval: %s", args[i+1])
+				}
+				opts.Interval = duration
+				i++
+			}
+		case "--include-tasks":
+			opts.IncludeTasks = true
+		case "--only-failed":
+			opts.OnlyFailed = true
+		case "--task":
+			if i+1 < len(args) {
+				task, err := strconv.Atoi(args[i+1])
+				if err != nil || task < 0 {
+					return fmt.Errorf("invalid task id: %s", args[i+1])
+				}
+				opts.Task = task
+				i++
+			}
+		case "--dry-run":
+			opts.DryRun = true
+		case "--prune":
+			opts.Prune = true
+		case "--validate":
+			opts.Validate = true
+		case "--context":
+			// Handled by applyContext
+			i++
+		case "--verbose", "-v":
+			opts.Verbose = true
+		default:
+			if kafkacontext.IsInlineFlag(arg) {
+				// Handled by applyContext
+				continue
+			}
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			opts.Args = append(opts.Args, arg)
+		}
+	}
+	return nil
+}
+
+func printHelp() error {
+	help := `Usage: kafka connect <subcommand> [options]
+
+Manage Kafka Connect connectors through the Connect REST API.
+
+Global Options:
+  --context NAME            Kafka context from ~/.config/dimutils/kafka.yaml (uses its connect: section)
+  --url, -u URL             Connect REST URL (default: http://localhost:8083)
+  --username USER           Basic auth username
+  --password PASS           Basic auth password
+  --timeout DURATION        Request timeout (default: 30s)
+  --output, -o FORMAT       Output format: table, json (default: table)
+  --verbose, -v             Verbose output
+
+Subcommands:
+  list, ls                  List connectors with their state
+  status [NAME...]          Show connector and task states (default: all connectors);
+                            exits non-zero when a connector or task has FAILED
+    --watch, -w             Refresh until interrupted, reporting tasks that fail
+    --interval DURATION     Refresh interval (default: 5s)
+  get NAME                  Show a connector's config and tasks
+  create FILE               Create a connector from a JSON/YAML definition ("-" for stdin)
+  update FILE               Replace the config of an existing connector
+  delete, rm NAME...        Delete connectors
+  pause NAME...             Pause connectors
+  resume NAME...            Resume paused connectors
+  restart NAME              Restart a connector
+    --include-tasks         Also restart its tasks (Kafka 3.0+)
+    --only-failed           Only restart failed instances (Kafka 3.0+)
+    --task ID               Restart a single task instead
+  validate FILE             Validate a definition against its connector plugin
+  apply PATH                Create or update connectors from a definition file or
+                            directory of *.json/*.yaml/*.yml files, showing a diff
+    --dry-run               Only show what would change
+    --prune                 Delete deployed connectors that have no definition
+    --validate              Validate changed definitions before applying
+  info                      Show the Connect worker version
+
+Definitions use the Connect create format or a flat config; the file name is
+used when no name is given:
+
+  name: orders-sink
+  config:
+    connector.class: io.confluent.connect.jdbc.JdbcSinkConnector
+    topics: orders
+    tasks.max: 2
+    connection.password: ${file:/etc/kafka/secrets.properties:db.password}
+
+Values that look like passwords or secrets are hidden in diffs.
+
+Examples:
+  kafka connect list
+  kafka connect status --watch
+  kafka connect get orders-sink -o json
+  kafka connect apply connectors/ --dry-run
+  kafka connect apply connectors/ --prune --validate
+  kafka connect restart orders-sink --include-tasks --only-failed`
+
+	fmt.Println(help)
+	return nil
+}
+
+func printJSON(value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// requireArgs checks the number of positional arguments
+func requireArgs(opts options, usage string, min int) error {
+	if len(opts.Args) < min {
+		return fmt.Errorf("usage: kafka connect %s", usage)
+	}
+	return nil
+}
+
+func showInfo(client *Client, opts options) error {
+	info, err := client.ServerInfo()
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		return printJSON(info)
+	}
+	fmt.Printf("URL:        %s\n", opts.Client.URL)
+	fmt.Printf("Version:    %s\n", info.Version)
+	fmt.Printf("Commit:     %s\n", info.Commit)
+	fmt.Printf("Cluster ID: %s\n", info.KafkaClusterID)
+	return nil
+}
+
+func listConnectors(client *Client, opts options) error {
+	names, err := client.ListConnectors()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := fetchStatuses(client, names)
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		return printJSON(statuses)
+	}
+	if len(statuses) == 0 {
+		fmt.Fprintln(os.Stderr, "No connectors")
+		return nil
+	}
+
+	width := nameWidth(statuses)
+	fmt.Printf("%-*s %-8s %-12s %s\n", width, "CONNECTOR", "TYPE", "STATE", "TASKS")
+	for _, status := range statuses {
+		fmt.Printf("%-*s %-8s %-12s %s\n", width, status.Name, status.Type, status.Connector.State, taskSummary(status))
+	}
+	return nil
+}
+
+// fetchStatuses gets the status of each named connector, skipping connectors deleted meanwhile
+func fetchStatuses(client *Client, names []string) ([]*ConnectorStatus, error) {
+	statuses := make([]*ConnectorStatus, 0, len(names))
+	for _, name := range names {
+		status, err := client.GetConnectorStatus(name)
+		if err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func nameWidth(statuses []*ConnectorStatus) int {
+	width := len("CONNECTOR")
+	for _, status := range statuses {
+		if len(status.Name) > width {
+			width = len(status.Name)
+		}
+	}
+	return width
+}
+
+// taskSummary describes task states, e.g. "2/3 running, 1 failed"
+func taskSummary(status *ConnectorStatus) string {
+	counts := make(map[string]int)
+	for _, task := range status.Tasks {
+		counts[task.State]++
+	}
+	summary := fmt.Sprintf("%d/%d running", counts["RUNNING"], len(status.Tasks))
+	states := make([]string, 0, len(counts))
+	for state := range counts {
+		if state != "RUNNING" {
+			states = append(states, state)
+		}
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		summary += fmt.Sprintf(", %d %s", counts[state], strings.ToLower(state))
+	}
+	return summary
+}
+
+func showStatus(client *Client, opts options) error {
+	if !opts.Watch {
+		statuses, err := loadStatuses(client, opts)
+		if err != nil {
+			return err
+		}
+		if opts.Output == "json" {
+			if err := printJSON(statuses); err != nil {
+				return err
+			}
+		} else {
+			printStatuses(statuses, opts.Verbose)
+		}
+		return unhealthyError(statuses)
+	}
+	return watchStatus(client, opts)
+}
+
+// loadStatuses returns the status of the named connectors, or of all connectors
+func loadStatuses(client *Client, opts options) ([]*ConnectorStatus, error) {
+	if len(opts.Args) > 0 {
+		statuses := make([]*ConnectorStatus, 0, len(opts.Args))
+		for _, name := range opts.Args {
+			status, err := client.GetConnectorStatus(name)
+			if err != nil {
+				return nil, err
+			}
+			statuses = append(statuses, status)
+		}
+		return statuses, nil
+	}
+
+	names, err := client.ListConnectors()
+	if err != nil {
+		return nil, err
+	}
+	return fetchStatuses(client, names)
+}
+
+func unhealthyError(statuses []*ConnectorStatus) error {
+	unhealthy := 0
+	for _, status := range statuses {
+		if !status.Healthy() {
+			unhealthy++
+		}
+	}
+	if unhealthy > 0 {
+		return fmt.Errorf("%d connector(s) have failed", unhealthy)
+	}
+	return nil
+}
+
+// printStatuses writes the connector and task states, flagging failures with "!"
+func printStatuses(statuses []*ConnectorStatus, verbose bool) {
+	if len(statuses) == 0 {
+		fmt.Println("No connectors")
+		return
+	}
+
+	width := nameWidth(statuses)
+	fmt.Printf("  %-*s %-12s %-22s %s\n", width, "CONNECTOR", "STATE", "WORKER", "TASKS")
+	for _, status := range statuses {
+		flag := " "
+		if !status.Healthy() {
+			flag = "!"
+		}
+		fmt.Printf("%s %-*s %-12s %-22s %s\n", flag, width, status.Name, status.Connector.State, status.Connector.WorkerID, taskSummary(status))
+		if status.Connector.State == "FAILED" && status.Connector.Trace != "" {
+			fmt.Printf("    %s\n", traceSummary(status.Connector.Trace, verbose))
+		}
+
+		for _, task := range status.Tasks {
+			if task.State == "RUNNING" && !verbose {
+				continue
+			}
+			flag := " "
+			if task.State == "FAILED" {
+				flag = "!"
+			}
+			fmt.Printf("%s   task %-*d %-12s %s\n", flag, width-7, task.ID, task.State, task.WorkerID)
+			if task.Trace != "" {
+				fmt.Printf("    %s\n", traceSummary(task.Trace, verbose))
+			}
+		}
+	}
+}
+
+// traceSummary returns the first line of a stack trace unless verbose
+func traceSummary(trace string, verbose bool) string {
+	trace = strings.TrimSpace(trace)
+	if verbose {
+		return strings.ReplaceAll(trace, "\n", "\n    ")
+	}
+	if idx := strings.IndexByte(trace, '\n'); idx >= 0 {
+		return trace[:idx]
+	}
+	return trace
+}
+
+// watchStatus refreshes the status until interrupted and reports tasks that newly fail on stderr
+func watchStatus(client *Client, opts options) error {
+	interactive := false
+	if info, err := os.Stdout.Stat(); err == nil {
+		interactive = info.Mode()&os.ModeCharDevice != 0
+	}
+
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigterm)
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	failed := make(map[string]bool)
+	for {
+		statuses, err := loadStatuses(client, opts)
+		if interactive {
+			fmt.Print("\033[H\033[2J")
+		}
+		fmt.Printf("%s  every %s  %s\n", time.Now().Format("15:04:05"), opts.Interval, opts.Client.URL)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		} else if opts.Output == "json" {
+			if err := printJSON(statuses); err != nil {
+				return err
+			}
+		} else {
+			printStatuses(statuses, opts.Verbose)
+		}
+		if err == nil {
+			reportFailures(statuses, failed)
+		}
+		if !interactive {
+			fmt.Println()
+		}
+
+		select {
+		case <-sigterm:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportFailures logs connectors and tasks that moved into or out of FAILED since the last refresh
+func reportFailures(statuses []*ConnectorStatus, failed map[string]bool) {
+	current := make(map[string]bool)
+	for _, status := range statuses {
+		if status.Connector.State == "FAILED" {
+			current[status.Name] = true
+		}
+		for _, task := range status.FailedTasks() {
+			current[fmt.Sprintf("%s task %d", status.Name, task.ID)] = true
+		}
+	}
+
+	var changes []string
+	for name := range current {
+		if !failed[name] {
+			changes = append(changes, "FAILED: "+name)
+		}
+	}
+	for name := range failed {
+		if !current[name] {
+			changes = append(changes, "RECOVERED: "+name)
+		}
+	}
+	sort.Strings(changes)
+	for _, change := range changes {
+		fmt.Fprintf(os.Stderr, "%s %s\n", time.Now().Format("15:04:05"), change)
+	}
+
+	for name := range failed {
+		delete(failed, name)
+	}
+	for name := range current {
+		failed[name] = true
+	}
+}
+
+func getConnector(client *Client, opts options) error {
+	if err := requireArgs(opts, "get NAME", 1); err != nil {
+		return err
+	}
+
+	info, err := client.GetConnector(opts.Args[0])
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		return printJSON(info)
+	}
+
+	fmt.Printf("Connector: %s\n", info.Name)
+	if info.Type != "" {
+		fmt.Printf("Type:      %s\n", info.Type)
+	}
+	fmt.Printf("Tasks:     %d\n", len(info.Tasks))
+	fmt.Println("\nConfig:")
+	keys := make([]string, 0, len(info.Config))
+	for key := range info.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("  %s = %s\n", key, info.Config[key])
+	}
+	return nil
+}
+
+func createConnector(client *Client, opts options) error {
+	if err := requireArgs(opts, "create FILE", 1); err != nil {
+		return err
+	}
+
+	definition, err := ReadDefinition(opts.Args[0])
+	if err != nil {
+		return err
+	}
+	info, err := client.CreateConnector(definition.Name, definition.Config)
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		return printJSON(info)
+	}
+	fmt.Printf("Created connector %s\n", info.Name)
+	return nil
+}
+
+func updateConnector(client *Client, opts options) error {
+	if err := requireArgs(opts, "update FILE", 1); err != nil {
+		return err
+	}
+
+	definition, err := ReadDefinition(opts.Args[0])
+	if err != nil {
+		return err
+	}
+	current, err := client.GetConnectorConfig(definition.Name)
+	if err != nil {
+		if IsNotFound(err) {
+			return fmt.Errorf("connector %s does not exist; use create", definition.Name)
+		}
+		return err
+	}
+
+	changes := DiffConfig(current, definition.Config)
+	if len(changes) == 0 {
+		fmt.Printf("Connector %s is unchanged\n", definition.Name)
+		return nil
+	}
+
+	info, err := client.PutConnectorConfig(definition.Name, definition.Config)
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		return printJSON(info)
+	}
+	printPlan(os.Stdout, []Action{{Name: definition.Name, Op: "update", Changes: changes}}, false)
+	fmt.Printf("Updated connector %s\n", info.Name)
+	return nil
+}
+
+// forEachConnector runs an action for every named connector, continuing past failures
+func forEachConnector(opts options, done string, action func(name string) error) error {
+	if len(opts.Args) == 0 {
+		return fmt.Errorf("at least one connector name is required")
+	}
+
+	failures := 0
+	for _, name := range opts.Args {
+		if err := action(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failures++
+			continue
+		}
+		fmt.Printf("%s connector %s\n", done, name)
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d of %d connector(s) failed", failures, len(opts.Args))
+	}
+	return nil
+}
+
+func restartConnector(client *Client, opts options) error {
+	if err := requireArgs(opts, "restart NAME [--include-tasks] [--only-failed] [--task ID]", 1); err != nil {
+		return err
+	}
+	name := opts.Args[0]
+
+	if opts.Task >= 0 {
+		if err := client.RestartTask(name, opts.Task); err != nil {
+			return err
+		}
+		fmt.Printf("Restarted task %d of connector %s\n", opts.Task, name)
+		return nil
+	}
+
+	if err := client.RestartConnector(name, opts.IncludeTasks, opts.OnlyFailed); err != nil {
+		return err
+	}
+	fmt.Printf("Restarted connector %s\n", name)
+	return nil
+}
+
+func validateConnector(client *Client, opts options) error {
+	if err := requireArgs(opts, "validate FILE", 1); err != nil {
+		return err
+	}
+
+	definition, err := ReadDefinition(opts.Args[0])
+	if err != nil {
+		return err
+	}
+	result, err := client.ValidateConfig(definition.Config)
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		printValidation(definition.Name, result)
+	}
+	if result.ErrorCount > 0 {
+		return fmt.Errorf("connector %s has %d config error(s)", definition.Name, result.ErrorCount)
+	}
+	return nil
+}
+
+func printValidation(name string, result *ValidationResult) {
+	if result.ErrorCount == 0 {
+		fmt.Printf("Connector %s: config is valid\n", name)
+		return
+	}
+
+	fmt.Printf("Connector %s: %d config error(s)\n", name, result.ErrorCount)
+	errs := result.Errors()
+	keys := make([]string, 0, len(errs))
+	for key := range errs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, message := range errs[key] {
+			fmt.Printf("  %s: %s\n", key, message)
+		}
+	}
+}
+
+func applyDefinitions(client *Client, opts options) error {
+	if err := requireArgs(opts, "apply PATH [--dry-run] [--prune] [--validate]", 1); err != nil {
+		return err
+	}
+
+	definitions, err := LoadDefinitions(opts.Args[0])
+	if err != nil {
+		return err
+	}
+	actions, err := client.Plan(definitions, opts.Prune)
+	if err != nil {
+		return err
+	}
+
+	printPlan(os.Stdout, actions, opts.Verbose)
+	if opts.DryRun {
+		return nil
+	}
+
+	if opts.Validate {
+		invalid := 0
+		for _, action := range actions {
+			if action.Op != "create" && action.Op != "update" {
+				continue
+			}
+			result, err := client.ValidateConfig(action.Definition.Config)
+			if err != nil {
+				return err
+			}
+			if result.ErrorCount > 0 {
+				printValidation(action.Name, result)
+				invalid++
+			}
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%d connector definition(s) are invalid; nothing was applied", invalid)
+		}
+	}
+
+	failures := 0
+	for _, action := range actions {
+		if action.Op == "unchanged" {
+			continue
+		}
+		if err := client.Apply(action); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failures++
+			continue
+		}
+		switch action.Op {
+		case "create":
+			fmt.Printf("Created connector %s\n", action.Name)
+		case "update":
+			fmt.Printf("Updated connector %s\n", action.Name)
+		case "delete":
+			fmt.Printf("Deleted connector %s\n", action.Name)
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("%d connector change(s) failed", failures)
+	}
+	return nil
+}
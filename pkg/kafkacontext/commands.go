@@ -0,0 +1,258 @@
+package kafkacontext
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Run is the main entry point for the kafka context subcommand
+func Run(args []string) error {
+	if len(args) == 0 {
+		return printHelp()
+	}
+
+	subcommand := args[0]
+	subArgs := args[1:]
+
+	switch subcommand {
+	case "list", "ls":
+		return listContexts()
+	case "use":
+		if len(subArgs) == 0 {
+			return fmt.Errorf("context name is required")
+		}
+		return useContext(subArgs[0])
+	case "show":
+		name := ""
+		if len(subArgs) > 0 {
+			name = subArgs[0]
+		}
+		return showContext(name)
+	case "help", "-h", "--help":
+		return printHelp()
+	default:
+		return fmt.Errorf("unknown context subcommand: %s. Use 'kafka context help' to see available commands", subcommand)
+	}
+}
+
+func printHelp() error {
+	help := `Usage: kafka context <subcommand> [options]
+
+Manage named Kafka connection profiles stored in ~/.config/dimutils/kafka.yaml.
+
+Subcommands:
+  list, ls          List configured contexts (* marks the active one)
+  use NAME          Set the current context
+  show [NAME]       Show a context (default: the active one)
+  help              Show this help message
+
+Every kafka subcommand accepts --context NAME. When it is not given,
+DIMUTILS_KAFKA_CONTEXT is used, then current-context from the file.
+Explicit flags such as --brokers always override context settings.
+
+Secrets can be given inline or referenced from a file or environment variable:
+
+  current-context: dev
+  contexts:
+    - name: dev
+      brokers: [localhost:9092]
+    - name: prod
+      brokers: [kafka-1:9093, kafka-2:9093]
+      consumer-group: ops-tools
+      sasl:
+        mechanism: SCRAM-SHA-512
+        username: ops
+        password: {env: KAFKA_PROD_PASSWORD}
+      tls:
+        enabled: true
+        ca-file: ~/.config/dimutils/prod-ca.pem
+      schema-registry:
+        url: https://registry.prod:8081
+        username: ops
+        password: {file: ~/.config/dimutils/registry-password}
+      connect:
+        url: https://connect.prod:8083
+        username: ops
+        password: {env: KAFKA_CONNECT_PASSWORD}
+    - name: cloud
+      brokers: [broker.cloud.example:9093]
+      sasl:
+        mechanism: OAUTHBEARER
+        oauth:
+          token-url: https://login.example.com/oauth2/token
+          client-id: dimutils
+          client-secret: {env: KAFKA_CLIENT_SECRET}
+          scopes: [kafka]
+      tls:
+        enabled: true
+      schema-registry:
+        url: https://registry.cloud.example
+        token: {env: REGISTRY_TOKEN}
+        tls:
+          cert-file: ~/.config/dimutils/client.pem
+          key-file: ~/.config/dimutils/client-key.pem
+    - name: corp
+      brokers: [kafka.corp.example:9092]
+      sasl:
+        mechanism: GSSAPI
+        username: svc-dimutils
+        kerberos:
+          realm: CORP.EXAMPLE
+          keytab-file: ~/.config/dimutils/svc.keytab
+          krb5-conf: /etc/krb5.conf
+
+Examples:
+  kafka context list
+  kafka context use prod
+  kafka consume --context dev my-topic
+  DIMUTILS_KAFKA_CONTEXT=prod kafka admin list-topics`
+
+	fmt.Println(help)
+	return nil
+}
+
+func listContexts() error {
+	file, err := Load(DefaultPath())
+	if err != nil {
+		return err
+	}
+
+	if len(file.Contexts) == 0 {
+		fmt.Fprintf(os.Stderr, "No contexts configured in %s\n", DefaultPath())
+		return nil
+	}
+
+	active := os.Getenv(EnvContext)
+	if active == "" {
+		active = file.CurrentContext
+	}
+
+	fmt.Printf("%-3s %-20s %s\n", "", "NAME", "BROKERS")
+	for _, ctx := range file.Contexts {
+		marker := ""
+		if ctx.Name == active {
+			marker = "*"
+		}
+		fmt.Printf("%-3s %-20s %s\n", marker, ctx.Name, strings.Join(ctx.Brokers, ","))
+	}
+
+	return nil
+}
+
+func useContext(name string) error {
+	path := DefaultPath()
+	file, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Get(name); err != nil {
+		return err
+	}
+
+	file.CurrentContext = name
+	if err := Save(path, file); err != nil {
+		return err
+	}
+
+	fmt.Printf("Switched to context %q\n", name)
+	return nil
+}
+
+func showContext(name string) error {
+	ctx, err := Resolve(name)
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		return fmt.Errorf("no active context; use 'kafka context use NAME' or --context")
+	}
+
+	fmt.Printf("Name:           %s\n", ctx.Name)
+	fmt.Printf("Brokers:        %s\n", strings.Join(ctx.Brokers, ","))
+	if ctx.ConsumerGroup != "" {
+		fmt.Printf("Consumer Group: %s\n", ctx.ConsumerGroup)
+	}
+
+	if ctx.SASL != nil {
+		fmt.Println("SASL:")
+		fmt.Printf("  Mechanism:    %s\n", ctx.SASL.Mechanism)
+		if ctx.SASL.Username != "" {
+			fmt.Printf("  Username:     %s\n", ctx.SASL.Username)
+		}
+		if !ctx.SASL.Password.IsZero() {
+			fmt.Printf("  Password:     %s\n", ctx.SASL.Password)
+		}
+		if oauth := ctx.SASL.OAuth; oauth != nil {
+			fmt.Printf("  Token URL:    %s\n", oauth.TokenURL)
+			fmt.Printf("  Client ID:    %s\n", oauth.ClientID)
+			fmt.Printf("  Secret:       %s\n", oauth.ClientSecret)
+			if len(oauth.Scopes) > 0 {
+				fmt.Printf("  Scopes:       %s\n", strings.Join(oauth.Scopes, " "))
+			}
+		}
+		if krb := ctx.SASL.Kerberos; krb != nil {
+			fmt.Printf("  Realm:        %s\n", krb.Realm)
+			if krb.ServiceName != "" {
+				fmt.Printf("  Service:      %s\n", krb.ServiceName)
+			}
+			if krb.KeytabFile != "" {
+				fmt.Printf("  Keytab:       %s\n", krb.KeytabFile)
+			}
+			if krb.CCacheFile != "" {
+				fmt.Printf("  CCache:       %s\n", krb.CCacheFile)
+			}
+			if krb.ConfigFile != "" {
+				fmt.Printf("  krb5.conf:    %s\n", krb.ConfigFile)
+			}
+		}
+	}
+
+	if ctx.TLS != nil {
+		fmt.Println("TLS:")
+		fmt.Printf("  Enabled:      %t\n", ctx.TLS.Enabled)
+		if ctx.TLS.InsecureSkipVerify {
+			fmt.Printf("  Insecure:     %t\n", ctx.TLS.InsecureSkipVerify)
+		}
+		if ctx.TLS.CAFile != "" {
+			fmt.Printf("  CA File:      %s\n", ctx.TLS.CAFile)
+		}
+		if ctx.TLS.CertFile != "" {
+			fmt.Printf("  Cert File:    %s\n", ctx.TLS.CertFile)
+			fmt.Printf("  Key File:     %s\n", ctx.TLS.KeyFile)
+		}
+	}
+
+	if ctx.SchemaRegistry != nil {
+		fmt.Println("Schema Registry:")
+		fmt.Printf("  URL:          %s\n", ctx.SchemaRegistry.URL)
+		if ctx.SchemaRegistry.Username != "" {
+			fmt.Printf("  Username:     %s\n", ctx.SchemaRegistry.Username)
+			fmt.Printf("  Password:     %s\n", ctx.SchemaRegistry.Password)
+		}
+		if !ctx.SchemaRegistry.Token.IsZero() {
+			fmt.Printf("  Token:        %s\n", ctx.SchemaRegistry.Token)
+		}
+		if tls := ctx.SchemaRegistry.TLS; tls != nil {
+			if tls.CAFile != "" {
+				fmt.Printf("  CA File:      %s\n", tls.CAFile)
+			}
+			if tls.CertFile != "" {
+				fmt.Printf("  Cert File:    %s\n", tls.CertFile)
+				fmt.Printf("  Key File:     %s\n", tls.KeyFile)
+			}
+		}
+	}
+
+	if ctx.Connect != nil {
+		fmt.Println("Connect:")
+		fmt.Printf("  URL:          %s\n", ctx.Connect.URL)
+		if ctx.Connect.Username != "" {
+			fmt.Printf("  Username:     %s\n", ctx.Connect.Username)
+			fmt.Printf("  Password:     %s\n", ctx.Connect.Password)
+		}
+	}
+
+	return nil
+}
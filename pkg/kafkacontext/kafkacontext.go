@@ -0,0 +1,379 @@
+package kafkacontext
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
+	"github.com/og-dim9/dimutils/pkg/schemaregistry"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvContext selects the context to use when --context is not given
+const EnvContext = "DIMUTILS_KAFKA_CONTEXT"
+
+// File represents the kafka.yaml contexts file
+type File struct {
+	CurrentContext string     `yaml:"current-context,omitempty"`
+	Contexts       []*Context `yaml:"contexts"`
+}
+
+// Context holds the connection profile for a single Kafka cluster
+type Context struct {
+	Name           string          `yaml:"name"`
+	Brokers        []string        `yaml:"brokers"`
+	ConsumerGroup  string          `yaml:"consumer-group,omitempty"`
+	SASL           *SASLConfig     `yaml:"sasl,omitempty"`
+	TLS            *TLSConfig      `yaml:"tls,omitempty"`
+	SchemaRegistry *RegistryConfig `yaml:"schema-registry,omitempty"`
+	Connect        *ConnectConfig  `yaml:"connect,omitempty"`
+}
+
+// SASLConfig holds SASL settings for a context
+type SASLConfig struct {
+	Mechanism string          `yaml:"mechanism"`
+	Username  string          `yaml:"username,omitempty"`
+	Password  Secret          `yaml:"password,omitempty"`
+	OAuth     *OAuthConfig    `yaml:"oauth,omitempty"`
+	Kerberos  *KerberosConfig `yaml:"kerberos,omitempty"`
+}
+
+// OAuthConfig holds OAUTHBEARER client-credentials settings for a context
+type OAuthConfig struct {
+	TokenURL     string            `yaml:"token-url"`
+	ClientID     string            `yaml:"client-id"`
+	ClientSecret Secret            `yaml:"client-secret,omitempty"`
+	Scopes       []string          `yaml:"scopes,omitempty"`
+	Extensions   map[string]string `yaml:"extensions,omitempty"`
+}
+
+// KerberosConfig holds GSSAPI settings for a context
+type KerberosConfig struct {
+	ServiceName     string `yaml:"service-name,omitempty"`
+	Realm           string `yaml:"realm"`
+	KeytabFile      string `yaml:"keytab-file,omitempty"`
+	CCacheFile      string `yaml:"ccache-file,omitempty"`
+	ConfigFile      string `yaml:"krb5-conf,omitempty"`
+	DisablePAFXFAST bool   `yaml:"disable-pa-fx-fast,omitempty"`
+}
+
+// TLSConfig holds TLS settings for a context
+type TLSConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify,omitempty"`
+	CertFile           string `yaml:"cert-file,omitempty"`
+	KeyFile            string `yaml:"key-file,omitempty"`
+	CAFile             string `yaml:"ca-file,omitempty"`
+}
+
+// RegistryConfig holds schema registry settings for a context
+type RegistryConfig struct {
+	URL      string     `yaml:"url"`
+	Username string     `yaml:"username,omitempty"`
+	Password Secret     `yaml:"password,omitempty"`
+	Token    Secret     `yaml:"token,omitempty"` // bearer token, instead of username and password
+	TLS      *TLSConfig `yaml:"tls,omitempty"`
+}
+
+// ConnectConfig holds Kafka Connect REST API settings for a context
+type ConnectConfig struct {
+	URL      string `yaml:"url"`
+	Username string `yaml:"username,omitempty"`
+	Password Secret `yaml:"password,omitempty"`
+}
+
+// Secret is a credential given inline or referenced from a file or environment variable
+type Secret struct {
+	Value string `yaml:"value,omitempty"`
+	File  string `yaml:"file,omitempty"`
+	Env   string `yaml:"env,omitempty"`
+}
+
+// UnmarshalYAML accepts either a plain string or a value/file/env mapping
+func (s *Secret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	if err := unmarshal(&value); err == nil {
+		s.Value = value
+		return nil
+	}
+
+	type plain Secret
+	return unmarshal((*plain)(s))
+}
+
+// IsZero reports whether the secret is unset
+func (s Secret) IsZero() bool {
+	return s.Value == "" && s.File == "" && s.Env == ""
+}
+
+// Resolve returns the secret value, reading the referenced file or environment variable
+func (s Secret) Resolve() (string, error) {
+	switch {
+	case s.Env != "":
+		value, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return value, nil
+	case s.File != "":
+		data, err := os.ReadFile(expandHome(s.File))
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		return s.Value, nil
+	}
+}
+
+// String describes where the secret comes from without revealing it
+func (s Secret) String() string {
+	switch {
+	case s.Env != "":
+		return "env:" + s.Env
+	case s.File != "":
+		return "file:" + s.File
+	case s.Value != "":
+		return "<inline>"
+	default:
+		return ""
+	}
+}
+
+// DefaultPath returns the location of the contexts file
+func DefaultPath() string {
+	configDir := os.Getenv("XDG_CONFIG_HOME")
+	if configDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return filepath.Join(".config", "dimutils", "kafka.yaml")
+		}
+		configDir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configDir, "dimutils", "kafka.yaml")
+}
+
+// Load reads a contexts file, returning an empty file if it does not exist
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &File{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	return &file, nil
+}
+
+// Save writes a contexts file, creating its directory if needed
+func Save(path string, file *File) error {
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to marshal contexts: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+// Get returns the context with the given name
+func (f *File) Get(name string) (*Context, error) {
+	for _, ctx := range f.Contexts {
+		if ctx.Name == name {
+			return ctx, nil
+		}
+	}
+	return nil, fmt.Errorf("kafka context %q not found in %s", name, DefaultPath())
+}
+
+// Resolve returns the active context. An explicit name takes precedence over
+// DIMUTILS_KAFKA_CONTEXT, which takes precedence over current-context.
+// It returns nil when no context is configured.
+func Resolve(name string) (*Context, error) {
+	if name == "" {
+		name = os.Getenv(EnvContext)
+	}
+
+	file, err := Load(DefaultPath())
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = file.CurrentContext
+	}
+	if name == "" {
+		return nil, nil
+	}
+
+	return file.Get(name)
+}
+
+// FlagValue returns the value of --context in args, if present
+func FlagValue(args []string) string {
+	for i, arg := range args {
+		if arg == "--context" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(arg, "--context=") {
+			return strings.TrimPrefix(arg, "--context=")
+		}
+	}
+	return ""
+}
+
+// IsInlineFlag reports whether arg is the --context=NAME form, which command
+// parsers skip as they skip --context NAME
+func IsInlineFlag(arg string) bool {
+	return strings.HasPrefix(arg, "--context=")
+}
+
+// FromArgs resolves the context named by --context in args, or the active
+// context; it returns nil when no context is configured
+func FromArgs(args []string) (*Context, error) {
+	return Resolve(FlagValue(args))
+}
+
+// ApplyClient returns the brokers and the SASL and TLS settings of the context
+// FromArgs resolves; all are nil when no context is configured
+func ApplyClient(args []string) ([]string, *kafkautils.AuthConfig, *kafkautils.TLSConfig, error) {
+	kctx, err := FromArgs(args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return kctx.ClientSettings()
+}
+
+// ClientSettings returns the brokers and the SASL and TLS settings of a
+// context; all are nil for a nil context
+func (c *Context) ClientSettings() ([]string, *kafkautils.AuthConfig, *kafkautils.TLSConfig, error) {
+	if c == nil {
+		return nil, nil, nil, nil
+	}
+	auth, err := c.AuthConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return c.Brokers, auth, c.TLSConfig(), nil
+}
+
+// AuthConfig returns the SASL settings as a kafkautils.AuthConfig
+func (c *Context) AuthConfig() (*kafkautils.AuthConfig, error) {
+	if c.SASL == nil || c.SASL.Mechanism == "" {
+		return nil, nil
+	}
+
+	password, err := c.SASL.Password.Resolve()
+	if err != nil {
+		return nil, fmt.Errorf("context %s: sasl password: %w", c.Name, err)
+	}
+
+	auth := &kafkautils.AuthConfig{
+		Mechanism: strings.ToUpper(c.SASL.Mechanism),
+		Username:  c.SASL.Username,
+		Password:  password,
+		SASLSSL:   c.TLS != nil && c.TLS.Enabled,
+	}
+
+	if oauth := c.SASL.OAuth; oauth != nil {
+		secret, err := oauth.ClientSecret.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("context %s: oauth client secret: %w", c.Name, err)
+		}
+		auth.OAuth = &kafkautils.OAuthConfig{
+			TokenURL:     oauth.TokenURL,
+			ClientID:     oauth.ClientID,
+			ClientSecret: secret,
+			Scopes:       oauth.Scopes,
+			Extensions:   oauth.Extensions,
+		}
+	}
+
+	if krb := c.SASL.Kerberos; krb != nil {
+		auth.Kerberos = &kafkautils.KerberosConfig{
+			ServiceName:     krb.ServiceName,
+			Realm:           krb.Realm,
+			KeytabFile:      expandHome(krb.KeytabFile),
+			CCacheFile:      expandHome(krb.CCacheFile),
+			ConfigFile:      expandHome(krb.ConfigFile),
+			DisablePAFXFAST: krb.DisablePAFXFAST,
+		}
+	}
+
+	return auth, nil
+}
+
+// TLSConfig returns the TLS settings as a kafkautils.TLSConfig
+func (c *Context) TLSConfig() *kafkautils.TLSConfig {
+	if c.TLS == nil {
+		return nil
+	}
+
+	return &kafkautils.TLSConfig{
+		Enabled:            c.TLS.Enabled,
+		InsecureSkipVerify: c.TLS.InsecureSkipVerify,
+		CertFile:           expandHome(c.TLS.CertFile),
+		KeyFile:            expandHome(c.TLS.KeyFile),
+		CAFile:             expandHome(c.TLS.CAFile),
+	}
+}
+
+// RegistryConfig returns the schema registry settings as a schemaregistry.Config
+func (c *Context) RegistryConfig() (*schemaregistry.Config, error) {
+	if c.SchemaRegistry == nil || c.SchemaRegistry.URL == "" {
+		return nil, nil
+	}
+
+	config := schemaregistry.DefaultConfig()
+	config.URL = c.SchemaRegistry.URL
+
+	if c.SchemaRegistry.Username != "" {
+		password, err := c.SchemaRegistry.Password.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("context %s: schema registry password: %w", c.Name, err)
+		}
+		config.Auth = &schemaregistry.AuthConfig{
+			Username: c.SchemaRegistry.Username,
+			Password: password,
+		}
+	}
+	if !c.SchemaRegistry.Token.IsZero() {
+		token, err := c.SchemaRegistry.Token.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("context %s: schema registry token: %w", c.Name, err)
+		}
+		config.Auth = &schemaregistry.AuthConfig{BearerToken: token}
+	}
+	if tls := c.SchemaRegistry.TLS; tls != nil {
+		config.TLS = &schemaregistry.TLSConfig{
+			InsecureSkipVerify: tls.InsecureSkipVerify,
+			CertFile:           expandHome(tls.CertFile),
+			KeyFile:            expandHome(tls.KeyFile),
+			CAFile:             expandHome(tls.CAFile),
+		}
+	}
+
+	return &config, nil
+}
+
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[2:])
+}
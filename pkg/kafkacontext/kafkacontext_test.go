@@ -0,0 +1,85 @@
+package kafkacontext
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testContexts = `current-context: dev
+contexts:
+  - name: dev
+    brokers: [localhost:9092]
+  - name: prod
+    brokers: [kafka-1:9093, kafka-2:9093]
+    sasl:
+      mechanism: scram-sha-512
+      username: app
+      password:
+        env: TEST_KAFKA_PASSWORD
+    tls:
+      enabled: true
+`
+
+func writeContexts(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv(EnvContext, "")
+	if err := os.MkdirAll(filepath.Join(dir, "dimutils"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "dimutils", "kafka.yaml"), []byte(testContexts), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyClient(t *testing.T) {
+	writeContexts(t)
+	t.Setenv("TEST_KAFKA_PASSWORD", "s3cret")
+
+	for _, args := range [][]string{
+		{"topic", "--context", "prod"},
+		{"topic", "--context=prod"},
+	} {
+		brokers, auth, tlsConfig, err := ApplyClient(args)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if len(brokers) != 2 || brokers[0] != "kafka-1:9093" {
+			t.Errorf("%v: unexpected brokers %v", args, brokers)
+		}
+		if auth == nil || auth.Mechanism != "SCRAM-SHA-512" || auth.Username != "app" || auth.Password != "s3cret" {
+			t.Errorf("%v: unexpected auth %+v", args, auth)
+		}
+		if tlsConfig == nil || !tlsConfig.Enabled {
+			t.Errorf("%v: expected TLS to be enabled, got %+v", args, tlsConfig)
+		}
+	}
+
+	// Without --context the current context applies
+	brokers, auth, tlsConfig, err := ApplyClient([]string{"topic"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(brokers) != 1 || brokers[0] != "localhost:9092" || auth != nil || tlsConfig != nil {
+		t.Errorf("unexpected current context settings %v %+v %+v", brokers, auth, tlsConfig)
+	}
+}
+
+func TestApplyClientWithoutContexts(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(EnvContext, "")
+
+	brokers, auth, tlsConfig, err := ApplyClient(nil)
+	if err != nil || brokers != nil || auth != nil || tlsConfig != nil {
+		t.Fatalf("expected no settings, got %v %+v %+v %v", brokers, auth, tlsConfig, err)
+	}
+}
+
+func TestApplyClientUnknownContext(t *testing.T) {
+	writeContexts(t)
+	if _, _, _, err := ApplyClient([]string{"--context=staging"}); err == nil {
+		t.Fatal("expected an error for an unknown context")
+	}
+}
@@ -0,0 +1,172 @@
+package kafkasearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// filterOperators lists the supported operators, longest first so that ">=" wins over ">"
+var filterOperators = []string{"==", "!=", ">=", "<=", "!~", "=", "~", ">", "<"}
+
+// Filter matches a field of a JSON message value
+type Filter struct {
+	Expr     string
+	Path     []string
+	Operator string // empty means the field only has to exist
+	Value    string
+	regex    *regexp.Regexp
+}
+
+// ParseFilter parses an expression such as "order.id=12345", "status!=done", "amount>=100" or "email~@example\.com$"
+func ParseFilter(expr string) (*Filter, error) {
+	idx := strings.IndexAny(expr, "=!<>~")
+	if idx == 0 {
+		return nil, fmt.Errorf("invalid filter %q: missing field path", expr)
+	}
+
+	filter := &Filter{Expr: expr}
+	path := expr
+	if idx > 0 {
+		path = expr[:idx]
+		for _, op := range filterOperators {
+			if strings.HasPrefix(expr[idx:], op) {
+				filter.Operator = op
+				break
+			}
+		}
+		if filter.Operator == "" {
+			return nil, fmt.Errorf("invalid filter %q: unknown operator", expr)
+		}
+		filter.Value = expr[idx+len(filter.Operator):]
+	}
+
+	filter.Path = strings.Split(strings.TrimSpace(path), ".")
+	for _, part := range filter.Path {
+		if part == "" {
+			return nil, fmt.Errorf("invalid filter %q: empty path segment", expr)
+		}
+	}
+
+	if filter.Operator == "~" || filter.Operator == "!~" {
+		re, err := regexp.Compile(filter.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %w", expr, err)
+		}
+		filter.regex = re
+	}
+
+	return filter, nil
+}
+
+// Match reports whether a decoded JSON document satisfies the filter
+func (f *Filter) Match(doc interface{}) bool {
+	value, found := lookup(doc, f.Path)
+	if f.Operator == "" {
+		return found
+	}
+	if !found {
+		// A missing field is never equal to anything
+		return f.Operator == "!=" || f.Operator == "!~"
+	}
+
+	text := valueString(value)
+	switch f.Operator {
+	case "~":
+		return f.regex.MatchString(text)
+	case "!~":
+		return !f.regex.MatchString(text)
+	}
+
+	cmp := compare(value, text, f.Value)
+	switch f.Operator {
+	case "=", "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// decodeJSON decodes a message value, keeping numbers exact
+func decodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// lookup resolves a dotted path through objects and arrays
+func lookup(doc interface{}, path []string) (interface{}, bool) {
+	current := doc
+	for _, part := range path {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(part)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// valueString renders a JSON value the way it would be written in a filter expression
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// compare orders a field against the filter value, numerically when both are numbers
+func compare(value interface{}, text, expected string) int {
+	if number, ok := value.(json.Number); ok {
+		actual, err1 := number.Float64()
+		want, err2 := strconv.ParseFloat(expected, 64)
+		if err1 == nil && err2 == nil {
+			switch {
+			case actual < want:
+				return -1
+			case actual > want:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return strings.Compare(text, expected)
+}
@@ -0,0 +1,684 @@
+package kafkasearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
+)
+
+// Config holds configuration for a topic search
+type Config struct {
+	Brokers     []string
+	Topic       string
+	KeyRegex    string
+	ValueRegex  string
+	Filters     []string
+	FromTime    time.Time
+	UntilTime   time.Time
+	Partitions  []int32
+	MaxMatches  int
+	Concurrency int
+	IdleTimeout time.Duration
+	Format      string // text, json
+	NoProgress  bool
+	Verbose     bool
+	Auth        *kafkautils.AuthConfig
+	TLS         *kafkautils.TLSConfig
+}
+
+// DefaultConfig returns default search configuration
+func DefaultConfig() Config {
+	return Config{
+		Brokers:     []string{"localhost:9092"},
+		MaxMatches:  -1, // unlimited
+		IdleTimeout: 5 * time.Second,
+		Format:      "text",
+	}
+}
+
+// Match is a message that satisfied the search criteria
+type Match struct {
+	Topic     string            `json:"topic"`
+	Partition int32             `json:"partition"`
+	Offset    int64             `json:"offset"`
+	Timestamp time.Time         `json:"timestamp"`
+	Key       string            `json:"key,omitempty"`
+	Value     string            `json:"value"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// partitionRange is the half-open offset range [Start, End) scanned in a partition
+type partitionRange struct {
+	Partition int32
+	Start     int64
+	End       int64
+}
+
+// Run is the main entry point for the search command
+func Run(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			return printHelp()
+		}
+	}
+
+	config := DefaultConfig()
+
+	brokers, auth, tlsConfig, err := kafkacontext.ApplyClient(args)
+	if err != nil {
+		return err
+	}
+	if len(brokers) > 0 {
+		config.Brokers = brokers
+	}
+	config.Auth, config.TLS = auth, tlsConfig
+
+	if err := parseArgs(args, &config, time.Now()); err != nil {
+		return err
+	}
+
+	if config.Topic == "" {
+		printHelp()
+		return fmt.Errorf("topic is required")
+	}
+
+	matcher, err := newMatcher(config)
+	if err != nil {
+		return err
+	}
+
+	return search(config, matcher)
+}
+
+func parseArgs(args []string, config *Config, now time.Time) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if kafkacontext.IsInlineFlag(arg) {
+			// Handled by kafkacontext.ApplyClient
+			continue
+		}
+
+		// Every option except the boolean flags takes a value
+		value := ""
+		switch arg {
+		case "--no-progress", "--verbose", "-v":
+		default:
+			if strings.HasPrefix(arg, "-") {
+				if i+1 >= len(args) {
+					return fmt.Errorf("%s requires a value", arg)
+				}
+				value = args[i+1]
+				i++
+			}
+		}
+
+		switch arg {
+		case "--brokers", "-b":
+			config.Brokers = strings.Split(value, ",")
+		case "--topic", "-t":
+			config.Topic = value
+		case "--context":
+			// Handled by kafkacontext.ApplyClient
+		case "--key-regex", "-k":
+			config.KeyRegex = value
+		case "--value-regex", "-r":
+			config.ValueRegex = value
+		case "--filter", "-f":
+			config.Filters = append(config.Filters, value)
+		case "--from-time", "--from":
+			t, err := ParseTime(value, now)
+			if err != nil {
+				return fmt.Errorf("invalid --from-time: %w", err)
+			}
+			config.FromTime = t
+		case "--until-time", "--until":
+			t, err := ParseTime(value, now)
+			if err != nil {
+				return fmt.Errorf("invalid --until-time: %w", err)
+			}
+			config.UntilTime = t
+		case "--partitions", "-p":
+			partitions, err := parsePartitions(value)
+			if err != nil {
+				return err
+			}
+			config.Partitions = partitions
+		case "--max-matches", "-m":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid --max-matches: %s", value)
+			}
+			config.MaxMatches = count
+		case "--concurrency", "-c":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 0 {
+				return fmt.Errorf("invalid --concurrency: %s", value)
+			}
+			config.Concurrency = count
+		case "--idle-timeout":
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid --idle-timeout: %s", value)
+			}
+			config.IdleTimeout = duration
+		case "--format", "-o":
+			if value != "text" && value != "json" {
+				return fmt.Errorf("unsupported format: %s (use text or json)", value)
+			}
+			config.Format = value
+		case "--no-progress":
+			config.NoProgress = true
+		case "--verbose", "-v":
+			config.Verbose = true
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			if config.Topic != "" {
+				return fmt.Errorf("unexpected argument: %s", arg)
+			}
+			config.Topic = arg
+		}
+	}
+
+	if !config.FromTime.IsZero() && !config.UntilTime.IsZero() && !config.UntilTime.After(config.FromTime) {
+		return fmt.Errorf("--until-time must be after --from-time")
+	}
+
+	return nil
+}
+
+// ParseTime accepts RFC 3339 timestamps, dates, epoch milliseconds or a duration ago such as "2h"
+func ParseTime(value string, now time.Time) (time.Time, error) {
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(millis), nil
+	}
+	if ago, err := time.ParseDuration(strings.TrimPrefix(value, "-")); err == nil {
+		return now.Add(-ago), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised time %q (use RFC 3339, YYYY-MM-DD, epoch millis or a duration such as 2h)", value)
+}
+
+// parsePartitions parses a comma-separated partition list
+func parsePartitions(value string) ([]int32, error) {
+	var partitions []int32
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		partition, err := strconv.ParseInt(part, 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition: %s", part)
+		}
+		partitions = append(partitions, int32(partition))
+	}
+	return partitions, nil
+}
+
+func printHelp() error {
+	help := `Usage: kafka search [options] <topic>
+
+Scan every partition of a topic concurrently and print the messages that match.
+The offset range is fixed when the search starts (bounded by --from-time and
+--until-time, otherwise the whole topic up to the current end), so the search
+terminates on its own. Progress is reported on stderr.
+
+Criteria (all given criteria must match):
+  --key-regex, -k REGEX     Match the message key against a regular expression
+  --value-regex, -r REGEX   Match the message value against a regular expression
+  --filter, -f EXPR         Match a field of a JSON value (can be used multiple times)
+  --from-time, --from TIME  Only messages at or after TIME
+  --until-time, --until TIME
+                            Only messages before TIME
+
+Filter expressions:
+  field                     Field exists
+  field=value               Equal (numbers compare numerically)
+  field!=value              Not equal
+  field>value, field>=value, field<value, field<=value
+  field~regex, field!~regex Matches / does not match a regular expression
+  Nested fields and array elements use dots: customer.address.city, items.0.sku
+
+Times are RFC 3339 (2024-05-01T10:00:00Z), a date or local time (2024-05-01,
+"2024-05-01 10:00:00"), epoch milliseconds, or a duration ago (2h, 30m).
+
+Options:
+  --context NAME            Kafka context from ~/.config/dimutils/kafka.yaml
+  --brokers, -b BROKERS     Comma-separated list of brokers (default: localhost:9092)
+  --topic, -t TOPIC         Topic to search
+  --partitions, -p LIST     Only search these partitions (e.g. 0,3,7)
+  --max-matches, -m COUNT   Stop after COUNT matches (default: unlimited)
+  --concurrency, -c NUM     Partitions scanned at once (default: all)
+  --idle-timeout DURATION   Give up on a partition that returns nothing for this long (default: 5s)
+  --format, -o FORMAT       Output format: text, json (default: text)
+  --no-progress             Do not report progress on stderr
+  --verbose, -v             Verbose output
+  -h, --help                Show this help message
+
+Examples:
+  kafka search orders --value-regex '"order_id":\s*12345\b' --max-matches 1
+  kafka search orders --filter order.id=12345 --from-time 2h
+  kafka search payments --key-regex '^cust-42' --from 2024-05-01 --until 2024-05-02
+  kafka search events --filter 'amount>=1000' --filter 'currency=EUR' --format json`
+
+	fmt.Println(help)
+	return nil
+}
+
+// matcher applies the search criteria to a message
+type matcher struct {
+	keyRegex   *regexp.Regexp
+	valueRegex *regexp.Regexp
+	filters    []*Filter
+	fromTime   time.Time
+	untilTime  time.Time
+}
+
+func newMatcher(config Config) (*matcher, error) {
+	m := &matcher{fromTime: config.FromTime, untilTime: config.UntilTime}
+
+	if config.KeyRegex != "" {
+		re, err := regexp.Compile(config.KeyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --key-regex: %w", err)
+		}
+		m.keyRegex = re
+	}
+	if config.ValueRegex != "" {
+		re, err := regexp.Compile(config.ValueRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --value-regex: %w", err)
+		}
+		m.valueRegex = re
+	}
+	for _, expr := range config.Filters {
+		filter, err := ParseFilter(expr)
+		if err != nil {
+			return nil, err
+		}
+		m.filters = append(m.filters, filter)
+	}
+
+	if m.keyRegex == nil && m.valueRegex == nil && len(m.filters) == 0 && m.fromTime.IsZero() && m.untilTime.IsZero() {
+		return nil, fmt.Errorf("at least one of --key-regex, --value-regex, --filter, --from-time or --until-time is required")
+	}
+
+	return m, nil
+}
+
+func (m *matcher) match(message *sarama.ConsumerMessage) bool {
+	// Time lookups are only exact to the record batch, so check each message too
+	if !m.fromTime.IsZero() && message.Timestamp.Before(m.fromTime) {
+		return false
+	}
+	if !m.untilTime.IsZero() && !message.Timestamp.Before(m.untilTime) {
+		return false
+	}
+	if m.keyRegex != nil && !m.keyRegex.Match(message.Key) {
+		return false
+	}
+	if m.valueRegex != nil && !m.valueRegex.Match(message.Value) {
+		return false
+	}
+	if len(m.filters) > 0 {
+		doc, err := decodeJSON(message.Value)
+		if err != nil {
+			return false
+		}
+		for _, filter := range m.filters {
+			if !filter.Match(doc) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// newSaramaConfig creates a Sarama configuration for standalone partition consumers
+func newSaramaConfig(config Config) (*sarama.Config, error) {
+	saramaConfig := sarama.NewConfig()
+	saramaConfig.ClientID = "dimutils-search"
+	saramaConfig.Consumer.Return.Errors = true
+
+	if err := kafkautils.ConfigureSecurity(saramaConfig, config.Auth, config.TLS); err != nil {
+		return nil, err
+	}
+
+	return saramaConfig, nil
+}
+
+// resolveRanges determines the offsets to scan in each partition
+func resolveRanges(client sarama.Client, config Config) ([]partitionRange, error) {
+	partitions := config.Partitions
+	if len(partitions) == 0 {
+		all, err := client.Partitions(config.Topic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get partitions for topic %s: %w", config.Topic, err)
+		}
+		partitions = all
+	}
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i] < partitions[j] })
+
+	var ranges []partitionRange
+	for _, partition := range partitions {
+		oldest, err := client.GetOffset(config.Topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get oldest offset for partition %d: %w", partition, err)
+		}
+		newest, err := client.GetOffset(config.Topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get newest offset for partition %d: %w", partition, err)
+		}
+
+		r := partitionRange{Partition: partition, Start: oldest, End: newest}
+		if !config.FromTime.IsZero() {
+			offset, err := client.GetOffset(config.Topic, partition, config.FromTime.UnixMilli())
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up --from-time in partition %d: %w", partition, err)
+			}
+			if offset < 0 {
+				// No message at or after the start time
+				offset = newest
+			}
+			if offset > r.Start {
+				r.Start = offset
+			}
+		}
+		if !config.UntilTime.IsZero() {
+			offset, err := client.GetOffset(config.Topic, partition, config.UntilTime.UnixMilli())
+			if err != nil {
+				return nil, fmt.Errorf("failed to look up --until-time in partition %d: %w", partition, err)
+			}
+			if offset >= 0 && offset < r.End {
+				r.End = offset
+			}
+		}
+		if r.End < r.Start {
+			r.End = r.Start
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// progress tracks how far the scan has got across all partitions
+type progress struct {
+	total   int64
+	scanned int64
+	matched int64
+	started time.Time
+}
+
+func (p *progress) line() string {
+	scanned := atomic.LoadInt64(&p.scanned)
+	percent := 100.0
+	if p.total > 0 {
+		percent = float64(scanned) * 100 / float64(p.total)
+	}
+	return fmt.Sprintf("Scanned %d/%d messages (%.1f%%), %d matches, %s",
+		scanned, p.total, percent, atomic.LoadInt64(&p.matched), time.Since(p.started).Round(time.Second))
+}
+
+// report writes progress to stderr until stop is closed; terminals get a single updating line
+func (p *progress) report(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	interactive := false
+	if info, err := os.Stderr.Stat(); err == nil {
+		interactive = info.Mode()&os.ModeCharDevice != 0
+	}
+	interval := 5 * time.Second
+	if interactive {
+		interval = 250 * time.Millisecond
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			if interactive {
+				fmt.Fprintf(os.Stderr, "\r\033[K%s\n", p.line())
+			} else {
+				fmt.Fprintln(os.Stderr, p.line())
+			}
+			return
+		case <-ticker.C:
+			if interactive {
+				fmt.Fprintf(os.Stderr, "\r\033[K%s", p.line())
+			} else {
+				fmt.Fprintln(os.Stderr, p.line())
+			}
+		}
+	}
+}
+
+func search(config Config, m *matcher) error {
+	saramaConfig, err := newSaramaConfig(config)
+	if err != nil {
+		return err
+	}
+
+	client, err := sarama.NewClient(config.Brokers, saramaConfig)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Kafka: %w", err)
+	}
+	defer client.Close()
+
+	ranges, err := resolveRanges(client, config)
+	if err != nil {
+		return err
+	}
+
+	prog := &progress{started: time.Now()}
+	for _, r := range ranges {
+		prog.total += r.End - r.Start
+		if config.Verbose {
+			log.Printf("Partition %d: scanning offsets %d to %d", r.Partition, r.Start, r.End)
+		}
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigterm)
+	go func() {
+		select {
+		case <-sigterm:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	concurrency := config.Concurrency
+	if concurrency <= 0 || concurrency > len(ranges) {
+		concurrency = len(ranges)
+	}
+	slots := make(chan struct{}, concurrency)
+
+	matches := make(chan *sarama.ConsumerMessage, 256)
+	errs := make(chan error, len(ranges))
+	var wg sync.WaitGroup
+	for _, r := range ranges {
+		if r.End <= r.Start {
+			continue
+		}
+		wg.Add(1)
+		go func(r partitionRange) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-slots }()
+
+			if err := scanPartition(ctx, consumer, config, m, r, matches, prog); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(r)
+	}
+	go func() {
+		wg.Wait()
+		close(matches)
+	}()
+
+	var stopProgress, progressDone chan struct{}
+	if !config.NoProgress {
+		stopProgress, progressDone = make(chan struct{}), make(chan struct{})
+		go prog.report(stopProgress, progressDone)
+	}
+
+	count := 0
+	for message := range matches {
+		if config.MaxMatches >= 0 && count >= config.MaxMatches {
+			// Drain what was already in flight after the limit was reached
+			continue
+		}
+		if err := outputMatch(message, config.Format); err != nil {
+			log.Printf("Error outputting message: %v", err)
+		}
+		count++
+		atomic.AddInt64(&prog.matched, 1)
+		if config.MaxMatches >= 0 && count >= config.MaxMatches {
+			cancel()
+		}
+	}
+
+	if stopProgress != nil {
+		close(stopProgress)
+		<-progressDone
+	}
+
+	close(errs)
+	for err := range errs {
+		return err
+	}
+	return nil
+}
+
+// scanPartition reads one partition's range and forwards matching messages
+func scanPartition(ctx context.Context, consumer sarama.Consumer, config Config, m *matcher, r partitionRange, matches chan<- *sarama.ConsumerMessage, prog *progress) error {
+	pc, err := consumer.ConsumePartition(config.Topic, r.Partition, r.Start)
+	if err != nil {
+		return fmt.Errorf("failed to consume partition %d: %w", r.Partition, err)
+	}
+	defer pc.Close()
+
+	// Account for offsets that are skipped (compaction, transaction markers) or never reached
+	position := r.Start
+	defer func() {
+		if position < r.End {
+			atomic.AddInt64(&prog.scanned, r.End-position)
+		}
+	}()
+
+	idle := time.NewTimer(config.IdleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-pc.Errors():
+			if err != nil {
+				return fmt.Errorf("partition %d: %w", r.Partition, err.Err)
+			}
+		case message, ok := <-pc.Messages():
+			if !ok {
+				return nil
+			}
+			if message.Offset >= r.End {
+				return nil
+			}
+			atomic.AddInt64(&prog.scanned, message.Offset+1-position)
+			position = message.Offset + 1
+
+			if m.match(message) {
+				select {
+				case matches <- message:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+			if position >= r.End {
+				return nil
+			}
+
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(config.IdleTimeout)
+		case <-idle.C:
+			if config.Verbose {
+				log.Printf("Partition %d: no messages for %s at offset %d, stopping before %d", r.Partition, config.IdleTimeout, position, r.End)
+			}
+			return nil
+		}
+	}
+}
+
+func outputMatch(message *sarama.ConsumerMessage, format string) error {
+	match := Match{
+		Topic:     message.Topic,
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Timestamp: message.Timestamp,
+		Value:     string(message.Value),
+	}
+	if message.Key != nil {
+		match.Key = string(message.Key)
+	}
+
+	if format == "json" {
+		if len(message.Headers) > 0 {
+			match.Headers = make(map[string]string)
+			for _, header := range message.Headers {
+				match.Headers[string(header.Key)] = string(header.Value)
+			}
+		}
+		data, err := json.Marshal(match)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("partition=%d offset=%d timestamp=%s key=%s value=%s\n",
+		match.Partition, match.Offset, match.Timestamp.Format(time.RFC3339), match.Key, match.Value)
+	return nil
+}
@@ -0,0 +1,114 @@
+package kafkautils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// DeadLetter is a record for a dead-letter sink: Envelope is written to the
+// file as a JSON line, Key, Value and Headers are produced to the topic
+type DeadLetter struct {
+	Envelope interface{}
+	Key      []byte
+	Value    []byte
+	Headers  []sarama.RecordHeader
+}
+
+// DeadLetterSink appends failed records to a JSON lines file and/or produces
+// them to a topic. It is safe for concurrent use.
+type DeadLetterSink struct {
+	mu       sync.Mutex
+	file     *os.File
+	topic    string
+	producer sarama.SyncProducer
+}
+
+// NewDeadLetterSink opens the dead-letter file and creates a producer for the
+// dead-letter topic; either may be empty
+func NewDeadLetterSink(file, topic string, brokers []string, config *sarama.Config) (*DeadLetterSink, error) {
+	s := &DeadLetterSink{topic: topic}
+
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("error opening dead-letter file: %w", err)
+		}
+		s.file = f
+	}
+
+	if topic != "" {
+		producer, err := sarama.NewSyncProducer(brokers, config)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("error creating dead-letter producer: %w", err)
+		}
+		s.producer = producer
+	}
+
+	return s, nil
+}
+
+// Enabled reports whether the sink has a file or topic to send records to
+func (s *DeadLetterSink) Enabled() bool {
+	return s != nil && (s.file != nil || s.producer != nil)
+}
+
+// Send writes the records to the file, synced before returning, and produces
+// them to the topic
+func (s *DeadLetterSink) Send(letters ...DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file != nil {
+		for _, letter := range letters {
+			data, err := json.Marshal(letter.Envelope)
+			if err != nil {
+				return err
+			}
+			if _, err := s.file.Write(append(data, '\n')); err != nil {
+				return fmt.Errorf("error writing dead-letter file: %w", err)
+			}
+		}
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("error writing dead-letter file: %w", err)
+		}
+	}
+
+	if s.producer != nil {
+		messages := make([]*sarama.ProducerMessage, 0, len(letters))
+		for _, letter := range letters {
+			message := &sarama.ProducerMessage{
+				Topic:   s.topic,
+				Value:   sarama.ByteEncoder(letter.Value),
+				Headers: letter.Headers,
+			}
+			if letter.Key != nil {
+				message.Key = sarama.ByteEncoder(letter.Key)
+			}
+			messages = append(messages, message)
+		}
+		if err := s.producer.SendMessages(messages); err != nil {
+			return fmt.Errorf("error producing to dead-letter topic: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close releases the dead-letter file and producer
+func (s *DeadLetterSink) Close() error {
+	var firstErr error
+	if s.file != nil {
+		firstErr = s.file.Close()
+	}
+	if s.producer != nil {
+		if err := s.producer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,71 @@
+package kafkautils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeadLetterSinkAppendsJSONLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.ndjson")
+	if err := os.WriteFile(path, []byte("{\"earlier\":true}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewDeadLetterSink(path, "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sink.Enabled() {
+		t.Fatal("expected a sink with a file to be enabled")
+	}
+	type envelope struct {
+		Offset int64  `json:"offset"`
+		Error  string `json:"error"`
+	}
+	err = sink.Send(
+		DeadLetter{Envelope: envelope{Offset: 1, Error: "exit status 1"}, Value: []byte("a")},
+		DeadLetter{Envelope: envelope{Offset: 2, Error: "exit status 2"}, Value: []byte("b")},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\"earlier\":true}\n" +
+		"{\"offset\":1,\"error\":\"exit status 1\"}\n" +
+		"{\"offset\":2,\"error\":\"exit status 2\"}\n"
+	if string(data) != want {
+		t.Fatalf("unexpected dead-letter file:\n%s", data)
+	}
+}
+
+func TestDeadLetterSinkWithoutTargets(t *testing.T) {
+	sink, err := NewDeadLetterSink("", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.Enabled() {
+		t.Fatal("expected a sink without a file or topic to be disabled")
+	}
+	var missing *DeadLetterSink
+	if missing.Enabled() {
+		t.Fatal("expected a nil sink to be disabled")
+	}
+	if err := sink.Send(DeadLetter{Envelope: "x"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadLetterSinkReportsUnopenableFile(t *testing.T) {
+	_, err := NewDeadLetterSink(filepath.Join(t.TempDir(), "missing", "dlq.ndjson"), "", nil, nil)
+	if err == nil {
+		t.Fatal("expected an error for a file in a missing directory")
+	}
+}
@@ -0,0 +1,165 @@
+package kafkautils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// OAuthConfig holds OAUTHBEARER client-credentials settings
+type OAuthConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Extensions   map[string]string // SASL extensions sent with the token (Kafka 2.1+)
+	// RefreshBuffer is how long before expiry a cached token is replaced (default: 1m)
+	RefreshBuffer time.Duration
+	// Timeout bounds a single token request (default: 10s)
+	Timeout time.Duration
+	// HTTPClient is used for token requests; nil means a client with Timeout
+	HTTPClient *http.Client
+}
+
+// ClientCredentialsTokenProvider fetches OAuth 2.0 access tokens with the
+// client-credentials grant and caches them until they are close to expiry
+type ClientCredentialsTokenProvider struct {
+	config OAuthConfig
+	client *http.Client
+	now    func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+}
+
+// tokenResponse is the token endpoint response body (RFC 6749 section 5)
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	TokenType        string      `json:"token_type"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// NewClientCredentialsTokenProvider creates a token provider for the given settings
+func NewClientCredentialsTokenProvider(config OAuthConfig) (*ClientCredentialsTokenProvider, error) {
+	if config.TokenURL == "" {
+		return nil, fmt.Errorf("oauth token URL is required")
+	}
+	if _, err := url.Parse(config.TokenURL); err != nil {
+		return nil, fmt.Errorf("invalid oauth token URL: %w", err)
+	}
+	if config.ClientID == "" {
+		return nil, fmt.Errorf("oauth client id is required")
+	}
+	if config.RefreshBuffer <= 0 {
+		config.RefreshBuffer = time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: config.Timeout}
+	}
+
+	return &ClientCredentialsTokenProvider{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}, nil
+}
+
+// Token implements sarama.AccessTokenProvider, returning a cached token while it is fresh
+func (p *ClientCredentialsTokenProvider) Token() (*sarama.AccessToken, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token == "" || !p.now().Before(p.refreshAt) {
+		if err := p.refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &sarama.AccessToken{Token: p.token, Extensions: p.config.Extensions}, nil
+}
+
+// refresh requests a new token; the caller must hold the lock
+func (p *ClientCredentialsTokenProvider) refresh() error {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(p.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(p.config.Scopes, " "))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 section 2.3.1: credentials are form-encoded before basic auth
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read token response: %w", err)
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("token endpoint returned %s", resp.Status)
+		}
+		return fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		if token.Error != "" {
+			return fmt.Errorf("token endpoint returned %s: %s %s", resp.Status, token.Error, token.ErrorDescription)
+		}
+		return fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("token response has no access_token")
+	}
+
+	now := p.now()
+	p.token = token.AccessToken
+	p.refreshAt = now.Add(time.Hour) // no expires_in: assume an hour, like most providers
+
+	if token.ExpiresIn != "" {
+		seconds, err := strconv.ParseFloat(string(token.ExpiresIn), 64)
+		if err != nil {
+			return fmt.Errorf("invalid expires_in in token response: %s", token.ExpiresIn)
+		}
+		lifetime := time.Duration(seconds * float64(time.Second))
+		buffer := p.config.RefreshBuffer
+		if buffer > lifetime/2 {
+			// Short-lived tokens are refreshed at half their lifetime
+			buffer = lifetime / 2
+		}
+		p.refreshAt = now.Add(lifetime - buffer)
+	}
+
+	return nil
+}
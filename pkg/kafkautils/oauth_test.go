@@ -0,0 +1,159 @@
+package kafkautils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// tokenEndpoint is a fake OAuth token endpoint serving one scripted response per request
+type tokenEndpoint struct {
+	t         *testing.T
+	mu        sync.Mutex
+	responses []tokenReply
+	requests  int
+}
+
+type tokenReply struct {
+	status int
+	body   string
+}
+
+func (e *tokenEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := r.ParseForm(); err != nil {
+		e.t.Errorf("failed to parse token request: %v", err)
+	}
+	if got := r.PostForm.Get("grant_type"); got != "client_credentials" {
+		e.t.Errorf("expected grant_type client_credentials, got %q", got)
+	}
+	if got := r.PostForm.Get("scope"); got != "kafka read" {
+		e.t.Errorf("expected scope %q, got %q", "kafka read", got)
+	}
+	// Credentials are form-encoded before basic auth
+	if id, secret, ok := r.BasicAuth(); !ok || id != "my+client" || secret != "s%26cret" {
+		e.t.Errorf("unexpected client credentials %q %q", id, secret)
+	}
+
+	if e.requests >= len(e.responses) {
+		e.t.Errorf("unexpected token request %d", e.requests+1)
+		http.Error(w, "no more tokens", http.StatusInternalServerError)
+		return
+	}
+	reply := e.responses[e.requests]
+	e.requests++
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(reply.status)
+	w.Write([]byte(reply.body))
+}
+
+// newTestProvider returns a provider for a fake endpoint and a clock the test can move
+func newTestProvider(t *testing.T, responses ...tokenReply) (*ClientCredentialsTokenProvider, *tokenEndpoint, *time.Time) {
+	t.Helper()
+	endpoint := &tokenEndpoint{t: t, responses: responses}
+	server := httptest.NewServer(endpoint)
+	t.Cleanup(server.Close)
+
+	provider, err := NewClientCredentialsTokenProvider(OAuthConfig{
+		TokenURL:     server.URL,
+		ClientID:     "my client",
+		ClientSecret: "s&cret",
+		Scopes:       []string{"kafka", "read"},
+		Extensions:   map[string]string{"logicalCluster": "lkc-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	provider.now = func() time.Time { return now }
+	return provider, endpoint, &now
+}
+
+func TestTokenProviderFetchesAndCaches(t *testing.T) {
+	provider, endpoint, now := newTestProvider(t,
+		tokenReply{http.StatusOK, `{"access_token":"first","token_type":"Bearer","expires_in":3600}`},
+		tokenReply{http.StatusOK, `{"access_token":"second","token_type":"Bearer","expires_in":"3600"}`},
+	)
+
+	token, err := provider.Token()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Token != "first" || token.Extensions["logicalCluster"] != "lkc-1" {
+		t.Fatalf("unexpected token %+v", token)
+	}
+
+	// Reused until the refresh buffer (1m) before expiry
+	*now = now.Add(58 * time.Minute)
+	if token, err = provider.Token(); err != nil || token.Token != "first" {
+		t.Fatalf("expected the cached token, got %+v, %v", token, err)
+	}
+	if endpoint.requests != 1 {
+		t.Fatalf("expected one token request, got %d", endpoint.requests)
+	}
+
+	*now = now.Add(time.Minute)
+	if token, err = provider.Token(); err != nil || token.Token != "second" {
+		t.Fatalf("expected a refreshed token, got %+v, %v", token, err)
+	}
+	if endpoint.requests != 2 {
+		t.Fatalf("expected two token requests, got %d", endpoint.requests)
+	}
+}
+
+func TestTokenProviderRefreshesShortLivedTokensAtHalfLife(t *testing.T) {
+	provider, endpoint, now := newTestProvider(t,
+		tokenReply{http.StatusOK, `{"access_token":"first","expires_in":60}`},
+		tokenReply{http.StatusOK, `{"access_token":"second","expires_in":60}`},
+	)
+
+	if _, err := provider.Token(); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(29 * time.Second)
+	if token, _ := provider.Token(); token == nil || token.Token != "first" {
+		t.Fatalf("expected the cached token before half its lifetime, got %+v", token)
+	}
+	*now = now.Add(time.Second)
+	if token, _ := provider.Token(); token == nil || token.Token != "second" {
+		t.Fatalf("expected a new token at half its lifetime, got %+v", token)
+	}
+	if endpoint.requests != 2 {
+		t.Fatalf("expected two token requests, got %d", endpoint.requests)
+	}
+}
+
+func TestTokenProviderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply tokenReply
+		want  string
+	}{
+		{"oauth error", tokenReply{http.StatusUnauthorized, `{"error":"invalid_client","error_description":"bad secret"}`}, "401 Unauthorized: invalid_client bad secret"},
+		{"non-JSON error", tokenReply{http.StatusBadGateway, `<html>bad gateway</html>`}, "token endpoint returned 502 Bad Gateway"},
+		{"error in a 200", tokenReply{http.StatusOK, `{"error":"invalid_scope"}`}, "invalid_scope"},
+		{"malformed JSON", tokenReply{http.StatusOK, `{"access_token":`}, "failed to parse token response"},
+		{"no token", tokenReply{http.StatusOK, `{"token_type":"Bearer"}`}, "no access_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, _, _ := newTestProvider(t, tt.reply, tokenReply{http.StatusOK, `{"access_token":"good"}`})
+
+			_, err := provider.Token()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected an error containing %q, got %v", tt.want, err)
+			}
+
+			// Nothing is cached, so the next call asks again
+			token, err := provider.Token()
+			if err != nil || token.Token != "good" {
+				t.Fatalf("expected the next request to succeed, got %+v, %v", token, err)
+			}
+		})
+	}
+}
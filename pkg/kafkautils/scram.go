@@ -3,19 +3,15 @@ package kafkautils
 import (
 	"crypto/sha256"
 	"crypto/sha512"
-	"hash"
 
 	"github.com/xdg-go/scram"
 )
 
-// HashGeneratorFcn represents a hash generator function
-type HashGeneratorFcn func() hash.Hash
-
 // SHA256 hash generator
-var SHA256 HashGeneratorFcn = sha256.New
+var SHA256 scram.HashGeneratorFcn = sha256.New
 
 // SHA512 hash generator  
-var SHA512 HashGeneratorFcn = sha512.New
+var SHA512 scram.HashGeneratorFcn = sha512.New
 
 // XDGSCRAMClient implements SCRAM authentication
 type XDGSCRAMClient struct {
@@ -2,7 +2,9 @@ package kafkautils
 
 import (
 	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -10,11 +12,26 @@ import (
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	Mechanism string // PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, GSSAPI
+	Mechanism string // PLAIN, SCRAM-SHA-256, SCRAM-SHA-512, GSSAPI, OAUTHBEARER
 	Username  string
 	Password  string
 	SASLSSL   bool
 	TLSConfig *tls.Config
+	OAuth     *OAuthConfig    // required for OAUTHBEARER
+	Kerberos  *KerberosConfig // required for GSSAPI
+}
+
+// KerberosConfig holds GSSAPI settings. Exactly one of KeytabFile, Password
+// (or AuthConfig.Password) and CCacheFile selects how credentials are obtained.
+type KerberosConfig struct {
+	ServiceName     string // Kafka broker principal name (default: kafka)
+	Realm           string
+	Username        string // defaults to AuthConfig.Username
+	Password        string // defaults to AuthConfig.Password
+	KeytabFile      string
+	CCacheFile      string
+	ConfigFile      string // krb5.conf path (default: $KRB5_CONFIG or /etc/krb5.conf)
+	DisablePAFXFAST bool
 }
 
 // ConnectionConfig holds Kafka connection configuration
@@ -60,7 +77,21 @@ func ConfigureAuthentication(config *sarama.Config, auth *AuthConfig) error {
 		}
 	case "GSSAPI":
 		config.Net.SASL.Mechanism = sarama.SASLTypeGSSAPI
-		// Additional GSSAPI configuration would go here
+		gssapi, err := gssapiConfig(auth)
+		if err != nil {
+			return err
+		}
+		config.Net.SASL.GSSAPI = gssapi
+	case "OAUTHBEARER":
+		if auth.OAuth == nil {
+			return fmt.Errorf("OAUTHBEARER requires oauth settings (token URL, client id and secret)")
+		}
+		provider, err := NewClientCredentialsTokenProvider(*auth.OAuth)
+		if err != nil {
+			return err
+		}
+		config.Net.SASL.Mechanism = sarama.SASLTypeOAuth
+		config.Net.SASL.TokenProvider = provider
 	default:
 		return fmt.Errorf("unsupported SASL mechanism: %s", auth.Mechanism)
 	}
@@ -75,6 +106,60 @@ func ConfigureAuthentication(config *sarama.Config, auth *AuthConfig) error {
 	return nil
 }
 
+// gssapiConfig builds the Sarama GSSAPI settings from the Kerberos configuration
+func gssapiConfig(auth *AuthConfig) (sarama.GSSAPIConfig, error) {
+	krb := KerberosConfig{}
+	if auth.Kerberos != nil {
+		krb = *auth.Kerberos
+	}
+
+	gssapi := sarama.GSSAPIConfig{
+		ServiceName:        krb.ServiceName,
+		Realm:              krb.Realm,
+		Username:           krb.Username,
+		Password:           krb.Password,
+		KerberosConfigPath: krb.ConfigFile,
+		DisablePAFXFAST:    krb.DisablePAFXFAST,
+	}
+	if gssapi.ServiceName == "" {
+		gssapi.ServiceName = "kafka"
+	}
+	if gssapi.Username == "" {
+		gssapi.Username = auth.Username
+	}
+	if gssapi.Password == "" {
+		gssapi.Password = auth.Password
+	}
+	if gssapi.KerberosConfigPath == "" {
+		gssapi.KerberosConfigPath = os.Getenv("KRB5_CONFIG")
+	}
+	if gssapi.KerberosConfigPath == "" {
+		gssapi.KerberosConfigPath = "/etc/krb5.conf"
+	}
+
+	switch {
+	case krb.KeytabFile != "":
+		gssapi.AuthType = sarama.KRB5_KEYTAB_AUTH
+		gssapi.KeyTabPath = krb.KeytabFile
+	case krb.CCacheFile != "":
+		gssapi.AuthType = sarama.KRB5_CCACHE_AUTH
+		gssapi.CCachePath = krb.CCacheFile
+	case gssapi.Password != "":
+		gssapi.AuthType = sarama.KRB5_USER_AUTH
+	default:
+		return gssapi, fmt.Errorf("GSSAPI requires a keytab, a credential cache or a password")
+	}
+
+	if gssapi.Username == "" {
+		return gssapi, fmt.Errorf("GSSAPI requires a username (principal)")
+	}
+	if gssapi.Realm == "" {
+		return gssapi, fmt.Errorf("GSSAPI requires a realm")
+	}
+
+	return gssapi, nil
+}
+
 // ConfigureTLS sets up TLS configuration
 func ConfigureTLS(config *sarama.Config, tlsConf *TLSConfig) error {
 	if tlsConf == nil || !tlsConf.Enabled {
@@ -83,11 +168,8 @@ func ConfigureTLS(config *sarama.Config, tlsConf *TLSConfig) error {
 
 	config.Net.TLS.Enable = true
 
-	if tlsConf.InsecureSkipVerify {
-		config.Net.TLS.Config = &tls.Config{
-			InsecureSkipVerify: true,
-		}
-		return nil
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: tlsConf.InsecureSkipVerify,
 	}
 
 	if tlsConf.CertFile != "" && tlsConf.KeyFile != "" {
@@ -95,10 +177,34 @@ func ConfigureTLS(config *sarama.Config, tlsConf *TLSConfig) error {
 		if err != nil {
 			return fmt.Errorf("failed to load client certificates: %w", err)
 		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
 
-		config.Net.TLS.Config = &tls.Config{
-			Certificates: []tls.Certificate{cert},
+	if tlsConf.CAFile != "" {
+		caCert, err := os.ReadFile(tlsConf.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return fmt.Errorf("no valid certificates found in CA file %s", tlsConf.CAFile)
 		}
+		tlsConfig.RootCAs = pool
+	}
+
+	config.Net.TLS.Config = tlsConfig
+
+	return nil
+}
+
+// ConfigureSecurity applies SASL and TLS settings to a Sarama configuration
+func ConfigureSecurity(config *sarama.Config, auth *AuthConfig, tlsConf *TLSConfig) error {
+	if err := ConfigureAuthentication(config, auth); err != nil {
+		return fmt.Errorf("failed to configure authentication: %w", err)
+	}
+
+	if err := ConfigureTLS(config, tlsConf); err != nil {
+		return fmt.Errorf("failed to configure TLS: %w", err)
 	}
 
 	return nil
@@ -262,9 +368,9 @@ func GetErrorMessage(err error) string {
 		return "Message format is invalid"
 	case sarama.ErrOffsetOutOfRange:
 		return "Requested offset is out of range"
-	case sarama.ErrInvalidTopicException:
+	case sarama.ErrInvalidTopic:
 		return "Topic name is invalid"
-	case sarama.ErrRecordListTooLarge:
+	case sarama.ErrMessageSetSizeTooLarge:
 		return "Record batch is too large"
 	case sarama.ErrNotLeaderForPartition:
 		return "Broker is not the leader for this partition"
@@ -341,8 +447,7 @@ func HealthCheck(brokers []string, timeout time.Duration) error {
 	defer client.Close()
 
 	// Try to get metadata
-	_, err = client.RefreshMetadata()
-	if err != nil {
+	if err := client.RefreshMetadata(); err != nil {
 		return fmt.Errorf("failed to refresh metadata: %w", err)
 	}
 
@@ -0,0 +1,478 @@
+package mockbroker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Consumer group states as reported by DescribeGroups and ListGroups
+const (
+	stateEmpty               = "Empty"
+	statePreparingRebalance  = "PreparingRebalance"
+	stateCompletingRebalance = "CompletingRebalance"
+	stateStable              = "Stable"
+)
+
+// groupProtocol is a partition assignment protocol offered by a member
+type groupProtocol struct {
+	name     string
+	metadata []byte
+}
+
+// joinResult is the response sent to a member waiting in JoinGroup
+type joinResult struct {
+	err        sarama.KError
+	generation int32
+	protocol   string
+	leader     string
+	memberID   string
+	members    []*member
+}
+
+// syncResult is the response sent to a member waiting in SyncGroup
+type syncResult struct {
+	err        sarama.KError
+	assignment []byte
+}
+
+// member is a consumer group member
+type member struct {
+	id               string
+	instanceID       *string
+	clientID         string
+	clientHost       string
+	protocols        []groupProtocol
+	sessionTimeout   time.Duration
+	rebalanceTimeout time.Duration
+	assignment       []byte
+	lastSeen         time.Time
+	joinCh           chan joinResult
+	syncCh           chan syncResult
+}
+
+func (m *member) metadata(protocol string) []byte {
+	for _, p := range m.protocols {
+		if p.name == protocol {
+			return p.metadata
+		}
+	}
+	return nil
+}
+
+// group is the coordinator state of a consumer group
+type group struct {
+	id             string
+	state          string
+	generation     int32
+	protocolType   string
+	protocol       string
+	leader         string
+	members        map[string]*member
+	rebalanceTimer *time.Timer
+}
+
+// coordinator implements the group membership protocol for all groups
+type coordinator struct {
+	mu         sync.Mutex
+	groups     map[string]*group
+	nextMember int
+	logf       func(format string, v ...interface{})
+}
+
+func newCoordinator(logf func(format string, v ...interface{})) *coordinator {
+	return &coordinator{
+		groups: make(map[string]*group),
+		logf:   logf,
+	}
+}
+
+// getGroup returns the group with the given id, creating it if needed; the caller must hold the lock
+func (c *coordinator) getGroup(id string) *group {
+	g, ok := c.groups[id]
+	if !ok {
+		g = &group{id: id, state: stateEmpty, members: make(map[string]*member)}
+		c.groups[id] = g
+	}
+	return g
+}
+
+// joinRequest carries the fields of a JoinGroup request
+type joinRequest struct {
+	groupID          string
+	memberID         string
+	instanceID       *string
+	clientID         string
+	clientHost       string
+	protocolType     string
+	protocols        []groupProtocol
+	sessionTimeout   time.Duration
+	rebalanceTimeout time.Duration
+}
+
+// join adds a member to a group and blocks until the rebalance completes
+func (c *coordinator) join(req joinRequest) joinResult {
+	c.mu.Lock()
+
+	g := c.getGroup(req.groupID)
+	if g.state != stateEmpty && g.protocolType != req.protocolType {
+		c.mu.Unlock()
+		return joinResult{err: sarama.ErrInconsistentGroupProtocol, generation: -1}
+	}
+
+	m, known := g.members[req.memberID]
+	if req.memberID != "" && !known {
+		c.mu.Unlock()
+		return joinResult{err: sarama.ErrUnknownMemberId, generation: -1}
+	}
+	if !known {
+		c.nextMember++
+		m = &member{id: fmt.Sprintf("%s-%d", req.clientID, c.nextMember)}
+		g.members[m.id] = m
+	}
+
+	m.instanceID = req.instanceID
+	m.clientID = req.clientID
+	m.clientHost = req.clientHost
+	m.protocols = req.protocols
+	m.sessionTimeout = req.sessionTimeout
+	m.rebalanceTimeout = req.rebalanceTimeout
+	m.lastSeen = time.Now()
+	ch := make(chan joinResult, 1)
+	m.joinCh = ch
+
+	g.protocolType = req.protocolType
+	if g.state != statePreparingRebalance {
+		c.prepareRebalance(g)
+	}
+	c.maybeCompleteJoin(g)
+
+	c.mu.Unlock()
+	return <-ch
+}
+
+// prepareRebalance asks all members to rejoin; the caller must hold the lock
+func (c *coordinator) prepareRebalance(g *group) {
+	for _, m := range g.members {
+		if m.syncCh != nil {
+			m.syncCh <- syncResult{err: sarama.ErrRebalanceInProgress}
+			m.syncCh = nil
+		}
+	}
+
+	g.state = statePreparingRebalance
+	c.logf("group %s: preparing rebalance", g.id)
+
+	timeout := time.Duration(0)
+	for _, m := range g.members {
+		if m.rebalanceTimeout > timeout {
+			timeout = m.rebalanceTimeout
+		}
+	}
+	if g.rebalanceTimer != nil {
+		g.rebalanceTimer.Stop()
+	}
+	g.rebalanceTimer = time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if g.state == statePreparingRebalance {
+			c.completeJoin(g)
+		}
+	})
+}
+
+// maybeCompleteJoin completes the join phase once every member has rejoined; the caller must hold the lock
+func (c *coordinator) maybeCompleteJoin(g *group) {
+	for _, m := range g.members {
+		if m.joinCh == nil {
+			return
+		}
+	}
+	c.completeJoin(g)
+}
+
+// completeJoin starts a new generation with the members that rejoined; the caller must hold the lock
+func (c *coordinator) completeJoin(g *group) {
+	if g.rebalanceTimer != nil {
+		g.rebalanceTimer.Stop()
+		g.rebalanceTimer = nil
+	}
+
+	for id, m := range g.members {
+		if m.joinCh == nil {
+			delete(g.members, id)
+		}
+	}
+
+	g.generation++
+	if len(g.members) == 0 {
+		g.state = stateEmpty
+		g.protocol = ""
+		g.leader = ""
+		return
+	}
+
+	members := sortedMembers(g)
+	if _, ok := g.members[g.leader]; !ok {
+		g.leader = members[0].id
+	}
+	g.protocol = selectProtocol(g.members[g.leader], members)
+	g.state = stateCompletingRebalance
+	c.logf("group %s: generation %d with %d member(s), leader %s", g.id, g.generation, len(members), g.leader)
+
+	for _, m := range members {
+		result := joinResult{
+			generation: g.generation,
+			protocol:   g.protocol,
+			leader:     g.leader,
+			memberID:   m.id,
+		}
+		if m.id == g.leader {
+			result.members = members
+		}
+		m.joinCh <- result
+		m.joinCh = nil
+	}
+}
+
+// selectProtocol picks the leader's most preferred protocol that every member supports
+func selectProtocol(leader *member, members []*member) string {
+	for _, candidate := range leader.protocols {
+		supported := true
+		for _, m := range members {
+			if m.metadata(candidate.name) == nil {
+				supported = false
+				break
+			}
+		}
+		if supported {
+			return candidate.name
+		}
+	}
+	if len(leader.protocols) > 0 {
+		return leader.protocols[0].name
+	}
+	return ""
+}
+
+func sortedMembers(g *group) []*member {
+	members := make([]*member, 0, len(g.members))
+	for _, m := range g.members {
+		members = append(members, m)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].id < members[j].id })
+	return members
+}
+
+// sync distributes the leader's assignments and blocks until they are available
+func (c *coordinator) sync(groupID, memberID string, generation int32, assignments map[string][]byte) syncResult {
+	c.mu.Lock()
+
+	g, ok := c.groups[groupID]
+	if !ok {
+		c.mu.Unlock()
+		return syncResult{err: sarama.ErrUnknownMemberId}
+	}
+	m, ok := g.members[memberID]
+	if !ok {
+		c.mu.Unlock()
+		return syncResult{err: sarama.ErrUnknownMemberId}
+	}
+	if generation != g.generation {
+		c.mu.Unlock()
+		return syncResult{err: sarama.ErrIllegalGeneration}
+	}
+	m.lastSeen = time.Now()
+
+	switch g.state {
+	case statePreparingRebalance:
+		c.mu.Unlock()
+		return syncResult{err: sarama.ErrRebalanceInProgress}
+	case stateStable:
+		c.mu.Unlock()
+		return syncResult{assignment: m.assignment}
+	}
+
+	ch := make(chan syncResult, 1)
+	m.syncCh = ch
+
+	if memberID == g.leader {
+		for _, gm := range g.members {
+			gm.assignment = assignments[gm.id]
+		}
+		g.state = stateStable
+		c.logf("group %s: generation %d is stable", g.id, g.generation)
+		for _, gm := range g.members {
+			if gm.syncCh != nil {
+				gm.syncCh <- syncResult{assignment: gm.assignment}
+				gm.syncCh = nil
+			}
+		}
+	}
+
+	c.mu.Unlock()
+	return <-ch
+}
+
+// heartbeat records member liveness and reports pending rebalances
+func (c *coordinator) heartbeat(groupID, memberID string, generation int32) sarama.KError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if !ok {
+		return sarama.ErrUnknownMemberId
+	}
+	m, ok := g.members[memberID]
+	if !ok {
+		return sarama.ErrUnknownMemberId
+	}
+	if generation != g.generation {
+		return sarama.ErrIllegalGeneration
+	}
+	m.lastSeen = time.Now()
+
+	if g.state != stateStable {
+		return sarama.ErrRebalanceInProgress
+	}
+	return sarama.ErrNoError
+}
+
+// leave removes a member and triggers a rebalance for the remaining members
+func (c *coordinator) leave(groupID, memberID string) sarama.KError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if !ok {
+		return sarama.ErrUnknownMemberId
+	}
+	if _, ok := g.members[memberID]; !ok {
+		return sarama.ErrUnknownMemberId
+	}
+
+	c.removeMember(g, memberID)
+	return sarama.ErrNoError
+}
+
+// removeMember drops a member from a group; the caller must hold the lock
+func (c *coordinator) removeMember(g *group, memberID string) {
+	delete(g.members, memberID)
+	c.logf("group %s: member %s left", g.id, memberID)
+
+	if len(g.members) == 0 {
+		if g.rebalanceTimer != nil {
+			g.rebalanceTimer.Stop()
+			g.rebalanceTimer = nil
+		}
+		g.state = stateEmpty
+		g.generation++
+		g.protocol = ""
+		g.leader = ""
+		return
+	}
+
+	if g.state != statePreparingRebalance {
+		c.prepareRebalance(g)
+	}
+	c.maybeCompleteJoin(g)
+}
+
+// validateCommit checks that an offset commit comes from a current group member
+func (c *coordinator) validateCommit(groupID, memberID string, generation int32) sarama.KError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if generation < 0 {
+		if ok && len(g.members) > 0 {
+			return sarama.ErrUnknownMemberId
+		}
+		return sarama.ErrNoError
+	}
+	if !ok {
+		return sarama.ErrUnknownMemberId
+	}
+	m, ok := g.members[memberID]
+	if !ok {
+		return sarama.ErrUnknownMemberId
+	}
+	if generation != g.generation {
+		return sarama.ErrIllegalGeneration
+	}
+	if g.state == statePreparingRebalance {
+		return sarama.ErrRebalanceInProgress
+	}
+	m.lastSeen = time.Now()
+	return sarama.ErrNoError
+}
+
+// expireSessions removes members whose session timed out
+func (c *coordinator) expireSessions(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, g := range c.groups {
+		for id, m := range g.members {
+			if m.joinCh == nil && m.sessionTimeout > 0 && now.Sub(m.lastSeen) > m.sessionTimeout {
+				c.logf("group %s: session of member %s expired", g.id, id)
+				c.removeMember(g, id)
+			}
+		}
+	}
+}
+
+// groupSummary describes a group for ListGroups and DescribeGroups
+type groupSummary struct {
+	id           string
+	state        string
+	protocolType string
+	protocol     string
+	members      []*member
+}
+
+// describe returns a snapshot of a group, or false if it has never been seen
+func (c *coordinator) describe(groupID string) (groupSummary, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	g, ok := c.groups[groupID]
+	if !ok {
+		return groupSummary{}, false
+	}
+	// Copy members so callers can read them without holding the lock
+	var members []*member
+	for _, m := range sortedMembers(g) {
+		copied := *m
+		members = append(members, &copied)
+	}
+	return groupSummary{
+		id:           g.id,
+		state:        g.state,
+		protocolType: g.protocolType,
+		protocol:     g.protocol,
+		members:      members,
+	}, true
+}
+
+// list returns a snapshot of every known group
+func (c *coordinator) list() []groupSummary {
+	c.mu.Lock()
+	ids := make([]string, 0, len(c.groups))
+	for id := range c.groups {
+		ids = append(ids, id)
+	}
+	c.mu.Unlock()
+
+	sort.Strings(ids)
+	summaries := make([]groupSummary, 0, len(ids))
+	for _, id := range ids {
+		if summary, ok := c.describe(id); ok {
+			summaries = append(summaries, summary)
+		}
+	}
+	return summaries
+}
@@ -0,0 +1,943 @@
+package mockbroker
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Kafka API keys served by the mock broker
+const (
+	apiProduce         int16 = 0
+	apiFetch           int16 = 1
+	apiListOffsets     int16 = 2
+	apiMetadata        int16 = 3
+	apiOffsetCommit    int16 = 8
+	apiOffsetFetch     int16 = 9
+	apiFindCoordinator int16 = 10
+	apiJoinGroup       int16 = 11
+	apiHeartbeat       int16 = 12
+	apiLeaveGroup      int16 = 13
+	apiSyncGroup       int16 = 14
+	apiDescribeGroups  int16 = 15
+	apiListGroups      int16 = 16
+	apiApiVersions     int16 = 18
+	apiCreateTopics    int16 = 19
+	apiDeleteTopics    int16 = 20
+	apiDescribeConfigs int16 = 32
+)
+
+// handlerFunc decodes a request body and encodes the response body.
+// It returns false when no response should be sent.
+type handlerFunc func(b *Broker, rc *requestContext, d *decoder, e *encoder) bool
+
+// apiSpec describes a supported API and its version range
+type apiSpec struct {
+	name            string
+	minVersion      int16
+	maxVersion      int16
+	flexibleVersion int16 // first flexible version, or -1 if none is supported
+	handler         handlerFunc
+}
+
+var apis map[int16]apiSpec
+
+func init() {
+	apis = map[int16]apiSpec{
+		apiProduce:         {"Produce", 3, 8, -1, handleProduce},
+		apiFetch:           {"Fetch", 4, 11, -1, handleFetch},
+		apiListOffsets:     {"ListOffsets", 1, 5, -1, handleListOffsets},
+		apiMetadata:        {"Metadata", 0, 9, 9, handleMetadata},
+		apiOffsetCommit:    {"OffsetCommit", 2, 7, -1, handleOffsetCommit},
+		apiOffsetFetch:     {"OffsetFetch", 1, 7, 6, handleOffsetFetch},
+		apiFindCoordinator: {"FindCoordinator", 0, 2, -1, handleFindCoordinator},
+		apiJoinGroup:       {"JoinGroup", 0, 5, -1, handleJoinGroup},
+		apiHeartbeat:       {"Heartbeat", 0, 3, -1, handleHeartbeat},
+		apiLeaveGroup:      {"LeaveGroup", 0, 3, -1, handleLeaveGroup},
+		apiSyncGroup:       {"SyncGroup", 0, 3, -1, handleSyncGroup},
+		apiDescribeGroups:  {"DescribeGroups", 0, 4, -1, handleDescribeGroups},
+		apiListGroups:      {"ListGroups", 0, 4, 3, handleListGroups},
+		apiApiVersions:     {"ApiVersions", 0, 3, 3, handleApiVersions},
+		apiCreateTopics:    {"CreateTopics", 0, 4, -1, handleCreateTopics},
+		apiDeleteTopics:    {"DeleteTopics", 0, 3, -1, handleDeleteTopics},
+		apiDescribeConfigs: {"DescribeConfigs", 0, 3, -1, handleDescribeConfigs},
+	}
+}
+
+func handleApiVersions(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	if rc.version >= 3 {
+		d.string() // client software name
+		d.string() // client software version
+		d.tags()
+	}
+	e.int16(0)
+	writeApiVersions(e, rc.version)
+	return true
+}
+
+// writeApiVersions writes the supported API ranges and the trailing fields of an ApiVersions response
+func writeApiVersions(e *encoder, version int16) {
+	keys := make([]int, 0, len(apis))
+	for key := range apis {
+		keys = append(keys, int(key))
+	}
+	sort.Ints(keys)
+
+	e.arrayLen(len(keys))
+	for _, key := range keys {
+		api := apis[int16(key)]
+		e.int16(int16(key))
+		e.int16(api.minVersion)
+		e.int16(api.maxVersion)
+		e.tags()
+	}
+	if version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.tags()
+}
+
+func handleMetadata(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	n := d.arrayLen()
+	var topics []string
+	for i := 0; i < n && d.err == nil; i++ {
+		topics = append(topics, d.string())
+		if rc.version >= 9 {
+			d.tags()
+		}
+	}
+	allowAutoCreate := true
+	if rc.version >= 4 {
+		allowAutoCreate = d.bool()
+	}
+	if rc.version >= 8 {
+		d.bool() // include cluster authorized operations
+		d.bool() // include topic authorized operations
+	}
+	d.tags()
+
+	if n < 0 || (n == 0 && rc.version == 0) {
+		topics = b.store.topicNames()
+	} else if allowAutoCreate && b.config.AutoCreateTopics {
+		for _, topic := range topics {
+			if b.store.partitionCount(topic) < 0 && b.store.createTopic(topic, b.config.DefaultPartitions) == sarama.ErrNoError {
+				b.logf("auto-created topic %s with %d partition(s)", topic, b.config.DefaultPartitions)
+			}
+		}
+	}
+
+	if rc.version >= 3 {
+		e.int32(0) // throttle time
+	}
+
+	e.arrayLen(1)
+	e.int32(nodeID)
+	e.string(b.config.Host)
+	e.int32(b.port)
+	if rc.version >= 1 {
+		e.nullableString(nil) // rack
+	}
+	e.tags()
+
+	if rc.version >= 2 {
+		clusterID := "dimutils-mock"
+		e.nullableString(&clusterID)
+	}
+	if rc.version >= 1 {
+		e.int32(nodeID) // controller
+	}
+
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		partitions := b.store.partitionCount(topic)
+		if partitions < 0 {
+			e.int16(int16(sarama.ErrUnknownTopicOrPartition))
+		} else {
+			e.int16(0)
+		}
+		e.string(topic)
+		if rc.version >= 1 {
+			e.bool(false) // internal
+		}
+
+		if partitions < 0 {
+			partitions = 0
+		}
+		e.arrayLen(partitions)
+		for p := 0; p < partitions; p++ {
+			e.int16(0)
+			e.int32(int32(p))
+			e.int32(nodeID) // leader
+			if rc.version >= 7 {
+				e.int32(0) // leader epoch
+			}
+			e.int32Array([]int32{nodeID}) // replicas
+			e.int32Array([]int32{nodeID}) // isr
+			if rc.version >= 5 {
+				e.int32Array([]int32{}) // offline replicas
+			}
+			e.tags()
+		}
+		if rc.version >= 8 {
+			e.int32(math.MinInt32) // topic authorized operations
+		}
+		e.tags()
+	}
+
+	if rc.version >= 8 {
+		e.int32(math.MinInt32) // cluster authorized operations
+	}
+	e.tags()
+	return true
+}
+
+func handleProduce(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	d.nullableString() // transactional id
+	acks := d.int16()
+	d.int32() // timeout
+
+	type partitionResult struct {
+		partition  int32
+		err        sarama.KError
+		baseOffset int64
+	}
+	type topicResult struct {
+		name       string
+		partitions []partitionResult
+	}
+
+	var results []topicResult
+	topicCount := d.arrayLen()
+	for i := 0; i < topicCount && d.err == nil; i++ {
+		topic := topicResult{name: d.string()}
+		partitionCount := d.arrayLen()
+		for j := 0; j < partitionCount && d.err == nil; j++ {
+			partition := d.int32()
+			records := d.bytes()
+			if d.err != nil {
+				break
+			}
+			baseOffset, kerr := b.store.append(topic.name, partition, records)
+			topic.partitions = append(topic.partitions, partitionResult{partition, kerr, baseOffset})
+			b.logf("produced to %s/%d at offset %d (%v)", topic.name, partition, baseOffset, kerr)
+		}
+		results = append(results, topic)
+	}
+
+	if acks == 0 {
+		return false
+	}
+
+	e.arrayLen(len(results))
+	for _, topic := range results {
+		e.string(topic.name)
+		e.arrayLen(len(topic.partitions))
+		for _, p := range topic.partitions {
+			e.int32(p.partition)
+			e.int16(int16(p.err))
+			e.int64(p.baseOffset)
+			e.int64(-1) // log append time
+			if rc.version >= 5 {
+				e.int64(0) // log start offset
+			}
+			if rc.version >= 8 {
+				e.arrayLen(0)         // record errors
+				e.nullableString(nil) // error message
+			}
+		}
+	}
+	e.int32(0) // throttle time
+	return true
+}
+
+// fetchPartition is a partition requested by a Fetch request
+type fetchPartition struct {
+	partition int32
+	offset    int64
+	maxBytes  int32
+}
+
+func handleFetch(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	d.int32() // replica id
+	maxWait := time.Duration(d.int32()) * time.Millisecond
+	minBytes := d.int32()
+	maxBytes := d.int32()
+	d.int8() // isolation level
+	if rc.version >= 7 {
+		d.int32() // session id
+		d.int32() // session epoch
+	}
+
+	type fetchTopic struct {
+		name       string
+		partitions []fetchPartition
+	}
+
+	var topics []fetchTopic
+	topicCount := d.arrayLen()
+	for i := 0; i < topicCount && d.err == nil; i++ {
+		topic := fetchTopic{name: d.string()}
+		partitionCount := d.arrayLen()
+		for j := 0; j < partitionCount && d.err == nil; j++ {
+			p := fetchPartition{partition: d.int32()}
+			if rc.version >= 9 {
+				d.int32() // current leader epoch
+			}
+			p.offset = d.int64()
+			if rc.version >= 5 {
+				d.int64() // log start offset
+			}
+			p.maxBytes = d.int32()
+			topic.partitions = append(topic.partitions, p)
+		}
+		topics = append(topics, topic)
+	}
+	if rc.version >= 7 {
+		forgotten := d.arrayLen()
+		for i := 0; i < forgotten && d.err == nil; i++ {
+			d.string()
+			d.int32Array()
+		}
+	}
+	if rc.version >= 11 {
+		d.string() // rack id
+	}
+	if d.err != nil {
+		return true
+	}
+
+	// Long-poll until enough data is available or max wait expires
+	deadline := time.NewTimer(maxWait)
+	defer deadline.Stop()
+	for {
+		changed := b.store.changed()
+		available := 0
+		for _, topic := range topics {
+			for _, p := range topic.partitions {
+				records, _, _, _ := b.store.fetch(topic.name, p.partition, p.offset, p.maxBytes)
+				available += len(records)
+			}
+		}
+		if available > 0 && available >= int(minBytes) {
+			break
+		}
+		select {
+		case <-changed:
+			continue
+		case <-deadline.C:
+		case <-b.closing:
+		}
+		break
+	}
+
+	e.int32(0) // throttle time
+	if rc.version >= 7 {
+		e.int16(0) // error code
+		e.int32(0) // session id
+	}
+
+	total := 0
+	e.arrayLen(len(topics))
+	for _, topic := range topics {
+		e.string(topic.name)
+		e.arrayLen(len(topic.partitions))
+		for _, p := range topic.partitions {
+			limit := p.maxBytes
+			if remaining := maxBytes - int32(total); remaining < limit && total > 0 {
+				limit = remaining
+			}
+			records, highWatermark, logStart, kerr := b.store.fetch(topic.name, p.partition, p.offset, limit)
+			if total > 0 && int32(total+len(records)) > maxBytes {
+				records = nil
+			}
+			total += len(records)
+
+			e.int32(p.partition)
+			e.int16(int16(kerr))
+			e.int64(highWatermark)
+			e.int64(highWatermark) // last stable offset
+			if rc.version >= 5 {
+				e.int64(logStart)
+			}
+			e.arrayLen(0) // aborted transactions
+			if rc.version >= 11 {
+				e.int32(-1) // preferred read replica
+			}
+			if records == nil {
+				records = []byte{}
+			}
+			e.bytes(records)
+		}
+	}
+	return true
+}
+
+func handleListOffsets(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	d.int32() // replica id
+	if rc.version >= 2 {
+		d.int8() // isolation level
+	}
+
+	type offsetResult struct {
+		partition int32
+		err       sarama.KError
+		timestamp int64
+		offset    int64
+	}
+	type topicResult struct {
+		name       string
+		partitions []offsetResult
+	}
+
+	var results []topicResult
+	topicCount := d.arrayLen()
+	for i := 0; i < topicCount && d.err == nil; i++ {
+		topic := topicResult{name: d.string()}
+		partitionCount := d.arrayLen()
+		for j := 0; j < partitionCount && d.err == nil; j++ {
+			partition := d.int32()
+			if rc.version >= 4 {
+				d.int32() // current leader epoch
+			}
+			timestamp := d.int64()
+			offset, foundTimestamp, kerr := b.store.offsetForTime(topic.name, partition, timestamp)
+			topic.partitions = append(topic.partitions, offsetResult{partition, kerr, foundTimestamp, offset})
+		}
+		results = append(results, topic)
+	}
+
+	if rc.version >= 2 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(len(results))
+	for _, topic := range results {
+		e.string(topic.name)
+		e.arrayLen(len(topic.partitions))
+		for _, p := range topic.partitions {
+			e.int32(p.partition)
+			e.int16(int16(p.err))
+			e.int64(p.timestamp)
+			e.int64(p.offset)
+			if rc.version >= 4 {
+				e.int32(0) // leader epoch
+			}
+		}
+	}
+	return true
+}
+
+func handleFindCoordinator(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	d.string() // key
+	if rc.version >= 1 {
+		d.int8() // key type
+	}
+
+	if rc.version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(0)
+	if rc.version >= 1 {
+		e.nullableString(nil) // error message
+	}
+	e.int32(nodeID)
+	e.string(b.config.Host)
+	e.int32(b.port)
+	return true
+}
+
+func handleJoinGroup(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	req := joinRequest{
+		groupID:    d.string(),
+		clientID:   rc.clientID,
+		clientHost: rc.clientHost,
+	}
+	req.sessionTimeout = time.Duration(d.int32()) * time.Millisecond
+	req.rebalanceTimeout = req.sessionTimeout
+	if rc.version >= 1 {
+		req.rebalanceTimeout = time.Duration(d.int32()) * time.Millisecond
+	}
+	req.memberID = d.string()
+	if rc.version >= 5 {
+		req.instanceID = d.nullableString()
+	}
+	req.protocolType = d.string()
+	protocolCount := d.arrayLen()
+	for i := 0; i < protocolCount && d.err == nil; i++ {
+		req.protocols = append(req.protocols, groupProtocol{name: d.string(), metadata: d.bytes()})
+	}
+	if d.err != nil {
+		return true
+	}
+
+	result := b.groups.join(req)
+
+	if rc.version >= 2 {
+		e.int32(0) // throttle time
+	}
+	e.int16(int16(result.err))
+	e.int32(result.generation)
+	e.string(result.protocol)
+	e.string(result.leader)
+	e.string(result.memberID)
+	e.arrayLen(len(result.members))
+	for _, m := range result.members {
+		e.string(m.id)
+		if rc.version >= 5 {
+			e.nullableString(m.instanceID)
+		}
+		e.bytes(m.metadata(result.protocol))
+	}
+	return true
+}
+
+func handleSyncGroup(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	groupID := d.string()
+	generation := d.int32()
+	memberID := d.string()
+	if rc.version >= 3 {
+		d.nullableString() // group instance id
+	}
+	assignments := make(map[string][]byte)
+	count := d.arrayLen()
+	for i := 0; i < count && d.err == nil; i++ {
+		id := d.string()
+		assignments[id] = d.bytes()
+	}
+	if d.err != nil {
+		return true
+	}
+
+	result := b.groups.sync(groupID, memberID, generation, assignments)
+
+	if rc.version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(int16(result.err))
+	if result.assignment == nil {
+		result.assignment = []byte{}
+	}
+	e.bytes(result.assignment)
+	return true
+}
+
+func handleHeartbeat(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	groupID := d.string()
+	generation := d.int32()
+	memberID := d.string()
+	if rc.version >= 3 {
+		d.nullableString() // group instance id
+	}
+
+	kerr := b.groups.heartbeat(groupID, memberID, generation)
+
+	if rc.version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(int16(kerr))
+	return true
+}
+
+func handleLeaveGroup(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	groupID := d.string()
+
+	type leaving struct {
+		memberID   string
+		instanceID *string
+		err        sarama.KError
+	}
+	var members []leaving
+	if rc.version >= 3 {
+		count := d.arrayLen()
+		for i := 0; i < count && d.err == nil; i++ {
+			members = append(members, leaving{memberID: d.string(), instanceID: d.nullableString()})
+		}
+	} else {
+		members = append(members, leaving{memberID: d.string()})
+	}
+	if d.err != nil {
+		return true
+	}
+
+	topErr := sarama.ErrNoError
+	for i := range members {
+		members[i].err = b.groups.leave(groupID, members[i].memberID)
+		if rc.version < 3 {
+			topErr = members[i].err
+		}
+	}
+
+	if rc.version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(int16(topErr))
+	if rc.version >= 3 {
+		e.arrayLen(len(members))
+		for _, m := range members {
+			e.string(m.memberID)
+			e.nullableString(m.instanceID)
+			e.int16(int16(m.err))
+		}
+	}
+	return true
+}
+
+func handleOffsetCommit(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	groupID := d.string()
+	generation := d.int32()
+	memberID := d.string()
+	if rc.version >= 7 {
+		d.nullableString() // group instance id
+	}
+	if rc.version <= 4 {
+		d.int64() // retention time
+	}
+
+	groupErr := b.groups.validateCommit(groupID, memberID, generation)
+
+	type commitResult struct {
+		partition int32
+		err       sarama.KError
+	}
+	type topicResult struct {
+		name       string
+		partitions []commitResult
+	}
+
+	var results []topicResult
+	topicCount := d.arrayLen()
+	for i := 0; i < topicCount && d.err == nil; i++ {
+		topic := topicResult{name: d.string()}
+		partitionCount := d.arrayLen()
+		for j := 0; j < partitionCount && d.err == nil; j++ {
+			partition := d.int32()
+			offset := d.int64()
+			if rc.version >= 6 {
+				d.int32() // leader epoch
+			}
+			metadata := d.string()
+			if d.err != nil {
+				break
+			}
+			kerr := groupErr
+			if kerr == sarama.ErrNoError {
+				kerr = b.store.commitOffset(groupID, topic.name, partition, offset, metadata)
+			}
+			topic.partitions = append(topic.partitions, commitResult{partition, kerr})
+		}
+		results = append(results, topic)
+	}
+
+	if rc.version >= 3 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(len(results))
+	for _, topic := range results {
+		e.string(topic.name)
+		e.arrayLen(len(topic.partitions))
+		for _, p := range topic.partitions {
+			e.int32(p.partition)
+			e.int16(int16(p.err))
+		}
+	}
+	return true
+}
+
+func handleOffsetFetch(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	groupID := d.string()
+
+	requested := make(map[string][]int32)
+	var order []string
+	topicCount := d.arrayLen()
+	for i := 0; i < topicCount && d.err == nil; i++ {
+		name := d.string()
+		requested[name] = d.int32Array()
+		order = append(order, name)
+		d.tags()
+	}
+	if rc.version >= 7 {
+		d.bool() // require stable
+	}
+	d.tags()
+
+	if topicCount < 0 {
+		requested = b.store.committedTopics(groupID)
+		for name := range requested {
+			order = append(order, name)
+		}
+		sort.Strings(order)
+	}
+
+	if rc.version >= 3 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(len(order))
+	for _, name := range order {
+		e.string(name)
+		partitions := requested[name]
+		e.arrayLen(len(partitions))
+		for _, partition := range partitions {
+			committed := b.store.committed(groupID, name, partition)
+			e.int32(partition)
+			e.int64(committed.Offset)
+			if rc.version >= 5 {
+				e.int32(-1) // leader epoch
+			}
+			e.nullableString(&committed.Metadata)
+			e.int16(0)
+			e.tags()
+		}
+		e.tags()
+	}
+	if rc.version >= 2 {
+		e.int16(0)
+	}
+	e.tags()
+	return true
+}
+
+func handleListGroups(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	var statesFilter []string
+	if rc.version >= 4 {
+		statesFilter = d.stringArray()
+	}
+	d.tags()
+
+	summaries := b.groupSummaries()
+	var groups []groupSummary
+	for _, summary := range summaries {
+		if len(statesFilter) == 0 || containsString(statesFilter, summary.state) {
+			groups = append(groups, summary)
+		}
+	}
+
+	if rc.version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.int16(0)
+	e.arrayLen(len(groups))
+	for _, g := range groups {
+		e.string(g.id)
+		e.string(g.protocolType)
+		if rc.version >= 4 {
+			e.string(g.state)
+		}
+		e.tags()
+	}
+	e.tags()
+	return true
+}
+
+// groupSummaries returns active groups plus groups that only have committed offsets
+func (b *Broker) groupSummaries() []groupSummary {
+	summaries := b.groups.list()
+	known := make(map[string]bool)
+	for _, summary := range summaries {
+		known[summary.id] = true
+	}
+	for _, name := range b.store.groupNames() {
+		if !known[name] {
+			summaries = append(summaries, groupSummary{id: name, state: stateEmpty, protocolType: "consumer"})
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].id < summaries[j].id })
+	return summaries
+}
+
+func handleDescribeGroups(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	groupIDs := d.stringArray()
+	if rc.version >= 3 {
+		d.bool() // include authorized operations
+	}
+
+	if rc.version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(len(groupIDs))
+	for _, id := range groupIDs {
+		summary, ok := b.groups.describe(id)
+		if !ok {
+			summary = groupSummary{id: id, state: "Dead"}
+			if len(b.store.committedTopics(id)) > 0 {
+				summary.state = stateEmpty
+				summary.protocolType = "consumer"
+			}
+		}
+
+		e.int16(0)
+		e.string(summary.id)
+		e.string(summary.state)
+		e.string(summary.protocolType)
+		e.string(summary.protocol)
+		e.arrayLen(len(summary.members))
+		for _, m := range summary.members {
+			e.string(m.id)
+			if rc.version >= 4 {
+				e.nullableString(m.instanceID)
+			}
+			e.string(m.clientID)
+			e.string(m.clientHost)
+			e.bytes(m.metadata(summary.protocol))
+			if m.assignment == nil {
+				e.bytes([]byte{})
+			} else {
+				e.bytes(m.assignment)
+			}
+		}
+		if rc.version >= 3 {
+			e.int32(math.MinInt32) // authorized operations
+		}
+	}
+	return true
+}
+
+func handleCreateTopics(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	type topicRequest struct {
+		name       string
+		partitions int32
+	}
+
+	var requests []topicRequest
+	count := d.arrayLen()
+	for i := 0; i < count && d.err == nil; i++ {
+		req := topicRequest{name: d.string(), partitions: d.int32()}
+		d.int16() // replication factor
+		assignments := d.arrayLen()
+		for j := 0; j < assignments && d.err == nil; j++ {
+			d.int32()
+			d.int32Array()
+		}
+		if assignments > 0 {
+			req.partitions = int32(assignments)
+		}
+		configs := d.arrayLen()
+		for j := 0; j < configs && d.err == nil; j++ {
+			d.string()
+			d.nullableString()
+		}
+		requests = append(requests, req)
+	}
+	d.int32() // timeout
+	validateOnly := false
+	if rc.version >= 1 {
+		validateOnly = d.bool()
+	}
+	if d.err != nil {
+		return true
+	}
+
+	if rc.version >= 2 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(len(requests))
+	for _, req := range requests {
+		partitions := req.partitions
+		if partitions < 0 {
+			partitions = b.config.DefaultPartitions
+		}
+
+		kerr := sarama.ErrNoError
+		if validateOnly {
+			if b.store.partitionCount(req.name) >= 0 {
+				kerr = sarama.ErrTopicAlreadyExists
+			}
+		} else {
+			kerr = b.store.createTopic(req.name, partitions)
+			if kerr == sarama.ErrNoError {
+				b.logf("created topic %s with %d partition(s)", req.name, partitions)
+			}
+		}
+
+		e.string(req.name)
+		e.int16(int16(kerr))
+		if rc.version >= 1 {
+			e.nullableString(nil) // error message
+		}
+	}
+	return true
+}
+
+func handleDeleteTopics(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	names := d.stringArray()
+	d.int32() // timeout
+	if d.err != nil {
+		return true
+	}
+
+	if rc.version >= 1 {
+		e.int32(0) // throttle time
+	}
+	e.arrayLen(len(names))
+	for _, name := range names {
+		kerr := b.store.deleteTopic(name)
+		if kerr == sarama.ErrNoError {
+			b.logf("deleted topic %s", name)
+		}
+		e.string(name)
+		e.int16(int16(kerr))
+	}
+	return true
+}
+
+// Config resource types used by DescribeConfigs
+const (
+	resourceTopic  int8 = 2
+	resourceBroker int8 = 4
+)
+
+func handleDescribeConfigs(b *Broker, rc *requestContext, d *decoder, e *encoder) bool {
+	type resource struct {
+		resourceType int8
+		name         string
+	}
+
+	var resources []resource
+	count := d.arrayLen()
+	for i := 0; i < count && d.err == nil; i++ {
+		r := resource{resourceType: d.int8(), name: d.string()}
+		d.stringArray() // config names
+		resources = append(resources, r)
+	}
+	if rc.version >= 1 {
+		d.bool() // include synonyms
+	}
+	if rc.version >= 3 {
+		d.bool() // include documentation
+	}
+	if d.err != nil {
+		return true
+	}
+
+	// The mock broker has no configurable settings, so every resource reports an empty config set
+	e.int32(0) // throttle time
+	e.arrayLen(len(resources))
+	for _, r := range resources {
+		kerr := sarama.ErrNoError
+		switch r.resourceType {
+		case resourceTopic:
+			if b.store.partitionCount(r.name) < 0 {
+				kerr = sarama.ErrUnknownTopicOrPartition
+			}
+		case resourceBroker:
+		default:
+			kerr = sarama.ErrInvalidRequest
+		}
+
+		e.int16(int16(kerr))
+		e.nullableString(nil) // error message
+		e.int8(r.resourceType)
+		e.string(r.name)
+		e.arrayLen(0) // configs
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,448 @@
+package mockbroker
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Config holds configuration for the mock broker
+type Config struct {
+	Host              string
+	Port              int
+	Topics            map[string]int32
+	DefaultPartitions int32
+	AutoCreateTopics  bool
+	SnapshotFile      string
+	SnapshotInterval  time.Duration
+	Verbose           bool
+}
+
+// DefaultConfig returns default mock broker configuration
+func DefaultConfig() Config {
+	return Config{
+		Host:              "localhost",
+		Port:              9092,
+		Topics:            make(map[string]int32),
+		DefaultPartitions: 1,
+		AutoCreateTopics:  true,
+		SnapshotInterval:  30 * time.Second,
+	}
+}
+
+// nodeID is the id the single mock broker advertises
+const nodeID int32 = 1
+
+// Broker is an in-process, in-memory Kafka broker
+type Broker struct {
+	config   Config
+	listener net.Listener
+	store    *store
+	groups   *coordinator
+	port     int32
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Run is the main entry point for the mock-broker command
+func Run(args []string) error {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			return printHelp()
+		}
+	}
+
+	config := DefaultConfig()
+
+	if err := parseArgs(args, &config); err != nil {
+		return err
+	}
+
+	broker, err := New(config)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Mock Kafka broker listening on %s", broker.Addr())
+	for _, topic := range broker.store.topicNames() {
+		log.Printf("  topic %s (%d partitions)", topic, broker.store.partitionCount(topic))
+	}
+
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- broker.Serve()
+	}()
+
+	select {
+	case <-sigterm:
+		log.Println("Shutting down mock broker...")
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	}
+
+	return broker.Close()
+}
+
+func parseArgs(args []string, config *Config) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--port", "-p":
+			if i+1 < len(args) {
+				port, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid port: %s", args[i+1])
+				}
+				config.Port = port
+				i++
+			}
+		case "--host":
+			if i+1 < len(args) {
+				config.Host = args[i+1]
+				i++
+			}
+		case "--topics", "-t":
+			if i+1 < len(args) {
+				topics, err := parseTopics(args[i+1])
+				if err != nil {
+					return err
+				}
+				for name, partitions := range topics {
+					config.Topics[name] = partitions
+				}
+				i++
+			}
+		case "--default-partitions":
+			if i+1 < len(args) {
+				partitions, err := strconv.ParseInt(args[i+1], 10, 32)
+				if err != nil || partitions <= 0 {
+					return fmt.Errorf("invalid partition count: %s", args[i+1])
+				}
+				config.DefaultPartitions = int32(partitions)
+				i++
+			}
+		case "--no-auto-create":
+			config.AutoCreateTopics = false
+		case "--snapshot", "-s":
+			if i+1 < len(args) {
+				config.SnapshotFile = args[i+1]
+				i++
+			}
+		case "--snapshot-interval":
+			if i+1 < len(args) {
+				interval, err := time.ParseDuration(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid snapshot interval: %s", args[i+1])
+				}
+				config.SnapshotInterval = interval
+				i++
+			}
+		case "--verbose", "-v":
+			config.Verbose = true
+		default:
+			return fmt.Errorf("unknown option: %s", arg)
+		}
+	}
+	return nil
+}
+
+// parseTopics parses a topic list such as "a:3,b:1"
+func parseTopics(spec string) (map[string]int32, error) {
+	topics := make(map[string]int32)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, count, found := strings.Cut(entry, ":")
+		partitions := int64(1)
+		if found {
+			var err error
+			partitions, err = strconv.ParseInt(count, 10, 32)
+			if err != nil || partitions <= 0 {
+				return nil, fmt.Errorf("invalid partition count for topic %s: %s", name, count)
+			}
+		}
+		topics[name] = int32(partitions)
+	}
+	return topics, nil
+}
+
+func printHelp() error {
+	help := `Usage: kafka mock-broker [options]
+
+Run an in-memory Kafka broker for offline development and tests. It speaks
+enough of the Kafka protocol for sarama-based clients (including consume,
+produce and admin) configured for Kafka versions up to 2.6.
+
+Supported APIs: ApiVersions, Metadata, Produce, Fetch, ListOffsets,
+FindCoordinator, JoinGroup, SyncGroup, Heartbeat, LeaveGroup, OffsetCommit,
+OffsetFetch, ListGroups, DescribeGroups, CreateTopics, DeleteTopics,
+DescribeConfigs.
+
+Options:
+  --port, -p PORT             Port to listen on (default: 9092, 0 for a random port)
+  --host HOST                 Host to bind and advertise (default: localhost)
+  --topics, -t TOPICS         Topics to create, as name:partitions (e.g. a:3,b:1)
+  --default-partitions NUM    Partitions for auto-created topics (default: 1)
+  --no-auto-create            Do not create unknown topics on metadata requests
+  --snapshot, -s FILE         Load data from FILE on start and save it on shutdown
+  --snapshot-interval DUR     How often to save the snapshot (default: 30s, 0 disables)
+  --verbose, -v               Log every request
+  -h, --help                  Show this help message
+
+Examples:
+  kafka mock-broker --topics orders:3,payments:1
+  kafka mock-broker --port 19092 --snapshot dev-kafka.json
+  kafka produce --brokers localhost:19092 orders < orders.ndjson`
+
+	fmt.Println(help)
+	return nil
+}
+
+// New creates a mock broker and starts listening, without serving requests yet
+func New(config Config) (*Broker, error) {
+	b := &Broker{
+		config:  config,
+		store:   newStore(),
+		conns:   make(map[net.Conn]struct{}),
+		closing: make(chan struct{}),
+	}
+	b.groups = newCoordinator(b.logf)
+
+	if config.SnapshotFile != "" {
+		if err := b.store.load(config.SnapshotFile); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(config.Topics))
+	for name := range config.Topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if b.store.partitionCount(name) >= 0 {
+			continue
+		}
+		if kerr := b.store.createTopic(name, config.Topics[name]); kerr != 0 {
+			return nil, fmt.Errorf("failed to create topic %s: %v", name, kerr)
+		}
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(config.Host, strconv.Itoa(config.Port)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	b.listener = listener
+	b.port = int32(listener.Addr().(*net.TCPAddr).Port)
+
+	return b, nil
+}
+
+// Addr returns the advertised host:port of the broker
+func (b *Broker) Addr() string {
+	return net.JoinHostPort(b.config.Host, strconv.Itoa(int(b.port)))
+}
+
+// Serve accepts connections until the broker is closed
+func (b *Broker) Serve() error {
+	b.wg.Add(1)
+	go b.housekeeping()
+
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			select {
+			case <-b.closing:
+				return nil
+			default:
+				return fmt.Errorf("accept failed: %w", err)
+			}
+		}
+
+		b.mu.Lock()
+		b.conns[conn] = struct{}{}
+		b.mu.Unlock()
+
+		b.wg.Add(1)
+		go b.handleConnection(conn)
+	}
+}
+
+// Close stops the broker and writes the final snapshot
+func (b *Broker) Close() error {
+	close(b.closing)
+	err := b.listener.Close()
+
+	b.mu.Lock()
+	for conn := range b.conns {
+		conn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+
+	if b.config.SnapshotFile != "" {
+		if saveErr := b.store.save(b.config.SnapshotFile); saveErr != nil {
+			return saveErr
+		}
+	}
+	return err
+}
+
+// housekeeping expires group sessions and saves periodic snapshots
+func (b *Broker) housekeeping() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	lastSnapshot := time.Now()
+
+	for {
+		select {
+		case <-b.closing:
+			return
+		case now := <-ticker.C:
+			b.groups.expireSessions(now)
+			if b.config.SnapshotFile != "" && b.config.SnapshotInterval > 0 && now.Sub(lastSnapshot) >= b.config.SnapshotInterval {
+				if err := b.store.save(b.config.SnapshotFile); err != nil {
+					log.Printf("Failed to save snapshot: %v", err)
+				}
+				lastSnapshot = now
+			}
+		}
+	}
+}
+
+func (b *Broker) logf(format string, v ...interface{}) {
+	if b.config.Verbose {
+		log.Printf(format, v...)
+	}
+}
+
+// requestContext carries per-request connection details to handlers
+type requestContext struct {
+	apiKey     int16
+	version    int16
+	clientID   string
+	clientHost string
+}
+
+// handleConnection reads requests from a connection and writes responses in order
+func (b *Broker) handleConnection(conn net.Conn) {
+	defer b.wg.Done()
+	defer func() {
+		conn.Close()
+		b.mu.Lock()
+		delete(b.conns, conn)
+		b.mu.Unlock()
+	}()
+
+	clientHost, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	reader := bufio.NewReader(conn)
+	sizeBuf := make([]byte, 4)
+
+	for {
+		if _, err := io.ReadFull(reader, sizeBuf); err != nil {
+			return
+		}
+		size := int32(binary.BigEndian.Uint32(sizeBuf))
+		if size <= 0 || size > 100*1024*1024 {
+			b.logf("closing connection from %s: invalid request size %d", clientHost, size)
+			return
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+
+		response, ok := b.handleRequest(body, clientHost)
+		if !ok {
+			return
+		}
+		if response == nil {
+			continue
+		}
+		if _, err := conn.Write(response); err != nil {
+			return
+		}
+	}
+}
+
+// handleRequest decodes a request frame and returns the encoded response frame.
+// A nil response with ok set means the request expects no response.
+func (b *Broker) handleRequest(body []byte, clientHost string) ([]byte, bool) {
+	header := &decoder{buf: body}
+	apiKey := header.int16()
+	version := header.int16()
+	correlationID := header.int32()
+	clientID := header.nullableString()
+	if header.err != nil {
+		b.logf("closing connection from %s: malformed request header", clientHost)
+		return nil, false
+	}
+
+	api, supported := apis[apiKey]
+	unsupported := !supported || version < api.minVersion || version > api.maxVersion
+	if unsupported {
+		log.Printf("Unsupported request from %s: api key %d version %d", clientHost, apiKey, version)
+		if apiKey != apiApiVersions {
+			return nil, false
+		}
+		// Tell the client which versions we support, using the v0 layout
+		version = 0
+	}
+
+	flexible := api.flexibleVersion >= 0 && version >= api.flexibleVersion
+	d := &decoder{buf: body, off: header.off, flexible: flexible}
+	d.tags()
+
+	rc := &requestContext{apiKey: apiKey, version: version, clientHost: clientHost}
+	if clientID != nil {
+		rc.clientID = *clientID
+	}
+	b.logf("%s request v%d from %s (%s)", api.name, version, rc.clientID, clientHost)
+
+	e := &encoder{flexible: flexible}
+	e.int32(0) // size placeholder
+	e.int32(correlationID)
+	if flexible && apiKey != apiApiVersions {
+		e.tags()
+	}
+
+	if unsupported {
+		e.int16(int16(sarama.ErrUnsupportedVersion))
+		writeApiVersions(e, 0)
+	} else if !api.handler(b, rc, d, e) {
+		return nil, true
+	}
+
+	if d.err != nil {
+		log.Printf("Malformed %s request from %s: %v", api.name, clientHost, d.err)
+		return nil, false
+	}
+
+	binary.BigEndian.PutUint32(e.buf, uint32(len(e.buf)-4))
+	return e.buf, true
+}
@@ -0,0 +1,350 @@
+package mockbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// startBroker serves a mock broker on a random port for the test
+func startBroker(t *testing.T, topics map[string]int32) *Broker {
+	t.Helper()
+	config := DefaultConfig()
+	config.Host = "127.0.0.1"
+	config.Port = 0
+	config.Topics = topics
+	broker, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go broker.Serve()
+	t.Cleanup(func() { broker.Close() })
+	return broker
+}
+
+func clientConfig() *sarama.Config {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_6_0_0
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = sarama.NewManualPartitioner
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+	return config
+}
+
+// produce sends each message on its own, so each is a record batch
+func produce(t *testing.T, broker *Broker, messages ...*sarama.ProducerMessage) {
+	t.Helper()
+	producer, err := sarama.NewSyncProducer([]string{broker.Addr()}, clientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer producer.Close()
+	for _, message := range messages {
+		if _, _, err := producer.SendMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProduceAndFetch(t *testing.T) {
+	broker := startBroker(t, map[string]int32{"orders": 2})
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var messages []*sarama.ProducerMessage
+	for i := 0; i < 3; i++ {
+		messages = append(messages, &sarama.ProducerMessage{
+			Topic:     "orders",
+			Partition: 1,
+			Key:       sarama.StringEncoder(fmt.Sprintf("k%d", i)),
+			Value:     sarama.StringEncoder(fmt.Sprintf(`{"n":%d}`, i)),
+			Headers:   []sarama.RecordHeader{{Key: []byte("source"), Value: []byte("test")}},
+			Timestamp: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	produce(t, broker, messages...)
+
+	consumer, err := sarama.NewConsumer([]string{broker.Addr()}, clientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+
+	partitions, err := consumer.Partitions("orders")
+	if err != nil || len(partitions) != 2 {
+		t.Fatalf("expected 2 partitions, got %v, %v", partitions, err)
+	}
+
+	pc, err := consumer.ConsumePartition("orders", 1, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case message := <-pc.Messages():
+			if message.Offset != int64(i) || string(message.Key) != fmt.Sprintf("k%d", i) || string(message.Value) != fmt.Sprintf(`{"n":%d}`, i) {
+				t.Fatalf("unexpected message %d: offset %d key %s value %s", i, message.Offset, message.Key, message.Value)
+			}
+			if !message.Timestamp.Equal(messages[i].Timestamp) {
+				t.Errorf("message %d: expected timestamp %v, got %v", i, messages[i].Timestamp, message.Timestamp)
+			}
+			if len(message.Headers) != 1 || string(message.Headers[0].Key) != "source" || string(message.Headers[0].Value) != "test" {
+				t.Errorf("message %d: unexpected headers %v", i, message.Headers)
+			}
+		case err := <-pc.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+
+	// A message produced while consuming is delivered
+	produce(t, broker, &sarama.ProducerMessage{Topic: "orders", Partition: 1, Value: sarama.StringEncoder("late")})
+	select {
+	case message := <-pc.Messages():
+		if message.Offset != 3 || string(message.Value) != "late" {
+			t.Fatalf("unexpected message at offset %d: %s", message.Offset, message.Value)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the late message")
+	}
+	pc.Close()
+
+	// Fetching from the middle starts at that offset
+	from, err := consumer.ConsumePartition("orders", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer from.Close()
+	select {
+	case message := <-from.Messages():
+		if message.Offset != 2 {
+			t.Fatalf("expected offset 2, got %d", message.Offset)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for offset 2")
+	}
+}
+
+func TestListOffsets(t *testing.T) {
+	broker := startBroker(t, map[string]int32{"events": 1})
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		produce(t, broker, &sarama.ProducerMessage{
+			Topic:     "events",
+			Value:     sarama.StringEncoder("x"),
+			Timestamp: base.Add(time.Duration(i) * time.Hour),
+		})
+	}
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, clientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	millis := func(t time.Time) int64 { return t.UnixNano() / int64(time.Millisecond) }
+	tests := []struct {
+		name string
+		time int64
+		want int64
+	}{
+		{"oldest", sarama.OffsetOldest, 0},
+		{"newest", sarama.OffsetNewest, 3},
+		{"before every message", millis(base.Add(-time.Hour)), 0},
+		{"at the second message", millis(base.Add(time.Hour)), 1},
+		{"between messages", millis(base.Add(90 * time.Minute)), 2},
+		{"after every message", millis(base.Add(3 * time.Hour)), -1},
+	}
+	for _, tt := range tests {
+		offset, err := client.GetOffset("events", 0, tt.time)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if offset != tt.want {
+			t.Errorf("%s: expected offset %d, got %d", tt.name, tt.want, offset)
+		}
+	}
+
+	if _, err := client.GetOffset("events", 5, sarama.OffsetNewest); err == nil {
+		t.Error("expected an error for an unknown partition")
+	}
+}
+
+func TestOffsetCommitAndFetch(t *testing.T) {
+	broker := startBroker(t, map[string]int32{"payments": 1})
+	produce(t, broker,
+		&sarama.ProducerMessage{Topic: "payments", Value: sarama.StringEncoder("a")},
+		&sarama.ProducerMessage{Topic: "payments", Value: sarama.StringEncoder("b")},
+	)
+
+	client, err := sarama.NewClient([]string{broker.Addr()}, clientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	nextOffset := func() (int64, string) {
+		manager, err := sarama.NewOffsetManagerFromClient("billing", client)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer manager.Close()
+		pom, err := manager.ManagePartition("payments", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pom.Close()
+		return pom.NextOffset()
+	}
+
+	// Nothing committed yet: the configured initial offset
+	if offset, _ := nextOffset(); offset != sarama.OffsetOldest {
+		t.Fatalf("expected no committed offset, got %d", offset)
+	}
+
+	manager, err := sarama.NewOffsetManagerFromClient("billing", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pom, err := manager.ManagePartition("payments", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pom.MarkOffset(2, "checkpoint")
+	manager.Commit()
+	pom.Close()
+	if err := manager.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if offset, metadata := nextOffset(); offset != 2 || metadata != "checkpoint" {
+		t.Fatalf("expected offset 2 with metadata, got %d %q", offset, metadata)
+	}
+}
+
+// collectHandler records the messages a consumer group session sees and
+// marks them consumed
+type collectHandler struct {
+	mu       sync.Mutex
+	values   []string
+	want     int
+	done     chan struct{}
+	doneOnce sync.Once
+	claims   map[string][]int32
+}
+
+func (h *collectHandler) Setup(session sarama.ConsumerGroupSession) error {
+	h.mu.Lock()
+	h.claims = session.Claims()
+	h.mu.Unlock()
+	return nil
+}
+
+func (h *collectHandler) Cleanup(sarama.ConsumerGroupSession) error { return nil }
+
+func (h *collectHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for message := range claim.Messages() {
+		session.MarkMessage(message, "")
+		h.mu.Lock()
+		h.values = append(h.values, string(message.Value))
+		if len(h.values) >= h.want {
+			h.doneOnce.Do(func() { close(h.done) })
+		}
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+// consumeGroup joins the group, reads want messages and leaves
+func consumeGroup(t *testing.T, broker *Broker, group string, want int) *collectHandler {
+	t.Helper()
+	config := clientConfig()
+	config.Consumer.Offsets.AutoCommit.Interval = 50 * time.Millisecond
+	consumerGroup, err := sarama.NewConsumerGroup([]string{broker.Addr()}, group, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := &collectHandler{want: want, done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan error, 1)
+	go func() {
+		for ctx.Err() == nil {
+			if err := consumerGroup.Consume(ctx, []string{"orders"}, handler); err != nil {
+				finished <- err
+				return
+			}
+		}
+		finished <- nil
+	}()
+
+	select {
+	case <-handler.done:
+	case err := <-consumerGroup.Errors():
+		t.Fatal(err)
+	case <-time.After(10 * time.Second):
+		t.Fatalf("timed out with %d of %d messages", len(handler.values), want)
+	}
+	cancel()
+	if err := <-finished; err != nil && !errors.Is(err, sarama.ErrClosedConsumerGroup) {
+		t.Fatal(err)
+	}
+	// Closing commits the marked offsets and leaves the group
+	if err := consumerGroup.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+func TestConsumerGroup(t *testing.T) {
+	broker := startBroker(t, map[string]int32{"orders": 2})
+	for i := 0; i < 4; i++ {
+		produce(t, broker, &sarama.ProducerMessage{Topic: "orders", Partition: int32(i % 2), Value: sarama.StringEncoder(fmt.Sprintf("m%d", i))})
+	}
+
+	first := consumeGroup(t, broker, "shipping", 4)
+	if len(first.values) != 4 {
+		t.Fatalf("expected 4 messages, got %v", first.values)
+	}
+	if claims := first.claims["orders"]; len(claims) != 2 {
+		t.Fatalf("expected the only member to be assigned both partitions, got %v", first.claims)
+	}
+
+	admin, err := sarama.NewClusterAdmin([]string{broker.Addr()}, clientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	groups, err := admin.ListConsumerGroups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := groups["shipping"]; !ok {
+		t.Fatalf("expected the group to be listed, got %v", groups)
+	}
+	offsets, err := admin.ListConsumerGroupOffsets("shipping", map[string][]int32{"orders": {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, partition := range []int32{0, 1} {
+		block := offsets.GetBlock("orders", partition)
+		if block == nil || block.Offset != 2 {
+			t.Fatalf("expected committed offset 2 for partition %d, got %+v", partition, block)
+		}
+	}
+
+	// Rejoining resumes after the committed offsets
+	produce(t, broker, &sarama.ProducerMessage{Topic: "orders", Partition: 1, Value: sarama.StringEncoder("m4")})
+	second := consumeGroup(t, broker, "shipping", 1)
+	if len(second.values) != 1 || second.values[0] != "m4" {
+		t.Fatalf("expected only the new message, got %v", second.values)
+	}
+}
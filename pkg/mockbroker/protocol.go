@@ -0,0 +1,256 @@
+package mockbroker
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errShortBuffer = errors.New("insufficient data to decode request")
+
+// decoder reads Kafka protocol primitives from a request body
+type decoder struct {
+	buf      []byte
+	off      int
+	flexible bool
+	err      error
+}
+
+func (d *decoder) need(n int) bool {
+	if d.err != nil {
+		return false
+	}
+	if n < 0 || d.off+n > len(d.buf) {
+		d.err = errShortBuffer
+		return false
+	}
+	return true
+}
+
+func (d *decoder) int8() int8 {
+	if !d.need(1) {
+		return 0
+	}
+	v := int8(d.buf[d.off])
+	d.off++
+	return v
+}
+
+func (d *decoder) bool() bool {
+	return d.int8() != 0
+}
+
+func (d *decoder) int16() int16 {
+	if !d.need(2) {
+		return 0
+	}
+	v := int16(binary.BigEndian.Uint16(d.buf[d.off:]))
+	d.off += 2
+	return v
+}
+
+func (d *decoder) int32() int32 {
+	if !d.need(4) {
+		return 0
+	}
+	v := int32(binary.BigEndian.Uint32(d.buf[d.off:]))
+	d.off += 4
+	return v
+}
+
+func (d *decoder) int64() int64 {
+	if !d.need(8) {
+		return 0
+	}
+	v := int64(binary.BigEndian.Uint64(d.buf[d.off:]))
+	d.off += 8
+	return v
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf[d.off:])
+	if n <= 0 {
+		d.err = errShortBuffer
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+func (d *decoder) raw(n int) []byte {
+	if !d.need(n) {
+		return nil
+	}
+	v := d.buf[d.off : d.off+n]
+	d.off += n
+	return v
+}
+
+// arrayLen returns the element count of an array, or -1 for a null array
+func (d *decoder) arrayLen() int {
+	if d.flexible {
+		return int(d.uvarint()) - 1
+	}
+	return int(d.int32())
+}
+
+// nullableString returns nil for a null string
+func (d *decoder) nullableString() *string {
+	var n int
+	if d.flexible {
+		n = int(d.uvarint()) - 1
+	} else {
+		n = int(d.int16())
+	}
+	if n < 0 {
+		return nil
+	}
+	s := string(d.raw(n))
+	return &s
+}
+
+func (d *decoder) string() string {
+	if s := d.nullableString(); s != nil {
+		return *s
+	}
+	return ""
+}
+
+func (d *decoder) bytes() []byte {
+	var n int
+	if d.flexible {
+		n = int(d.uvarint()) - 1
+	} else {
+		n = int(d.int32())
+	}
+	if n < 0 {
+		return nil
+	}
+	return d.raw(n)
+}
+
+func (d *decoder) int32Array() []int32 {
+	n := d.arrayLen()
+	var values []int32
+	for i := 0; i < n && d.err == nil; i++ {
+		values = append(values, d.int32())
+	}
+	return values
+}
+
+func (d *decoder) stringArray() []string {
+	n := d.arrayLen()
+	var values []string
+	for i := 0; i < n && d.err == nil; i++ {
+		values = append(values, d.string())
+	}
+	return values
+}
+
+// tags skips a tagged field section in flexible versions
+func (d *decoder) tags() {
+	if !d.flexible {
+		return
+	}
+	count := int(d.uvarint())
+	for i := 0; i < count && d.err == nil; i++ {
+		d.uvarint()
+		d.raw(int(d.uvarint()))
+	}
+}
+
+// encoder writes Kafka protocol primitives into a response body
+type encoder struct {
+	buf      []byte
+	flexible bool
+}
+
+func (e *encoder) int8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.int8(1)
+	} else {
+		e.int8(0)
+	}
+}
+
+func (e *encoder) int16(v int16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+}
+
+func (e *encoder) int32(v int32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+}
+
+func (e *encoder) int64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *encoder) uvarint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) arrayLen(n int) {
+	if e.flexible {
+		e.uvarint(uint64(n + 1))
+	} else {
+		e.int32(int32(n))
+	}
+}
+
+func (e *encoder) string(s string) {
+	if e.flexible {
+		e.uvarint(uint64(len(s) + 1))
+	} else {
+		e.int16(int16(len(s)))
+	}
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) nullableString(s *string) {
+	if s == nil {
+		if e.flexible {
+			e.uvarint(0)
+		} else {
+			e.int16(-1)
+		}
+		return
+	}
+	e.string(*s)
+}
+
+func (e *encoder) bytes(b []byte) {
+	if b == nil {
+		if e.flexible {
+			e.uvarint(0)
+		} else {
+			e.int32(-1)
+		}
+		return
+	}
+	if e.flexible {
+		e.uvarint(uint64(len(b) + 1))
+	} else {
+		e.int32(int32(len(b)))
+	}
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) int32Array(values []int32) {
+	e.arrayLen(len(values))
+	for _, v := range values {
+		e.int32(v)
+	}
+}
+
+// tags writes an empty tagged field section in flexible versions
+func (e *encoder) tags() {
+	if e.flexible {
+		e.uvarint(0)
+	}
+}
@@ -0,0 +1,387 @@
+package mockbroker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/IBM/sarama"
+)
+
+// Offsets within a v2 record batch header
+const (
+	batchBaseOffset      = 0
+	batchLength          = 8
+	batchMagic           = 16
+	batchCRC             = 17
+	batchAttributes      = 21
+	batchLastOffsetDelta = 23
+	batchFirstTimestamp  = 27
+	batchMaxTimestamp    = 35
+	batchHeaderSize      = 61
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// partitionLog holds the record batches of a single partition
+type partitionLog struct {
+	Batches     [][]byte `json:"batches"`
+	StartOffset int64    `json:"start_offset"`
+	NextOffset  int64    `json:"next_offset"`
+}
+
+// committedOffset is a consumer group offset commit
+type committedOffset struct {
+	Offset   int64  `json:"offset"`
+	Metadata string `json:"metadata,omitempty"`
+}
+
+// snapshot is the on-disk representation of the store
+type snapshot struct {
+	Topics  map[string][]*partitionLog                      `json:"topics"`
+	Offsets map[string]map[string]map[int32]committedOffset `json:"offsets"`
+}
+
+// store keeps topic data and committed offsets in memory
+type store struct {
+	mu      sync.Mutex
+	topics  map[string][]*partitionLog
+	offsets map[string]map[string]map[int32]committedOffset
+	notify  chan struct{}
+}
+
+func newStore() *store {
+	return &store{
+		topics:  make(map[string][]*partitionLog),
+		offsets: make(map[string]map[string]map[int32]committedOffset),
+		notify:  make(chan struct{}),
+	}
+}
+
+// changed returns a channel that is closed when new records are appended
+func (s *store) changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.notify
+}
+
+// topicNames returns all topic names in sorted order
+func (s *store) topicNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.topics))
+	for name := range s.topics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// partitionCount returns the number of partitions of a topic, or -1 if it does not exist
+func (s *store) partitionCount(topic string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	partitions, ok := s.topics[topic]
+	if !ok {
+		return -1
+	}
+	return len(partitions)
+}
+
+func (s *store) createTopic(name string, partitions int32) sarama.KError {
+	if name == "" || len(name) > 249 {
+		return sarama.ErrInvalidTopic
+	}
+	if partitions <= 0 {
+		return sarama.ErrInvalidPartitions
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.topics[name]; exists {
+		return sarama.ErrTopicAlreadyExists
+	}
+
+	logs := make([]*partitionLog, partitions)
+	for i := range logs {
+		logs[i] = &partitionLog{}
+	}
+	s.topics[name] = logs
+	return sarama.ErrNoError
+}
+
+func (s *store) deleteTopic(name string) sarama.KError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.topics[name]; !exists {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+	delete(s.topics, name)
+	return sarama.ErrNoError
+}
+
+// partition returns the log for a partition; the caller must hold the lock
+func (s *store) partition(topic string, partition int32) *partitionLog {
+	partitions, ok := s.topics[topic]
+	if !ok || partition < 0 || int(partition) >= len(partitions) {
+		return nil
+	}
+	return partitions[partition]
+}
+
+// append stores v2 record batches and assigns their offsets
+func (s *store) append(topic string, partition int32, records []byte) (int64, sarama.KError) {
+	var batches [][]byte
+	for len(records) > 0 {
+		if len(records) < batchHeaderSize {
+			return -1, sarama.ErrInvalidMessage
+		}
+		size := 12 + int(int32(binary.BigEndian.Uint32(records[batchLength:])))
+		if size < batchHeaderSize || size > len(records) {
+			return -1, sarama.ErrInvalidMessage
+		}
+		if records[batchMagic] != 2 {
+			return -1, sarama.ErrUnsupportedVersion
+		}
+		batch := make([]byte, size)
+		copy(batch, records[:size])
+		setMaxTimestamp(batch)
+		batches = append(batches, batch)
+		records = records[size:]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log := s.partition(topic, partition)
+	if log == nil {
+		return -1, sarama.ErrUnknownTopicOrPartition
+	}
+
+	baseOffset := log.NextOffset
+	for _, batch := range batches {
+		// The batch CRC starts after the base offset, so rewriting it is safe
+		binary.BigEndian.PutUint64(batch[batchBaseOffset:], uint64(log.NextOffset))
+		lastOffsetDelta := int64(int32(binary.BigEndian.Uint32(batch[batchLastOffsetDelta:])))
+		log.NextOffset += lastOffsetDelta + 1
+		log.Batches = append(log.Batches, batch)
+	}
+
+	if len(batches) > 0 {
+		close(s.notify)
+		s.notify = make(chan struct{})
+	}
+
+	return baseOffset, sarama.ErrNoError
+}
+
+// setMaxTimestamp fills in the batch max timestamp from its records, as a real broker
+// does, since producers such as sarama leave it unset. The CRC is updated to match.
+func setMaxTimestamp(batch []byte) {
+	first := int64(binary.BigEndian.Uint64(batch[batchFirstTimestamp:]))
+	max := first
+
+	var payload []byte
+	switch batch[batchAttributes+1] & 0x07 {
+	case 0:
+		payload = batch[batchHeaderSize:]
+	case 1:
+		reader, err := gzip.NewReader(bytes.NewReader(batch[batchHeaderSize:]))
+		if err == nil {
+			payload, _ = io.ReadAll(reader)
+		}
+	}
+
+	// Each record starts with its length, attributes and timestamp delta
+	for len(payload) > 0 {
+		length, n := binary.Varint(payload)
+		if n <= 0 || length < 0 || int64(len(payload)-n) < length {
+			break
+		}
+		record := payload[n : n+int(length)]
+		payload = payload[n+int(length):]
+		if len(record) < 2 {
+			break
+		}
+		delta, m := binary.Varint(record[1:])
+		if m <= 0 {
+			break
+		}
+		if first+delta > max {
+			max = first + delta
+		}
+	}
+
+	if int64(binary.BigEndian.Uint64(batch[batchMaxTimestamp:])) >= max {
+		return
+	}
+	binary.BigEndian.PutUint64(batch[batchMaxTimestamp:], uint64(max))
+	binary.BigEndian.PutUint32(batch[batchCRC:], crc32.Checksum(batch[batchAttributes:], castagnoli))
+}
+
+// fetch returns batches starting at the one containing offset, up to maxBytes
+func (s *store) fetch(topic string, partition int32, offset int64, maxBytes int32) ([]byte, int64, int64, sarama.KError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log := s.partition(topic, partition)
+	if log == nil {
+		return nil, -1, -1, sarama.ErrUnknownTopicOrPartition
+	}
+	if offset < log.StartOffset || offset > log.NextOffset {
+		return nil, log.NextOffset, log.StartOffset, sarama.ErrOffsetOutOfRange
+	}
+
+	var records []byte
+	for _, batch := range log.Batches {
+		base := int64(binary.BigEndian.Uint64(batch[batchBaseOffset:]))
+		last := base + int64(int32(binary.BigEndian.Uint32(batch[batchLastOffsetDelta:])))
+		if last < offset {
+			continue
+		}
+		if len(records) > 0 && len(records)+len(batch) > int(maxBytes) {
+			break
+		}
+		records = append(records, batch...)
+	}
+
+	return records, log.NextOffset, log.StartOffset, sarama.ErrNoError
+}
+
+// offsetForTime resolves a ListOffsets timestamp to an offset
+func (s *store) offsetForTime(topic string, partition int32, timestamp int64) (int64, int64, sarama.KError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log := s.partition(topic, partition)
+	if log == nil {
+		return -1, -1, sarama.ErrUnknownTopicOrPartition
+	}
+
+	switch timestamp {
+	case sarama.OffsetNewest:
+		return log.NextOffset, -1, sarama.ErrNoError
+	case sarama.OffsetOldest:
+		return log.StartOffset, -1, sarama.ErrNoError
+	}
+
+	// Batch granularity: the first batch whose newest record is not older than timestamp
+	for _, batch := range log.Batches {
+		maxTimestamp := int64(binary.BigEndian.Uint64(batch[batchMaxTimestamp:]))
+		if maxTimestamp >= timestamp {
+			base := int64(binary.BigEndian.Uint64(batch[batchBaseOffset:]))
+			return base, int64(binary.BigEndian.Uint64(batch[batchFirstTimestamp:])), sarama.ErrNoError
+		}
+	}
+
+	return -1, -1, sarama.ErrNoError
+}
+
+func (s *store) commitOffset(group, topic string, partition int32, offset int64, metadata string) sarama.KError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.partition(topic, partition) == nil {
+		return sarama.ErrUnknownTopicOrPartition
+	}
+
+	if s.offsets[group] == nil {
+		s.offsets[group] = make(map[string]map[int32]committedOffset)
+	}
+	if s.offsets[group][topic] == nil {
+		s.offsets[group][topic] = make(map[int32]committedOffset)
+	}
+	s.offsets[group][topic][partition] = committedOffset{Offset: offset, Metadata: metadata}
+	return sarama.ErrNoError
+}
+
+// committed returns the committed offset for a partition, or -1 if there is none
+func (s *store) committed(group, topic string, partition int32) committedOffset {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if offset, ok := s.offsets[group][topic][partition]; ok {
+		return offset
+	}
+	return committedOffset{Offset: -1}
+}
+
+// committedTopics returns the topics a group has committed offsets for
+func (s *store) committedTopics(group string) map[string][]int32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make(map[string][]int32)
+	for topic, partitions := range s.offsets[group] {
+		for partition := range partitions {
+			result[topic] = append(result[topic], partition)
+		}
+	}
+	return result
+}
+
+// groupNames returns groups that have committed offsets
+func (s *store) groupNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.offsets))
+	for name := range s.offsets {
+		names = append(names, name)
+	}
+	return names
+}
+
+// save writes the store to a snapshot file
+func (s *store) save(path string) error {
+	s.mu.Lock()
+	data, err := json.Marshal(snapshot{Topics: s.topics, Offsets: s.offsets})
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// load restores the store from a snapshot file, if it exists
+func (s *store) load(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to parse snapshot %s: %w", path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if snap.Topics != nil {
+		s.topics = snap.Topics
+	}
+	if snap.Offsets != nil {
+		s.offsets = snap.Offsets
+	}
+	return nil
+}
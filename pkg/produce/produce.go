@@ -11,6 +11,10 @@ import (
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/fieldcrypt"
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
+	"github.com/og-dim9/dimutils/pkg/schema"
 )
 
 // Config holds configuration for Kafka producer
@@ -33,6 +37,23 @@ type Config struct {
 	InputFile       string
 	MessageFormat   string // raw, json
 	ValueField      string // JSON field to use as message value
+	Auth            *kafkautils.AuthConfig
+	TLS             *kafkautils.TLSConfig
+
+	// Field encryption
+	EncryptFields string // comma-separated JSON field paths
+	KeyFile       string
+	KeyID         string // master key to wrap data keys with (default: the keyring's current key)
+
+	keyring       *fieldcrypt.Keyring
+	encryptPaths  [][]string
+
+	// Schema validation
+	SchemaFile    string
+	DLQFile       string
+	DLQTopic      string
+
+	schema        *schema.Schema
 }
 
 // DefaultConfig returns default producer configuration
@@ -62,6 +83,15 @@ type MessageInput struct {
 // Run is the main entry point for produce functionality
 func Run(args []string) error {
 	config := DefaultConfig()
+
+	brokers, auth, tlsConfig, err := kafkacontext.ApplyClient(args)
+	if err != nil {
+		return err
+	}
+	if len(brokers) > 0 {
+		config.Brokers = brokers
+	}
+	config.Auth, config.TLS = auth, tlsConfig
 	
 	if err := parseArgs(args, &config); err != nil {
 		return err
@@ -83,33 +113,40 @@ func parseArgs(args []string, config *Config) error {
 		}
 	}
 	
-	for i, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch arg {
 		case "--brokers", "-b":
 			if i+1 < len(args) {
 				config.Brokers = strings.Split(args[i+1], ",")
+				i++
 			}
 		case "--topic", "-t":
 			if i+1 < len(args) {
 				config.Topic = args[i+1]
+				i++
 			}
 		case "--key", "-k":
 			if i+1 < len(args) {
 				config.Key = args[i+1]
+				i++
 			}
 		case "--key-field":
 			if i+1 < len(args) {
 				config.KeyField = args[i+1]
+				i++
 			}
 		case "--value-field":
 			if i+1 < len(args) {
 				config.ValueField = args[i+1]
+				i++
 			}
 		case "--partition", "-p":
 			if i+1 < len(args) {
 				if partition, err := strconv.ParseInt(args[i+1], 10, 32); err == nil {
 					config.Partition = int32(partition)
 				}
+				i++
 			}
 		case "--header", "-H":
 			if i+1 < len(args) {
@@ -117,6 +154,7 @@ func parseArgs(args []string, config *Config) error {
 				if len(parts) == 2 {
 					config.Headers[parts[0]] = parts[1]
 				}
+				i++
 			}
 		case "--async", "-a":
 			config.Async = true
@@ -125,41 +163,82 @@ func parseArgs(args []string, config *Config) error {
 				if size, err := strconv.Atoi(args[i+1]); err == nil {
 					config.BatchSize = size
 				}
+				i++
 			}
 		case "--linger-ms":
 			if i+1 < len(args) {
 				if linger, err := strconv.Atoi(args[i+1]); err == nil {
 					config.LingerMs = linger
 				}
+				i++
 			}
 		case "--compression", "-c":
 			if i+1 < len(args) {
 				config.Compression = args[i+1]
+				i++
 			}
 		case "--acks":
 			if i+1 < len(args) {
 				config.Acks = args[i+1]
+				i++
 			}
 		case "--retries":
 			if i+1 < len(args) {
 				if retries, err := strconv.Atoi(args[i+1]); err == nil {
 					config.Retries = retries
 				}
+				i++
 			}
 		case "--timeout":
 			if i+1 < len(args) {
 				if timeout, err := strconv.Atoi(args[i+1]); err == nil {
 					config.TimeoutMs = timeout
 				}
+				i++
 			}
 		case "--input", "-i":
 			if i+1 < len(args) {
 				config.InputFile = args[i+1]
+				i++
 			}
 		case "--format", "-f":
 			if i+1 < len(args) {
 				config.MessageFormat = args[i+1]
+				i++
+			}
+		case "--encrypt-fields":
+			if i+1 < len(args) {
+				config.EncryptFields = args[i+1]
+				i++
+			}
+		case "--key-file":
+			if i+1 < len(args) {
+				config.KeyFile = args[i+1]
+				i++
+			}
+		case "--key-id":
+			if i+1 < len(args) {
+				config.KeyID = args[i+1]
+				i++
+			}
+		case "--schema":
+			if i+1 < len(args) {
+				config.SchemaFile = args[i+1]
+				i++
+			}
+		case "--dlq-file":
+			if i+1 < len(args) {
+				config.DLQFile = args[i+1]
+				i++
 			}
+		case "--dlq-topic":
+			if i+1 < len(args) {
+				config.DLQTopic = args[i+1]
+				i++
+			}
+		case "--context":
+			// Handled by kafkacontext.ApplyClient
+			i++
 		case "--verbose", "-v":
 			config.Verbose = true
 		case "--dry-run":
@@ -182,6 +261,7 @@ func printHelp() error {
 Produce messages to a Kafka topic from stdin or file.
 
 Options:
+  --context NAME            Kafka context from ~/.config/dimutils/kafka.yaml
   --brokers, -b BROKERS     Comma-separated list of brokers (default: localhost:9092)
   --topic, -t TOPIC         Topic to produce to
   --key, -k KEY             Message key (same for all messages)
@@ -202,11 +282,44 @@ Options:
   --dry-run                 Show what would be sent without actually sending
   -h, --help                Show this help message
 
+Schema validation:
+  --schema FILE             Validate each input line against a JSON schema (as written
+                            by "schema generate") before it is produced
+  --dlq-file FILE           Append rejected lines to FILE as JSON lines with the error
+  --dlq-topic TOPIC         Produce rejected lines to TOPIC with a dlq.error header
+                            Without a dead-letter target, rejected lines are logged and
+                            produce exits with an error once the input is done
+
+  Lines that are not valid JSON are rejected too. A summary of accepted and
+  rejected counts is printed to stderr at the end.
+
+Field encryption:
+  --encrypt-fields PATHS    Encrypt these comma-separated JSON field paths, e.g.
+                            customer.email,cards.*.number ("*" matches every element;
+                            a backslash escapes a "." or "," in a field name)
+  --key-file FILE           Keyring file with the master keys
+  --key-id ID               Master key to use (default: the keyring's current key)
+
+  Each message gets a random AES-256-GCM data key that encrypts the fields and
+  is itself wrapped by the master key. Encrypted fields become "enc:v1:..." strings
+  and the headers dimutils.enc.key-id, dimutils.enc.data-key and
+  dimutils.enc.fields carry what consume --decrypt needs. Messages that are not
+  JSON are skipped with an error. Keyring file (keys are base64 AES keys, e.g.
+  from "openssl rand -base64 32"; like context secrets they may use file: or env:):
+
+    current: 2024-06
+    keys:
+      2024-06: 3q2+7w...
+      2023-12:
+        env: OLD_ORDERS_KEY
+
 Examples:
   echo "hello world" | produce my-topic
   produce --brokers broker1:9092 --key mykey my-topic < messages.txt
   produce --format json --key-field id --value-field data my-topic < data.json
-  produce --async --compression gzip --batch-size 32768 my-topic < large-file.txt`
+  produce --async --compression gzip --batch-size 32768 my-topic < large-file.txt
+  produce --format json --key-field id --schema orders.schema.json --dlq-file rejected.ndjson orders < orders.json
+  produce --format json --encrypt-fields customer.email,card --key-file keys.yaml orders < orders.json`
 
 	fmt.Println(help)
 	return nil
@@ -217,19 +330,32 @@ func startProducer(config Config) error {
 		log.Printf("Starting producer for topic %s", config.Topic)
 	}
 
+	if err := loadEncryption(&config); err != nil {
+		return err
+	}
+
+	if err := loadSchema(&config); err != nil {
+		return err
+	}
+
 	// Skip producer creation in dry-run mode
 	var producer sarama.SyncProducer
 	var asyncProducer sarama.AsyncProducer
+	var saramaConfig *sarama.Config
 	var err error
 
 	if !config.DryRun {
 		// Create Sarama config
-		saramaConfig := sarama.NewConfig()
+		saramaConfig = sarama.NewConfig()
 		saramaConfig.Producer.Return.Successes = true
 		saramaConfig.Producer.Return.Errors = true
 		saramaConfig.Producer.Retry.Max = config.Retries
 		saramaConfig.Producer.Timeout = time.Duration(config.TimeoutMs) * time.Millisecond
 
+		if err := kafkautils.ConfigureSecurity(saramaConfig, config.Auth, config.TLS); err != nil {
+			return err
+		}
+
 		// Set acknowledgment level
 		switch config.Acks {
 		case "0":
@@ -289,6 +415,15 @@ func startProducer(config Config) error {
 		}
 	}
 
+	var rejects *rejecter
+	if config.schema != nil {
+		rejects, err = newRejecter(config, saramaConfig)
+		if err != nil {
+			return err
+		}
+		defer rejects.Close()
+	}
+
 	// Set up input source
 	var input *os.File
 	if config.InputFile != "" {
@@ -304,13 +439,25 @@ func startProducer(config Config) error {
 	// Process messages
 	scanner := bufio.NewScanner(input)
 	messageCount := 0
+	lineNumber := 0
 
 	for scanner.Scan() {
+		lineNumber++
 		line := scanner.Text()
 		if strings.TrimSpace(line) == "" {
 			continue
 		}
 
+		if rejects != nil {
+			valid, err := rejects.Check(lineNumber, line)
+			if err != nil {
+				return fmt.Errorf("error dead-lettering line %d: %w", lineNumber, err)
+			}
+			if !valid {
+				continue
+			}
+		}
+
 		message, err := prepareMessage(line, config)
 		if err != nil {
 			log.Printf("Error preparing message: %v", err)
@@ -356,6 +503,9 @@ func startProducer(config Config) error {
 		log.Printf("Sent %d messages", messageCount)
 	}
 
+	if rejects != nil {
+		return rejects.Summary()
+	}
 	return nil
 }
 
@@ -378,12 +528,77 @@ func prepareMessage(line string, config Config) (*sarama.ProducerMessage, error)
 	}
 
 	// Process message based on format
+	var err error
 	switch config.MessageFormat {
 	case "json":
-		return prepareJSONMessage(message, line, config)
+		message, err = prepareJSONMessage(message, line, config)
 	default:
-		return prepareRawMessage(message, line, config)
+		message, err = prepareRawMessage(message, line, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if config.keyring != nil {
+		if err := encryptMessage(message, config); err != nil {
+			return nil, err
+		}
+	}
+	return message, nil
+}
+
+// loadEncryption reads the keyring when fields are to be encrypted
+func loadEncryption(config *Config) error {
+	if config.EncryptFields == "" {
+		return nil
+	}
+	if config.KeyFile == "" {
+		return fmt.Errorf("--encrypt-fields requires --key-file")
+	}
+
+	paths, err := fieldcrypt.ParsePaths(config.EncryptFields)
+	if err != nil {
+		return err
+	}
+	keyring, err := fieldcrypt.LoadKeyring(config.KeyFile)
+	if err != nil {
+		return err
+	}
+	if config.KeyID != "" && !keyring.HasKey(config.KeyID) {
+		return fmt.Errorf("key %s is not in %s", config.KeyID, config.KeyFile)
+	}
+
+	config.keyring = keyring
+	config.encryptPaths = paths
+	return nil
+}
+
+// encryptMessage encrypts the configured fields of the message value and adds the envelope headers
+func encryptMessage(message *sarama.ProducerMessage, config Config) error {
+	if message.Value == nil {
+		return nil
+	}
+	value, err := message.Value.Encode()
+	if err != nil {
+		return err
 	}
+
+	encrypted, envelope, err := config.keyring.Encrypt(value, config.encryptPaths, config.KeyID)
+	if err != nil {
+		return err
+	}
+	if envelope == nil {
+		return nil
+	}
+
+	message.Value = sarama.ByteEncoder(encrypted)
+	for _, header := range envelope.Headers() {
+		message.Headers = append(message.Headers, sarama.RecordHeader{
+			Key:   []byte(header.Key),
+			Value: []byte(header.Value),
+		})
+	}
+	return nil
 }
 
 func prepareJSONMessage(message *sarama.ProducerMessage, line string, config Config) (*sarama.ProducerMessage, error) {
@@ -0,0 +1,140 @@
+package produce
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/og-dim9/dimutils/pkg/kafkautils"
+	"github.com/og-dim9/dimutils/pkg/schema"
+)
+
+// Rejected is the JSON line written to the --dlq-file for a record that failed validation
+type Rejected struct {
+	Topic      string    `json:"topic"`
+	Line       int       `json:"line"`
+	Value      string    `json:"value"`
+	Schema     string    `json:"schema"`
+	Error      string    `json:"error"`
+	RejectedAt time.Time `json:"rejected_at"`
+}
+
+// rejecter sends records that fail schema validation to the dead-letter file and/or topic
+type rejecter struct {
+	config   Config
+	dlq      *kafkautils.DeadLetterSink
+	accepted int
+	rejected int
+}
+
+func newRejecter(config Config, saramaConfig *sarama.Config) (*rejecter, error) {
+	r := &rejecter{config: config}
+	if config.DryRun {
+		return r, nil
+	}
+
+	dlq, err := kafkautils.NewDeadLetterSink(config.DLQFile, config.DLQTopic, config.Brokers, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	r.dlq = dlq
+
+	return r, nil
+}
+
+// Close releases the dead-letter file and producer
+func (r *rejecter) Close() error {
+	if r.dlq == nil {
+		return nil
+	}
+	return r.dlq.Close()
+}
+
+// loadSchema reads the schema records are validated against
+func loadSchema(config *Config) error {
+	if config.SchemaFile == "" {
+		if config.DLQFile != "" || config.DLQTopic != "" {
+			return fmt.Errorf("--dlq-file and --dlq-topic require --schema")
+		}
+		return nil
+	}
+
+	s, err := schema.Load(config.SchemaFile)
+	if err != nil {
+		return err
+	}
+	config.schema = s
+	return nil
+}
+
+// Check validates an input line, dead-lettering it when it is invalid; it reports whether the line should be produced
+func (r *rejecter) Check(lineNumber int, line string) (bool, error) {
+	var value interface{}
+	err := json.Unmarshal([]byte(line), &value)
+	if err != nil {
+		err = fmt.Errorf("invalid JSON: %w", err)
+	} else {
+		err = r.config.schema.Validate(value)
+	}
+	if err == nil {
+		r.accepted++
+		return true, nil
+	}
+
+	r.rejected++
+	if r.config.DryRun {
+		fmt.Printf("Would reject line %d: %v\n", lineNumber, err)
+		return false, nil
+	}
+	if !r.dlq.Enabled() {
+		log.Printf("Rejected line %d: %v", lineNumber, err)
+		return false, nil
+	}
+	if r.config.Verbose {
+		log.Printf("Dead-lettering line %d: %v", lineNumber, err)
+	}
+
+	sendErr := r.dlq.Send(kafkautils.DeadLetter{
+		Envelope: Rejected{
+			Topic:      r.config.Topic,
+			Line:       lineNumber,
+			Value:      line,
+			Schema:     r.config.SchemaFile,
+			Error:      err.Error(),
+			RejectedAt: time.Now(),
+		},
+		Value: []byte(line),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("dlq.target.topic"), Value: []byte(r.config.Topic)},
+			{Key: []byte("dlq.schema"), Value: []byte(r.config.SchemaFile)},
+			{Key: []byte("dlq.line"), Value: []byte(strconv.Itoa(lineNumber))},
+			{Key: []byte("dlq.error"), Value: []byte(err.Error())},
+		},
+	})
+	return false, sendErr
+}
+
+// Summary prints the accepted and rejected counts; rejected records are an error when they were not dead-lettered
+func (r *rejecter) Summary() error {
+	destination := ""
+	switch {
+	case r.config.DryRun:
+	case r.config.DLQFile != "" && r.config.DLQTopic != "":
+		destination = fmt.Sprintf(" (dead-lettered to %s and topic %s)", r.config.DLQFile, r.config.DLQTopic)
+	case r.config.DLQFile != "":
+		destination = fmt.Sprintf(" (dead-lettered to %s)", r.config.DLQFile)
+	case r.config.DLQTopic != "":
+		destination = fmt.Sprintf(" (dead-lettered to topic %s)", r.config.DLQTopic)
+	}
+	fmt.Fprintf(os.Stderr, "Validated against %s: %d accepted, %d rejected%s\n",
+		r.config.SchemaFile, r.accepted, r.rejected, destination)
+
+	if r.rejected > 0 && destination == "" {
+		return fmt.Errorf("%d records failed validation", r.rejected)
+	}
+	return nil
+}
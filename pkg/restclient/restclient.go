@@ -0,0 +1,229 @@
+package restclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRetryBackoff caps the retry delay, both the doubling backoff and the
+// server's Retry-After; a variable so tests can shorten it
+var maxRetryBackoff = 30 * time.Second
+
+// Client sends requests to a JSON REST API with authentication, TLS and retries
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	auth         *AuthConfig
+	contentType  string
+	retries      int
+	retryBackoff time.Duration
+	setupErr     error // TLS files that failed to load, returned by every request
+}
+
+// Config holds REST client configuration
+type Config struct {
+	URL          string
+	Timeout      time.Duration
+	Auth         *AuthConfig
+	TLS          *TLSConfig
+	ContentType  string        // Content-Type and Accept header (default: application/json)
+	Retries      int           // retries of requests that fail with 429, a 5xx or a connection error
+	RetryBackoff time.Duration // delay before the first retry, doubled for each one after
+}
+
+// AuthConfig holds authentication configuration: basic auth with Username and
+// Password, or a BearerToken
+type AuthConfig struct {
+	Username    string
+	Password    string
+	BearerToken string
+}
+
+// TLSConfig holds client TLS settings for an https URL
+type TLSConfig struct {
+	InsecureSkipVerify bool
+	CertFile           string // client certificate for mTLS
+	KeyFile            string
+	CAFile             string
+}
+
+// APIError is an error response from a REST API
+type APIError struct {
+	StatusCode int    `json:"-"`
+	ErrorCode  int    `json:"error_code"`
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	if e.ErrorCode != 0 && e.ErrorCode != e.StatusCode {
+		return fmt.Sprintf("HTTP %d: %s (error %d)", e.StatusCode, e.Message, e.ErrorCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// NewAPIError reads an error response body
+func NewAPIError(resp *http.Response) *APIError {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
+
+// New creates a REST client. Problems loading the TLS files are reported by
+// the client's first request.
+func New(config Config) *Client {
+	httpClient := &http.Client{
+		Timeout: config.Timeout,
+	}
+
+	contentType := config.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	client := &Client{
+		baseURL:      strings.TrimSuffix(config.URL, "/"),
+		httpClient:   httpClient,
+		auth:         config.Auth,
+		contentType:  contentType,
+		retries:      config.Retries,
+		retryBackoff: config.RetryBackoff,
+	}
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.Load()
+		if err != nil {
+			client.setupErr = err
+		} else {
+			httpClient.Transport = &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			}
+		}
+	}
+	return client
+}
+
+// Load builds a tls.Config from the certificate files
+func (t *TLSConfig) Load() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: t.InsecureSkipVerify}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if t.CAFile != "" {
+		caCert, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no valid certificates found in CA file %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// Do makes an HTTP request, retrying when the server is unavailable or rate
+// limiting. Only clients whose requests are all safe to repeat should set Retries.
+func (c *Client) Do(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	if c.setupErr != nil {
+		return nil, c.setupErr
+	}
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, payload)
+		retry := attempt < c.retries && ctx.Err() == nil &&
+			(err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500)
+		if !retry {
+			return resp, err
+		}
+
+		backoff := c.retryBackoff << attempt
+		if backoff > maxRetryBackoff || backoff <= 0 {
+			backoff = maxRetryBackoff
+		}
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				backoff = maxRetryBackoff
+				if seconds < int(maxRetryBackoff/time.Second) {
+					backoff = time.Duration(seconds) * time.Second
+				}
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("request failed: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes one attempt at a request
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", c.contentType)
+	req.Header.Set("Accept", c.contentType)
+
+	if c.auth != nil {
+		if c.auth.BearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+c.auth.BearerToken)
+		} else {
+			req.SetBasicAuth(c.auth.Username, c.auth.Password)
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,82 @@
+package restclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unavailableOnce answers the first request with 503 and a Retry-After header,
+// and the ones after with 200
+func unavailableOnce(t *testing.T, retryAfter string) (*httptest.Server, *int32) {
+	t.Helper()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRetryAfterIsClamped(t *testing.T) {
+	saved := maxRetryBackoff
+	maxRetryBackoff = 20 * time.Millisecond
+	defer func() { maxRetryBackoff = saved }()
+
+	server, requests := unavailableOnce(t, "86400")
+	client := New(Config{URL: server.URL, Timeout: 5 * time.Second, Retries: 1, RetryBackoff: time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := client.Do(ctx, "GET", "/subjects", nil)
+	if err != nil {
+		t.Fatalf("expected the retry to wait at most %v, got %v", maxRetryBackoff, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || atomic.LoadInt32(requests) != 2 {
+		t.Fatalf("expected a successful retry, got HTTP %d after %d requests", resp.StatusCode, *requests)
+	}
+}
+
+func TestRetryAfterOverridesBackoff(t *testing.T) {
+	server, requests := unavailableOnce(t, "0")
+	client := New(Config{URL: server.URL, Timeout: 5 * time.Second, Retries: 1, RetryBackoff: time.Hour})
+
+	start := time.Now()
+	resp, err := client.Do(context.Background(), "GET", "/subjects", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected Retry-After: 0 to retry at once, took %v", elapsed)
+	}
+	if atomic.LoadInt32(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", *requests)
+	}
+}
+
+func TestWithoutRetriesTheErrorIsReturned(t *testing.T) {
+	server, requests := unavailableOnce(t, "0")
+	client := New(Config{URL: server.URL, Timeout: 5 * time.Second})
+
+	resp, err := client.Do(context.Background(), "GET", "/subjects", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || atomic.LoadInt32(requests) != 1 {
+		t.Fatalf("expected the 503 without a retry, got HTTP %d after %d requests", resp.StatusCode, *requests)
+	}
+	if apiErr := NewAPIError(resp); apiErr.Message != "unavailable" {
+		t.Fatalf("expected the plain text body as the message, got %q", apiErr.Message)
+	}
+}
@@ -0,0 +1,782 @@
+package schema
+
+import (
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// codegenOptions holds the command line settings for "schema codegen"
+type codegenOptions struct {
+	Lang       string // go, typescript, python
+	From       string // json, avro, proto (default: from the file)
+	OutputFile string
+	Package    string // go package name
+	Name       string // root type name
+	Message    string // protobuf input message
+	File       string
+}
+
+// codegen holds the named types of a schema and their names in the generated code
+type codegen struct {
+	root   *convType
+	source string
+	types  []*convType // records and enums, the root first
+	names  map[*convType]string
+	uses   map[string]bool // imports and helpers the generated code needs
+}
+
+// runCodegen is the entry point for "schema codegen"
+func runCodegen(args []string) error {
+	opts := codegenOptions{Package: "model"}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--lang", "-l":
+			if i+1 < len(args) {
+				opts.Lang = args[i+1]
+				i++
+			}
+		case "--from", "-f":
+			if i+1 < len(args) {
+				opts.From = args[i+1]
+				i++
+			}
+		case "--output", "-o":
+			if i+1 < len(args) {
+				opts.OutputFile = args[i+1]
+				i++
+			}
+		case "--package", "-p":
+			if i+1 < len(args) {
+				opts.Package = args[i+1]
+				i++
+			}
+		case "--name", "-n":
+			if i+1 < len(args) {
+				opts.Name = args[i+1]
+				i++
+			}
+		case "--message", "-m":
+			if i+1 < len(args) {
+				opts.Message = args[i+1]
+				i++
+			}
+		case "-h", "--help", "help":
+			return printCodegenHelp()
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			if opts.File != "" {
+				return fmt.Errorf("usage: schema codegen --lang LANG [options] FILE")
+			}
+			opts.File = arg
+		}
+	}
+	if opts.File == "" || opts.Lang == "" {
+		return fmt.Errorf("usage: schema codegen --lang LANG [options] FILE")
+	}
+
+	text, detected, err := readSchemaFile(opts.File, convertFormatType(opts.From))
+	if err != nil {
+		return err
+	}
+	c := &converter{}
+	root, err := c.read(text, detected, convertOptions{File: opts.File, Message: opts.Message})
+	if err != nil {
+		return err
+	}
+	if root.Kind != "record" {
+		return fmt.Errorf("the top-level schema must be a record")
+	}
+	if opts.Name != "" {
+		root.Name = opts.Name
+	}
+	assignNames(root)
+	g := newCodegen(root, filepath.Base(opts.File))
+
+	var output string
+	switch strings.ToLower(opts.Lang) {
+	case "go", "golang":
+		if !isIdentifier(opts.Package) {
+			return fmt.Errorf("invalid package name %q", opts.Package)
+		}
+		output = g.goCode(opts.Package)
+	case "typescript", "ts":
+		output = g.typescriptCode()
+	case "python", "py":
+		output = g.pythonCode()
+	default:
+		return fmt.Errorf("unknown language %q: use go, typescript or python", opts.Lang)
+	}
+	for _, warning := range c.warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if opts.OutputFile != "" {
+		return os.WriteFile(opts.OutputFile, []byte(output), 0644)
+	}
+	fmt.Print(output)
+	return nil
+}
+
+func newCodegen(root *convType, source string) *codegen {
+	g := &codegen{root: root, source: source, names: make(map[*convType]string), uses: make(map[string]bool)}
+	used := make(map[string]bool)
+	var walk func(t *convType)
+	walk = func(t *convType) {
+		if t == nil {
+			return
+		}
+		if t.isNamed() {
+			if _, ok := g.names[t]; ok {
+				return
+			}
+			name := pascalCase(t.Name)
+			for n := 2; used[name]; n++ {
+				name = fmt.Sprintf("%s%d", pascalCase(t.Name), n)
+			}
+			used[name] = true
+			g.names[t] = name
+			g.types = append(g.types, t)
+		}
+		for _, field := range t.Fields {
+			walk(field.Type)
+		}
+		walk(t.Items)
+	}
+	walk(root)
+	return g
+}
+
+// needsCheck reports whether values of a type have enum values to check
+func needsCheck(t *convType) bool {
+	switch t.Kind {
+	case "enum", "record":
+		return true
+	case "array", "map":
+		return needsCheck(t.Items)
+	}
+	return false
+}
+
+// Go
+
+var goInitialisms = map[string]bool{"id": true, "url": true, "uri": true, "uuid": true, "ip": true, "http": true, "json": true, "api": true, "sql": true, "html": true}
+
+// goName makes an exported Go identifier, e.g. user_id to UserID
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if goInitialisms[strings.ToLower(part)] {
+			b.WriteString(strings.ToUpper(part))
+			continue
+		}
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	result := b.String()
+	if result == "" || unicode.IsDigit([]rune(result)[0]) {
+		result = "X" + result
+	}
+	return result
+}
+
+// symbolName makes a constant name from an enum value, e.g. IN_PROGRESS or
+// in-progress to InProgress
+func symbolName(symbol string) string {
+	if strings.ToUpper(symbol) == symbol {
+		symbol = strings.ToLower(symbol)
+	}
+	return goName(symbol)
+}
+
+// memberNames names the constants of an enum's values, numbering any that
+// come out the same, e.g. a-b and a_b
+func memberNames(symbols []string, name func(string) string) []string {
+	names := make([]string, len(symbols))
+	used := make(map[string]bool)
+	for i, symbol := range symbols {
+		base := name(symbol)
+		names[i] = base
+		for n := 2; used[names[i]]; n++ {
+			names[i] = fmt.Sprintf("%s%d", base, n)
+		}
+		used[names[i]] = true
+	}
+	return names
+}
+
+func (g *codegen) goType(t *convType, nullable bool) string {
+	var base string
+	switch t.Kind {
+	case "string", "uuid", "date":
+		base = "string"
+	case "int":
+		base = "int32"
+	case "long":
+		base = "int64"
+	case "float":
+		base = "float32"
+	case "double":
+		base = "float64"
+	case "boolean":
+		base = "bool"
+	case "bytes":
+		return "[]byte"
+	case "timestamp":
+		g.uses["time"] = true
+		base = "time.Time"
+	case "decimal":
+		g.uses["encoding/json"] = true
+		base = "json.Number"
+	case "enum", "record":
+		base = g.names[t]
+	case "array":
+		return "[]" + g.goType(t.Items, t.ItemsNullable)
+	case "map":
+		return "map[string]" + g.goType(t.Items, t.ItemsNullable)
+	default:
+		return "interface{}"
+	}
+	if nullable {
+		return "*" + base
+	}
+	return base
+}
+
+func (g *codegen) goCode(pkg string) string {
+	var body strings.Builder
+	for _, t := range g.types {
+		name := g.names[t]
+		body.WriteString("\n")
+		writeComment(&body, "", "// ", t.Doc)
+		if t.Kind == "enum" {
+			members := memberNames(t.Symbols, symbolName)
+			fmt.Fprintf(&body, "type %s string\n\n// %s values\nconst (\n", name, name)
+			for i, symbol := range t.Symbols {
+				fmt.Fprintf(&body, "\t%s%s %s = %q\n", name, members[i], name, symbol)
+			}
+			fmt.Fprintf(&body, ")\n\n// Valid reports whether the value is one of the %s values\n", name)
+			fmt.Fprintf(&body, "func (v %s) Valid() bool {\n\tswitch v {\n\tcase ", name)
+			for i, member := range members {
+				if i > 0 {
+					body.WriteString(", ")
+				}
+				body.WriteString(name + member)
+			}
+			body.WriteString(":\n\t\treturn true\n\t}\n\treturn false\n}\n")
+			continue
+		}
+
+		fmt.Fprintf(&body, "type %s struct {\n", name)
+		fieldNames := make(map[string]bool)
+		goFields := make([]string, len(t.Fields))
+		for i, field := range t.Fields {
+			fieldName := goName(field.Name)
+			for n := 2; fieldNames[fieldName]; n++ {
+				fieldName = fmt.Sprintf("%s%d", goName(field.Name), n)
+			}
+			fieldNames[fieldName] = true
+			goFields[i] = fieldName
+			writeComment(&body, "\t", "// ", field.Doc)
+			tag := field.Name
+			if field.Nullable {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&body, "\t%s %s `json:%q`\n", fieldName, g.goType(field.Type, field.Nullable), tag)
+		}
+		body.WriteString("}\n")
+
+		fmt.Fprintf(&body, "\n// Validate checks the enum values in the %s and the records it contains\n", name)
+		fmt.Fprintf(&body, "func (r *%s) Validate() error {\n", name)
+		for i, field := range t.Fields {
+			if needsCheck(field.Type) {
+				g.goCheck(&body, "r."+goFields[i], field.Type, field.Nullable, field.Name, nil, "\t", 1)
+			}
+		}
+		body.WriteString("\treturn nil\n}\n")
+	}
+	if len(g.types) > 0 {
+		g.uses["fmt"] = true
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by dimutils schema codegen from %s. DO NOT EDIT.\n\npackage %s\n", g.source, pkg)
+	var imports []string
+	for _, path := range []string{"encoding/json", "fmt", "time"} {
+		if g.uses[path] {
+			imports = append(imports, path)
+		}
+	}
+	if len(imports) > 0 {
+		b.WriteString("\nimport (\n")
+		for _, path := range imports {
+			fmt.Fprintf(&b, "\t%q\n", path)
+		}
+		b.WriteString(")\n")
+	}
+	b.WriteString(body.String())
+	if formatted, err := format.Source([]byte(b.String())); err == nil {
+		return string(formatted)
+	}
+	return b.String()
+}
+
+// goCheck writes the check of one value; label and args build the error
+// prefix, e.g. "lines[%d]" with the loop index
+func (g *codegen) goCheck(b *strings.Builder, expr string, t *convType, nullable bool, label string, args []string, indent string, depth int) {
+	errorf := func(format string, extra ...string) string {
+		all := append(append([]string{}, args...), extra...)
+		if len(all) == 0 {
+			return fmt.Sprintf("fmt.Errorf(%q)", format)
+		}
+		return fmt.Sprintf("fmt.Errorf(%q, %s)", format, strings.Join(all, ", "))
+	}
+	value := expr
+	if nullable && (t.Kind == "enum" || t.Kind == "record") {
+		fmt.Fprintf(b, "%sif %s != nil {\n", indent, expr)
+		defer fmt.Fprintf(b, "%s}\n", indent)
+		indent += "\t"
+		if t.Kind == "enum" {
+			value = "*" + expr
+		}
+	}
+	switch t.Kind {
+	case "enum":
+		fmt.Fprintf(b, "%sif !%s.Valid() {\n%s\treturn %s\n%s}\n", indent, value, indent,
+			errorf(label+": invalid value %q", value), indent)
+	case "record":
+		fmt.Fprintf(b, "%sif err := %s.Validate(); err != nil {\n%s\treturn %s\n%s}\n", indent, value, indent,
+			errorf(label+": %w", "err"), indent)
+	case "array", "map":
+		key, item := fmt.Sprintf("i%d", depth), fmt.Sprintf("v%d", depth)
+		keyFormat := "[%d]"
+		if t.Kind == "map" {
+			key, keyFormat = fmt.Sprintf("k%d", depth), "[%q]"
+		}
+		if t.Kind == "array" && t.Items.Kind == "record" && !t.ItemsNullable {
+			// Validate has a pointer receiver; check the element in place
+			fmt.Fprintf(b, "%sfor %s := range %s {\n", indent, key, expr)
+			item = fmt.Sprintf("%s[%s]", expr, key)
+		} else {
+			fmt.Fprintf(b, "%sfor %s, %s := range %s {\n", indent, key, item, expr)
+		}
+		g.goCheck(b, item, t.Items, t.ItemsNullable, label+keyFormat, append(append([]string{}, args...), key), indent+"\t", depth+1)
+		fmt.Fprintf(b, "%s}\n", indent)
+	}
+}
+
+// TypeScript
+
+func (g *codegen) tsType(t *convType) string {
+	switch t.Kind {
+	case "string", "uuid", "date", "timestamp", "bytes":
+		return "string"
+	case "int", "long", "float", "double", "decimal":
+		return "number"
+	case "boolean":
+		return "boolean"
+	case "enum", "record":
+		return g.names[t]
+	case "array":
+		items := g.tsType(t.Items)
+		if t.ItemsNullable {
+			return "(" + items + " | null)[]"
+		}
+		return items + "[]"
+	case "map":
+		items := g.tsType(t.Items)
+		if t.ItemsNullable {
+			items += " | null"
+		}
+		return "Record<string, " + items + ">"
+	}
+	return "unknown"
+}
+
+// tsCheck is a type guard expression for a value
+func (g *codegen) tsCheck(expr string, t *convType) string {
+	switch t.Kind {
+	case "string", "uuid", "date", "timestamp", "bytes":
+		return fmt.Sprintf("typeof %s === \"string\"", expr)
+	case "int", "long", "float", "double", "decimal":
+		return fmt.Sprintf("typeof %s === \"number\"", expr)
+	case "boolean":
+		return fmt.Sprintf("typeof %s === \"boolean\"", expr)
+	case "enum", "record":
+		return fmt.Sprintf("is%s(%s)", g.names[t], expr)
+	case "array":
+		return fmt.Sprintf("Array.isArray(%s)", expr)
+	case "map":
+		return fmt.Sprintf("(typeof %s === \"object\" && %s !== null)", expr, expr)
+	}
+	return ""
+}
+
+func tsKey(name string) string {
+	if isIdentifier(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+func (g *codegen) typescriptCode() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Generated by dimutils schema codegen from %s. Do not edit.\n", g.source)
+	for _, t := range g.types {
+		name := g.names[t]
+		b.WriteString("\n")
+		writeComment(&b, "", "/** ", t.Doc)
+		if t.Kind == "enum" {
+			fmt.Fprintf(&b, "export const %s = {\n", name)
+			for _, symbol := range t.Symbols {
+				fmt.Fprintf(&b, "  %s: %q,\n", tsKey(symbol), symbol)
+			}
+			fmt.Fprintf(&b, "} as const;\n\nexport type %s = (typeof %s)[keyof typeof %s];\n\n", name, name, name)
+			fmt.Fprintf(&b, "export function is%s(value: unknown): value is %s {\n", name, name)
+			fmt.Fprintf(&b, "  return (Object.values(%s) as unknown[]).includes(value);\n}\n", name)
+			continue
+		}
+
+		fmt.Fprintf(&b, "export interface %s {\n", name)
+		var checks []string
+		for _, field := range t.Fields {
+			writeComment(&b, "  ", "/** ", field.Doc)
+			key := tsKey(field.Name)
+			fieldType := g.tsType(field.Type)
+			if field.Nullable {
+				fmt.Fprintf(&b, "  %s?: %s | null;\n", key, fieldType)
+			} else {
+				fmt.Fprintf(&b, "  %s: %s;\n", key, fieldType)
+			}
+			expr := fmt.Sprintf("v[%q]", field.Name)
+			check := g.tsCheck(expr, field.Type)
+			switch {
+			case check == "" && !field.Nullable:
+				checks = append(checks, fmt.Sprintf("%q in v", field.Name))
+			case check == "":
+			case field.Nullable:
+				checks = append(checks, fmt.Sprintf("(%s === undefined || %s === null || %s)", expr, expr, check))
+			default:
+				checks = append(checks, check)
+			}
+		}
+		b.WriteString("}\n\n")
+		fmt.Fprintf(&b, "export function is%s(value: unknown): value is %s {\n", name, name)
+		b.WriteString("  if (typeof value !== \"object\" || value === null) {\n    return false;\n  }\n")
+		if len(checks) == 0 {
+			b.WriteString("  return true;\n}\n")
+			continue
+		}
+		b.WriteString("  const v = value as Record<string, unknown>;\n")
+		fmt.Fprintf(&b, "  return (\n    %s\n  );\n}\n", strings.Join(checks, " &&\n    "))
+	}
+	return b.String()
+}
+
+// Python
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+	"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// pyName makes a Python identifier, e.g. first-name to first_name and class to class_
+func pyName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	result := b.String()
+	if result == "" || unicode.IsDigit([]rune(result)[0]) {
+		result = "_" + result
+	}
+	if pythonKeywords[result] {
+		result += "_"
+	}
+	return result
+}
+
+// pyMemberName names an enum member; Enum reserves names starting with _
+func pyMemberName(symbol string) string {
+	name := pyName(symbol)
+	if strings.HasPrefix(name, "_") {
+		name = "V" + name
+	}
+	return name
+}
+
+func (g *codegen) pyType(t *convType) string {
+	switch t.Kind {
+	case "string", "uuid", "bytes":
+		return "str"
+	case "int", "long":
+		return "int"
+	case "float", "double":
+		return "float"
+	case "boolean":
+		return "bool"
+	case "date":
+		g.uses["date"] = true
+		return "date"
+	case "timestamp":
+		g.uses["datetime"] = true
+		return "datetime"
+	case "decimal":
+		g.uses["Decimal"] = true
+		return "Decimal"
+	case "enum", "record":
+		return g.names[t]
+	case "array":
+		g.uses["List"] = true
+		return "List[" + g.pyOptional(g.pyType(t.Items), t.ItemsNullable) + "]"
+	case "map":
+		g.uses["Dict"] = true
+		return "Dict[str, " + g.pyOptional(g.pyType(t.Items), t.ItemsNullable) + "]"
+	}
+	g.uses["Any"] = true
+	return "Any"
+}
+
+func (g *codegen) pyOptional(pyType string, nullable bool) string {
+	if !nullable {
+		return pyType
+	}
+	g.uses["Optional"] = true
+	return "Optional[" + pyType + "]"
+}
+
+// pyFrom converts a decoded JSON value to its Python type; it returns the
+// expression itself when nothing needs converting
+func (g *codegen) pyFrom(expr string, t *convType, nullable bool, depth int) string {
+	var converted string
+	switch t.Kind {
+	case "date":
+		converted = fmt.Sprintf("date.fromisoformat(%s)", expr)
+	case "timestamp":
+		g.uses["parse_datetime"] = true
+		converted = fmt.Sprintf("_parse_datetime(%s)", expr)
+	case "decimal":
+		converted = fmt.Sprintf("Decimal(str(%s))", expr)
+	case "enum":
+		converted = fmt.Sprintf("%s(%s)", g.names[t], expr)
+	case "record":
+		converted = fmt.Sprintf("%s.from_dict(%s)", g.names[t], expr)
+	case "array", "map":
+		item := fmt.Sprintf("v%d", depth)
+		inner := g.pyFrom(item, t.Items, t.ItemsNullable, depth+1)
+		if inner == item {
+			return expr
+		}
+		if t.Kind == "array" {
+			converted = fmt.Sprintf("[%s for %s in %s]", inner, item, expr)
+		} else {
+			converted = fmt.Sprintf("{k%d: %s for k%d, %s in %s.items()}", depth, inner, depth, item, expr)
+		}
+	default:
+		return expr
+	}
+	if nullable {
+		return fmt.Sprintf("%s if %s is not None else None", converted, expr)
+	}
+	return converted
+}
+
+// pyTo converts a Python value back to its JSON form
+func (g *codegen) pyTo(expr string, t *convType, nullable bool, depth int) string {
+	var converted string
+	switch t.Kind {
+	case "date", "timestamp":
+		converted = expr + ".isoformat()"
+	case "decimal":
+		converted = fmt.Sprintf("float(%s)", expr)
+	case "enum":
+		converted = expr + ".value"
+	case "record":
+		converted = expr + ".to_dict()"
+	case "array", "map":
+		item := fmt.Sprintf("v%d", depth)
+		inner := g.pyTo(item, t.Items, t.ItemsNullable, depth+1)
+		if inner == item {
+			return expr
+		}
+		if t.Kind == "array" {
+			converted = fmt.Sprintf("[%s for %s in %s]", inner, item, expr)
+		} else {
+			converted = fmt.Sprintf("{k%d: %s for k%d, %s in %s.items()}", depth, inner, depth, item, expr)
+		}
+	default:
+		return expr
+	}
+	if nullable {
+		return fmt.Sprintf("%s if %s is not None else None", converted, expr)
+	}
+	return converted
+}
+
+func (g *codegen) pythonCode() string {
+	var body strings.Builder
+	for _, t := range g.types {
+		name := g.names[t]
+		body.WriteString("\n\n")
+		if t.Kind == "enum" {
+			g.uses["Enum"] = true
+			fmt.Fprintf(&body, "class %s(str, Enum):\n", name)
+			writePythonDoc(&body, t.Doc)
+			members := memberNames(t.Symbols, pyMemberName)
+			for i, symbol := range t.Symbols {
+				fmt.Fprintf(&body, "    %s = %q\n", members[i], symbol)
+			}
+			continue
+		}
+
+		// Dataclass fields with defaults must come last
+		fields := append([]*convField(nil), t.Fields...)
+		sort.SliceStable(fields, func(i, j int) bool {
+			return !fields[i].Nullable && fields[j].Nullable
+		})
+		fmt.Fprintf(&body, "@dataclass\nclass %s:\n", name)
+		writePythonDoc(&body, t.Doc)
+		if len(fields) == 0 {
+			body.WriteString("    pass\n")
+		}
+		for _, field := range fields {
+			writeComment(&body, "    ", "# ", field.Doc)
+			fieldType := g.pyOptional(g.pyType(field.Type), field.Nullable)
+			if field.Nullable {
+				fmt.Fprintf(&body, "    %s: %s = None\n", pyName(field.Name), fieldType)
+			} else {
+				fmt.Fprintf(&body, "    %s: %s\n", pyName(field.Name), fieldType)
+			}
+		}
+
+		g.uses["Dict"], g.uses["Any"] = true, true
+		fmt.Fprintf(&body, "\n    @classmethod\n    def from_dict(cls, data: Dict[str, Any]) -> \"%s\":\n        return cls(\n", name)
+		for _, field := range fields {
+			source := fmt.Sprintf("data[%q]", field.Name)
+			if field.Nullable {
+				source = fmt.Sprintf("data.get(%q)", field.Name)
+			}
+			fmt.Fprintf(&body, "            %s=%s,\n", pyName(field.Name), g.pyFrom(source, field.Type, field.Nullable, 1))
+		}
+		body.WriteString("        )\n\n    def to_dict(self) -> Dict[str, Any]:\n        return {\n")
+		for _, field := range t.Fields {
+			fmt.Fprintf(&body, "            %q: %s,\n", field.Name, g.pyTo("self."+pyName(field.Name), field.Type, field.Nullable, 1))
+		}
+		body.WriteString("        }\n")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by dimutils schema codegen from %s. Do not edit.\nfrom __future__ import annotations\n\n", g.source)
+	b.WriteString("from dataclasses import dataclass\n")
+	var datetimeNames []string
+	for _, name := range []string{"date", "datetime"} {
+		if g.uses[name] || (name == "datetime" && g.uses["parse_datetime"]) {
+			datetimeNames = append(datetimeNames, name)
+		}
+	}
+	if len(datetimeNames) > 0 {
+		fmt.Fprintf(&b, "from datetime import %s\n", strings.Join(datetimeNames, ", "))
+	}
+	if g.uses["Decimal"] {
+		b.WriteString("from decimal import Decimal\n")
+	}
+	if g.uses["Enum"] {
+		b.WriteString("from enum import Enum\n")
+	}
+	var typingNames []string
+	for _, name := range []string{"Any", "Dict", "List", "Optional"} {
+		if g.uses[name] {
+			typingNames = append(typingNames, name)
+		}
+	}
+	if len(typingNames) > 0 {
+		fmt.Fprintf(&b, "from typing import %s\n", strings.Join(typingNames, ", "))
+	}
+	if g.uses["parse_datetime"] {
+		b.WriteString("\n\ndef _parse_datetime(value: str) -> datetime:\n")
+		b.WriteString("    # fromisoformat only accepts a Z suffix from Python 3.11\n")
+		b.WriteString("    return datetime.fromisoformat(value.replace(\"Z\", \"+00:00\"))\n")
+	}
+	b.WriteString(body.String())
+	return b.String()
+}
+
+func writePythonDoc(b *strings.Builder, doc string) {
+	if doc != "" {
+		fmt.Fprintf(b, "    \"\"\"%s\"\"\"\n\n", strings.ReplaceAll(doc, `"""`, `\"\"\"`))
+	}
+}
+
+// writeComment writes a doc comment line by line; "/** " comments are closed
+func writeComment(b *strings.Builder, indent, prefix, doc string) {
+	if doc == "" {
+		return
+	}
+	lines := strings.Split(strings.TrimSpace(doc), "\n")
+	if prefix == "/** " {
+		if len(lines) == 1 {
+			fmt.Fprintf(b, "%s/** %s */\n", indent, strings.ReplaceAll(lines[0], "*/", "* /"))
+			return
+		}
+		fmt.Fprintf(b, "%s/**\n", indent)
+		for _, line := range lines {
+			fmt.Fprintf(b, "%s * %s\n", indent, strings.ReplaceAll(line, "*/", "* /"))
+		}
+		fmt.Fprintf(b, "%s */\n", indent)
+		return
+	}
+	for _, line := range lines {
+		fmt.Fprintf(b, "%s%s%s\n", indent, prefix, line)
+	}
+}
+
+func printCodegenHelp() error {
+	help := `Usage: schema codegen --lang LANG [options] FILE
+
+Generate model types from a JSON Schema, Avro or protobuf schema: Go structs,
+TypeScript interfaces or Python dataclasses, with a type for every nested
+record and enum. The types describe the JSON form of the data, as
+'schema convert --to json' does.
+
+  Go           structs with json tags; optional fields are pointers with
+               omitempty; enums are string types with constants and Valid();
+               records get Validate() to check enum values
+  TypeScript   interfaces with optional (?) nullable fields; enums are const
+               objects with a union type; isName() type guards
+  Python       dataclasses with Optional fields defaulting to None; enums
+               are str Enums; from_dict() and to_dict() convert nested
+               types, dates and decimals
+
+Options:
+  --lang, -l LANG       go, typescript (ts) or python (py)
+  --from, -f FORMAT     Input format: json, avro, proto (default: from the file)
+  --output, -o FILE     Output file (default: stdout)
+  --package, -p NAME    Go package name (default: model)
+  --name, -n NAME       Name of the top-level type (default: the schema's
+                        title or name, or the file name)
+  --message, -m NAME    Protobuf message to generate from
+
+Examples:
+  schema codegen --lang go --package orders -o orders/model.go orders.avsc
+  schema codegen --lang typescript -o src/order.ts orders.schema.json
+  schema codegen --lang python -o order.py orders.avsc`
+
+	fmt.Println(help)
+	return nil
+}
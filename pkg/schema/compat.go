@@ -0,0 +1,287 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/schemaregistry"
+)
+
+func init() {
+	schemaregistry.RegisterChecker("JSON", checkJSON)
+}
+
+// checkJSON is the registry's compatibility checker for JSON schemas: it lists
+// the findings that stop data valid under writer from being valid under reader
+func checkJSON(reader, writer string) ([]string, error) {
+	readerSchema, err := parseJSONSchema(&schemaregistry.Schema{Subject: "reader", Schema: reader})
+	if err != nil {
+		return nil, err
+	}
+	writerSchema, err := parseJSONSchema(&schemaregistry.Schema{Subject: "writer", Schema: writer})
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []string
+	for _, finding := range Compare(writerSchema, readerSchema) {
+		if finding.BreaksBackward {
+			messages = append(messages, fmt.Sprintf("%s: %s (%s)", finding.Path, finding.Message, finding.Kind))
+		}
+	}
+	return messages, nil
+}
+
+// compatOptions holds the command line settings for "schema compat"
+type compatOptions struct {
+	Type   string // AVRO, JSON (default: from the files)
+	Level  string
+	Output string // text, json
+	Files  []string
+}
+
+// compatReport is the json output of "schema compat"
+type compatReport struct {
+	Compatible bool            `json:"compatible"`
+	Level      string          `json:"level"`
+	Type       string          `json:"type"`
+	Messages   []string        `json:"messages"`
+	Findings   []compatFinding `json:"findings,omitempty"` // JSON schemas only
+}
+
+// compatFinding is a JSON schema finding against one of the old files
+type compatFinding struct {
+	Finding
+	Against  string `json:"against"`
+	Breaking bool   `json:"breaking"`
+}
+
+// runCompat is the entry point for "schema compat"
+func runCompat(args []string) error {
+	opts := compatOptions{Level: "BACKWARD", Output: "text"}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--type", "-t":
+			if i+1 < len(args) {
+				opts.Type = args[i+1]
+				i++
+			}
+		case "--level", "-l":
+			if i+1 < len(args) {
+				opts.Level = args[i+1]
+				i++
+			}
+		case "--output", "-o":
+			if i+1 < len(args) {
+				opts.Output = args[i+1]
+				i++
+			}
+		case "-h", "--help", "help":
+			return printCompatHelp()
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			opts.Files = append(opts.Files, arg)
+		}
+	}
+
+	if len(opts.Files) < 2 {
+		return fmt.Errorf("usage: schema compat [options] OLD... NEW")
+	}
+	opts.Level = strings.ToUpper(opts.Level)
+	if err := schemaregistry.ValidateCompatibilityLevel(opts.Level); err != nil {
+		return err
+	}
+
+	newFile := opts.Files[len(opts.Files)-1]
+	candidate, err := readCompatSchema(newFile, opts.Type)
+	if err != nil {
+		return err
+	}
+	var previous []*schemaregistry.Schema
+	for _, path := range opts.Files[:len(opts.Files)-1] {
+		schema, err := readCompatSchema(path, candidate.Type)
+		if err != nil {
+			return err
+		}
+		previous = append(previous, schema)
+	}
+
+	var messages []string
+	var findings []compatFinding
+	if candidate.Type == "JSON" {
+		findings, err = checkJSONLevel(opts.Level, previous, candidate)
+		if err != nil {
+			return err
+		}
+		for _, finding := range findings {
+			if finding.Breaking {
+				messages = append(messages, formatFinding(finding, len(previous) > 1))
+			}
+		}
+	} else {
+		messages, err = schemaregistry.CheckLevel(opts.Level, previous, candidate)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.Output == "json" {
+		report := compatReport{
+			Compatible: len(messages) == 0,
+			Level:      opts.Level,
+			Type:       strings.ToUpper(candidate.Type),
+			Messages:   messages,
+			Findings:   findings,
+		}
+		if report.Messages == nil {
+			report.Messages = []string{}
+		}
+		if err := printRegistryJSON(report); err != nil {
+			return err
+		}
+	} else if len(messages) == 0 {
+		fmt.Printf("%s is %s compatible\n", newFile, opts.Level)
+	} else {
+		fmt.Printf("%s is not %s compatible:\n", newFile, opts.Level)
+		for _, message := range messages {
+			fmt.Printf("  - %s\n", message)
+		}
+	}
+	if opts.Output != "json" {
+		printOtherChanges(findings, len(previous) > 1)
+	}
+
+	if len(messages) > 0 {
+		return fmt.Errorf("%s has %d %s incompatibilities", newFile, len(messages), opts.Level)
+	}
+	return nil
+}
+
+// readCompatSchema reads a schema file, labelled by its path in messages
+func readCompatSchema(path, schemaType string) (*schemaregistry.Schema, error) {
+	schema, schemaType, err := readSchemaFile(path, schemaType)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaregistry.Schema{Subject: path, Schema: schema, Type: strings.ToUpper(schemaType)}, nil
+}
+
+// checkJSONLevel compares a JSON schema with the previous versions the level
+// covers, marking the findings that break it
+func checkJSONLevel(level string, previous []*schemaregistry.Schema, candidate *schemaregistry.Schema) ([]compatFinding, error) {
+	newSchema, err := parseJSONSchema(candidate)
+	if err != nil {
+		return nil, err
+	}
+	against := previous
+	if !strings.HasSuffix(level, "_TRANSITIVE") {
+		against = previous[len(previous)-1:]
+	}
+
+	var findings []compatFinding
+	for i := len(against) - 1; i >= 0; i-- {
+		oldSchema, err := parseJSONSchema(against[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, finding := range Compare(oldSchema, newSchema) {
+			findings = append(findings, compatFinding{
+				Finding:  finding,
+				Against:  against[i].Subject,
+				Breaking: finding.Breaks(level),
+			})
+		}
+	}
+	return findings, nil
+}
+
+func parseJSONSchema(schema *schemaregistry.Schema) (*Schema, error) {
+	var parsed Schema
+	if err := json.Unmarshal([]byte(schema.Schema), &parsed); err != nil {
+		return nil, fmt.Errorf("error parsing schema %s: %w", schema.Subject, err)
+	}
+	return &parsed, nil
+}
+
+func formatFinding(finding compatFinding, withFile bool) string {
+	message := fmt.Sprintf("%s: %s (%s)", finding.Path, finding.Message, finding.Kind)
+	if withFile {
+		return finding.Against + ": " + message
+	}
+	return message
+}
+
+// printOtherChanges lists the JSON schema findings that don't break the level
+func printOtherChanges(findings []compatFinding, withFile bool) {
+	printed := false
+	for _, finding := range findings {
+		if finding.Breaking {
+			continue
+		}
+		if !printed {
+			fmt.Println("Compatible changes:")
+			printed = true
+		}
+		fmt.Printf("  - %s\n", formatFinding(finding, withFile))
+	}
+}
+
+func printCompatHelp() error {
+	help := `Usage: schema compat [options] OLD... NEW
+
+Check offline whether the NEW schema file can replace the OLD ones, using the
+same rules as a schema registry. Each incompatibility is listed with its path
+in the schema, and the command exits 1 when there are any, so it can guard
+schema changes in CI.
+
+Levels are case-insensitive. With a _TRANSITIVE level NEW is checked against
+every OLD file (oldest first); otherwise only against the last one.
+
+Options:
+  --type, -t TYPE       Schema type: avro, json (default: from the files)
+  --level, -l LEVEL     BACKWARD, FORWARD, FULL, their _TRANSITIVE variants
+                        or NONE (default: BACKWARD)
+  --output, -o FORMAT   Output format: text, json (default: text)
+
+Levels:
+  BACKWARD    NEW can read data written with OLD
+  FORWARD     OLD can read data written with NEW
+  FULL        both
+
+Avro schemas follow the schema resolution rules: added fields need defaults,
+int/long/float widen, string and bytes interchange, unions and enums must
+cover what the writer may produce (or the enum needs a default), and reader
+aliases match renamed records, enums, fixed types and fields.
+
+JSON schemas are compared property by property. Each finding has a JSON path
+and a kind, and breaks BACKWARD when the new schema rejects old data or
+FORWARD when old consumers may not cope with new data:
+
+  required-added        property newly required                  BACKWARD
+  required-removed      property no longer required              FORWARD
+  property-removed      property dropped                         FORWARD
+  property-added        optional property added                  -
+  type-narrowed         e.g. number to integer, or null dropped  BACKWARD
+  type-widened          e.g. integer to number, or null allowed  FORWARD
+  items-changed         array item type changed                  as for the type
+  enum-shrunk           enum values removed                      BACKWARD
+  enum-expanded         enum values added                        FORWARD
+  constraint-tightened  format or bound added or narrowed        BACKWARD
+  constraint-loosened   format or bound removed or widened       FORWARD
+
+Other type changes (e.g. integer to string) break both ways; they are named
+widened or narrowed by the promotion order 'schema generate --evolve' uses.
+
+Examples:
+  schema compat old.json new.json --level full
+  schema compat --type avro old.avsc new.avsc
+  schema compat --level FULL_TRANSITIVE v1.avsc v2.avsc v3.avsc
+  git show main:orders.avsc > /tmp/old.avsc && schema compat /tmp/old.avsc orders.avsc`
+
+	fmt.Println(help)
+	return nil
+}
@@ -0,0 +1,50 @@
+package schema
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/og-dim9/dimutils/pkg/schemaregistry"
+)
+
+func TestRegistryChecksJSONCompatibility(t *testing.T) {
+	registry, err := schemaregistry.NewServer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(registry)
+	defer server.Close()
+	config := schemaregistry.DefaultConfig()
+	config.URL = server.URL
+	config.Retries = 0
+	client := schemaregistry.NewClient(config)
+
+	v1 := `{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`
+	optional := `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name"]}`
+	required := `{"type":"object","properties":{"name":{"type":"string"},"age":{"type":"integer"}},"required":["name","age"]}`
+
+	if _, err := client.RegisterSchema("users-value", v1, "JSON"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new required property rejects data valid under the old schema
+	_, err = client.RegisterSchema("users-value", required, "JSON")
+	var apiErr *schemaregistry.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for a new required property, got %v", err)
+	}
+
+	result, err := client.CheckCompatibility("users-value", "latest", required, "JSON")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Compatible || len(result.Messages) == 0 {
+		t.Fatalf("expected an incompatible result with messages, got %+v", result)
+	}
+
+	if _, err := client.RegisterSchema("users-value", optional, "JSON"); err != nil {
+		t.Fatalf("expected a new optional property to be compatible: %v", err)
+	}
+}
@@ -0,0 +1,553 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// convertOptions holds the command line settings for "schema convert"
+type convertOptions struct {
+	From       string // json, avro, proto (default: from the file)
+	To         string // json, avro, proto, sql, sql:postgres, sql:mysql
+	OutputFile string
+	Name       string // record, message or table name
+	Namespace  string // Avro namespace or protobuf package
+	Message    string // protobuf input message
+	File       string
+}
+
+// convType is a schema type in the format-neutral form conversions go through
+type convType struct {
+	Kind          string // string, int, long, float, double, boolean, bytes, date, timestamp, decimal, uuid, enum, record, array, map, any
+	Name          string // record and enum
+	Namespace     string
+	Doc           string
+	Fields        []*convField // record
+	Symbols       []string     // enum
+	Items         *convType    // array items, map values
+	ItemsNullable bool
+	Precision     int // decimal; 6 for microsecond timestamps
+	Scale         int // decimal
+}
+
+type convField struct {
+	Name     string
+	Doc      string
+	Type     *convType
+	Nullable bool
+	Default  json.RawMessage // Avro field default, nil when there is none
+}
+
+// isNamed reports whether the type is a record or enum, which share definitions by name
+func (t *convType) isNamed() bool {
+	return t.Kind == "record" || t.Kind == "enum"
+}
+
+// converter collects the warnings about what a conversion loses
+type converter struct {
+	warnings []string
+	seen     map[string]bool
+	// symbolEnums keeps only enums whose values are identifiers, as Avro and
+	// protobuf require; other targets take any string values
+	symbolEnums bool
+}
+
+func (c *converter) warnf(path, format string, args ...interface{}) {
+	warning := path + ": " + fmt.Sprintf(format, args...)
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	if !c.seen[warning] {
+		c.seen[warning] = true
+		c.warnings = append(c.warnings, warning)
+	}
+}
+
+// runConvert is the entry point for "schema convert"
+func runConvert(args []string) error {
+	var opts convertOptions
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--from", "-f":
+			if i+1 < len(args) {
+				opts.From = args[i+1]
+				i++
+			}
+		case "--to", "-t":
+			if i+1 < len(args) {
+				opts.To = args[i+1]
+				i++
+			}
+		case "--output", "-o":
+			if i+1 < len(args) {
+				opts.OutputFile = args[i+1]
+				i++
+			}
+		case "--name", "-n":
+			if i+1 < len(args) {
+				opts.Name = args[i+1]
+				i++
+			}
+		case "--namespace":
+			if i+1 < len(args) {
+				opts.Namespace = args[i+1]
+				i++
+			}
+		case "--message", "-m":
+			if i+1 < len(args) {
+				opts.Message = args[i+1]
+				i++
+			}
+		case "-h", "--help", "help":
+			return printConvertHelp()
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			if opts.File != "" {
+				return fmt.Errorf("usage: schema convert --to FORMAT [options] FILE")
+			}
+			opts.File = arg
+		}
+	}
+	if opts.File == "" || opts.To == "" {
+		return fmt.Errorf("usage: schema convert --to FORMAT [options] FILE")
+	}
+
+	text, detected, err := readSchemaFile(opts.File, convertFormatType(opts.From))
+	if err != nil {
+		return err
+	}
+	to := strings.ToLower(opts.To)
+	c := &converter{symbolEnums: to == "avro" || to == "proto" || to == "protobuf"}
+	root, err := c.read(text, detected, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Name != "" {
+		root.Name = opts.Name
+	}
+	if opts.Namespace != "" {
+		root.Namespace = opts.Namespace
+	}
+	assignNames(root)
+
+	output, err := c.write(root, opts.To)
+	if err != nil {
+		return err
+	}
+	for _, warning := range c.warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+	if opts.OutputFile != "" {
+		return os.WriteFile(opts.OutputFile, []byte(output), 0644)
+	}
+	fmt.Print(output)
+	return nil
+}
+
+// convertFormatType maps a --from format to the schema type readSchemaFile uses
+func convertFormatType(format string) string {
+	switch strings.ToLower(format) {
+	case "json":
+		return "JSON"
+	case "avro":
+		return "AVRO"
+	case "proto", "protobuf":
+		return "PROTOBUF"
+	}
+	return format
+}
+
+// rootName is the default record name: the schema file's base name
+func rootName(file string) string {
+	if file == "-" {
+		return "Record"
+	}
+	return pascalCase(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+}
+
+func (c *converter) read(text, schemaType string, opts convertOptions) (*convType, error) {
+	switch schemaType {
+	case "JSON":
+		var parsed Schema
+		if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+			return nil, fmt.Errorf("error parsing JSON schema %s: %w", opts.File, err)
+		}
+		if parsed.Type != TypeObject {
+			return nil, fmt.Errorf("%s: the top-level JSON schema must be an object", opts.File)
+		}
+		name := parsed.Title
+		if name == "" {
+			name = rootName(opts.File)
+		}
+		root, _ := c.fromJSON(&parsed, name, name)
+		return root, nil
+	case "AVRO":
+		return c.fromAvroSchema(text)
+	case "PROTOBUF":
+		return c.fromProto(text, opts.Message)
+	}
+	return nil, fmt.Errorf("unknown input format %q: use json, avro or proto", schemaType)
+}
+
+func (c *converter) write(root *convType, format string) (string, error) {
+	if root.Kind != "record" {
+		return "", fmt.Errorf("the top-level schema must be a record")
+	}
+	target, dialect, _ := strings.Cut(strings.ToLower(format), ":")
+	switch target {
+	case "json":
+		return marshalConverted(c.toJSONRoot(root))
+	case "avro":
+		return marshalConverted(c.toAvro(root, root.Name, make(map[string]bool)))
+	case "proto", "protobuf":
+		return c.toProto(root), nil
+	case "sql":
+		if dialect == "" {
+			dialect = "postgres"
+		}
+		if dialect != "postgres" && dialect != "mysql" {
+			return "", fmt.Errorf("unknown SQL dialect %q: use postgres or mysql", dialect)
+		}
+		return c.toSQL(root, dialect), nil
+	}
+	return "", fmt.Errorf("unknown output format %q: use json, avro, proto or sql[:postgres|mysql]", format)
+}
+
+func marshalConverted(value interface{}) (string, error) {
+	output, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error marshaling schema: %w", err)
+	}
+	return string(output) + "\n", nil
+}
+
+// fromJSON converts a JSON schema; properties that aren't required are
+// nullable, as are values that allow null
+func (c *converter) fromJSON(s *Schema, name, path string) (*convType, bool) {
+	t := &convType{Doc: s.Description}
+	switch s.Type {
+	case TypeObject:
+		if len(s.Properties) == 0 {
+			c.warnf(path, "object without properties becomes a map of strings")
+			return &convType{Kind: "map", Doc: s.Description, Items: &convType{Kind: "string"}}, false
+		}
+		t.Kind, t.Name = "record", name
+		required := stringSet(s.Required)
+		names := make([]string, 0, len(s.Properties))
+		for propName := range s.Properties {
+			names = append(names, propName)
+		}
+		sort.Strings(names)
+		for _, propName := range names {
+			prop := s.Properties[propName]
+			childName := prop.Title
+			if childName == "" {
+				childName = pascalCase(propName)
+			}
+			propType, onlyNull := c.fromJSON(prop, childName, path+"."+propName)
+			propType.Doc = "" // kept on the field
+			t.Fields = append(t.Fields, &convField{
+				Name:     propName,
+				Doc:      prop.Description,
+				Type:     propType,
+				Nullable: onlyNull || prop.Nullable || !required[propName],
+			})
+		}
+	case TypeArray:
+		t.Kind = "array"
+		if s.Items == nil {
+			c.warnf(path, "array without an item schema becomes an array of strings")
+			t.Items = &convType{Kind: "string"}
+		} else {
+			itemName := s.Items.Title
+			if itemName == "" {
+				itemName = name + "Item"
+			}
+			var onlyNull bool
+			t.Items, onlyNull = c.fromJSON(s.Items, itemName, path+"[]")
+			t.ItemsNullable = onlyNull || s.Items.Nullable
+		}
+	case TypeString:
+		t.Kind = "string"
+		switch s.Format {
+		case "date":
+			t.Kind = "date"
+		case "date-time":
+			t.Kind = "timestamp"
+		case "uuid":
+			t.Kind = "uuid"
+		}
+		if len(s.Enum) > 0 {
+			c.jsonEnum(t, s.Enum, name, path)
+		}
+	case TypeInteger:
+		t.Kind = "long"
+	case TypeNumber:
+		t.Kind = "double"
+		if logicalType, _ := s.Metadata["logicalType"].(string); logicalType == "decimal" {
+			precision, _ := s.Metadata["precision"].(float64)
+			scale, _ := s.Metadata["scale"].(float64)
+			if precision > 0 {
+				t.Kind, t.Precision, t.Scale = "decimal", int(precision), int(scale)
+			}
+		}
+	case TypeBoolean:
+		t.Kind = "boolean"
+	case TypeNull:
+		c.warnf(path, "only null values are allowed; using a nullable string")
+		return &convType{Kind: "string", Doc: s.Description}, true
+	default:
+		c.warnf(path, "no type given; using any type")
+		t.Kind = "any"
+	}
+	if len(s.Enum) > 0 && t.Kind != "enum" && s.Type != TypeString {
+		c.warnf(path, "enum of %s values is dropped", typeName(s.Type))
+	}
+	return t, false
+}
+
+// jsonEnum makes a string type an enum of its values, which must be valid
+// symbols when the target needs them
+func (c *converter) jsonEnum(t *convType, values []interface{}, name, path string) {
+	symbols := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			continue // null is allowed by a nullable type
+		}
+		symbol, ok := value.(string)
+		if !ok {
+			c.warnf(path, "enum value %s is not a string; keeping a plain string", enumList([]interface{}{value}))
+			return
+		}
+		if c.symbolEnums && !isIdentifier(symbol) {
+			c.warnf(path, "enum value %s is not a valid symbol; keeping a plain string", enumList([]interface{}{value}))
+			return
+		}
+		symbols = append(symbols, symbol)
+	}
+	t.Kind, t.Name, t.Symbols = "enum", name, symbols
+}
+
+// toJSONRoot converts the top-level record to a JSON schema document
+func (c *converter) toJSONRoot(root *convType) *Schema {
+	s := c.toJSON(root, root.Name, make(map[*convType]bool))
+	s.Schema = "https://json-schema.org/draft/2020-12/schema"
+	return s
+}
+
+func (c *converter) toJSON(t *convType, path string, active map[*convType]bool) *Schema {
+	s := &Schema{Description: t.Doc}
+	switch t.Kind {
+	case "string":
+		s.Type = TypeString
+	case "uuid":
+		s.Type, s.Format = TypeString, "uuid"
+	case "date":
+		s.Type, s.Format = TypeString, "date"
+	case "timestamp":
+		s.Type, s.Format = TypeString, "date-time"
+	case "bytes":
+		c.warnf(path, "bytes become a string")
+		s.Type = TypeString
+	case "int", "long":
+		s.Type = TypeInteger
+	case "float", "double":
+		s.Type = TypeNumber
+	case "decimal":
+		s.Type = TypeNumber
+		s.Metadata = map[string]interface{}{"logicalType": "decimal", "precision": t.Precision, "scale": t.Scale}
+	case "boolean":
+		s.Type = TypeBoolean
+	case "enum":
+		s.Type, s.Title = TypeString, t.Name
+		for _, symbol := range t.Symbols {
+			s.Enum = append(s.Enum, symbol)
+		}
+	case "array":
+		s.Type = TypeArray
+		s.Items = c.toJSON(t.Items, path+"[]", active)
+		if t.ItemsNullable {
+			allowNull(s.Items)
+		}
+	case "map":
+		c.warnf(path, "map values are not typed in the JSON schema")
+		s.Type = TypeObject
+	case "record":
+		if active[t] {
+			c.warnf(path, "recursive record %s is not expanded; any value is allowed", t.Name)
+			return &Schema{}
+		}
+		active[t] = true
+		defer delete(active, t)
+		s.Type, s.Title = TypeObject, t.Name
+		s.Properties = make(map[string]*Schema, len(t.Fields))
+		for _, field := range t.Fields {
+			s.Properties[field.Name] = c.toJSON(field.Type, path+"."+field.Name, active)
+			if field.Doc != "" {
+				s.Properties[field.Name].Description = field.Doc
+			}
+			if field.Nullable {
+				// Accept both a null and a missing value
+				allowNull(s.Properties[field.Name])
+			} else {
+				s.Required = append(s.Required, field.Name)
+			}
+		}
+		sort.Strings(s.Required)
+	}
+	return s
+}
+
+// allowNull makes a converted schema also accept null
+func allowNull(s *Schema) {
+	if s.Type == "" {
+		return // any value, null included
+	}
+	s.Nullable = true
+	if len(s.Enum) > 0 {
+		s.Enum = append(s.Enum, nil)
+	}
+}
+
+// assignNames renames records and enums that share a name with a different
+// type, prefixing the name of the record they appear in
+func assignNames(root *convType) {
+	owners := make(map[string]*convType)
+	var walk func(t *convType, parent string)
+	walk = func(t *convType, parent string) {
+		if t == nil {
+			return
+		}
+		if t.isNamed() {
+			if owners[t.Name] == t {
+				return
+			}
+			if owners[t.Name] != nil {
+				base := parent + t.Name
+				name := base
+				for i := 2; owners[name] != nil; i++ {
+					name = fmt.Sprintf("%s%d", base, i)
+				}
+				t.Name = name
+			}
+			owners[t.Name] = t
+			parent = t.Name
+		}
+		for _, field := range t.Fields {
+			walk(field.Type, parent)
+		}
+		walk(t.Items, parent)
+	}
+	walk(root, "")
+}
+
+// pascalCase turns a property or file name into a type name, e.g. line_items to LineItems
+func pascalCase(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	result := b.String()
+	if result == "" || unicode.IsDigit(rune(result[0])) {
+		result = "T" + result
+	}
+	return result
+}
+
+// isIdentifier reports whether a name is valid in Avro and protobuf
+func isIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// identifier makes a field name valid in Avro and protobuf
+func (c *converter) identifier(name, path string) string {
+	if isIdentifier(name) {
+		return name
+	}
+	var b strings.Builder
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	renamed := b.String()
+	if renamed == "" || (renamed[0] >= '0' && renamed[0] <= '9') {
+		renamed = "_" + renamed
+	}
+	c.warnf(path, "renamed to %s", renamed)
+	return renamed
+}
+
+func printConvertHelp() error {
+	help := `Usage: schema convert --to FORMAT [options] FILE
+
+Convert a schema between JSON Schema, Avro, Protobuf and SQL DDL. FILE may be
+"-" for stdin. Anything the target format can't express is reported on stderr
+as a warning with the field path, and the closest equivalent is written.
+
+Options:
+  --from, -f FORMAT     Input format: json, avro, proto (default: from the file)
+  --to, -t FORMAT       Output format: json, avro, proto, sql, sql:postgres,
+                        sql:mysql (sql is postgres)
+  --output, -o FILE     Output file (default: stdout)
+  --name, -n NAME       Record, message or table name (default: the schema's
+                        title or name, or the file name)
+  --namespace NS        Avro namespace or protobuf package
+  --message, -m NAME    Protobuf message to convert (default: the first
+                        top-level message no other message uses)
+
+Mapping:
+  JSON Schema           Avro                      Protobuf                   SQL
+  optional or null type union with null          optional / message field   NULL
+  integer               long                      int64                      BIGINT
+  number                double                    double                     DOUBLE PRECISION / DOUBLE
+  string format date    int date                  google.type.Date           DATE
+  string date-time      long timestamp-millis     google.protobuf.Timestamp  TIMESTAMP WITH TIME ZONE / DATETIME(3)
+  string format uuid    string uuid               string                     UUID / CHAR(36)
+  string enum           enum                      enum                       CHECK / ENUM
+  number + metadata     bytes decimal             string                     NUMERIC(p,s) / DECIMAL(p,s)
+  object                record                    message                    columns prefixed with the field name
+  array                 array                     repeated                   T[] / JSON
+  object without props  map                       map<string, V>             JSONB / JSON
+
+JSON Schema properties are read in name order. Decimals are kept in JSON
+Schema output as numbers with {"logicalType": "decimal", "precision": P,
+"scale": S} metadata, which convert reads back.
+
+Examples:
+  schema generate -i samples.jsonl | schema convert --from json --to avro - > orders.avsc
+  schema convert --to sql:mysql --name orders orders.avsc
+  schema convert --to proto --namespace shop.v1 orders.avsc
+  schema convert --to json --message Order orders.proto`
+
+	fmt.Println(help)
+	return nil
+}
@@ -0,0 +1,227 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/avro"
+	"github.com/og-dim9/dimutils/pkg/datagen"
+)
+
+// avroReader converts a parsed Avro schema, sharing one type per named type
+type avroReader struct {
+	c     *converter
+	named map[*avro.Schema]*convType
+}
+
+// fromAvroSchema converts an Avro schema document. It is parsed by pkg/avro,
+// like the registry does, so both agree on names, references and defaults.
+func (c *converter) fromAvroSchema(text string) (*convType, error) {
+	schema, err := avro.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing Avro schema: %w", err)
+	}
+	r := &avroReader{c: c, named: make(map[*avro.Schema]*convType)}
+	t, _, err := r.read(schema, "")
+	return t, err
+}
+
+// read converts an Avro type, reporting whether it is a union with null
+func (r *avroReader) read(schema *avro.Schema, path string) (*convType, bool, error) {
+	if t, ok := r.named[schema]; ok {
+		return t, false, nil
+	}
+
+	switch schema.Type {
+	case "null":
+		return nil, false, fmt.Errorf("%s: null is only supported in a union", path)
+	case "union":
+		return r.union(schema, path)
+	case "record", "error":
+		name, ns := avroName(schema.Name)
+		t := &convType{Kind: "record", Name: name, Namespace: ns, Doc: schema.Doc}
+		r.named[schema] = t
+		if path == "" {
+			path = name
+		}
+		for _, field := range schema.Fields {
+			fieldType, nullable, err := r.read(field.Type, path+"."+field.Name)
+			if err != nil {
+				return nil, false, err
+			}
+			converted := &convField{Name: field.Name, Doc: field.Doc, Type: fieldType, Nullable: nullable}
+			if field.HasDefault {
+				converted.Default, _ = json.Marshal(field.Default)
+			}
+			t.Fields = append(t.Fields, converted)
+		}
+		return t, false, nil
+	case "enum":
+		name, ns := avroName(schema.Name)
+		t := &convType{Kind: "enum", Name: name, Namespace: ns, Doc: schema.Doc, Symbols: schema.Symbols}
+		r.named[schema] = t
+		return t, false, nil
+	case "fixed":
+		name, ns := avroName(schema.Name)
+		t := &convType{Kind: "bytes", Name: name, Namespace: ns}
+		if schema.LogicalType == "decimal" {
+			t.Kind, t.Precision, t.Scale = "decimal", schema.Precision, schema.Scale
+		} else {
+			r.c.warnf(path, "fixed(%d) %s becomes bytes", schema.Size, name)
+		}
+		r.named[schema] = t
+		return t, false, nil
+	case "array", "map":
+		items := schema.Items
+		if schema.Type == "map" {
+			items = schema.Values
+		}
+		t := &convType{Kind: schema.Type}
+		var err error
+		if t.Items, t.ItemsNullable, err = r.read(items, path+"[]"); err != nil {
+			return nil, false, err
+		}
+		return t, false, nil
+	}
+
+	t := &convType{Kind: schema.Type}
+	switch {
+	case schema.LogicalType == "":
+	case schema.LogicalType == "date" && schema.Type == "int":
+		t.Kind = "date"
+	case strings.HasPrefix(schema.LogicalType, "timestamp-") || strings.HasPrefix(schema.LogicalType, "local-timestamp-"):
+		t.Kind = "timestamp"
+		if strings.HasSuffix(schema.LogicalType, "-micros") {
+			t.Precision = 6
+		}
+		if strings.HasPrefix(schema.LogicalType, "local-") {
+			r.c.warnf(path, "%s becomes a UTC timestamp", schema.LogicalType)
+		}
+	case schema.LogicalType == "decimal" && schema.Type == "bytes":
+		t.Kind, t.Precision, t.Scale = "decimal", schema.Precision, schema.Scale
+	case schema.LogicalType == "uuid" && schema.Type == "string":
+		t.Kind = "uuid"
+	default:
+		r.c.warnf(path, "logical type %s is dropped; keeping %s", schema.LogicalType, schema.Type)
+	}
+	return t, false, nil
+}
+
+func (r *avroReader) union(schema *avro.Schema, path string) (*convType, bool, error) {
+	nullable := false
+	var types []*convType
+	var names []string
+	for _, branch := range schema.Types {
+		if branch.Type == "null" {
+			nullable = true
+			continue
+		}
+		t, _, err := r.read(branch, path)
+		if err != nil {
+			return nil, false, err
+		}
+		types = append(types, t)
+		names = append(names, avroTypeName(t))
+	}
+	switch len(types) {
+	case 0:
+		r.c.warnf(path, "a union of only null becomes a nullable string")
+		return &convType{Kind: "string"}, true, nil
+	case 1:
+		return types[0], nullable, nil
+	}
+	r.c.warnf(path, "union of %s becomes a value of any type", strings.Join(names, ", "))
+	return &convType{Kind: "any"}, nullable, nil
+}
+
+// avroName splits a full name into its short name and namespace
+func avroName(fullName string) (string, string) {
+	if idx := strings.LastIndex(fullName, "."); idx >= 0 {
+		return fullName[idx+1:], fullName[:idx]
+	}
+	return fullName, ""
+}
+
+func avroFullName(name, namespace string) string {
+	if namespace == "" || strings.Contains(name, ".") {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func avroTypeName(t *convType) string {
+	if t.isNamed() {
+		return t.Name
+	}
+	return t.Kind
+}
+
+// toAvro converts a type to an Avro schema; named types are written once and
+// referred to by name afterwards
+func (c *converter) toAvro(t *convType, path string, defined map[string]bool) interface{} {
+	switch t.Kind {
+	case "string", "int", "long", "float", "double", "boolean", "bytes":
+		return t.Kind
+	case "any":
+		c.warnf(path, "value of any type becomes a string")
+		return "string"
+	case "date":
+		return datagen.AvroSchema{Type: "int", LogicalType: "date"}
+	case "timestamp":
+		if t.Precision == 6 {
+			return datagen.AvroSchema{Type: "long", LogicalType: "timestamp-micros"}
+		}
+		return datagen.AvroSchema{Type: "long", LogicalType: "timestamp-millis"}
+	case "decimal":
+		return datagen.AvroSchema{Type: "bytes", LogicalType: "decimal", Precision: t.Precision, Scale: t.Scale}
+	case "uuid":
+		return datagen.AvroSchema{Type: "string", LogicalType: "uuid"}
+	case "array":
+		return datagen.AvroSchema{Type: "array", Items: c.avroItems(t, path, defined)}
+	case "map":
+		return datagen.AvroSchema{Type: "map", Values: c.avroItems(t, path, defined)}
+	}
+
+	fullName := avroFullName(t.Name, t.Namespace)
+	if defined[fullName] {
+		return fullName
+	}
+	defined[fullName] = true
+	schema := avroNamed{AvroSchema: datagen.AvroSchema{Type: t.Kind, Name: t.Name, Namespace: t.Namespace}, Doc: t.Doc}
+	if t.Kind == "enum" {
+		schema.Symbols = t.Symbols
+		return schema
+	}
+	for _, field := range t.Fields {
+		fieldPath := path + "." + field.Name
+		avroField := datagen.AvroField{
+			Name: c.identifier(field.Name, fieldPath),
+			Type: c.toAvro(field.Type, fieldPath, defined),
+			Doc:  field.Doc,
+		}
+		if field.Nullable {
+			avroField.Type = []interface{}{"null", avroField.Type}
+			avroField.Default = json.RawMessage("null")
+		}
+		schema.Fields = append(schema.Fields, avroField)
+	}
+	if schema.Fields == nil {
+		schema.Fields = []datagen.AvroField{}
+	}
+	return schema
+}
+
+func (c *converter) avroItems(t *convType, path string, defined map[string]bool) interface{} {
+	items := c.toAvro(t.Items, path+"[]", defined)
+	if t.ItemsNullable {
+		return []interface{}{"null", items}
+	}
+	return items
+}
+
+// avroNamed is a record or enum with its doc, which datagen.AvroSchema doesn't carry
+type avroNamed struct {
+	datagen.AvroSchema
+	Doc string `json:"doc,omitempty"`
+}
@@ -0,0 +1,59 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromAvroSchema(t *testing.T) {
+	c := &converter{}
+	root, err := c.fromAvroSchema(`{"type":"record","name":"Node","namespace":"com.example","fields":[
+		{"name":"value","type":"int","default":0},
+		{"name":"next","type":["null","Node"],"default":null},
+		{"name":"kind","type":{"type":"enum","name":"Kind","namespace":"other","symbols":["A","B"]}},
+		{"name":"kinds","type":{"type":"array","items":"other.Kind"}},
+		{"name":"hash","type":{"type":"fixed","name":"Hash","size":16}},
+		{"name":"either","type":["int","string"]}
+	]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Kind != "record" || root.Name != "Node" || root.Namespace != "com.example" {
+		t.Fatalf("unexpected root %+v", root)
+	}
+
+	fields := make(map[string]*convField)
+	for _, field := range root.Fields {
+		fields[field.Name] = field
+	}
+	if next := fields["next"]; next.Type != root || !next.Nullable || string(next.Default) != "null" {
+		t.Errorf("expected next to be the nullable record itself with a null default, got %+v", next)
+	}
+	if value := fields["value"]; value.Type.Kind != "int" || string(value.Default) != "0" {
+		t.Errorf("unexpected value field %+v", value)
+	}
+	kind := fields["kind"].Type
+	if kind.Kind != "enum" || kind.Namespace != "other" || fields["kinds"].Type.Items != kind {
+		t.Errorf("expected kinds to share the Kind enum, got %+v and %+v", kind, fields["kinds"].Type.Items)
+	}
+	if fields["hash"].Type.Kind != "bytes" || fields["either"].Type.Kind != "any" {
+		t.Errorf("unexpected hash %+v or either %+v", fields["hash"].Type, fields["either"].Type)
+	}
+
+	want := []string{"Node.hash: fixed(16) Hash becomes bytes", "Node.either: union of int, string becomes a value of any type"}
+	if !reflect.DeepEqual(c.warnings, want) {
+		t.Errorf("expected warnings %q, got %q", want, c.warnings)
+	}
+}
+
+func TestFromAvroSchemaErrors(t *testing.T) {
+	for _, text := range []string{
+		`{"type":`,
+		`"null"`,
+		`{"type":"record","name":"A","fields":[{"name":"b","type":"Missing"}]}`,
+	} {
+		if _, err := (&converter{}).fromAvroSchema(text); err == nil {
+			t.Errorf("expected an error for %s", text)
+		}
+	}
+}
@@ -0,0 +1,611 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// protoFile is the part of a .proto file conversions use
+type protoFile struct {
+	Package  string
+	Messages []*protoMessage // top-level
+	types    map[string]*protoType
+}
+
+// protoType is a message or enum, by full name
+type protoType struct {
+	FullName  string
+	Message   *protoMessage
+	Enum      *protoEnum
+	scope     string // full name of the enclosing scope, for resolving field types
+	converted *convType
+}
+
+type protoMessage struct {
+	Name     string
+	Fields   []*protoField
+	Messages []*protoMessage
+	Enums    []*protoEnum
+	fullName string
+}
+
+type protoField struct {
+	Name    string
+	Type    string // scalar, message or enum name; value type for maps
+	KeyType string // maps only
+	Label   string // repeated, optional, required or ""
+	Oneof   string
+	Map     bool
+}
+
+type protoEnum struct {
+	Name   string
+	Values []string
+}
+
+// protoParser reads messages and enums from proto2/proto3 source, skipping
+// options, services and other declarations
+type protoParser struct {
+	tokens []string
+	pos    int
+	file   *protoFile
+}
+
+func parseProto(text string) (*protoFile, error) {
+	tokens, err := tokenizeProto(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{tokens: tokens, file: &protoFile{types: make(map[string]*protoType)}}
+	for !p.done() {
+		switch p.peek() {
+		case "package":
+			p.next()
+			p.file.Package = p.next()
+			p.expect(";")
+		case "message":
+			message, err := p.message(p.file.Package)
+			if err != nil {
+				return nil, err
+			}
+			p.file.Messages = append(p.file.Messages, message)
+		case "enum":
+			if _, err := p.enum(p.file.Package); err != nil {
+				return nil, err
+			}
+		case ";":
+			p.next()
+		default:
+			p.skipStatement()
+		}
+	}
+	return p.file, nil
+}
+
+// tokenizeProto splits proto source into identifiers, numbers, strings and
+// punctuation, dropping comments
+func tokenizeProto(text string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(text); {
+		switch ch := text[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case strings.HasPrefix(text[i:], "//"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				return tokens, nil
+			}
+			i += end
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment in proto file")
+			}
+			i += end + 4
+		case ch == '"' || ch == '\'':
+			j := i + 1
+			for j < len(text) && text[j] != ch {
+				if text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(text) {
+				return nil, fmt.Errorf("unterminated string in proto file")
+			}
+			tokens = append(tokens, text[i:j+1])
+			i = j + 1
+		case isProtoNameChar(ch):
+			j := i
+			for j < len(text) && isProtoNameChar(text[j]) {
+				j++
+			}
+			tokens = append(tokens, text[i:j])
+			i = j
+		default:
+			tokens = append(tokens, text[i:i+1])
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+func isProtoNameChar(ch byte) bool {
+	return ch == '_' || ch == '.' || ch == '-' || ch == '+' || (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func (p *protoParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *protoParser) peek() string {
+	if p.done() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *protoParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *protoParser) expect(token string) {
+	if p.peek() == token {
+		p.pos++
+	}
+}
+
+// skipStatement skips to the end of a statement or a balanced block
+func (p *protoParser) skipStatement() {
+	depth := 0
+	for !p.done() {
+		switch p.next() {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth <= 0 {
+				return
+			}
+		case ";":
+			if depth == 0 {
+				return
+			}
+		}
+	}
+}
+
+// skipOptions skips [ ... ] field options
+func (p *protoParser) skipOptions() {
+	if p.peek() != "[" {
+		return
+	}
+	for !p.done() && p.next() != "]" {
+	}
+}
+
+func (p *protoParser) message(scope string) (*protoMessage, error) {
+	p.next() // message
+	message := &protoMessage{Name: p.next()}
+	message.fullName = joinProtoName(scope, message.Name)
+	p.file.types[message.fullName] = &protoType{FullName: message.fullName, Message: message, scope: message.fullName}
+	if p.next() != "{" {
+		return nil, fmt.Errorf("expected { after message %s", message.Name)
+	}
+	return message, p.messageBody(message, "")
+}
+
+func (p *protoParser) messageBody(message *protoMessage, oneof string) error {
+	for !p.done() {
+		token := p.peek()
+		switch token {
+		case "}":
+			p.next()
+			return nil
+		case ";":
+			p.next()
+		case "message":
+			nested, err := p.message(message.fullName)
+			if err != nil {
+				return err
+			}
+			message.Messages = append(message.Messages, nested)
+		case "enum":
+			enum, err := p.enum(message.fullName)
+			if err != nil {
+				return err
+			}
+			message.Enums = append(message.Enums, enum)
+		case "oneof":
+			p.next()
+			name := p.next()
+			p.expect("{")
+			if err := p.messageBody(message, name); err != nil {
+				return err
+			}
+		case "option", "reserved", "extensions", "extend", "group":
+			p.skipStatement()
+		default:
+			if err := p.field(message, oneof); err != nil {
+				return err
+			}
+		}
+	}
+	return fmt.Errorf("missing } at the end of message %s", message.Name)
+}
+
+func (p *protoParser) field(message *protoMessage, oneof string) error {
+	field := &protoField{Oneof: oneof}
+	switch p.peek() {
+	case "repeated", "optional", "required":
+		field.Label = p.next()
+	}
+	if p.peek() == "map" {
+		p.next()
+		p.expect("<")
+		field.Map = true
+		field.KeyType = p.next()
+		p.expect(",")
+		field.Type = p.next()
+		p.expect(">")
+	} else {
+		field.Type = p.next()
+	}
+	field.Name = p.next()
+	if p.next() != "=" {
+		return fmt.Errorf("invalid field %s in message %s", field.Name, message.Name)
+	}
+	p.next() // number
+	p.skipOptions()
+	p.expect(";")
+	message.Fields = append(message.Fields, field)
+	return nil
+}
+
+func (p *protoParser) enum(scope string) (*protoEnum, error) {
+	p.next() // enum
+	enum := &protoEnum{Name: p.next()}
+	fullName := joinProtoName(scope, enum.Name)
+	p.file.types[fullName] = &protoType{FullName: fullName, Enum: enum, scope: scope}
+	if p.next() != "{" {
+		return nil, fmt.Errorf("expected { after enum %s", enum.Name)
+	}
+	for !p.done() {
+		token := p.next()
+		switch token {
+		case "}":
+			return enum, nil
+		case ";":
+		case "option", "reserved":
+			p.skipStatement()
+		default:
+			enum.Values = append(enum.Values, token)
+			p.skipStatement()
+		}
+	}
+	return nil, fmt.Errorf("missing } at the end of enum %s", enum.Name)
+}
+
+func joinProtoName(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+// resolve finds a message or enum type by name, searching outwards from scope
+func (f *protoFile) resolve(name, scope string) *protoType {
+	if strings.HasPrefix(name, ".") {
+		return f.types[name[1:]]
+	}
+	for {
+		if t, ok := f.types[joinProtoName(scope, name)]; ok {
+			return t
+		}
+		if scope == "" {
+			return nil
+		}
+		idx := strings.LastIndex(scope, ".")
+		if idx < 0 {
+			scope = ""
+		} else {
+			scope = scope[:idx]
+		}
+	}
+}
+
+// protoScalars maps protobuf scalar types to conversion kinds
+var protoScalars = map[string]string{
+	"double": "double", "float": "float",
+	"int32": "int", "sint32": "int", "sfixed32": "int",
+	"uint32": "long", "fixed32": "long",
+	"int64": "long", "sint64": "long", "sfixed64": "long",
+	"uint64": "long", "fixed64": "long",
+	"bool": "boolean", "string": "string", "bytes": "bytes",
+}
+
+// protoWellKnown maps well-known message types to conversion kinds; wrappers are nullable
+var protoWellKnown = map[string]string{
+	"google.protobuf.Timestamp":   "timestamp",
+	"google.type.Date":            "date",
+	"google.protobuf.StringValue": "string",
+	"google.protobuf.BytesValue":  "bytes",
+	"google.protobuf.BoolValue":   "boolean",
+	"google.protobuf.Int32Value":  "int",
+	"google.protobuf.UInt32Value": "long",
+	"google.protobuf.Int64Value":  "long",
+	"google.protobuf.UInt64Value": "long",
+	"google.protobuf.FloatValue":  "float",
+	"google.protobuf.DoubleValue": "double",
+	"google.protobuf.Struct":      "any",
+	"google.protobuf.Value":       "any",
+	"google.protobuf.Any":         "any",
+}
+
+// fromProto converts a message from a .proto file, by default the first
+// top-level message that no other message uses
+func (c *converter) fromProto(text, messageName string) (*convType, error) {
+	file, err := parseProto(text)
+	if err != nil {
+		return nil, err
+	}
+	var root *protoType
+	if messageName != "" {
+		if root = file.resolve(messageName, file.Package); root == nil || root.Message == nil {
+			return nil, fmt.Errorf("message %s not found", messageName)
+		}
+	} else if root = file.defaultMessage(); root == nil {
+		return nil, fmt.Errorf("no message found")
+	}
+	t, err := c.protoMessage(file, root)
+	if err != nil {
+		return nil, err
+	}
+	t.Namespace = file.Package
+	return t, nil
+}
+
+func (f *protoFile) defaultMessage() *protoType {
+	used := make(map[string]bool)
+	for _, t := range f.types {
+		if t.Message == nil {
+			continue
+		}
+		for _, field := range t.Message.Fields {
+			if target := f.resolve(field.Type, t.scope); target != nil {
+				used[target.FullName] = true
+			}
+		}
+	}
+	for _, message := range f.Messages {
+		if !used[message.fullName] {
+			return f.types[message.fullName]
+		}
+	}
+	if len(f.Messages) > 0 {
+		return f.types[f.Messages[0].fullName]
+	}
+	return nil
+}
+
+func (c *converter) protoMessage(file *protoFile, pt *protoType) (*convType, error) {
+	if pt.converted != nil {
+		return pt.converted, nil
+	}
+	message := pt.Message
+	t := &convType{Kind: "record", Name: message.Name}
+	pt.converted = t
+	for _, field := range message.Fields {
+		path := message.Name + "." + field.Name
+		fieldType, presence, err := c.protoFieldType(file, pt.scope, field.Type, path)
+		if err != nil {
+			return nil, err
+		}
+		cf := &convField{Name: field.Name, Type: fieldType}
+		switch {
+		case field.Map:
+			if protoScalars[field.KeyType] != "string" {
+				c.warnf(path, "map keys of type %s become strings", field.KeyType)
+			}
+			cf.Type = &convType{Kind: "map", Items: fieldType}
+		case field.Label == "repeated":
+			cf.Type = &convType{Kind: "array", Items: fieldType}
+		case field.Oneof != "":
+			c.warnf(path, "oneof %s becomes separate nullable fields", field.Oneof)
+			cf.Nullable = true
+		default:
+			// proto2 fields are always labelled; unlabelled proto3 scalars have no presence
+			cf.Nullable = presence || field.Label == "optional"
+		}
+		if field.Type == "uint64" || field.Type == "fixed64" {
+			c.warnf(path, "%s values above 2^63-1 don't fit a long", field.Type)
+		}
+		t.Fields = append(t.Fields, cf)
+	}
+	return t, nil
+}
+
+// protoFieldType converts a field's type, reporting whether the field has
+// presence (messages and wrapper types can be unset)
+func (c *converter) protoFieldType(file *protoFile, scope, typeName, path string) (*convType, bool, error) {
+	if kind, ok := protoScalars[typeName]; ok {
+		return &convType{Kind: kind}, false, nil
+	}
+	name := strings.TrimPrefix(typeName, ".")
+	if kind, ok := protoWellKnown[name]; ok {
+		if kind == "any" {
+			c.warnf(path, "%s becomes a value of any type", name)
+		}
+		return &convType{Kind: kind}, true, nil
+	}
+	target := file.resolve(typeName, scope)
+	if target == nil {
+		return nil, false, fmt.Errorf("%s: unknown type %s (imported types are not supported)", path, typeName)
+	}
+	if target.Enum != nil {
+		if target.converted == nil {
+			target.converted = &convType{Kind: "enum", Name: target.Enum.Name, Symbols: target.Enum.Values}
+		}
+		return target.converted, false, nil
+	}
+	t, err := c.protoMessage(file, target)
+	return t, true, err
+}
+
+// protoWriter builds a proto3 file from converted types
+type protoWriter struct {
+	c        *converter
+	imports  map[string]bool
+	messages []string // rendered messages, root first
+	written  map[*convType]bool
+	enums    map[*convType]string // message each enum is nested in
+}
+
+// toProto converts a record to a proto3 file; nested records become
+// top-level messages and enums are nested in the first message using them
+func (c *converter) toProto(root *convType) string {
+	w := &protoWriter{c: c, imports: make(map[string]bool), written: make(map[*convType]bool), enums: make(map[*convType]string)}
+	w.message(root, root.Name)
+
+	var b strings.Builder
+	b.WriteString("syntax = \"proto3\";\n\n")
+	if root.Namespace != "" {
+		fmt.Fprintf(&b, "package %s;\n\n", root.Namespace)
+	}
+	if len(w.imports) > 0 {
+		imports := make([]string, 0, len(w.imports))
+		for imp := range w.imports {
+			imports = append(imports, imp)
+		}
+		sort.Strings(imports)
+		for _, imp := range imports {
+			fmt.Fprintf(&b, "import %q;\n", imp)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.Join(w.messages, "\n"))
+	return b.String()
+}
+
+func (w *protoWriter) message(t *convType, path string) {
+	if w.written[t] {
+		return
+	}
+	w.written[t] = true
+	index := len(w.messages)
+	w.messages = append(w.messages, "")
+
+	var b strings.Builder
+	writeProtoComment(&b, t.Doc, "")
+	fmt.Fprintf(&b, "message %s {\n", t.Name)
+	var body strings.Builder
+	symbols := make(map[string]string)
+	for i, field := range t.Fields {
+		fieldPath := path + "." + field.Name
+		name := w.c.identifier(field.Name, fieldPath)
+		typeName := w.fieldType(field.Type, fieldPath, t.Name, &body, symbols)
+		label := ""
+		switch field.Type.Kind {
+		case "array":
+			label = "repeated "
+			if field.Nullable {
+				w.c.warnf(fieldPath, "null and empty lists are not distinguished")
+			}
+		case "map":
+			if field.Nullable {
+				w.c.warnf(fieldPath, "null and empty maps are not distinguished")
+			}
+		case "record", "date", "timestamp":
+			// message fields always have presence
+		default:
+			if field.Nullable {
+				label = "optional "
+			}
+		}
+		writeProtoComment(&b, field.Doc, "  ")
+		fmt.Fprintf(&b, "  %s%s %s = %d;\n", label, typeName, name, i+1)
+	}
+	if body.Len() > 0 {
+		b.WriteString("\n")
+		b.WriteString(body.String())
+	}
+	b.WriteString("}\n")
+	w.messages[index] = b.String()
+}
+
+// fieldType returns the protobuf type of a field of message owner, rendering
+// enums it is the first to use into body
+func (w *protoWriter) fieldType(t *convType, path, owner string, body *strings.Builder, symbols map[string]string) string {
+	switch t.Kind {
+	case "array", "map":
+		items := t.Items
+		if items.Kind == "array" || items.Kind == "map" {
+			w.c.warnf(path, "nested %s is wrapped in a message", items.Kind)
+			items = &convType{Kind: "record", Name: pascalCase(path[strings.LastIndex(path, ".")+1:]) + "Item",
+				Fields: []*convField{{Name: "values", Type: items}}}
+		}
+		if t.ItemsNullable {
+			w.c.warnf(path, "null %s can't be represented", map[string]string{"array": "items", "map": "values"}[t.Kind])
+		}
+		itemType := w.fieldType(items, path+"[]", owner, body, symbols)
+		if t.Kind == "map" {
+			return "map<string, " + itemType + ">"
+		}
+		return itemType
+	case "string", "bytes", "double", "float":
+		return t.Kind
+	case "int":
+		return "int32"
+	case "long":
+		return "int64"
+	case "boolean":
+		return "bool"
+	case "uuid":
+		return "string"
+	case "decimal":
+		w.c.warnf(path, "decimal(%d,%d) becomes a string", t.Precision, t.Scale)
+		return "string"
+	case "any":
+		w.c.warnf(path, "value of any type becomes a string")
+		return "string"
+	case "date":
+		w.imports["google/type/date.proto"] = true
+		return "google.type.Date"
+	case "timestamp":
+		w.imports["google/protobuf/timestamp.proto"] = true
+		return "google.protobuf.Timestamp"
+	case "enum":
+		if parent, nested := w.enums[t]; nested {
+			if parent == owner {
+				return t.Name
+			}
+			return parent + "." + t.Name
+		}
+		w.enums[t] = owner
+		fmt.Fprintf(body, "  enum %s {\n", t.Name)
+		for i, symbol := range t.Symbols {
+			if other, clash := symbols[symbol]; clash {
+				w.c.warnf(path, "enum value %s is also in %s; enum values share their message's scope", symbol, other)
+			}
+			symbols[symbol] = t.Name
+			fmt.Fprintf(body, "    %s = %d;\n", symbol, i)
+		}
+		body.WriteString("  }\n")
+		return t.Name
+	case "record":
+		w.message(t, t.Name)
+		return t.Name
+	}
+	return "string"
+}
+
+func writeProtoComment(b *strings.Builder, doc, indent string) {
+	for _, line := range strings.Split(doc, "\n") {
+		if doc != "" {
+			fmt.Fprintf(b, "%s// %s\n", indent, line)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// sqlColumn is one column of a generated table
+type sqlColumn struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Check    string
+}
+
+// toSQL converts a record to a CREATE TABLE statement; nested records are
+// flattened into columns prefixed with the field name
+func (c *converter) toSQL(root *convType, dialect string) string {
+	var columns []sqlColumn
+	c.sqlColumns(root, "", root.Name, false, dialect, map[*convType]bool{root: true}, &columns)
+
+	table := root.Name
+	if table == "" {
+		table = "record"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "CREATE TABLE %s (\n", sqlQuote(snakeCase(table), dialect))
+	for i, column := range columns {
+		fmt.Fprintf(&b, "  %s %s", sqlQuote(column.Name, dialect), column.Type)
+		if !column.Nullable {
+			b.WriteString(" NOT NULL")
+		}
+		if column.Check != "" {
+			fmt.Fprintf(&b, " CHECK (%s)", column.Check)
+		}
+		if i < len(columns)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(");\n")
+	return b.String()
+}
+
+func (c *converter) sqlColumns(t *convType, prefix, path string, nullable bool, dialect string, active map[*convType]bool, columns *[]sqlColumn) {
+	for _, field := range t.Fields {
+		name := prefix + field.Name
+		fieldPath := path + "." + field.Name
+		fieldNullable := nullable || field.Nullable
+		if field.Type.Kind == "record" && !active[field.Type] {
+			active[field.Type] = true
+			c.sqlColumns(field.Type, name+"_", fieldPath, fieldNullable, dialect, active, columns)
+			delete(active, field.Type)
+			continue
+		}
+		column := sqlColumn{Name: name, Nullable: fieldNullable}
+		column.Type = c.sqlType(field.Type, fieldPath, dialect)
+		if field.Type.Kind == "enum" && dialect == "postgres" {
+			column.Check = fmt.Sprintf("%s IN (%s)", sqlQuote(name, dialect), sqlStrings(field.Type.Symbols))
+		}
+		*columns = append(*columns, column)
+	}
+}
+
+func (c *converter) sqlType(t *convType, path, dialect string) string {
+	postgres := dialect == "postgres"
+	pick := func(postgresType, mysqlType string) string {
+		if postgres {
+			return postgresType
+		}
+		return mysqlType
+	}
+	switch t.Kind {
+	case "string":
+		return "TEXT"
+	case "int":
+		return pick("INTEGER", "INT")
+	case "long":
+		return "BIGINT"
+	case "float":
+		return pick("REAL", "FLOAT")
+	case "double":
+		return pick("DOUBLE PRECISION", "DOUBLE")
+	case "boolean":
+		return "BOOLEAN"
+	case "bytes":
+		return pick("BYTEA", "BLOB")
+	case "date":
+		return "DATE"
+	case "timestamp":
+		if !postgres {
+			c.warnf(path, "DATETIME doesn't keep the time zone; store UTC")
+			if t.Precision == 6 {
+				return "DATETIME(6)"
+			}
+			return "DATETIME(3)"
+		}
+		return "TIMESTAMP WITH TIME ZONE"
+	case "decimal":
+		return fmt.Sprintf("%s(%d,%d)", pick("NUMERIC", "DECIMAL"), t.Precision, t.Scale)
+	case "uuid":
+		return pick("UUID", "CHAR(36)")
+	case "enum":
+		if postgres {
+			return "TEXT"
+		}
+		return "ENUM(" + sqlStrings(t.Symbols) + ")"
+	case "array":
+		if postgres && t.Items.Kind != "array" && t.Items.Kind != "map" && t.Items.Kind != "record" && t.Items.Kind != "any" {
+			return c.sqlType(t.Items, path+"[]", dialect) + "[]"
+		}
+		c.warnf(path, "array of %s is stored as %s", avroTypeName(t.Items), pick("JSONB", "JSON"))
+	case "map":
+		c.warnf(path, "map is stored as %s", pick("JSONB", "JSON"))
+	case "record":
+		c.warnf(path, "recursive record %s is stored as %s", t.Name, pick("JSONB", "JSON"))
+	}
+	return pick("JSONB", "JSON")
+}
+
+func sqlQuote(name, dialect string) string {
+	if dialect == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func sqlStrings(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// snakeCase turns a type name into a table name, e.g. LineItem to line_item
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 && name[i-1] != '_' && !(name[i-1] >= 'A' && name[i-1] <= 'Z') {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,600 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/schemaregistry"
+)
+
+// docsOptions holds the command line settings for "schema docs"
+type docsOptions struct {
+	Format       string // markdown, html
+	Output       string // file for one schema, directory otherwise
+	RegistryPath string
+	Type         string // AVRO, JSON (default: from the files)
+	Files        []string
+}
+
+// docPage is the documentation of one schema
+type docPage struct {
+	Name        string // subject or file name
+	File        string // page file name in a docs directory
+	Title       string
+	Description string
+	Type        string // JSON, AVRO, PROTOBUF
+	Version     string
+	Source      string
+	Properties  []*docProperty
+	Examples    []string // JSON encoded
+	Raw         string   // the schema text, shown when it can't be broken down
+	Indexed     bool     // linked from an index page
+}
+
+// docProperty is a node in the property tree of a page
+type docProperty struct {
+	Name        string
+	Type        string
+	Required    bool
+	Format      string
+	Default     string   // JSON encoded, empty when there is none
+	Enum        []string // JSON encoded
+	Constraints []string
+	Examples    []string // JSON encoded
+	Description string
+	Children    []*docProperty
+}
+
+// runDocs is the entry point for "schema docs"
+func runDocs(args []string) error {
+	opts := docsOptions{Format: "markdown", RegistryPath: DefaultConfig().RegistryPath}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--format", "-f":
+			if i+1 < len(args) {
+				opts.Format = args[i+1]
+				i++
+			}
+		case "--output", "-o":
+			if i+1 < len(args) {
+				opts.Output = args[i+1]
+				i++
+			}
+		case "--registry", "-r":
+			if i+1 < len(args) {
+				opts.RegistryPath = args[i+1]
+				i++
+			}
+		case "--type", "-t":
+			if i+1 < len(args) {
+				opts.Type = strings.ToUpper(args[i+1])
+				i++
+			}
+		case "-h", "--help", "help":
+			return printDocsHelp()
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			opts.Files = append(opts.Files, arg)
+		}
+	}
+	switch strings.ToLower(opts.Format) {
+	case "markdown", "md":
+		opts.Format = "markdown"
+	case "html":
+		opts.Format = "html"
+	default:
+		return fmt.Errorf("invalid format %q: use markdown or html", opts.Format)
+	}
+
+	var pages []*docPage
+	if len(opts.Files) > 0 {
+		files, err := expandSchemaFiles(opts.Files)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			text, schemaType, err := readSchemaFile(file, opts.Type)
+			if err != nil {
+				return err
+			}
+			name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+			pages = append(pages, newDocPage(name, file, "", text, schemaType))
+		}
+		if len(pages) == 1 {
+			return writeDoc(opts.Output, renderDocPage(pages[0], opts.Format))
+		}
+	} else {
+		var err error
+		if pages, err = registryDocPages(opts.RegistryPath); err != nil {
+			return err
+		}
+		if len(pages) == 0 {
+			return fmt.Errorf("no subjects in %s; give schema files to document instead", opts.RegistryPath)
+		}
+	}
+
+	// Several schemas: a page each and an index
+	dir := opts.Output
+	if dir == "" {
+		dir = "schema-docs"
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating %s: %w", dir, err)
+	}
+	assignDocFiles(pages, opts.Format)
+	for _, page := range pages {
+		page.Indexed = true
+		if err := writeDoc(filepath.Join(dir, page.File), renderDocPage(page, opts.Format)); err != nil {
+			return err
+		}
+	}
+	index := filepath.Join(dir, "index"+docExtension(opts.Format))
+	if err := writeDoc(index, renderDocIndex(pages, opts.Format)); err != nil {
+		return err
+	}
+	noun := "pages"
+	if len(pages) == 1 {
+		noun = "page"
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d %s and %s\n", len(pages), noun, index)
+	return nil
+}
+
+// registryDocPages documents the latest version of every subject in the
+// registry path: the schema history and the local registry's subjects
+func registryDocPages(registryPath string) ([]*docPage, error) {
+	var pages []*docPage
+	subjects, err := historySubjects(registryPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, subject := range subjects {
+		h, err := openHistory(registryPath, subject)
+		if err != nil {
+			return nil, err
+		}
+		entry, err := h.find("latest")
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(h.dir, entry.File))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s v%d: %w", subject, entry.Version, err)
+		}
+		source := fmt.Sprintf("schema history v%d, %s", entry.Version, entry.Created.Format("2006-01-02"))
+		pages = append(pages, newDocPage(subject, source, "", string(data), "JSON"))
+	}
+
+	registered, err := schemaregistry.LocalSchemas(registryPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, schema := range registered {
+		schemaType := schema.Type
+		if schemaType == "" {
+			schemaType = "AVRO"
+		}
+		source := fmt.Sprintf("local registry, id %d", schema.ID)
+		pages = append(pages, newDocPage(schema.Subject, source, fmt.Sprint(schema.Version), schema.Schema, schemaType))
+	}
+	return pages, nil
+}
+
+// newDocPage breaks a schema down into a property tree; schemas that can't be
+// are shown as they are
+func newDocPage(name, source, version, text, schemaType string) *docPage {
+	page := &docPage{Name: name, Title: name, Type: schemaType, Version: version, Source: source}
+	switch schemaType {
+	case "JSON":
+		var schema Schema
+		if err := json.Unmarshal([]byte(text), &schema); err == nil {
+			if schema.Title != "" {
+				page.Title = schema.Title
+			}
+			if page.Version == "" {
+				page.Version = schema.Version
+			}
+			page.Description = schema.Description
+			page.Properties = jsonDocChildren(&schema)
+			page.Examples = encodeDocValues(schema.Examples)
+			return page
+		}
+	case "AVRO":
+		c := &converter{}
+		if root, err := c.fromAvroSchema(text); err == nil {
+			page.Title = avroFullName(root.Name, root.Namespace)
+			page.Description = root.Doc
+			page.Properties = avroDocChildren(root, make(map[*convType]bool))
+			if root.Kind != "record" {
+				page.Properties = []*docProperty{{Name: "(value)", Type: avroDocType(root), Required: true}}
+			}
+			return page
+		}
+	}
+	page.Raw = prettySchema(text)
+	return page
+}
+
+// jsonDocChildren returns the properties of an object, or of the objects in an array
+func jsonDocChildren(s *Schema) []*docProperty {
+	if s.Type == TypeArray && s.Items != nil {
+		return jsonDocChildren(s.Items)
+	}
+	required := stringSet(s.Required)
+	var properties []*docProperty
+	for _, name := range sortedPropertyNames(s) {
+		prop := s.Properties[name]
+		properties = append(properties, &docProperty{
+			Name:        name,
+			Type:        jsonDocType(prop),
+			Required:    required[name],
+			Format:      prop.Format,
+			Enum:        encodeDocValues(prop.Enum),
+			Constraints: jsonDocConstraints(prop),
+			Examples:    encodeDocValues(prop.Examples),
+			Description: prop.Description,
+			Children:    jsonDocChildren(prop),
+		})
+	}
+	return properties
+}
+
+func jsonDocType(s *Schema) string {
+	label := typeName(s.Type)
+	if s.Type == TypeArray && s.Items != nil {
+		label = "array of " + jsonDocType(s.Items)
+	}
+	if s.Nullable {
+		label += " or null"
+	}
+	return label
+}
+
+func jsonDocConstraints(s *Schema) []string {
+	var constraints []string
+	if s.Minimum != nil {
+		constraints = append(constraints, fmt.Sprintf("minimum %v", *s.Minimum))
+	}
+	if s.Maximum != nil {
+		constraints = append(constraints, fmt.Sprintf("maximum %v", *s.Maximum))
+	}
+	for _, bound := range []struct {
+		keyword string
+		value   *int
+	}{{"minLength", s.MinLength}, {"maxLength", s.MaxLength}, {"minItems", s.MinItems}, {"maxItems", s.MaxItems}} {
+		if bound.value != nil {
+			constraints = append(constraints, fmt.Sprintf("%s %d", bound.keyword, *bound.value))
+		}
+	}
+	return constraints
+}
+
+// avroDocChildren returns the fields of a record, or of the records in an
+// array or map; a record inside itself isn't expanded again
+func avroDocChildren(t *convType, active map[*convType]bool) []*docProperty {
+	if t.Kind == "array" || t.Kind == "map" {
+		return avroDocChildren(t.Items, active)
+	}
+	if t.Kind != "record" || active[t] {
+		return nil
+	}
+	active[t] = true
+	defer delete(active, t)
+
+	var properties []*docProperty
+	for _, field := range t.Fields {
+		label := avroDocType(field.Type)
+		if field.Nullable {
+			label += " or null"
+		}
+		// A reader fills in a missing field from its default, so only
+		// fields without one are required
+		property := &docProperty{
+			Name:        field.Name,
+			Type:        label,
+			Required:    !field.Nullable && field.Default == nil,
+			Default:     string(field.Default),
+			Description: field.Doc,
+			Children:    avroDocChildren(field.Type, active),
+		}
+		if field.Type.Kind == "enum" {
+			for _, symbol := range field.Type.Symbols {
+				property.Enum = append(property.Enum, symbol)
+			}
+		}
+		properties = append(properties, property)
+	}
+	return properties
+}
+
+func avroDocType(t *convType) string {
+	switch t.Kind {
+	case "date":
+		return "int (date)"
+	case "timestamp":
+		if t.Precision == 6 {
+			return "long (timestamp-micros)"
+		}
+		return "long (timestamp-millis)"
+	case "decimal":
+		return fmt.Sprintf("decimal(%d,%d)", t.Precision, t.Scale)
+	case "uuid":
+		return "string (uuid)"
+	case "record", "enum":
+		return t.Kind + " " + t.Name
+	case "array", "map":
+		label := avroDocType(t.Items)
+		if t.ItemsNullable {
+			label += " or null"
+		}
+		return t.Kind + " of " + label
+	}
+	return t.Kind
+}
+
+func encodeDocValues(values []interface{}) []string {
+	var encoded []string
+	for _, value := range values {
+		data, _ := json.Marshal(value)
+		encoded = append(encoded, string(data))
+	}
+	return encoded
+}
+
+// assignDocFiles names the page files after their subjects, keeping the names unique
+func assignDocFiles(pages []*docPage, format string) {
+	used := map[string]bool{"index": true}
+	for _, page := range pages {
+		base := strings.Map(func(r rune) rune {
+			if r == '.' || r == '-' || r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' {
+				return r
+			}
+			return '_'
+		}, page.Name)
+		name := base
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[name] = true
+		page.File = name + docExtension(format)
+	}
+}
+
+func docExtension(format string) string {
+	if format == "html" {
+		return ".html"
+	}
+	return ".md"
+}
+
+func writeDoc(path, content string) error {
+	if path == "" || path == "-" {
+		fmt.Print(content)
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
+
+func renderDocPage(page *docPage, format string) string {
+	if format == "html" {
+		return renderHTML("page", page)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", page.Title)
+	if page.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", page.Description)
+	}
+	b.WriteString("| | |\n|---|---|\n")
+	fmt.Fprintf(&b, "| Subject | %s |\n", markdownCell(page.Name))
+	fmt.Fprintf(&b, "| Type | %s |\n", docTypeName(page.Type))
+	if page.Version != "" {
+		fmt.Fprintf(&b, "| Version | %s |\n", markdownCell(page.Version))
+	}
+	if page.Source != "" {
+		fmt.Fprintf(&b, "| Source | %s |\n", markdownCell(page.Source))
+	}
+
+	if page.Raw != "" {
+		fmt.Fprintf(&b, "\n## Schema\n\n```\n%s\n```\n", page.Raw)
+		return b.String()
+	}
+	b.WriteString("\n## Properties\n\n")
+	if len(page.Properties) == 0 {
+		b.WriteString("No properties.\n")
+	}
+	writeMarkdownProperties(&b, page.Properties, "")
+	if len(page.Examples) > 0 {
+		b.WriteString("\n## Examples\n")
+		for _, example := range page.Examples {
+			fmt.Fprintf(&b, "\n```json\n%s\n```\n", prettySchema(example))
+		}
+	}
+	return b.String()
+}
+
+// writeMarkdownProperties writes the property tree as a nested list
+func writeMarkdownProperties(b *strings.Builder, properties []*docProperty, indent string) {
+	for _, p := range properties {
+		fmt.Fprintf(b, "%s- **`%s`** `%s`", indent, p.Name, p.Type)
+		if p.Required {
+			b.WriteString(" _required_")
+		}
+		if p.Format != "" {
+			fmt.Fprintf(b, " · format `%s`", p.Format)
+		}
+		if p.Default != "" {
+			fmt.Fprintf(b, " · default `%s`", p.Default)
+		}
+		if len(p.Enum) > 0 {
+			fmt.Fprintf(b, " · one of %s", markdownCodes(p.Enum))
+		}
+		if len(p.Constraints) > 0 {
+			fmt.Fprintf(b, " · %s", strings.Join(p.Constraints, ", "))
+		}
+		if len(p.Examples) > 0 {
+			fmt.Fprintf(b, " · e.g. %s", markdownCodes(p.Examples))
+		}
+		if p.Description != "" {
+			fmt.Fprintf(b, " — %s", strings.Join(strings.Fields(p.Description), " "))
+		}
+		b.WriteString("\n")
+		writeMarkdownProperties(b, p.Children, indent+"  ")
+	}
+}
+
+func markdownCodes(values []string) string {
+	codes := make([]string, len(values))
+	for i, value := range values {
+		codes[i] = "`" + value + "`"
+	}
+	return strings.Join(codes, ", ")
+}
+
+func markdownCell(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
+
+func renderDocIndex(pages []*docPage, format string) string {
+	sorted := append([]*docPage(nil), pages...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	if format == "html" {
+		return renderHTML("index", sorted)
+	}
+	var b strings.Builder
+	b.WriteString("# Schemas\n\n| Subject | Type | Version | Description |\n|---|---|---|---|\n")
+	for _, page := range sorted {
+		description, _, _ := strings.Cut(page.Description, "\n")
+		fmt.Fprintf(&b, "| [%s](%s) | %s | %s | %s |\n", markdownCell(page.Name), page.File,
+			docTypeName(page.Type), markdownCell(page.Version), markdownCell(description))
+	}
+	return b.String()
+}
+
+func docTypeName(schemaType string) string {
+	switch schemaType {
+	case "JSON":
+		return "JSON Schema"
+	case "AVRO":
+		return "Avro"
+	case "PROTOBUF":
+		return "Protobuf"
+	}
+	return schemaType
+}
+
+var docTemplates = template.Must(template.New("docs").Funcs(template.FuncMap{"typeName": docTypeName}).Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; color: #222; line-height: 1.5; }
+table { border-collapse: collapse; margin: 1rem 0; }
+th, td { border: 1px solid #ddd; padding: .3rem .6rem; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+ul.tree { list-style: none; padding-left: 1.2rem; border-left: 1px dotted #bbb; }
+ul.tree > li { margin: .3rem 0; }
+code, pre { background: #f5f5f5; border-radius: 3px; padding: 0 .2rem; }
+pre { padding: .6rem; overflow-x: auto; }
+.name { font-weight: bold; }
+.type { color: #0a6; }
+.required { color: #c33; font-size: .85em; }
+.detail { color: #555; font-size: .9em; }
+</style>
+</head>
+<body>
+{{end}}
+{{define "properties"}}<ul class="tree">
+{{range .}}<li><code class="name">{{.Name}}</code> <code class="type">{{.Type}}</code>
+{{- if .Required}} <span class="required">required</span>{{end}}
+{{- if .Format}} <span class="detail">· format <code>{{.Format}}</code></span>{{end}}
+{{- if .Default}} <span class="detail">· default <code>{{.Default}}</code></span>{{end}}
+{{- if .Enum}} <span class="detail">· one of {{range $i, $v := .Enum}}{{if $i}}, {{end}}<code>{{$v}}</code>{{end}}</span>{{end}}
+{{- range .Constraints}} <span class="detail">· {{.}}</span>{{end}}
+{{- if .Examples}} <span class="detail">· e.g. {{range $i, $v := .Examples}}{{if $i}}, {{end}}<code>{{$v}}</code>{{end}}</span>{{end}}
+{{- if .Description}}<div>{{.Description}}</div>{{end}}
+{{- if .Children}}
+{{template "properties" .Children}}{{end}}</li>
+{{end}}</ul>
+{{end}}
+{{define "page"}}{{template "head" .Title}}{{if .Indexed}}<p><a href="index.html">All schemas</a></p>{{end}}
+<h1>{{.Title}}</h1>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<table>
+<tr><th>Subject</th><td>{{.Name}}</td></tr>
+<tr><th>Type</th><td>{{typeName .Type}}</td></tr>
+{{if .Version}}<tr><th>Version</th><td>{{.Version}}</td></tr>{{end}}
+{{if .Source}}<tr><th>Source</th><td>{{.Source}}</td></tr>{{end}}
+</table>
+{{if .Raw}}<h2>Schema</h2>
+<pre>{{.Raw}}</pre>
+{{else}}<h2>Properties</h2>
+{{if .Properties}}{{template "properties" .Properties}}{{else}}<p>No properties.</p>{{end}}
+{{if .Examples}}<h2>Examples</h2>
+{{range .Examples}}<pre>{{.}}</pre>
+{{end}}{{end}}{{end}}</body>
+</html>
+{{end}}
+{{define "index"}}{{template "head" "Schemas"}}<h1>Schemas</h1>
+<table>
+<tr><th>Subject</th><th>Type</th><th>Version</th><th>Description</th></tr>
+{{range .}}<tr><td><a href="{{.File}}">{{.Name}}</a></td><td>{{typeName .Type}}</td><td>{{.Version}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+{{end}}`))
+
+func renderHTML(name string, data interface{}) string {
+	var b bytes.Buffer
+	if err := docTemplates.ExecuteTemplate(&b, name, data); err != nil {
+		return fmt.Sprintf("<!-- error rendering %s: %v -->\n", name, err)
+	}
+	return b.String()
+}
+
+func printDocsHelp() error {
+	help := `Usage: schema docs [options] [FILE...]
+
+Render schemas as Markdown or as self-contained HTML pages: a property tree
+with types, required flags, formats, enums, bounds, examples and
+descriptions. JSON Schema and Avro schemas are broken down into properties;
+other schemas are shown as they are.
+
+With one FILE the page is written to --output or stdout. With several files
+(globs are expanded) or none, a page per schema and an index page are written
+to the --output directory. Without files every subject under the registry
+path is documented: the latest version in the schema history and the latest
+version of each subject in a local registry ('schema registry serve').
+
+Options:
+  --format, -f FORMAT   markdown or html (default: markdown)
+  --output, -o PATH     Output file for one schema, directory otherwise
+                        (default: stdout, or schema-docs/)
+  --registry, -r PATH   Registry path (default: .schema-registry)
+  --type, -t TYPE       Schema type of the files: avro, json (default: from
+                        the files)
+
+Examples:
+  schema docs orders.schema.json
+  schema docs --format html -o orders.html orders.avsc
+  schema docs --format html -o docs/ 'schemas/*.json'
+  schema docs --format html -o wiki/schemas`
+
+	fmt.Println(help)
+	return nil
+}
@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAvroDocsTreatDefaultsAndNullUnionsAsOptional(t *testing.T) {
+	page := newDocPage("user", "user.avsc", "", `{"type":"record","name":"User","fields":[
+		{"name":"id","type":"long"},
+		{"name":"country","type":"string","default":"NZ"},
+		{"name":"nick","type":["null","string"]},
+		{"name":"email","type":["null","string"],"default":null}
+	]}`, "AVRO")
+
+	required := make(map[string]bool)
+	defaults := make(map[string]string)
+	for _, p := range page.Properties {
+		required[p.Name] = p.Required
+		defaults[p.Name] = p.Default
+	}
+	want := map[string]bool{"id": true, "country": false, "nick": false, "email": false}
+	for name, req := range want {
+		if required[name] != req {
+			t.Errorf("%s: expected required %v, got %v", name, req, required[name])
+		}
+	}
+	if defaults["country"] != `"NZ"` || defaults["email"] != "null" || defaults["nick"] != "" {
+		t.Errorf("unexpected defaults %v", defaults)
+	}
+
+	markdown := renderDocPage(page, "markdown")
+	for _, line := range []string{"**`id`** `long` _required_", "**`country`** `string` · default `\"NZ\"`", "**`email`** `string or null` · default `null`"} {
+		if !strings.Contains(markdown, line) {
+			t.Errorf("expected the page to contain %q:\n%s", line, markdown)
+		}
+	}
+	if html := renderDocPage(page, "html"); !strings.Contains(html, "default <code>&#34;NZ&#34;</code>") {
+		t.Errorf("expected the HTML page to show the default:\n%s", html)
+	}
+}
@@ -0,0 +1,286 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Finding kinds reported by Compare
+const (
+	FindingRequiredAdded       = "required-added"
+	FindingRequiredRemoved     = "required-removed"
+	FindingPropertyAdded       = "property-added"
+	FindingPropertyRemoved     = "property-removed"
+	FindingTypeNarrowed        = "type-narrowed"
+	FindingTypeWidened         = "type-widened"
+	FindingItemsChanged        = "items-changed"
+	FindingEnumShrunk          = "enum-shrunk"
+	FindingEnumExpanded        = "enum-expanded"
+	FindingConstraintTightened = "constraint-tightened"
+	FindingConstraintLoosened  = "constraint-loosened"
+)
+
+// Finding is one difference between two versions of a schema
+type Finding struct {
+	Path    string `json:"path"` // JSON path in the schema's data, e.g. $.items[*].price
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// BreaksBackward means the new schema rejects data valid under the old one
+	BreaksBackward bool `json:"breaksBackward"`
+	// BreaksForward means consumers of the old schema can't read data valid under the new one
+	BreaksForward bool `json:"breaksForward"`
+}
+
+// Breaks reports whether the finding is incompatible at a compatibility level
+// (BACKWARD, FORWARD or FULL, optionally _TRANSITIVE)
+func (f Finding) Breaks(level string) bool {
+	level = strings.ToUpper(level)
+	switch {
+	case strings.HasPrefix(level, "BACKWARD"):
+		return f.BreaksBackward
+	case strings.HasPrefix(level, "FORWARD"):
+		return f.BreaksForward
+	case strings.HasPrefix(level, "FULL"):
+		return f.BreaksBackward || f.BreaksForward
+	}
+	return false
+}
+
+// Compare lists the differences between an old and a new version of a schema,
+// sorted by path
+func Compare(oldSchema, newSchema *Schema) []Finding {
+	var findings []Finding
+	compareSchema(oldSchema, newSchema, "$", false, &findings)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Path < findings[j].Path
+	})
+	return findings
+}
+
+func compareSchema(oldSchema, newSchema *Schema, path string, items bool, findings *[]Finding) {
+	add := func(kind string, backward, forward bool, format string, args ...interface{}) {
+		addFinding(findings, path, kind, backward, forward, format, args...)
+	}
+
+	if oldSchema.Type != newSchema.Type {
+		kind, backward, forward := classifyTypeChange(oldSchema.Type, newSchema.Type)
+		if items {
+			add(FindingItemsChanged, backward, forward, "item type changed from %s to %s", typeName(oldSchema.Type), typeName(newSchema.Type))
+		} else {
+			add(kind, backward, forward, "type changed from %s to %s", typeName(oldSchema.Type), typeName(newSchema.Type))
+		}
+		// Structure below a changed type can't be compared meaningfully
+		if oldSchema.Type != "" && newSchema.Type != "" {
+			return
+		}
+	}
+
+	if oldSchema.Nullable != newSchema.Nullable {
+		widened, narrowed := FindingTypeWidened, FindingTypeNarrowed
+		if items {
+			widened, narrowed = FindingItemsChanged, FindingItemsChanged
+		}
+		if newSchema.Nullable {
+			add(widened, false, true, "null is now allowed")
+		} else {
+			add(narrowed, true, false, "null is no longer allowed")
+		}
+	}
+
+	compareEnum(oldSchema.Enum, newSchema.Enum, add)
+	compareConstraints(oldSchema, newSchema, add)
+
+	oldRequired := stringSet(oldSchema.Required)
+	newRequired := stringSet(newSchema.Required)
+	for _, name := range propertyNames(oldSchema, newSchema) {
+		propPath := path + "." + name
+		oldProp, inOld := oldSchema.Properties[name]
+		newProp, inNew := newSchema.Properties[name]
+
+		removed := inOld && !inNew
+		switch {
+		case removed && oldRequired[name]:
+			addFinding(findings, propPath, FindingPropertyRemoved, false, true, "required property removed")
+			continue
+		case removed:
+			// Consumers of the old schema may still read the property
+			addFinding(findings, propPath, FindingPropertyRemoved, false, true, "property removed")
+		case !inOld && inNew:
+			addFinding(findings, propPath, FindingPropertyAdded, false, false, "property added")
+		case inOld && inNew:
+			compareSchema(oldProp, newProp, propPath, false, findings)
+		}
+
+		switch {
+		case newRequired[name] && !oldRequired[name]:
+			// Data written with the old schema may not have it
+			addFinding(findings, propPath, FindingRequiredAdded, true, false, "property is now required")
+		case oldRequired[name] && !newRequired[name]:
+			addFinding(findings, propPath, FindingRequiredRemoved, false, true, "property is no longer required")
+		}
+	}
+
+	switch {
+	case oldSchema.Items != nil && newSchema.Items != nil:
+		compareSchema(oldSchema.Items, newSchema.Items, path+"[*]", true, findings)
+	case oldSchema.Items != nil:
+		add(FindingItemsChanged, false, true, "item schema removed")
+	case newSchema.Items != nil && newSchema.Items.Type != "":
+		add(FindingItemsChanged, true, false, "items are now restricted to %s", newSchema.Items.Type)
+	}
+}
+
+func addFinding(findings *[]Finding, path, kind string, backward, forward bool, format string, args ...interface{}) {
+	*findings = append(*findings, Finding{
+		Path:           path,
+		Kind:           kind,
+		Message:        fmt.Sprintf(format, args...),
+		BreaksBackward: backward,
+		BreaksForward:  forward,
+	})
+}
+
+// classifyTypeChange names a type change using the same promotion order as
+// schema generation: a change to the type getCompatibleType would pick is a
+// widening. Only integer to number keeps old data valid; other changes also
+// break in the other direction.
+func classifyTypeChange(oldType, newType SchemaType) (kind string, backward, forward bool) {
+	switch {
+	case oldType == "":
+		return FindingTypeNarrowed, true, false
+	case newType == "":
+		return FindingTypeWidened, false, true
+	case oldType == TypeInteger && newType == TypeNumber:
+		return FindingTypeWidened, false, true
+	case oldType == TypeNumber && newType == TypeInteger:
+		return FindingTypeNarrowed, true, false
+	case getCompatibleType(oldType, newType) == newType:
+		return FindingTypeWidened, true, true
+	default:
+		return FindingTypeNarrowed, true, true
+	}
+}
+
+func compareEnum(oldEnum, newEnum []interface{}, add func(string, bool, bool, string, ...interface{})) {
+	switch {
+	case len(oldEnum) == 0 && len(newEnum) == 0:
+		return
+	case len(oldEnum) == 0:
+		add(FindingEnumShrunk, true, false, "values are now restricted to %s", enumList(newEnum))
+		return
+	case len(newEnum) == 0:
+		add(FindingEnumExpanded, false, true, "values are no longer restricted")
+		return
+	}
+
+	if removed := enumDifference(oldEnum, newEnum); len(removed) > 0 {
+		add(FindingEnumShrunk, true, false, "enum values removed: %s", enumList(removed))
+	}
+	if added := enumDifference(newEnum, oldEnum); len(added) > 0 {
+		add(FindingEnumExpanded, false, true, "enum values added: %s", enumList(added))
+	}
+}
+
+// compareConstraints reports changed formats and bounds; a tighter constraint
+// rejects old data, a looser one lets through data old consumers didn't expect
+func compareConstraints(oldSchema, newSchema *Schema, add func(string, bool, bool, string, ...interface{})) {
+	switch {
+	case oldSchema.Format == newSchema.Format:
+	case oldSchema.Format == "":
+		add(FindingConstraintTightened, true, false, "format %s added", newSchema.Format)
+	case newSchema.Format == "":
+		add(FindingConstraintLoosened, false, true, "format %s removed", oldSchema.Format)
+	default:
+		add(FindingConstraintTightened, true, true, "format changed from %s to %s", oldSchema.Format, newSchema.Format)
+	}
+
+	compareBound("minimum", oldSchema.Minimum, newSchema.Minimum, true, add)
+	compareBound("maximum", oldSchema.Maximum, newSchema.Maximum, false, add)
+	compareBound("minLength", intBound(oldSchema.MinLength), intBound(newSchema.MinLength), true, add)
+	compareBound("maxLength", intBound(oldSchema.MaxLength), intBound(newSchema.MaxLength), false, add)
+	compareBound("minItems", intBound(oldSchema.MinItems), intBound(newSchema.MinItems), true, add)
+	compareBound("maxItems", intBound(oldSchema.MaxItems), intBound(newSchema.MaxItems), false, add)
+}
+
+// compareBound compares a lower or upper bound; nil means unbounded
+func compareBound(keyword string, oldBound, newBound *float64, lower bool, add func(string, bool, bool, string, ...interface{})) {
+	switch {
+	case oldBound == nil && newBound == nil:
+	case oldBound == nil:
+		add(FindingConstraintTightened, true, false, "%s %v added", keyword, *newBound)
+	case newBound == nil:
+		add(FindingConstraintLoosened, false, true, "%s %v removed", keyword, *oldBound)
+	case *oldBound == *newBound:
+	case (*newBound > *oldBound) == lower:
+		add(FindingConstraintTightened, true, false, "%s changed from %v to %v", keyword, *oldBound, *newBound)
+	default:
+		add(FindingConstraintLoosened, false, true, "%s changed from %v to %v", keyword, *oldBound, *newBound)
+	}
+}
+
+func intBound(value *int) *float64 {
+	if value == nil {
+		return nil
+	}
+	bound := float64(*value)
+	return &bound
+}
+
+// enumDifference returns the values of a that are not in b
+func enumDifference(a, b []interface{}) []interface{} {
+	var diff []interface{}
+	for _, value := range a {
+		if !enumContains(b, value) {
+			diff = append(diff, value)
+		}
+	}
+	return diff
+}
+
+func enumList(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		encoded, _ := json.Marshal(value)
+		parts[i] = string(encoded)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func typeName(t SchemaType) string {
+	if t == "" {
+		return "any"
+	}
+	return string(t)
+}
+
+func stringSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// propertyNames returns the sorted names of properties defined or required by either schema
+func propertyNames(a, b *Schema) []string {
+	seen := make(map[string]bool)
+	var names []string
+	addName := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, schema := range []*Schema{a, b} {
+		for name := range schema.Properties {
+			addName(name)
+		}
+		for _, name := range schema.Required {
+			addName(name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,454 @@
+package schema
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// historyDir is the directory in the registry path that holds the schema history
+const historyDir = "history"
+
+// historyFile lists the versions of one subject
+const historyFile = "history.json"
+
+// historyEntry is one stored version of a subject
+type historyEntry struct {
+	Version    int       `json:"version"`
+	Created    time.Time `json:"created"`
+	Source     string    `json:"source"`          // generate, merge or checkout vN
+	Label      string    `json:"label,omitempty"` // the --version given
+	Samples    int       `json:"samples"`         // records or schema files read
+	SampleHash string    `json:"sampleHash"`      // sha256 of the input samples
+	File       string    `json:"file"`
+}
+
+// history is the list of versions of a subject, oldest first; each version's
+// schema is a file next to the list
+type history struct {
+	dir      string
+	Subject  string          `json:"subject"`
+	Versions []*historyEntry `json:"versions"`
+}
+
+// historyOptions holds the command line settings for "schema history",
+// "schema diff" and "schema checkout"
+type historyOptions struct {
+	RegistryPath string
+	Format       string // text, json
+	OutputFile   string // checkout
+	NoRecord     bool   // checkout: don't record a new version
+	Args         []string
+}
+
+// openHistory reads the history of a subject; a subject without one has no versions yet
+func openHistory(registryPath, subject string) (*history, error) {
+	if subject == "" || subject == "." || subject == ".." {
+		return nil, fmt.Errorf("invalid subject %q", subject)
+	}
+	h := &history{dir: filepath.Join(registryPath, historyDir, url.PathEscape(subject)), Subject: subject}
+	data, err := os.ReadFile(filepath.Join(h.dir, historyFile))
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history of %s: %w", subject, err)
+	}
+	if err := json.Unmarshal(data, h); err != nil {
+		return nil, fmt.Errorf("error parsing history of %s: %w", subject, err)
+	}
+	return h, nil
+}
+
+// historySubjects lists the subjects with a history
+func historySubjects(registryPath string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(registryPath, historyDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema history: %w", err)
+	}
+	var subjects []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if subject, err := url.PathUnescape(entry.Name()); err == nil {
+			subjects = append(subjects, subject)
+		}
+	}
+	sort.Strings(subjects)
+	return subjects, nil
+}
+
+// add stores a schema as the next version; the schema's Version becomes the
+// label, or the version number when there is none
+func (h *history) add(schema *Schema, entry historyEntry) (*historyEntry, error) {
+	entry.Version = 1
+	if len(h.Versions) > 0 {
+		entry.Version = h.Versions[len(h.Versions)-1].Version + 1
+	}
+	entry.Created = time.Now().UTC()
+	entry.File = fmt.Sprintf("v%d.json", entry.Version)
+	schema.Version = entry.Label
+	if schema.Version == "" {
+		schema.Version = strconv.Itoa(entry.Version)
+	}
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling schema: %w", err)
+	}
+	if err := os.MkdirAll(h.dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating history directory: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(h.dir, entry.File), data); err != nil {
+		return nil, err
+	}
+
+	h.Versions = append(h.Versions, &entry)
+	data, err = json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling history: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(h.dir, historyFile), data); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// find looks up a version by number (3 or v3) or "latest"
+func (h *history) find(ref string) (*historyEntry, error) {
+	if len(h.Versions) == 0 {
+		return nil, fmt.Errorf("subject %s has no schema history", h.Subject)
+	}
+	if ref == "latest" {
+		return h.Versions[len(h.Versions)-1], nil
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(ref), "v"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version %q: use a number, vN or latest", ref)
+	}
+	for _, entry := range h.Versions {
+		if entry.Version == version {
+			return entry, nil
+		}
+	}
+	return nil, fmt.Errorf("subject %s has no version %d", h.Subject, version)
+}
+
+// load reads the schema of a version
+func (h *history) load(entry *historyEntry) (*Schema, error) {
+	data, err := os.ReadFile(filepath.Join(h.dir, entry.File))
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s v%d: %w", h.Subject, entry.Version, err)
+	}
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("error parsing %s v%d: %w", h.Subject, entry.Version, err)
+	}
+	return &schema, nil
+}
+
+// writeFileAtomic writes through a temporary file so a crash never leaves it half written
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// historySubject is the subject a generated or merged schema is stored under:
+// --subject, or the output, input or schema file name without its extension
+func historySubject(config Config) string {
+	if config.Subject != "" {
+		return config.Subject
+	}
+	for _, path := range []string{config.OutputFile, config.InputFile, config.SchemaFile} {
+		if path == "" || path == "-" {
+			continue
+		}
+		name := filepath.Base(path)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+		name = strings.TrimSuffix(name, ".schema")
+		if name != "" {
+			return name
+		}
+	}
+	return "default"
+}
+
+// recordVersion stores a generated or merged schema in the history of its
+// subject, unless --no-history was given
+func recordVersion(schema *Schema, config Config, source string, samples int, sampleHash hash.Hash) error {
+	if !config.History {
+		schema.Version = config.Version
+		if schema.Version == "" {
+			schema.Version = "1.0.0"
+		}
+		return nil
+	}
+	h, err := openHistory(config.RegistryPath, historySubject(config))
+	if err != nil {
+		return err
+	}
+	entry, err := h.add(schema, historyEntry{
+		Source:     source,
+		Label:      config.Version,
+		Samples:    samples,
+		SampleHash: hex.EncodeToString(sampleHash.Sum(nil)),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Stored %s v%d in %s\n", h.Subject, entry.Version, h.dir)
+	return nil
+}
+
+// parseHistoryArgs parses the options shared by history, diff and checkout;
+// it returns no options after printing the help
+func parseHistoryArgs(args []string) (*historyOptions, error) {
+	opts := &historyOptions{RegistryPath: DefaultConfig().RegistryPath, Format: "text"}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--registry", "-r":
+			if i+1 < len(args) {
+				opts.RegistryPath = args[i+1]
+				i++
+			}
+		case "--format", "-f":
+			if i+1 < len(args) {
+				opts.Format = args[i+1]
+				i++
+			}
+		case "--output", "-o":
+			if i+1 < len(args) {
+				opts.OutputFile = args[i+1]
+				i++
+			}
+		case "--no-record":
+			opts.NoRecord = true
+		case "-h", "--help", "help":
+			return nil, printHistoryHelp()
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return nil, fmt.Errorf("unknown option: %s", arg)
+			}
+			opts.Args = append(opts.Args, arg)
+		}
+	}
+	if opts.Format != "text" && opts.Format != "json" {
+		return nil, fmt.Errorf("invalid format %q: use text or json", opts.Format)
+	}
+	return opts, nil
+}
+
+// runHistory is the entry point for "schema history"
+func runHistory(args []string) error {
+	opts, err := parseHistoryArgs(args)
+	if opts == nil || err != nil {
+		return err
+	}
+	if len(opts.Args) > 1 {
+		return fmt.Errorf("usage: schema history [SUBJECT]")
+	}
+
+	if len(opts.Args) == 0 {
+		subjects, err := historySubjects(opts.RegistryPath)
+		if err != nil {
+			return err
+		}
+		if opts.Format == "json" {
+			if subjects == nil {
+				subjects = []string{}
+			}
+			return printRegistryJSON(subjects)
+		}
+		for _, subject := range subjects {
+			fmt.Println(subject)
+		}
+		return nil
+	}
+
+	h, err := openHistory(opts.RegistryPath, opts.Args[0])
+	if err != nil {
+		return err
+	}
+	if len(h.Versions) == 0 {
+		return fmt.Errorf("subject %s has no schema history", h.Subject)
+	}
+	if opts.Format == "json" {
+		return printRegistryJSON(h)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tCREATED\tSOURCE\tSAMPLES\tSAMPLE HASH\tLABEL")
+	for _, entry := range h.Versions {
+		fmt.Fprintf(w, "v%d\t%s\t%s\t%d\t%.12s\t%s\n", entry.Version, entry.Created.Format(time.RFC3339),
+			entry.Source, entry.Samples, entry.SampleHash, entry.Label)
+	}
+	return w.Flush()
+}
+
+// historyDiff is the json output of "schema diff"
+type historyDiff struct {
+	Subject  string    `json:"subject"`
+	From     int       `json:"from"`
+	To       int       `json:"to"`
+	Findings []Finding `json:"findings"`
+}
+
+// runDiff is the entry point for "schema diff"
+func runDiff(args []string) error {
+	opts, err := parseHistoryArgs(args)
+	if opts == nil || err != nil {
+		return err
+	}
+	if len(opts.Args) < 2 || len(opts.Args) > 3 {
+		return fmt.Errorf("usage: schema diff SUBJECT FROM [TO]")
+	}
+	h, err := openHistory(opts.RegistryPath, opts.Args[0])
+	if err != nil {
+		return err
+	}
+	toRef := "latest"
+	if len(opts.Args) == 3 {
+		toRef = opts.Args[2]
+	}
+	from, err := h.find(opts.Args[1])
+	if err != nil {
+		return err
+	}
+	to, err := h.find(toRef)
+	if err != nil {
+		return err
+	}
+	oldSchema, err := h.load(from)
+	if err != nil {
+		return err
+	}
+	newSchema, err := h.load(to)
+	if err != nil {
+		return err
+	}
+
+	diff := historyDiff{Subject: h.Subject, From: from.Version, To: to.Version, Findings: Compare(oldSchema, newSchema)}
+	if opts.Format == "json" {
+		if diff.Findings == nil {
+			diff.Findings = []Finding{}
+		}
+		return printRegistryJSON(diff)
+	}
+	if len(diff.Findings) == 0 {
+		fmt.Printf("%s v%d and v%d have the same structure\n", h.Subject, from.Version, to.Version)
+		return nil
+	}
+	fmt.Printf("%s v%d -> v%d:\n", h.Subject, from.Version, to.Version)
+	for _, finding := range diff.Findings {
+		fmt.Printf("  - %s: %s (%s%s)\n", finding.Path, finding.Message, finding.Kind, breaksLabel(finding))
+	}
+	return nil
+}
+
+// breaksLabel names the compatibility levels a finding breaks
+func breaksLabel(finding Finding) string {
+	switch {
+	case finding.BreaksBackward && finding.BreaksForward:
+		return ", breaks FULL"
+	case finding.BreaksBackward:
+		return ", breaks BACKWARD"
+	case finding.BreaksForward:
+		return ", breaks FORWARD"
+	}
+	return ""
+}
+
+// runCheckout is the entry point for "schema checkout"; the version is
+// written out and recorded again as the latest, so rolling back keeps the
+// history linear
+func runCheckout(args []string) error {
+	opts, err := parseHistoryArgs(args)
+	if opts == nil || err != nil {
+		return err
+	}
+	if len(opts.Args) != 2 {
+		return fmt.Errorf("usage: schema checkout SUBJECT VERSION")
+	}
+	h, err := openHistory(opts.RegistryPath, opts.Args[0])
+	if err != nil {
+		return err
+	}
+	entry, err := h.find(opts.Args[1])
+	if err != nil {
+		return err
+	}
+	schema, err := h.load(entry)
+	if err != nil {
+		return err
+	}
+
+	latest := h.Versions[len(h.Versions)-1]
+	if !opts.NoRecord && entry != latest {
+		recorded, err := h.add(schema, historyEntry{
+			Source:     fmt.Sprintf("checkout v%d", entry.Version),
+			Label:      entry.Label,
+			Samples:    entry.Samples,
+			SampleHash: entry.SampleHash,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Restored %s v%d as v%d\n", h.Subject, entry.Version, recorded.Version)
+	}
+	return outputSchema(schema, Config{Pretty: true, OutputFile: opts.OutputFile})
+}
+
+func printHistoryHelp() error {
+	help := `Usage: schema history [SUBJECT] [options]
+       schema diff SUBJECT FROM [TO] [options]
+       schema checkout SUBJECT VERSION [options]
+
+Every 'schema generate --evolve' and 'schema merge' result is stored as a new
+version of its subject under the registry path, with the time, the number of
+input samples and their sha256 hash. The subject is --subject, or else the
+output, input or schema file name without its extension.
+
+Commands:
+  history [SUBJECT]          List the subjects, or the versions of one
+  diff SUBJECT FROM [TO]     Structural diff between two versions (TO
+                             defaults to latest), with the compatibility
+                             levels each change breaks
+  checkout SUBJECT VERSION   Write a version out and record it again as the
+                             latest version (a rollback)
+
+Versions are numbers (3 or v3) or latest.
+
+Options:
+  --registry, -r PATH   Registry path (default: .schema-registry)
+  --format, -f FORMAT   history, diff: text or json (default: text)
+  --output, -o FILE     checkout: output file (default: stdout)
+  --no-record           checkout: don't record the version again
+
+Examples:
+  schema generate --evolve --subject orders -i orders.json -o orders.schema.json
+  schema history orders
+  schema diff orders v3 v5
+  schema checkout orders v3 -o orders.schema.json`
+
+	fmt.Println(help)
+	return nil
+}
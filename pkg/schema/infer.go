@@ -0,0 +1,306 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Constraint kinds inferred by schema generate
+const (
+	ConstraintFormats  = "formats"
+	ConstraintEnums    = "enums"
+	ConstraintRanges   = "ranges"
+	ConstraintLengths  = "lengths"
+	ConstraintNullable = "nullable"
+)
+
+var allConstraints = []string{ConstraintFormats, ConstraintEnums, ConstraintRanges, ConstraintLengths, ConstraintNullable}
+
+// inferFormats are the string formats detected, most specific first
+var inferFormats = []string{"uuid", "date-time", "date", "email", "ipv4", "uri"}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// InferOptions controls which constraints schema generation infers from the data
+type InferOptions struct {
+	Constraints map[string]bool
+	// EnumThreshold is the most distinct values a string field can have to become an enum
+	EnumThreshold int
+}
+
+// DefaultInferOptions infers every kind of constraint
+func DefaultInferOptions() InferOptions {
+	options := InferOptions{Constraints: make(map[string]bool), EnumThreshold: 10}
+	for _, kind := range allConstraints {
+		options.Constraints[kind] = true
+	}
+	return options
+}
+
+// parseConstraints reads a comma-separated list of constraint kinds; "all"
+// and "none" select every kind or none
+func parseConstraints(list string) (map[string]bool, error) {
+	constraints := make(map[string]bool)
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		switch kind {
+		case "", "none":
+		case "all":
+			for _, kind := range allConstraints {
+				constraints[kind] = true
+			}
+		case "format", "enum", "range", "length":
+			constraints[kind+"s"] = true
+		case ConstraintFormats, ConstraintEnums, ConstraintRanges, ConstraintLengths, ConstraintNullable:
+			constraints[kind] = true
+		default:
+			return nil, fmt.Errorf("unknown constraint kind %q (expected %s, all or none)", kind, strings.Join(allConstraints, ", "))
+		}
+	}
+	return constraints, nil
+}
+
+// fieldStats accumulates what the samples of one field looked like
+type fieldStats struct {
+	samples   int
+	nulls     int
+	strings   int
+	numbers   int
+	fractions int // numbers that are not integers
+	arrays    int
+
+	formats map[string]int // strings matching each format
+	values  map[string]int // distinct strings, until there are too many
+	tooMany bool
+
+	min, max             float64
+	minLength, maxLength int
+	minItems, maxItems   int
+
+	properties map[string]*fieldStats
+	items      *fieldStats
+}
+
+func newFieldStats() *fieldStats {
+	return &fieldStats{formats: make(map[string]int), values: make(map[string]int)}
+}
+
+// observe records one sample of the field
+func (f *fieldStats) observe(value interface{}, options InferOptions) {
+	f.samples++
+	switch v := value.(type) {
+	case nil:
+		f.nulls++
+	case string:
+		length := len([]rune(v))
+		if f.strings == 0 || length < f.minLength {
+			f.minLength = length
+		}
+		if f.strings == 0 || length > f.maxLength {
+			f.maxLength = length
+		}
+		f.strings++
+		for _, format := range inferFormats {
+			if matchesFormat(format, v) {
+				f.formats[format]++
+			}
+		}
+		if !f.tooMany {
+			f.values[v]++
+			if len(f.values) > options.EnumThreshold {
+				f.values, f.tooMany = nil, true
+			}
+		}
+	case float64:
+		if f.numbers == 0 || v < f.min {
+			f.min = v
+		}
+		if f.numbers == 0 || v > f.max {
+			f.max = v
+		}
+		if v != math.Trunc(v) {
+			f.fractions++
+		}
+		f.numbers++
+	case []interface{}:
+		if f.arrays == 0 || len(v) < f.minItems {
+			f.minItems = len(v)
+		}
+		if f.arrays == 0 || len(v) > f.maxItems {
+			f.maxItems = len(v)
+		}
+		f.arrays++
+		if f.items == nil {
+			f.items = newFieldStats()
+		}
+		for _, item := range v {
+			f.items.observe(item, options)
+		}
+	case map[string]interface{}:
+		if f.properties == nil {
+			f.properties = make(map[string]*fieldStats)
+		}
+		for key, item := range v {
+			if f.properties[key] == nil {
+				f.properties[key] = newFieldStats()
+			}
+			f.properties[key].observe(item, options)
+		}
+	}
+}
+
+// apply adds the constraints the samples support to a generated schema and
+// records their sample counts and confidence in its metadata. Confidence
+// estimates how likely a new sample is to satisfy the constraint.
+func (f *fieldStats) apply(schema *Schema, options InferOptions) {
+	for key, prop := range schema.Properties {
+		if stats := f.properties[key]; stats != nil {
+			stats.apply(prop, options)
+		}
+	}
+	if schema.Items != nil && f.items != nil {
+		f.items.apply(schema.Items, options)
+	}
+
+	// The type comes from the first record, so a later fraction widens it
+	if schema.Type == TypeInteger && f.fractions > 0 {
+		schema.Type = TypeNumber
+	}
+
+	inferred := make(map[string]interface{})
+	nonNull := f.samples - f.nulls
+	if options.Constraints[ConstraintNullable] && f.nulls > 0 && nonNull > 0 && schema.Type != TypeNull && schema.Type != "" {
+		schema.Nullable = true
+		inferred["nullable"] = map[string]interface{}{"samples": f.samples, "nulls": f.nulls, "confidence": 1.0}
+	}
+
+	switch {
+	case schema.Type == TypeString && f.strings > 0 && f.strings == nonNull:
+		if options.Constraints[ConstraintFormats] {
+			for _, format := range inferFormats {
+				if f.formats[format] == f.strings {
+					schema.Format = format
+					inferred["format"] = map[string]interface{}{"samples": f.strings, "confidence": successConfidence(f.strings)}
+					break
+				}
+			}
+		}
+		// An enum needs few distinct values and, on average, each seen twice;
+		// a field whose values are all different (names, ids) is not one
+		if options.Constraints[ConstraintEnums] && schema.Format == "" && !f.tooMany && f.strings >= 2*len(f.values) {
+			schema.Enum = enumValues(f.values, schema.Nullable)
+			inferred["enum"] = map[string]interface{}{"samples": f.strings, "distinct": len(f.values), "confidence": enumConfidence(f.values, f.strings)}
+		}
+		if options.Constraints[ConstraintLengths] && schema.Format == "" && len(schema.Enum) == 0 {
+			schema.MinLength, schema.MaxLength = intPtr(f.minLength), intPtr(f.maxLength)
+			inferred["length"] = map[string]interface{}{"samples": f.strings, "confidence": rangeConfidence(f.strings)}
+		}
+	case (schema.Type == TypeInteger || schema.Type == TypeNumber) && f.numbers > 0 && f.numbers == nonNull:
+		if options.Constraints[ConstraintRanges] {
+			schema.Minimum, schema.Maximum = floatPtr(f.min), floatPtr(f.max)
+			inferred["range"] = map[string]interface{}{"samples": f.numbers, "confidence": rangeConfidence(f.numbers)}
+		}
+	case schema.Type == TypeArray && f.arrays > 0 && f.arrays == nonNull:
+		if options.Constraints[ConstraintLengths] {
+			schema.MinItems, schema.MaxItems = intPtr(f.minItems), intPtr(f.maxItems)
+			inferred["length"] = map[string]interface{}{"samples": f.arrays, "confidence": rangeConfidence(f.arrays)}
+		}
+	}
+
+	if len(inferred) > 0 {
+		inferred["samples"] = f.samples
+		if schema.Metadata == nil {
+			schema.Metadata = make(map[string]interface{})
+		}
+		schema.Metadata["inferred"] = inferred
+	}
+}
+
+// matchesFormat reports whether a string is a value of a detected format
+func matchesFormat(format, value string) bool {
+	switch format {
+	case "uuid":
+		return uuidPattern.MatchString(value)
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "date":
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value && address.Name == ""
+	case "ipv4":
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "") && !strings.ContainsAny(value, " \t\n")
+	}
+	return false
+}
+
+// enumValues returns the distinct values, most frequent first
+func enumValues(counts map[string]int, nullable bool) []interface{} {
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+	enum := make([]interface{}, 0, len(values)+1)
+	for _, value := range values {
+		enum = append(enum, value)
+	}
+	if nullable {
+		enum = append(enum, nil)
+	}
+	return enum
+}
+
+// successConfidence is Laplace's rule of succession: the chance the next
+// sample matches after n samples all did
+func successConfidence(n int) float64 {
+	return roundConfidence(float64(n+1) / float64(n+2))
+}
+
+// rangeConfidence is the chance the next sample falls between the smallest
+// and largest of n samples
+func rangeConfidence(n int) float64 {
+	return roundConfidence(float64(n-1) / float64(n+1))
+}
+
+// enumConfidence is the Good-Turing estimate that the next sample is a value
+// already seen: one minus the share of samples whose value was seen once
+func enumConfidence(counts map[string]int, n int) float64 {
+	once := 0
+	for _, count := range counts {
+		if count == 1 {
+			once++
+		}
+	}
+	return roundConfidence(1 - float64(once)/float64(n))
+}
+
+func roundConfidence(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
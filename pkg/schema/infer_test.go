@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGeneratedSchemaValidatesItsSamples generates a schema from each input
+// and validates the same input against it
+func TestGeneratedSchemaValidatesItsSamples(t *testing.T) {
+	tests := []struct {
+		name   string
+		evolve bool
+		lines  []string
+		want   SchemaType // type of the n property
+	}{
+		{"integer then fraction", false, []string{`{"n":null}`, `{"n":3}`, `{"n":4.5}`}, TypeNumber},
+		{"integer then fraction, evolved", true, []string{`{"n":null}`, `{"n":3}`, `{"n":4.5}`}, TypeNumber},
+		{"fraction in an array", false, []string{`{"n":[1,2]}`, `{"n":[0.5]}`}, TypeArray},
+		{"integers only", false, []string{`{"n":1}`, `{"n":-7}`, `{"n":1e3}`}, TypeInteger},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			input := filepath.Join(dir, "data.json")
+			if err := os.WriteFile(input, []byte(strings.Join(tt.lines, "\n")+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			config := DefaultConfig()
+			config.InputFile = input
+			config.OutputFile = filepath.Join(dir, "schema.json")
+			config.Evolve = tt.evolve
+			config.RegistryPath = filepath.Join(dir, "registry")
+			config.History = false
+			if err := generateSchema(config); err != nil {
+				t.Fatal(err)
+			}
+
+			generated, err := Load(config.OutputFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := generated.Properties["n"].Type; got != tt.want {
+				t.Errorf("expected n to be %s, got %s", tt.want, got)
+			}
+
+			config.SchemaFile = config.OutputFile
+			if err := validateData(config); err != nil {
+				data, _ := os.ReadFile(config.OutputFile)
+				t.Fatalf("the schema rejects its own samples: %v\n%s", err, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package schema
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Merge strategies
+const (
+	MergeUnion        = "union"
+	MergeIntersection = "intersection"
+	MergeStrict       = "strict"
+)
+
+// Conflict kinds reported by schema merge
+const (
+	ConflictType     = "type"
+	ConflictBreaking = "breaking" // a type change that rejects data an input accepts
+	ConflictFormat   = "format"
+	ConflictRequired = "required"
+	ConflictPartial  = "partial"
+)
+
+// mergeConflict is a difference between an input schema and the merged
+// result so far
+type mergeConflict struct {
+	File    string
+	Path    string
+	Kind    string
+	Message string
+}
+
+// schemaMerger carries the strategy and the conflicts found while merging
+type schemaMerger struct {
+	strategy      string
+	forceOptional bool
+	conflicts     []mergeConflict
+	files         []string
+	present       map[string][]string // property path -> files that define it
+}
+
+func newSchemaMerger(strategy string, forceOptional bool) (*schemaMerger, error) {
+	strategy = strings.ToLower(strategy)
+	switch strategy {
+	case MergeUnion, MergeIntersection, MergeStrict:
+	default:
+		return nil, fmt.Errorf("invalid merge strategy %q: use union, intersection or strict", strategy)
+	}
+	return &schemaMerger{strategy: strategy, forceOptional: forceOptional, present: make(map[string][]string)}, nil
+}
+
+// expandSchemaFiles expands glob patterns; a pattern without matches is an error
+func expandSchemaFiles(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(pattern); err != nil {
+				return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no schema files match %s", pattern)
+			}
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+func (m *schemaMerger) conflict(file, path, kind, format string, args ...interface{}) {
+	m.conflicts = append(m.conflicts, mergeConflict{File: file, Path: path, Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
+// observe records the property paths an input defines, before merging
+// changes any of them
+func (m *schemaMerger) observe(file string, schema *Schema) {
+	m.files = append(m.files, file)
+	var walk func(s *Schema, path string)
+	walk = func(s *Schema, path string) {
+		for name, prop := range s.Properties {
+			propPath := path + "." + name
+			m.present[propPath] = append(m.present[propPath], file)
+			walk(prop, propPath)
+		}
+		if s.Items != nil {
+			walk(s.Items, path+"[*]")
+		}
+	}
+	walk(schema, "$")
+}
+
+// typeConflict reports two types merged into the more general one. The merge
+// breaks when that type rejects values of either input type, as string does
+// integers; an integer widening to number does not.
+func (m *schemaMerger) typeConflict(file, path string, earlier, here, merged SchemaType) {
+	var rejected []string
+	for _, t := range []SchemaType{earlier, here} {
+		if _, backward, _ := classifyTypeChange(t, merged); t != merged && backward {
+			rejected = append(rejected, typeName(t))
+		}
+	}
+	if len(rejected) == 0 {
+		m.conflict(file, path, ConflictType, "type %s conflicts with %s; using %s", typeName(here), typeName(earlier), typeName(merged))
+		return
+	}
+	m.conflict(file, path, ConflictBreaking, "type %s conflicts with %s; %s would reject %s values",
+		typeName(here), typeName(earlier), typeName(merged), strings.Join(rejected, " and "))
+}
+
+// requiredConflicts reports properties both schemas define that only one requires
+func (m *schemaMerger) requiredConflicts(target, source *Schema, path, file string) {
+	targetRequired := stringSet(target.Required)
+	sourceRequired := stringSet(source.Required)
+	for _, name := range propertyNames(target, source) {
+		if target.Properties[name] == nil || source.Properties[name] == nil || targetRequired[name] == sourceRequired[name] {
+			continue
+		}
+		if sourceRequired[name] {
+			m.conflict(file, path+"."+name, ConflictRequired, "required here but not in earlier inputs; no longer required")
+		} else {
+			m.conflict(file, path+"."+name, ConflictRequired, "required in earlier inputs but not here; no longer required")
+		}
+	}
+}
+
+// checkProperties reports properties that only some inputs define and, for
+// the intersection strategy, removes them from the merged schema
+func (m *schemaMerger) checkProperties(merged *Schema) {
+	var walk func(s *Schema, path string)
+	walk = func(s *Schema, path string) {
+		for _, name := range sortedPropertyNames(s) {
+			propPath := path + "." + name
+			files := m.present[propPath]
+			if len(files) < len(m.files) {
+				action := "kept as optional"
+				if m.strategy == MergeIntersection {
+					action = "dropped"
+					delete(s.Properties, name)
+					s.Required = removeString(s.Required, name)
+				}
+				m.conflict(strings.Join(files, ", "), propPath, ConflictPartial, "only in %d of %d inputs; %s", len(files), len(m.files), action)
+				if m.strategy == MergeIntersection {
+					continue
+				}
+			}
+			walk(s.Properties[name], propPath)
+		}
+		if s.Items != nil {
+			walk(s.Items, path+"[*]")
+		}
+	}
+	walk(merged, "$")
+}
+
+// report prints the conflicts to stderr. Breaking type conflicts fail every
+// strategy; the strict strategy also fails on the other type conflicts.
+func (m *schemaMerger) report() error {
+	typeConflicts, breaking := 0, 0
+	for _, c := range m.conflicts {
+		fmt.Fprintf(os.Stderr, "Conflict (%s): %s: %s: %s\n", c.Kind, c.File, c.Path, c.Message)
+		switch c.Kind {
+		case ConflictBreaking:
+			breaking++
+			typeConflicts++
+		case ConflictType, ConflictFormat:
+			typeConflicts++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Merged %d schemas with %d conflicts\n", len(m.files), len(m.conflicts))
+	if breaking > 0 {
+		return fmt.Errorf("merge failed with %d breaking type conflicts: no single type accepts the data of every input", breaking)
+	}
+	if m.strategy == MergeStrict && typeConflicts > 0 {
+		return fmt.Errorf("strict merge failed with %d type conflicts", typeConflicts)
+	}
+	return nil
+}
+
+// mergeConstraints loosens formats, enums and bounds so the merged schema
+// accepts what each input accepts
+func mergeConstraints(target, source *Schema, merger *schemaMerger, path, file string) {
+	if target.Format != source.Format {
+		if target.Format != "" && source.Format != "" {
+			merger.conflict(file, path, ConflictFormat, "format %s conflicts with %s; dropping the format", source.Format, target.Format)
+		}
+		target.Format = ""
+	}
+
+	if len(target.Enum) > 0 && len(source.Enum) > 0 {
+		target.Enum = append(target.Enum, enumDifference(source.Enum, target.Enum)...)
+	} else {
+		target.Enum = nil
+	}
+
+	target.Minimum = looserFloat(target.Minimum, source.Minimum, true)
+	target.Maximum = looserFloat(target.Maximum, source.Maximum, false)
+	target.MinLength = looserInt(target.MinLength, source.MinLength, true)
+	target.MaxLength = looserInt(target.MaxLength, source.MaxLength, false)
+	target.MinItems = looserInt(target.MinItems, source.MinItems, true)
+	target.MaxItems = looserInt(target.MaxItems, source.MaxItems, false)
+}
+
+// dropStaleConstraints removes what no longer applies after a schema's type
+// changed: constraints of other types, enum values and the inferred sample
+// statistics of the old type
+func dropStaleConstraints(s *Schema) {
+	if s.Type != TypeInteger && s.Type != TypeNumber {
+		s.Minimum, s.Maximum = nil, nil
+	}
+	if s.Type != TypeString {
+		s.MinLength, s.MaxLength = nil, nil
+		s.Format = ""
+	}
+	if s.Type != TypeArray {
+		s.MinItems, s.MaxItems = nil, nil
+	}
+	if s.Type != TypeNumber {
+		// Only integer enum values still fit after widening to number
+		s.Enum = nil
+	}
+	delete(s.Metadata, "inferred")
+}
+
+// looserFloat returns the looser of two lower or upper bounds; nil is unbounded
+func looserFloat(a, b *float64, lower bool) *float64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	if (*b < *a) == lower {
+		return b
+	}
+	return a
+}
+
+func looserInt(a, b *int, lower bool) *int {
+	if a == nil || b == nil {
+		return nil
+	}
+	if (*b < *a) == lower {
+		return b
+	}
+	return a
+}
+
+func sortedPropertyNames(s *Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func removeString(values []string, value string) []string {
+	var kept []string
+	for _, v := range values {
+		if v != value {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// mergeJSON merges schemas given as JSON with a strategy, returning the
+// merged schema and the merger with its conflicts
+func mergeJSON(t *testing.T, strategy string, schemas ...string) (*Schema, *schemaMerger) {
+	t.Helper()
+	merger, err := newSchemaMerger(strategy, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var merged *Schema
+	for i, data := range schemas {
+		var schema Schema
+		if err := json.Unmarshal([]byte(data), &schema); err != nil {
+			t.Fatal(err)
+		}
+		file := string(rune('a'+i)) + ".json"
+		merger.observe(file, &schema)
+		if merged == nil {
+			merged = &schema
+		} else if err := mergeTwoSchemas(merged, &schema, merger, "$", file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	merger.checkProperties(merged)
+	return merged, merger
+}
+
+func conflictKinds(merger *schemaMerger) map[string]string {
+	kinds := make(map[string]string)
+	for _, c := range merger.conflicts {
+		kinds[c.Path] = c.Kind
+	}
+	return kinds
+}
+
+func TestMergeIntegerAndStringIsBreaking(t *testing.T) {
+	for _, strategy := range []string{MergeUnion, MergeIntersection, MergeStrict} {
+		_, merger := mergeJSON(t, strategy,
+			`{"type":"object","properties":{"id":{"type":"integer","minimum":1,"enum":[1,2],"metadata":{"inferred":{"range":{"samples":2}}}}}}`,
+			`{"type":"object","properties":{"id":{"type":"string","minLength":3}}}`,
+		)
+		if kind := conflictKinds(merger)["$.id"]; kind != ConflictBreaking {
+			t.Errorf("%s: expected a breaking conflict at $.id, got %q", strategy, kind)
+		}
+		if err := merger.report(); err == nil || !strings.Contains(err.Error(), "breaking") {
+			t.Errorf("%s: expected the merge to fail, got %v", strategy, err)
+		}
+	}
+}
+
+func TestMergeWideningDropsStaleConstraints(t *testing.T) {
+	merged, merger := mergeJSON(t, MergeUnion,
+		`{"type":"object","properties":{"price":{"type":"integer","minimum":1,"maximum":10,"enum":[1,10],"metadata":{"inferred":{"range":{"samples":2}}}}}}`,
+		`{"type":"object","properties":{"price":{"type":"number","minimum":0.5,"metadata":{"inferred":{"range":{"samples":5}}}}}}`,
+	)
+	if err := merger.report(); err != nil {
+		t.Fatalf("expected integer to widen to number: %v", err)
+	}
+	price := merged.Properties["price"]
+	if price.Type != TypeNumber || price.Minimum == nil || *price.Minimum != 0.5 || price.Maximum != nil || price.Enum != nil {
+		t.Fatalf("expected a number with the looser minimum only, got %+v", price)
+	}
+	if _, ok := price.Metadata["inferred"]; ok {
+		t.Fatalf("expected the inferred statistics of the old type to be dropped, got %v", price.Metadata)
+	}
+}
+
+func TestMergeAlwaysNullMakesNullable(t *testing.T) {
+	merged, merger := mergeJSON(t, MergeStrict,
+		`{"type":"object","properties":{"note":{"type":"null"},"email":{"type":"string","format":"email"}}}`,
+		`{"type":"object","properties":{"note":{"type":"string","format":"email"},"email":{"type":"null"}}}`,
+	)
+	for _, name := range []string{"note", "email"} {
+		prop := merged.Properties[name]
+		if prop.Type != TypeString || !prop.Nullable || prop.Format != "email" {
+			t.Errorf("expected %s to be a nullable email string, got %+v", name, prop)
+		}
+		if kind := conflictKinds(merger)["$."+name]; kind != ConflictType {
+			t.Errorf("expected a type conflict at $.%s, got %q", name, kind)
+		}
+	}
+}
@@ -0,0 +1,851 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"github.com/og-dim9/dimutils/pkg/schemaregistry"
+)
+
+// registryOptions holds the command line settings for the schema registry subcommands
+type registryOptions struct {
+	Client      schemaregistry.Config
+	Output      string // table, json
+	Type        string // AVRO, JSON, PROTOBUF
+	Permanent   bool
+	SchemaOnly  bool
+	Version     string
+	Dir         string // serve: storage directory
+	Host        string
+	Port        int
+	Verbose     bool
+	BackupDir   string // export/import
+	PreserveIDs bool
+	DryRun      bool
+	From        string // migrate: source and target registry URLs or contexts
+	To          string
+	FromContext string
+	ToContext   string
+	References  []schemaregistry.Reference // register, test-compat: --reference NAME=SUBJECT:VERSION
+	Args        []string                   // positional arguments after the subcommand
+}
+
+// runRegistry is the entry point for "schema registry"
+func runRegistry(args []string) error {
+	if len(args) == 0 || args[0] == "help" {
+		return printRegistryHelp()
+	}
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			return printRegistryHelp()
+		}
+	}
+
+	opts := registryOptions{
+		Client:  schemaregistry.DefaultConfig(),
+		Output:  "table",
+		Version: "latest",
+		Dir:     DefaultConfig().RegistryPath,
+		Host:    "localhost",
+		Port:    8081,
+	}
+	if err := applyRegistryContext(args, &opts.Client); err != nil {
+		return err
+	}
+	if err := parseRegistryArgs(args, &opts); err != nil {
+		return err
+	}
+	if len(opts.Args) == 0 {
+		return printRegistryHelp()
+	}
+	if opts.Output != "table" && opts.Output != "json" {
+		return fmt.Errorf("invalid output format %q: use table or json", opts.Output)
+	}
+	if opts.Type != "" {
+		if err := schemaregistry.ValidateSchemaType(opts.Type); err != nil {
+			return err
+		}
+		opts.Type = strings.ToUpper(opts.Type)
+	}
+
+	// Global options may come before or after the subcommand
+	subcommand := opts.Args[0]
+	opts.Args = opts.Args[1:]
+
+	client := schemaregistry.NewClient(opts.Client)
+
+	switch subcommand {
+	case "subjects", "ls":
+		return listSubjects(client, opts)
+	case "versions":
+		return listVersions(client, opts)
+	case "get":
+		return getSubjectSchema(client, opts)
+	case "get-id":
+		return getSchemaByID(client, opts)
+	case "register":
+		return registerSchema(client, opts)
+	case "delete", "rm":
+		return deleteSubject(client, opts)
+	case "compat":
+		return showCompatibility(client, opts)
+	case "set-compat":
+		return setCompatibility(client, opts)
+	case "test-compat":
+		return testCompatibility(client, opts)
+	case "health":
+		return checkHealth(client, opts)
+	case "serve":
+		return serveRegistry(opts)
+	case "resolve":
+		return resolveSchema(client, opts)
+	case "export":
+		return exportRegistry(client, opts)
+	case "import":
+		return importRegistry(client, opts)
+	case "migrate":
+		return migrateRegistry(opts)
+	default:
+		return fmt.Errorf("unknown registry subcommand: %s. Use 'schema registry help' to see available commands", subcommand)
+	}
+}
+
+// applyRegistryContext loads the registry URL and credentials from the active kafka context
+func applyRegistryContext(args []string, config *schemaregistry.Config) error {
+	kctx, err := kafkacontext.FromArgs(args)
+	if err != nil || kctx == nil {
+		return err
+	}
+
+	registry, err := kctx.RegistryConfig()
+	if err != nil || registry == nil {
+		return err
+	}
+	config.URL = registry.URL
+	config.Auth = registry.Auth
+	config.TLS = registry.TLS
+	return nil
+}
+
+func parseRegistryArgs(args []string, opts *registryOptions) error {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "--url", "-u":
+			if i+1 < len(args) {
+				opts.Client.URL = args[i+1]
+				i++
+			}
+		case "--username":
+			if i+1 < len(args) {
+				if opts.Client.Auth == nil {
+					opts.Client.Auth = &schemaregistry.AuthConfig{}
+				}
+				opts.Client.Auth.Username = args[i+1]
+				i++
+			}
+		case "--password":
+			if i+1 < len(args) {
+				if opts.Client.Auth == nil {
+					opts.Client.Auth = &schemaregistry.AuthConfig{}
+				}
+				opts.Client.Auth.Password = args[i+1]
+				i++
+			}
+		case "--token":
+			if i+1 < len(args) {
+				opts.Client.Auth = &schemaregistry.AuthConfig{BearerToken: args[i+1]}
+				i++
+			}
+		case "--ca-file", "--cert-file", "--key-file":
+			if i+1 < len(args) {
+				if opts.Client.TLS == nil {
+					opts.Client.TLS = &schemaregistry.TLSConfig{}
+				}
+				switch arg {
+				case "--ca-file":
+					opts.Client.TLS.CAFile = args[i+1]
+				case "--cert-file":
+					opts.Client.TLS.CertFile = args[i+1]
+				default:
+					opts.Client.TLS.KeyFile = args[i+1]
+				}
+				i++
+			}
+		case "--insecure":
+			if opts.Client.TLS == nil {
+				opts.Client.TLS = &schemaregistry.TLSConfig{}
+			}
+			opts.Client.TLS.InsecureSkipVerify = true
+		case "--retries":
+			if i+1 < len(args) {
+				retries, err := strconv.Atoi(args[i+1])
+				if err != nil || retries < 0 {
+					return fmt.Errorf("invalid retries: %s", args[i+1])
+				}
+				opts.Client.Retries = retries
+				i++
+			}
+		case "--reference":
+			if i+1 < len(args) {
+				ref, err := parseReference(args[i+1])
+				if err != nil {
+					return err
+				}
+				opts.References = append(opts.References, ref)
+				i++
+			}
+		case "--timeout":
+			if i+1 < len(args) {
+				duration, err := time.ParseDuration(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid timeout: %w", err)
+				}
+				opts.Client.Timeout = duration
+				i++
+			}
+		case "--output", "-o":
+			if i+1 < len(args) {
+				opts.Output = args[i+1]
+				i++
+			}
+		case "--type", "-t":
+			if i+1 < len(args) {
+				opts.Type = args[i+1]
+				i++
+			}
+		case "--version":
+			if i+1 < len(args) {
+				opts.Version = args[i+1]
+				i++
+			}
+		case "--registry", "-r":
+			if i+1 < len(args) {
+				opts.Dir = args[i+1]
+				i++
+			}
+		case "--host":
+			if i+1 < len(args) {
+				opts.Host = args[i+1]
+				i++
+			}
+		case "--port", "-p":
+			if i+1 < len(args) {
+				port, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return fmt.Errorf("invalid port: %s", args[i+1])
+				}
+				opts.Port = port
+				i++
+			}
+		case "--verbose", "-v":
+			opts.Verbose = true
+		case "--permanent":
+			opts.Permanent = true
+		case "--schema-only":
+			opts.SchemaOnly = true
+		case "--dir", "-d":
+			if i+1 < len(args) {
+				opts.BackupDir = args[i+1]
+				i++
+			}
+		case "--preserve-ids":
+			opts.PreserveIDs = true
+		case "--dry-run":
+			opts.DryRun = true
+		case "--from":
+			if i+1 < len(args) {
+				opts.From = args[i+1]
+				i++
+			}
+		case "--to":
+			if i+1 < len(args) {
+				opts.To = args[i+1]
+				i++
+			}
+		case "--from-context":
+			if i+1 < len(args) {
+				opts.FromContext = args[i+1]
+				i++
+			}
+		case "--to-context":
+			if i+1 < len(args) {
+				opts.ToContext = args[i+1]
+				i++
+			}
+		case "--context":
+			// Handled by applyRegistryContext
+			i++
+		default:
+			if kafkacontext.IsInlineFlag(arg) {
+				// Handled by applyRegistryContext
+				continue
+			}
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return fmt.Errorf("unknown option: %s", arg)
+			}
+			opts.Args = append(opts.Args, arg)
+		}
+	}
+	return nil
+}
+
+func printRegistryHelp() error {
+	help := `Usage: schema registry <subcommand> [options]
+
+Work with a Confluent-compatible schema registry through its REST API.
+Every subcommand exits non-zero when the request fails, the subject or
+schema is not found, or (for test-compat and health) the check fails.
+
+Global Options:
+  --context NAME            Kafka context from ~/.config/dimutils/kafka.yaml (uses its schema-registry: section)
+  --url, -u URL             Registry URL (default: http://localhost:8081)
+  --username USER           Basic auth username
+  --password PASS           Basic auth password
+  --token TOKEN             Bearer token, instead of basic auth
+  --ca-file FILE            CA certificate for an https registry
+  --cert-file FILE          Client certificate for mTLS
+  --key-file FILE           Client key for mTLS
+  --insecure                Skip verifying the registry's certificate
+  --timeout DURATION        Request timeout (default: 30s)
+  --retries N               Retries on 429, 5xx and connection errors, with doubling backoff (default: 3)
+  --output, -o FORMAT       Output format: table, json (default: table)
+
+Subcommands:
+  subjects, ls              List subjects
+  versions SUBJECT          List the versions of a subject
+  get SUBJECT [VERSION]     Show a schema version (default: latest)
+    --schema-only           Print only the schema
+  get-id ID                 Show the schema with a global ID
+    --schema-only           Print only the schema
+  register SUBJECT FILE     Register a schema file ("-" for stdin) and print its ID
+    --type, -t TYPE         AVRO, JSON or PROTOBUF (default: from the file, see below)
+    --reference NAME=SUBJECT:VERSION
+                            A registered schema this one imports, e.g. a .proto import
+                            path; repeat for each
+  resolve SUBJECT [VERSION] List the schemas a version refers to, recursively
+    --dir, -d DIR           Write the schema and its references to files in DIR instead,
+                            named after the references (ready for protoc)
+  delete, rm SUBJECT [VERSION]
+                            Delete a subject or one version of it
+    --permanent             Hard delete (the subject or version must be soft-deleted first)
+  compat [SUBJECT]          Show the compatibility level (default: global)
+  set-compat [SUBJECT] LEVEL
+                            Set the compatibility level: NONE, BACKWARD, FORWARD, FULL
+                            or their _TRANSITIVE variants (no subject sets the global level)
+  test-compat SUBJECT FILE  Check a schema file against a subject; exits 1 when incompatible
+    --version VERSION       Version to check against (default: latest)
+    --type, -t TYPE         Schema type, as for register
+    --reference NAME=SUBJECT:VERSION
+                            As for register
+  health                    Check that the registry responds
+  export                    Save every subject version, schema ID, type, references and
+                            compatibility setting
+    --dir, -d DIR           Backup directory
+  import                    Register the versions of a backup that the registry lacks and
+                            copy its compatibility settings; lists the changes first
+    --dir, -d DIR           Backup directory written by export
+    --preserve-ids          Keep schema IDs and versions, using IMPORT mode on each subject
+    --dry-run               Only list the changes
+  migrate                   Copy a registry into another, as export then import
+    --from URL              Source registry (user:password@ in the URL for basic auth)
+    --to URL                Target registry
+    --from-context NAME     Source registry from a kafka context instead
+    --to-context NAME       Target registry from a kafka context instead
+    --preserve-ids          As for import
+    --dry-run               As for import
+  serve                     Run a local Confluent-compatible registry for development and tests
+    --registry, -r PATH     Storage directory (default: .schema-registry)
+    --host HOST             Address to listen on (default: localhost)
+    --port, -p PORT         Port to listen on (default: 8081)
+    --verbose, -v           Log every request
+
+The local registry keeps subjects, versions, global schema IDs and
+compatibility settings in PATH/registry.json. Registering an identical schema
+returns its existing ID, and new versions of AVRO and JSON subjects must be
+compatible with earlier versions at the subject's level (default: BACKWARD) or
+the request fails with HTTP 409. New versions of PROTOBUF subjects can't be
+checked and are refused with HTTP 422 unless the subject's level is NONE.
+
+Import and migrate list each change as "+ SUBJECT vN" (register), "! ..."
+(conflict) or "~ compatibility ..." and stop without writing if there are
+conflicts. With --preserve-ids a conflict is a schema already registered under
+another ID, or an ID or version already taken by another schema, since
+records in Kafka refer to schemas by ID. Soft-deleted versions are not copied.
+
+Without --type, .proto files are PROTOBUF, JSON files with "$schema" or
+"properties" at the top level are JSON, and everything else is AVRO.
+
+Examples:
+  schema registry subjects
+  schema registry get orders-value --schema-only > orders.avsc
+  schema registry test-compat orders-value orders.avsc && \
+    schema registry register orders-value orders.avsc
+  schema registry set-compat orders-value FULL_TRANSITIVE
+  schema registry --context prod versions payments-value -o json
+  schema registry register common-value common/money.proto
+  schema registry register orders-value orders.proto --reference common/money.proto=common-value:1
+  schema registry resolve orders-value --dir protos/
+  schema registry serve --port 8081 --registry .schema-registry
+  schema registry --context prod export --dir backup/
+  schema registry --url http://staging:8081 import --dir backup/ --preserve-ids --dry-run
+  schema registry migrate --from-context prod --to http://dr-registry:8081 --preserve-ids`
+
+	fmt.Println(help)
+	return nil
+}
+
+func printRegistryJSON(value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// requireRegistryArgs checks the number of positional arguments
+func requireRegistryArgs(opts registryOptions, usage string, min, max int) error {
+	if len(opts.Args) < min || len(opts.Args) > max {
+		return fmt.Errorf("usage: schema registry %s", usage)
+	}
+	return nil
+}
+
+func listSubjects(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "subjects", 0, 0); err != nil {
+		return err
+	}
+	subjects, err := client.GetSubjects()
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		return printRegistryJSON(subjects)
+	}
+	for _, subject := range subjects {
+		fmt.Println(subject)
+	}
+	return nil
+}
+
+func listVersions(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "versions SUBJECT", 1, 1); err != nil {
+		return err
+	}
+	versions, err := client.GetSubjectVersions(opts.Args[0])
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		return printRegistryJSON(versions)
+	}
+	for _, version := range versions {
+		fmt.Println(version)
+	}
+	return nil
+}
+
+func getSubjectSchema(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "get SUBJECT [VERSION]", 1, 2); err != nil {
+		return err
+	}
+	version := "latest"
+	if len(opts.Args) == 2 {
+		version = opts.Args[1]
+	}
+	schema, err := client.GetSchema(opts.Args[0], version)
+	if err != nil {
+		return err
+	}
+	return printRegisteredSchema(schema, opts)
+}
+
+func getSchemaByID(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "get-id ID", 1, 1); err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(opts.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid schema id: %s", opts.Args[0])
+	}
+	schema, err := client.GetSchemaByID(id)
+	if err != nil {
+		return err
+	}
+	return printRegisteredSchema(schema, opts)
+}
+
+func printRegisteredSchema(schema *schemaregistry.Schema, opts registryOptions) error {
+	if schema.Type == "" {
+		schema.Type = "AVRO"
+	}
+	if opts.SchemaOnly {
+		fmt.Println(prettySchema(schema.Schema))
+		return nil
+	}
+	if opts.Output == "json" {
+		return printRegistryJSON(schema)
+	}
+
+	if schema.Subject != "" {
+		fmt.Printf("Subject: %s\n", schema.Subject)
+		fmt.Printf("Version: %d\n", schema.Version)
+	}
+	fmt.Printf("ID:      %d\n", schema.ID)
+	fmt.Printf("Type:    %s\n", schema.Type)
+	for _, ref := range schema.References {
+		fmt.Printf("Imports: %s (%s version %d)\n", ref.Name, ref.Subject, ref.Version)
+	}
+	fmt.Println()
+	fmt.Println(prettySchema(schema.Schema))
+	return nil
+}
+
+// prettySchema indents JSON schemas and returns others unchanged
+func prettySchema(schema string) string {
+	var buf bytes.Buffer
+	if json.Indent(&buf, []byte(schema), "", "  ") != nil {
+		return schema
+	}
+	return buf.String()
+}
+
+// readSchemaFile reads a schema file ("-" for stdin) and works out its type unless given
+func readSchemaFile(path, schemaType string) (string, string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("error reading schema file: %w", err)
+	}
+	if schemaType != "" {
+		return string(data), schemaType, nil
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".proto") {
+		return string(data), "PROTOBUF", nil
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) == nil {
+		if _, ok := fields["$schema"]; ok {
+			return string(data), "JSON", nil
+		}
+		if _, ok := fields["properties"]; ok {
+			return string(data), "JSON", nil
+		}
+	}
+	return string(data), "AVRO", nil
+}
+
+func registerSchema(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "register SUBJECT FILE", 2, 2); err != nil {
+		return err
+	}
+	subject := opts.Args[0]
+	schema, schemaType, err := readSchemaFile(opts.Args[1], opts.Type)
+	if err != nil {
+		return err
+	}
+
+	registered, err := client.RegisterSchema(subject, schema, schemaType, opts.References...)
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		return printRegistryJSON(map[string]interface{}{"subject": subject, "id": registered.ID, "schemaType": schemaType})
+	}
+	fmt.Printf("Registered %s schema for %s with ID %d\n", schemaType, subject, registered.ID)
+	return nil
+}
+
+// parseReference parses NAME=SUBJECT:VERSION
+func parseReference(value string) (schemaregistry.Reference, error) {
+	name, target, ok := strings.Cut(value, "=")
+	idx := strings.LastIndex(target, ":")
+	if !ok || name == "" || idx <= 0 {
+		return schemaregistry.Reference{}, fmt.Errorf("invalid reference %q: use NAME=SUBJECT:VERSION", value)
+	}
+	version, err := strconv.Atoi(target[idx+1:])
+	if err != nil || version < 1 {
+		return schemaregistry.Reference{}, fmt.Errorf("invalid reference %q: version must be a number", value)
+	}
+	return schemaregistry.Reference{Name: name, Subject: target[:idx], Version: version}, nil
+}
+
+// resolveSchema shows the schemas a subject version refers to, recursively,
+// or with --dir writes it and them to files named after the references
+func resolveSchema(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "resolve SUBJECT [VERSION] [--dir DIR]", 1, 2); err != nil {
+		return err
+	}
+	version := "latest"
+	if len(opts.Args) == 2 {
+		version = opts.Args[1]
+	}
+	schema, err := client.GetSchema(opts.Args[0], version)
+	if err != nil {
+		return err
+	}
+	resolved, err := client.ResolveReferences(schema)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(resolved))
+	for name := range resolved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if opts.BackupDir != "" {
+		rootName := opts.Args[0] + schemaExtension(normalizeSchemaType(schema.Type))
+		files := map[string]string{rootName: schema.Schema}
+		for _, name := range names {
+			files[name] = resolved[name].Schema
+		}
+		for name, content := range files {
+			path := filepath.Join(opts.BackupDir, filepath.FromSlash(name))
+			if rel, err := filepath.Rel(opts.BackupDir, path); err != nil || strings.HasPrefix(rel, "..") {
+				return fmt.Errorf("reference name %s is outside %s", name, opts.BackupDir)
+			}
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("error creating directory: %w", err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return fmt.Errorf("error writing %s: %w", path, err)
+			}
+		}
+		fmt.Printf("Wrote %s and %d referenced schemas to %s\n", rootName, len(names), opts.BackupDir)
+		return nil
+	}
+
+	if opts.Output == "json" {
+		return printRegistryJSON(map[string]interface{}{"schema": schema, "references": resolved})
+	}
+	fmt.Printf("%s version %d (ID %d)\n", opts.Args[0], schema.Version, schema.ID)
+	for _, name := range names {
+		ref := resolved[name]
+		fmt.Printf("  %s: %s version %d (ID %d)\n", name, ref.Subject, ref.Version, ref.ID)
+	}
+	return nil
+}
+
+func deleteSubject(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "delete SUBJECT [VERSION]", 1, 2); err != nil {
+		return err
+	}
+	subject := opts.Args[0]
+	kind := "Deleted"
+	if opts.Permanent {
+		kind = "Permanently deleted"
+	}
+
+	if len(opts.Args) == 2 {
+		version, err := strconv.Atoi(opts.Args[1])
+		if err != nil {
+			return fmt.Errorf("invalid version: %s", opts.Args[1])
+		}
+		if err := client.DeleteSubjectVersion(subject, version, opts.Permanent); err != nil {
+			return err
+		}
+		if opts.Output == "json" {
+			return printRegistryJSON([]int{version})
+		}
+		fmt.Printf("%s %s version %d\n", kind, subject, version)
+		return nil
+	}
+
+	versions, err := client.DeleteSubject(subject, opts.Permanent)
+	if err != nil {
+		return err
+	}
+	if opts.Output == "json" {
+		return printRegistryJSON(versions)
+	}
+	fmt.Printf("%s %s (versions %s)\n", kind, subject, joinInts(versions))
+	return nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.Itoa(value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func showCompatibility(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "compat [SUBJECT]", 0, 1); err != nil {
+		return err
+	}
+
+	subject := ""
+	global := true
+	var level *schemaregistry.CompatibilityLevel
+	var err error
+	if len(opts.Args) == 1 {
+		subject = opts.Args[0]
+		level, err = client.GetCompatibility(subject)
+		global = false
+		if schemaregistry.IsNotFound(err) {
+			// No subject-level setting: the global level applies
+			global = true
+			level, err = client.GetGlobalCompatibility()
+		}
+	} else {
+		level, err = client.GetGlobalCompatibility()
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		return printRegistryJSON(map[string]interface{}{"subject": subject, "compatibility": level.Compatibility, "global": global})
+	}
+	switch {
+	case subject == "":
+		fmt.Println(level.Compatibility)
+	case global:
+		fmt.Printf("%s (global default)\n", level.Compatibility)
+	default:
+		fmt.Println(level.Compatibility)
+	}
+	return nil
+}
+
+func setCompatibility(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "set-compat [SUBJECT] LEVEL", 1, 2); err != nil {
+		return err
+	}
+	level := strings.ToUpper(opts.Args[len(opts.Args)-1])
+	if err := schemaregistry.ValidateCompatibilityLevel(level); err != nil {
+		return err
+	}
+
+	if len(opts.Args) == 1 {
+		if err := client.SetGlobalCompatibility(level); err != nil {
+			return err
+		}
+		fmt.Printf("Set global compatibility to %s\n", level)
+		return nil
+	}
+
+	subject := opts.Args[0]
+	if err := client.SetCompatibility(subject, level); err != nil {
+		return err
+	}
+	fmt.Printf("Set compatibility of %s to %s\n", subject, level)
+	return nil
+}
+
+func testCompatibility(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "test-compat SUBJECT FILE", 2, 2); err != nil {
+		return err
+	}
+	subject := opts.Args[0]
+	schema, schemaType, err := readSchemaFile(opts.Args[1], opts.Type)
+	if err != nil {
+		return err
+	}
+
+	result, err := client.CheckCompatibility(subject, opts.Version, schema, schemaType, opts.References...)
+	if err != nil {
+		return err
+	}
+
+	if opts.Output == "json" {
+		if err := printRegistryJSON(result); err != nil {
+			return err
+		}
+	} else if result.Compatible {
+		fmt.Printf("Compatible with %s version %s\n", subject, opts.Version)
+	} else {
+		fmt.Printf("Not compatible with %s version %s\n", subject, opts.Version)
+		for _, message := range result.Messages {
+			fmt.Printf("  - %s\n", message)
+		}
+	}
+
+	if !result.Compatible {
+		return fmt.Errorf("%s is not compatible with %s version %s", opts.Args[1], subject, opts.Version)
+	}
+	return nil
+}
+
+func checkHealth(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "health", 0, 0); err != nil {
+		return err
+	}
+	if err := client.HealthCheck(); err != nil {
+		if opts.Output == "json" {
+			printRegistryJSON(map[string]interface{}{"url": opts.Client.URL, "healthy": false, "error": err.Error()})
+		}
+		return err
+	}
+	if opts.Output == "json" {
+		return printRegistryJSON(map[string]interface{}{"url": opts.Client.URL, "healthy": true})
+	}
+	fmt.Printf("Schema registry at %s is healthy\n", opts.Client.URL)
+	return nil
+}
+
+// serveRegistry runs the local registry until interrupted
+func serveRegistry(opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "serve", 0, 0); err != nil {
+		return err
+	}
+
+	registry, err := schemaregistry.NewServer(opts.Dir)
+	if err != nil {
+		return err
+	}
+	registry.Verbose = opts.Verbose
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(opts.Host, strconv.Itoa(opts.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	server := &http.Server{Handler: registry}
+
+	log.Printf("Schema registry listening on http://%s (storage: %s)", listener.Addr(), opts.Dir)
+
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigterm)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+
+	select {
+	case <-sigterm:
+		log.Println("Shutting down schema registry...")
+	case err := <-done:
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
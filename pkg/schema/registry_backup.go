@@ -0,0 +1,504 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/kafkacontext"
+	"github.com/og-dim9/dimutils/pkg/schemaregistry"
+)
+
+// backupManifest is the file in a backup directory that describes its contents
+const backupManifest = "registry.json"
+
+// registryBackup is everything export saves from a registry; schema texts are
+// kept in separate files next to the manifest
+type registryBackup struct {
+	Compatibility string          `json:"compatibility,omitempty"`
+	Mode          string          `json:"mode,omitempty"`
+	Subjects      []backupSubject `json:"subjects"`
+}
+
+type backupSubject struct {
+	Name          string          `json:"subject"`
+	Compatibility string          `json:"compatibility,omitempty"` // subject-level override only
+	Versions      []backupVersion `json:"versions"`
+}
+
+type backupVersion struct {
+	Version    int                        `json:"version"`
+	ID         int                        `json:"id"`
+	Type       string                     `json:"schemaType"`
+	References []schemaregistry.Reference `json:"references,omitempty"`
+	File       string                     `json:"file"`
+	Schema     string                     `json:"-"`
+}
+
+// importStep is one change an import makes (or would make) to the target registry
+type importStep struct {
+	Subject string
+	Version backupVersion
+	Action  string // "+" register, "=" already there, "!" conflict
+	Reason  string
+}
+
+// fetchBackup reads every live subject version and compatibility setting from a registry
+func fetchBackup(client *schemaregistry.Client) (*registryBackup, error) {
+	backup := &registryBackup{}
+	global, err := client.GetGlobalCompatibility()
+	if err != nil {
+		return nil, err
+	}
+	backup.Compatibility = global.Compatibility
+	if mode, err := client.GetMode(""); err == nil {
+		backup.Mode = mode
+	}
+
+	subjects, err := client.GetSubjects()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(subjects)
+	for _, name := range subjects {
+		subject := backupSubject{Name: name}
+		compat, err := client.GetCompatibility(name)
+		switch {
+		case err == nil:
+			subject.Compatibility = compat.Compatibility
+		case !schemaregistry.IsNotFound(err):
+			return nil, err
+		}
+
+		versions, err := client.GetSubjectVersions(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, number := range versions {
+			schema, err := client.GetSchema(name, number)
+			if err != nil {
+				return nil, err
+			}
+			subject.Versions = append(subject.Versions, backupVersion{
+				Version:    schema.Version,
+				ID:         schema.ID,
+				Type:       normalizeSchemaType(schema.Type),
+				References: schema.References,
+				Schema:     schema.Schema,
+			})
+		}
+		backup.Subjects = append(backup.Subjects, subject)
+	}
+	return backup, nil
+}
+
+// writeBackup saves a backup as a manifest plus one file per schema version
+func writeBackup(dir string, backup *registryBackup) error {
+	for i := range backup.Subjects {
+		subject := &backup.Subjects[i]
+		for j := range subject.Versions {
+			version := &subject.Versions[j]
+			version.File = filepath.ToSlash(filepath.Join("schemas", url.PathEscape(subject.Name),
+				fmt.Sprintf("v%d%s", version.Version, schemaExtension(version.Type))))
+			path := filepath.Join(dir, filepath.FromSlash(version.File))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return fmt.Errorf("error creating backup directory: %w", err)
+			}
+			if err := os.WriteFile(path, []byte(version.Schema), 0644); err != nil {
+				return fmt.Errorf("error writing %s: %w", path, err)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, backupManifest), append(data, '\n'), 0644)
+}
+
+// readBackup loads a backup written by writeBackup
+func readBackup(dir string) (*registryBackup, error) {
+	path := filepath.Join(dir, backupManifest)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading backup: %w", err)
+	}
+	var backup registryBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	for i := range backup.Subjects {
+		for j := range backup.Subjects[i].Versions {
+			version := &backup.Subjects[i].Versions[j]
+			schema, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(version.File)))
+			if err != nil {
+				return nil, fmt.Errorf("error reading backup: %w", err)
+			}
+			version.Schema = string(schema)
+		}
+	}
+	return &backup, nil
+}
+
+func schemaExtension(schemaType string) string {
+	switch schemaType {
+	case "JSON":
+		return ".json"
+	case "PROTOBUF":
+		return ".proto"
+	}
+	return ".avsc"
+}
+
+// normalizeSchemaType returns the upper-case schema type, AVRO when unset
+func normalizeSchemaType(schemaType string) string {
+	if schemaType == "" {
+		return "AVRO"
+	}
+	return strings.ToUpper(schemaType)
+}
+
+// planImport works out which backup versions the target registry is missing.
+// Versions are ordered by schema ID so referenced schemas come first.
+func planImport(client *schemaregistry.Client, backup *registryBackup, preserveIDs bool) ([]importStep, error) {
+	var steps []importStep
+	for _, subject := range backup.Subjects {
+		for _, version := range subject.Versions {
+			steps = append(steps, importStep{Subject: subject.Name, Version: version})
+		}
+	}
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Version.ID < steps[j].Version.ID
+	})
+
+	for i := range steps {
+		step := &steps[i]
+		version := step.Version
+		existing, err := client.LookupSchema(step.Subject, version.Schema, version.Type, version.References)
+		if err != nil && !schemaregistry.IsNotFound(err) {
+			return nil, err
+		}
+		if existing != nil {
+			step.Action = "="
+			if preserveIDs && existing.ID != version.ID {
+				step.Action = "!"
+				step.Reason = fmt.Sprintf("already registered with ID %d", existing.ID)
+			}
+			continue
+		}
+
+		step.Action = "+"
+		if !preserveIDs {
+			continue
+		}
+		if current, err := client.GetSchemaByID(version.ID); err == nil {
+			if !sameSchema(current.Schema, version.Schema) {
+				step.Action = "!"
+				step.Reason = fmt.Sprintf("ID %d holds a different schema", version.ID)
+				continue
+			}
+		} else if !schemaregistry.IsNotFound(err) {
+			return nil, err
+		}
+		if current, err := client.GetSchema(step.Subject, version.Version); err == nil {
+			step.Action = "!"
+			step.Reason = fmt.Sprintf("version %d holds schema ID %d", version.Version, current.ID)
+		} else if !schemaregistry.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return steps, nil
+}
+
+// sameSchema compares two schema texts, ignoring JSON whitespace
+func sameSchema(a, b string) bool {
+	var compactA, compactB bytes.Buffer
+	if json.Compact(&compactA, []byte(a)) == nil && json.Compact(&compactB, []byte(b)) == nil {
+		return compactA.String() == compactB.String()
+	}
+	return strings.TrimSpace(a) == strings.TrimSpace(b)
+}
+
+// compatibilityChanges lists the compatibility settings the target needs to match the backup
+func compatibilityChanges(client *schemaregistry.Client, backup *registryBackup) (map[string][2]string, error) {
+	changes := make(map[string][2]string) // subject ("" for global) -> current, wanted
+	if backup.Compatibility != "" {
+		global, err := client.GetGlobalCompatibility()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(global.Compatibility, backup.Compatibility) {
+			changes[""] = [2]string{global.Compatibility, backup.Compatibility}
+		}
+	}
+	for _, subject := range backup.Subjects {
+		if subject.Compatibility == "" {
+			continue
+		}
+		current := ""
+		compat, err := client.GetCompatibility(subject.Name)
+		if err == nil {
+			current = compat.Compatibility
+		} else if !schemaregistry.IsNotFound(err) {
+			return nil, err
+		}
+		if !strings.EqualFold(current, subject.Compatibility) {
+			changes[subject.Name] = [2]string{current, subject.Compatibility}
+		}
+	}
+	return changes, nil
+}
+
+// importBackup registers the versions the target is missing and copies the
+// compatibility settings, printing each change. With preserveIDs every subject
+// being written is switched to IMPORT mode for the duration.
+func importBackup(client *schemaregistry.Client, backup *registryBackup, preserveIDs, dryRun bool) error {
+	steps, err := planImport(client, backup, preserveIDs)
+	if err != nil {
+		return err
+	}
+	changes, err := compatibilityChanges(client, backup)
+	if err != nil {
+		return err
+	}
+
+	var added, unchanged, conflicts int
+	for _, step := range steps {
+		switch step.Action {
+		case "+":
+			added++
+			fmt.Printf("+ %s v%d (%s, ID %d)\n", step.Subject, step.Version.Version, step.Version.Type, step.Version.ID)
+		case "=":
+			unchanged++
+		case "!":
+			conflicts++
+			fmt.Printf("! %s v%d (ID %d): %s\n", step.Subject, step.Version.Version, step.Version.ID, step.Reason)
+		}
+	}
+	subjects := make([]string, 0, len(changes))
+	for subject := range changes {
+		subjects = append(subjects, subject)
+	}
+	sort.Strings(subjects)
+	for _, subject := range subjects {
+		name := subject
+		if name == "" {
+			name = "(global)"
+		}
+		current := changes[subject][0]
+		if current == "" {
+			current = "unset"
+		}
+		fmt.Printf("~ compatibility %s: %s -> %s\n", name, current, changes[subject][1])
+	}
+
+	summary := fmt.Sprintf("%d to register, %d already present, %d conflicts, %d compatibility changes",
+		added, unchanged, conflicts, len(changes))
+	if conflicts > 0 {
+		return fmt.Errorf("%s; nothing imported", summary)
+	}
+	if dryRun {
+		fmt.Printf("Dry run: %s\n", summary)
+		return nil
+	}
+
+	// Compatibility is copied last so a stricter level can't block registering older versions
+	if err := applyImportSteps(client, steps, preserveIDs); err != nil {
+		return err
+	}
+	for _, subject := range subjects {
+		level := changes[subject][1]
+		if subject == "" {
+			err = client.SetGlobalCompatibility(level)
+		} else {
+			err = client.SetCompatibility(subject, level)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Imported %d schema versions (%d already present, %d compatibility changes)\n",
+		added, unchanged, len(changes))
+	return nil
+}
+
+func applyImportSteps(client *schemaregistry.Client, steps []importStep, preserveIDs bool) error {
+	// Without preserved IDs the target numbers versions itself, so references
+	// are rewritten to the versions their subjects got there
+	versions := make(map[string]int)
+	versionKey := func(subject string, version int) string {
+		return fmt.Sprintf("%s\x00%d", subject, version)
+	}
+	restoreModes := make(map[string]string) // subject -> mode to put back ("" to delete)
+	defer func() {
+		for subject, mode := range restoreModes {
+			var err error
+			if mode == "" {
+				err = client.DeleteMode(subject)
+			} else {
+				err = client.SetMode(subject, mode, true)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to restore the mode of %s: %v\n", subject, err)
+			}
+		}
+	}()
+
+	for _, step := range steps {
+		if step.Action != "+" {
+			continue
+		}
+		schema := &schemaregistry.Schema{
+			Subject: step.Subject,
+			Schema:  step.Version.Schema,
+			Type:    step.Version.Type,
+		}
+		for _, ref := range step.Version.References {
+			if version, ok := versions[versionKey(ref.Subject, ref.Version)]; ok && !preserveIDs {
+				ref.Version = version
+			}
+			schema.References = append(schema.References, ref)
+		}
+
+		if preserveIDs {
+			if _, done := restoreModes[step.Subject]; !done {
+				previous, err := client.GetMode(step.Subject)
+				if err != nil && !schemaregistry.IsNotFound(err) {
+					return err
+				}
+				_, existsErr := client.GetSubjectVersions(step.Subject)
+				if err := client.SetMode(step.Subject, "IMPORT", existsErr == nil); err != nil {
+					return err
+				}
+				restoreModes[step.Subject] = previous
+			}
+			schema.ID = step.Version.ID
+			schema.Version = step.Version.Version
+		}
+
+		id, err := client.ImportSchema(schema)
+		if err != nil {
+			return fmt.Errorf("%s v%d: %w", step.Subject, step.Version.Version, err)
+		}
+		if !preserveIDs {
+			registered, err := client.LookupSchema(step.Subject, schema.Schema, schema.Type, schema.References)
+			if err != nil {
+				return err
+			}
+			versions[versionKey(step.Subject, step.Version.Version)] = registered.Version
+			if id != step.Version.ID {
+				fmt.Printf("  %s v%d registered as v%d with ID %d (was %d)\n",
+					step.Subject, step.Version.Version, registered.Version, id, step.Version.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func exportRegistry(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "export --dir DIR", 0, 0); err != nil {
+		return err
+	}
+	if opts.BackupDir == "" {
+		return fmt.Errorf("usage: schema registry export --dir DIR")
+	}
+
+	backup, err := fetchBackup(client)
+	if err != nil {
+		return err
+	}
+	if err := writeBackup(opts.BackupDir, backup); err != nil {
+		return err
+	}
+
+	count := 0
+	for _, subject := range backup.Subjects {
+		count += len(subject.Versions)
+	}
+	fmt.Printf("Exported %d subjects (%d schema versions) to %s\n", len(backup.Subjects), count, opts.BackupDir)
+	return nil
+}
+
+func importRegistry(client *schemaregistry.Client, opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "import --dir DIR [--preserve-ids] [--dry-run]", 0, 0); err != nil {
+		return err
+	}
+	if opts.BackupDir == "" {
+		return fmt.Errorf("usage: schema registry import --dir DIR [--preserve-ids] [--dry-run]")
+	}
+
+	backup, err := readBackup(opts.BackupDir)
+	if err != nil {
+		return err
+	}
+	return importBackup(client, backup, opts.PreserveIDs, opts.DryRun)
+}
+
+func migrateRegistry(opts registryOptions) error {
+	if err := requireRegistryArgs(opts, "migrate --from URL --to URL [--preserve-ids] [--dry-run]", 0, 0); err != nil {
+		return err
+	}
+	if (opts.From == "" && opts.FromContext == "") || (opts.To == "" && opts.ToContext == "") {
+		return fmt.Errorf("usage: schema registry migrate --from URL --to URL [--preserve-ids] [--dry-run]")
+	}
+
+	source, err := registryEndpoint(opts.From, opts.FromContext, opts.Client)
+	if err != nil {
+		return err
+	}
+	target, err := registryEndpoint(opts.To, opts.ToContext, opts.Client)
+	if err != nil {
+		return err
+	}
+
+	backup, err := fetchBackup(schemaregistry.NewClient(source))
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", source.URL, err)
+	}
+	return importBackup(schemaregistry.NewClient(target), backup, opts.PreserveIDs, opts.DryRun)
+}
+
+// registryEndpoint builds the client settings for one side of a migration from
+// a kafka context and/or a URL, which may carry user:password credentials
+func registryEndpoint(rawURL, contextName string, base schemaregistry.Config) (schemaregistry.Config, error) {
+	config := schemaregistry.DefaultConfig()
+	config.Timeout, config.Retries = base.Timeout, base.Retries
+	if contextName != "" {
+		kctx, err := kafkacontext.Resolve(contextName)
+		if err != nil {
+			return config, err
+		}
+		registry, err := kctx.RegistryConfig()
+		if err != nil {
+			return config, err
+		}
+		if registry == nil {
+			return config, fmt.Errorf("context %s has no schema registry", contextName)
+		}
+		config.URL = registry.URL
+		config.Auth = registry.Auth
+		config.TLS = registry.TLS
+	}
+	if rawURL == "" {
+		return config, nil
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return config, fmt.Errorf("invalid registry URL %s: %w", rawURL, err)
+	}
+	if parsed.User != nil {
+		password, _ := parsed.User.Password()
+		config.Auth = &schemaregistry.AuthConfig{Username: parsed.User.Username(), Password: password}
+		parsed.User = nil
+	}
+	config.URL = parsed.String()
+	return config, nil
+}
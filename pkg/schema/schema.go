@@ -2,11 +2,16 @@ package schema
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/jsonschema"
 )
 
 // SchemaType represents the type of a field in the schema
@@ -24,31 +29,103 @@ const (
 
 // Schema represents a JSON schema structure
 type Schema struct {
-	Type       SchemaType             `json:"type,omitempty"`
-	Properties map[string]*Schema     `json:"properties,omitempty"`
-	Items      *Schema                `json:"items,omitempty"`
-	Required   []string               `json:"required,omitempty"`
-	Examples   []interface{}          `json:"examples,omitempty"`
-	Title      string                 `json:"title,omitempty"`
-	Version    string                 `json:"version,omitempty"`
-	Schema     string                 `json:"$schema,omitempty"`
-	ID         string                 `json:"$id,omitempty"`
-	Metadata   map[string]interface{} `json:"metadata,omitempty"`
+	Type        SchemaType             `json:"type,omitempty"`
+	Properties  map[string]*Schema     `json:"properties,omitempty"`
+	Items       *Schema                `json:"items,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Enum        []interface{}          `json:"enum,omitempty"`
+	Format      string                 `json:"format,omitempty"`
+	Minimum     *float64               `json:"minimum,omitempty"`
+	Maximum     *float64               `json:"maximum,omitempty"`
+	MinLength   *int                   `json:"minLength,omitempty"`
+	MaxLength   *int                   `json:"maxLength,omitempty"`
+	MinItems    *int                   `json:"minItems,omitempty"`
+	MaxItems    *int                   `json:"maxItems,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Examples    []interface{}          `json:"examples,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Version     string                 `json:"version,omitempty"`
+	Schema      string                 `json:"$schema,omitempty"`
+	ID          string                 `json:"$id,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	// Nullable also allows null; it is written as a type array, e.g. ["string", "null"]
+	Nullable bool `json:"-"`
+
+	compiled *jsonschema.Schema // the full schema document, when loaded from a file
+}
+
+// schemaJSON is Schema without its JSON methods
+type schemaJSON Schema
+
+// MarshalJSON writes the type of a nullable schema as a type array
+func (s Schema) MarshalJSON() ([]byte, error) {
+	var schemaType interface{}
+	if s.Type != "" {
+		schemaType = s.Type
+		if s.Nullable && s.Type != TypeNull {
+			schemaType = []SchemaType{s.Type, TypeNull}
+		}
+	}
+	return json.Marshal(struct {
+		Type interface{} `json:"type,omitempty"`
+		schemaJSON
+	}{schemaType, schemaJSON(s)})
+}
+
+// UnmarshalJSON reads a type array of one type and null as a nullable type
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Type json.RawMessage `json:"type,omitempty"`
+		*schemaJSON
+	}
+	raw.schemaJSON = (*schemaJSON)(s)
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	s.Type, s.Nullable = "", false
+	if len(raw.Type) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw.Type, &s.Type); err == nil {
+		return nil
+	}
+	var types []SchemaType
+	if err := json.Unmarshal(raw.Type, &types); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	for _, t := range types {
+		switch {
+		case t == TypeNull && len(types) > 1:
+			s.Nullable = true
+		case s.Type == "":
+			s.Type = t
+		default:
+			// Several non-null types: keep the most general one
+			s.Type = getCompatibleType(s.Type, t)
+		}
+	}
+	return nil
 }
 
 // Config holds configuration for schema operations
 type Config struct {
-	Pretty          bool
-	Evolve          bool
-	ForceOptional   bool
-	OutputFile      string
-	InputFile       string
-	SchemaFile      string
-	RegistryPath    string
-	Validate        bool
-	Generate        bool
-	Merge           bool
-	Version         string
+	Pretty        bool
+	Evolve        bool
+	ForceOptional bool
+	OutputFile    string
+	InputFile     string
+	SchemaFile    string
+	SchemaFiles   []string // every --schema given
+	Files         []string // positional arguments, e.g. more schema files to merge
+	Strategy      string   // merge: union, intersection, strict
+	RegistryPath  string
+	Validate      bool
+	Generate      bool
+	Merge         bool
+	Version       string
+	Subject       string
+	History       bool
+	Infer         InferOptions
 }
 
 // DefaultConfig returns default configuration
@@ -56,14 +133,48 @@ func DefaultConfig() Config {
 	return Config{
 		Pretty:       true,
 		RegistryPath: ".schema-registry",
-		Version:      "1.0.0",
+		History:      true,
+		Strategy:     "union",
+		Infer:        DefaultInferOptions(),
 	}
 }
 
 // Run is the main entry point for schema functionality
 func Run(args []string) error {
+	if len(args) > 0 && args[0] == "registry" {
+		return runRegistry(args[1:])
+	}
+	if len(args) > 0 && args[0] == "compat" {
+		return runCompat(args[1:])
+	}
+	if len(args) > 0 && args[0] == "convert" {
+		return runConvert(args[1:])
+	}
+	if len(args) > 0 && args[0] == "history" {
+		return runHistory(args[1:])
+	}
+	if len(args) > 0 && args[0] == "diff" {
+		return runDiff(args[1:])
+	}
+	if len(args) > 0 && args[0] == "checkout" {
+		return runCheckout(args[1:])
+	}
+	if len(args) > 0 && args[0] == "docs" {
+		return runDocs(args[1:])
+	}
+	if len(args) > 0 && args[0] == "codegen" {
+		return runCodegen(args[1:])
+	}
+
+	// Help is checked first, so "generate --help" doesn't go on to read stdin
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			return printHelp()
+		}
+	}
+
 	config := DefaultConfig()
-	
+
 	// Parse arguments
 	if err := parseArgs(args, &config); err != nil {
 		return err
@@ -83,7 +194,8 @@ func Run(args []string) error {
 }
 
 func parseArgs(args []string, config *Config) error {
-	for i, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 		switch arg {
 		case "generate", "gen":
 			config.Generate = true
@@ -102,25 +214,65 @@ func parseArgs(args []string, config *Config) error {
 		case "--output", "-o":
 			if i+1 < len(args) {
 				config.OutputFile = args[i+1]
+				i++
 			}
 		case "--input", "-i":
 			if i+1 < len(args) {
 				config.InputFile = args[i+1]
+				i++
 			}
 		case "--schema", "-s":
 			if i+1 < len(args) {
 				config.SchemaFile = args[i+1]
+				config.SchemaFiles = append(config.SchemaFiles, args[i+1])
+				i++
 			}
 		case "--registry", "-r":
 			if i+1 < len(args) {
 				config.RegistryPath = args[i+1]
+				i++
 			}
 		case "--version", "-v":
 			if i+1 < len(args) {
 				config.Version = args[i+1]
+				i++
+			}
+		case "--subject":
+			if i+1 < len(args) {
+				config.Subject = args[i+1]
+				i++
+			}
+		case "--no-history":
+			config.History = false
+		case "--constraints", "-c":
+			if i+1 < len(args) {
+				constraints, err := parseConstraints(args[i+1])
+				if err != nil {
+					return err
+				}
+				config.Infer.Constraints = constraints
+				i++
+			}
+		case "--no-constraints":
+			config.Infer.Constraints = map[string]bool{}
+		case "--enum-threshold":
+			if i+1 < len(args) {
+				threshold, err := strconv.Atoi(args[i+1])
+				if err != nil || threshold < 1 {
+					return fmt.Errorf("invalid --enum-threshold %q: must be a positive number", args[i+1])
+				}
+				config.Infer.EnumThreshold = threshold
+				i++
+			}
+		case "--strategy":
+			if i+1 < len(args) {
+				config.Strategy = args[i+1]
+				i++
+			}
+		default:
+			if !strings.HasPrefix(arg, "-") || arg == "-" {
+				config.Files = append(config.Files, arg)
 			}
-		case "-h", "--help":
-			return printHelp()
 		}
 	}
 	return nil
@@ -131,25 +283,68 @@ func printHelp() error {
 
 Commands:
   generate, gen     Generate schema from JSON data
-  validate, val     Validate JSON data against schema
+  validate, val     Validate JSON data against a JSON Schema (draft 2020-12)
   merge            Merge multiple schemas
+  compat           Check whether a schema change is compatible (see 'schema compat --help')
+  convert          Convert between JSON Schema, Avro, Protobuf and SQL (see 'schema convert --help')
+  registry         Work with a schema registry (see 'schema registry help')
+  history          List the stored versions of a subject (see 'schema history --help')
+  diff             Structural diff between two stored versions
+  checkout         Restore a stored version
+  docs             Render schemas as Markdown or HTML (see 'schema docs --help')
+  codegen          Generate Go, TypeScript or Python types (see 'schema codegen --help')
 
 Options:
   --input, -i FILE      Input JSON file (default: stdin)
   --output, -o FILE     Output schema file (default: stdout)
   --schema, -s FILE     Schema file for validation
   --registry, -r PATH   Schema registry path (default: .schema-registry)
-  --version, -v VER     Schema version (default: 1.0.0)
+  --version, -v VER     Schema version label (default: the stored version
+                       number, or 1.0.0 with --no-history)
+  --subject NAME        Subject to store the result under (default: the
+                       output, input or schema file name)
+  --no-history          Don't store generate --evolve and merge results
   --pretty             Pretty print output (default: true)
   --no-pretty         Disable pretty printing
   --evolve             Enable schema evolution
   --force-optional     Make all fields optional when merging
+  --strategy NAME      Merge strategy: union, intersection or strict
+                       (default: union; see below)
+  --constraints, -c LIST  Constraints to infer when generating, comma-separated:
+                       formats, enums, ranges, lengths, nullable, all or none
+                       (default: all)
+  --no-constraints     Infer types and required fields only
+  --enum-threshold N   Most distinct values a string can have to become an enum (default: 10)
   -h, --help           Show this help message
 
+Merge strategies (conflicts are listed on stderr with the file they come from):
+  union          Keep every property; a property is required only when every
+                 input requires it, and conflicting types widen
+  intersection   Keep only the properties every input has
+  strict         Like union, but fail on any type conflict
+Types widen only when the wider type accepts the data of both (integer to
+number, or any type to nullable when an input is always null); any other type
+conflict, such as integer and string, fails the merge whatever the strategy.
+
+Constraints are inferred from every sample of a field:
+  formats    uuid, date-time, date, email, ipv4 or uri, when every string matches
+  enums      the distinct strings, when there are at most --enum-threshold of
+             them and each appears twice on average (samples >= 2 x distinct),
+             so a field seen once per value is not mistaken for an enum
+  ranges     minimum and maximum of the numbers seen
+  lengths    shortest and longest string or array seen
+  nullable   allow null when some samples are null and others are not
+
 Examples:
   cat data.json | schema generate --output schema.json
+  schema generate --evolve --constraints formats,nullable -i data.json
   schema validate --input data.json --schema schema.json
-  schema merge --schema schema1.json schema2.json --output merged.json`
+  schema merge --schema schema1.json schema2.json --output merged.json
+  schema merge --strategy strict 'producers/*.schema.json' -o orders.schema.json
+  schema compat --type avro old.avsc new.avsc --level FULL
+  schema convert --to sql:postgres orders.avsc
+  schema diff orders v3 v5
+  schema registry subjects --url http://localhost:8081`
 
 	fmt.Println(help)
 	return nil
@@ -176,11 +371,12 @@ func generateSchema(config Config) error {
 		Schema:     "https://json-schema.org/draft/2020-12/schema",
 		Type:       TypeObject,
 		Properties: make(map[string]*Schema),
-		Version:    config.Version,
 	}
 
 	scanner := bufio.NewScanner(input)
 	recordCount := 0
+	stats := newFieldStats()
+	sampleHash := sha256.New()
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -196,6 +392,8 @@ func generateSchema(config Config) error {
 		if err := updateSchemaFromData(schema, data, config.Evolve); err != nil {
 			return fmt.Errorf("error updating schema: %w", err)
 		}
+		stats.observe(data, config.Infer)
+		sampleHash.Write([]byte(line + "\n"))
 
 		recordCount++
 	}
@@ -208,6 +406,17 @@ func generateSchema(config Config) error {
 		return fmt.Errorf("no valid JSON data found")
 	}
 
+	// Add the constraints every record satisfied
+	stats.apply(schema, config.Infer)
+
+	// Only evolved schemas are kept in the history
+	if !config.Evolve {
+		config.History = false
+	}
+	if err := recordVersion(schema, config, "generate", recordCount, sampleHash); err != nil {
+		return err
+	}
+
 	// Output the schema
 	return outputSchema(schema, config)
 }
@@ -226,7 +435,12 @@ func updateSchemaFromData(schema *Schema, data interface{}, evolve bool) error {
 				if err := mergeFieldSchema(existingSchema, value); err != nil {
 					return err
 				}
-			} else if !exists {
+			} else if exists {
+				// Keep the first record's types, but learn the ones it could not show
+				if err := fillUnknownTypes(existingSchema, value); err != nil {
+					return err
+				}
+			} else {
 				// Add new field
 				fieldSchema, err := inferSchemaFromValue(value)
 				if err != nil {
@@ -292,6 +506,46 @@ func inferSchemaFromValue(value interface{}) (*Schema, error) {
 	return schema, nil
 }
 
+// fillUnknownTypes takes the type of a field that has only been null, or the
+// items of an array that has only been empty, from a later value
+func fillUnknownTypes(schema *Schema, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	if schema.Type == TypeNull {
+		inferred, err := inferSchemaFromValue(value)
+		if err != nil {
+			return err
+		}
+		*schema = *inferred
+		return nil
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		if schema.Type != TypeArray {
+			return nil
+		}
+		for _, item := range v {
+			if schema.Items == nil {
+				items, err := inferSchemaFromValue(item)
+				if err != nil {
+					return err
+				}
+				schema.Items = items
+			} else if err := fillUnknownTypes(schema.Items, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		for key, prop := range schema.Properties {
+			if err := fillUnknownTypes(prop, v[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // mergeFieldSchema merges schema information from a new value
 func mergeFieldSchema(schema *Schema, value interface{}) error {
 	newSchema, err := inferSchemaFromValue(value)
@@ -395,15 +649,9 @@ func validateData(config Config) error {
 		return fmt.Errorf("schema file required for validation")
 	}
 
-	// Load schema
-	schemaData, err := os.ReadFile(config.SchemaFile)
+	schema, err := Load(config.SchemaFile)
 	if err != nil {
-		return fmt.Errorf("error reading schema file: %w", err)
-	}
-
-	var schema Schema
-	if err := json.Unmarshal(schemaData, &schema); err != nil {
-		return fmt.Errorf("error parsing schema: %w", err)
+		return err
 	}
 
 	// Set up input source
@@ -438,8 +686,15 @@ func validateData(config Config) error {
 			continue
 		}
 
-		if err := validateValue(data, &schema, ""); err != nil {
-			fmt.Fprintf(os.Stderr, "Line %d: Validation error: %v\n", lineNumber, err)
+		if err := schema.Validate(data); err != nil {
+			var validationErr *jsonschema.ValidationError
+			if errors.As(err, &validationErr) {
+				for _, e := range validationErr.Errors {
+					fmt.Fprintf(os.Stderr, "Line %d: Validation error: %v\n", lineNumber, e)
+				}
+			} else {
+				fmt.Fprintf(os.Stderr, "Line %d: Validation error: %v\n", lineNumber, err)
+			}
 			errorCount++
 		} else {
 			validCount++
@@ -455,110 +710,85 @@ func validateData(config Config) error {
 	return nil
 }
 
-// validateValue validates a value against a schema
-func validateValue(value interface{}, schema *Schema, path string) error {
-	// Type validation
-	actualType := getValueType(value)
-	if schema.Type != "" && actualType != schema.Type {
-		return fmt.Errorf("type mismatch at %s: expected %s, got %s", path, schema.Type, actualType)
+// Load reads a schema from a JSON file
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema file: %w", err)
 	}
 
-	// Object validation
-	if schema.Type == TypeObject && schema.Properties != nil {
-		obj, ok := value.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("expected object at %s", path)
-		}
-
-		// Check required fields
-		for _, required := range schema.Required {
-			if _, exists := obj[required]; !exists {
-				return fmt.Errorf("missing required field: %s%s", path, required)
-			}
-		}
-
-		// Validate properties
-		for key, val := range obj {
-			if propSchema, exists := schema.Properties[key]; exists {
-				newPath := path + "." + key
-				if path == "" {
-					newPath = key
-				}
-				if err := validateValue(val, propSchema, newPath); err != nil {
-					return err
-				}
-			}
-		}
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("error parsing schema %s: %w", path, err)
 	}
+	if schema.compiled, err = jsonschema.Load(path); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
 
-	// Array validation
-	if schema.Type == TypeArray && schema.Items != nil {
-		arr, ok := value.([]interface{})
-		if !ok {
-			return fmt.Errorf("expected array at %s", path)
+// Validate checks a decoded JSON value against the schema with the draft
+// 2020-12 validator; errors are a *jsonschema.ValidationError
+func (s *Schema) Validate(value interface{}) error {
+	if s.compiled == nil {
+		data, err := json.Marshal(s)
+		if err != nil {
+			return fmt.Errorf("error marshaling schema: %w", err)
 		}
-
-		for i, item := range arr {
-			itemPath := fmt.Sprintf("%s[%d]", path, i)
-			if err := validateValue(item, schema.Items, itemPath); err != nil {
-				return err
-			}
+		if s.compiled, err = jsonschema.Compile(data); err != nil {
+			return err
 		}
 	}
-
-	return nil
+	return s.compiled.Validate(value)
 }
 
-// getValueType returns the SchemaType for a value
-func getValueType(value interface{}) SchemaType {
-	switch v := value.(type) {
-	case nil:
-		return TypeNull
-	case bool:
-		return TypeBoolean
-	case float64:
-		if v == float64(int64(v)) {
-			return TypeInteger
+// enumContains reports whether a decoded JSON value is one of the enum values
+func enumContains(values []interface{}, value interface{}) bool {
+	encoded, _ := json.Marshal(value)
+	for _, allowed := range values {
+		if candidate, _ := json.Marshal(allowed); string(candidate) == string(encoded) {
+			return true
 		}
-		return TypeNumber
-	case string:
-		return TypeString
-	case []interface{}:
-		return TypeArray
-	case map[string]interface{}:
-		return TypeObject
-	default:
-		return TypeString // fallback
 	}
+	return false
 }
 
 // mergeSchemas merges multiple schemas
 func mergeSchemas(config Config) error {
-	if config.SchemaFile == "" {
+	// Schema files come from --schema and the arguments after merge; globs are expanded
+	schemaFiles, err := expandSchemaFiles(append(config.SchemaFiles, config.Files...))
+	if err != nil {
+		return err
+	}
+	if len(schemaFiles) == 0 {
 		return fmt.Errorf("at least one schema file required for merging")
 	}
+	merger, err := newSchemaMerger(config.Strategy, config.ForceOptional)
+	if err != nil {
+		return err
+	}
 
-	// Parse schema files from args (they come after the --schema flag)
-	schemaFiles := []string{config.SchemaFile}
-	
 	// Load and merge all schemas
 	var mergedSchema *Schema
-	
+	sampleHash := sha256.New()
+
 	for i, schemaFile := range schemaFiles {
 		schemaData, err := os.ReadFile(schemaFile)
 		if err != nil {
 			return fmt.Errorf("error reading schema file %s: %w", schemaFile, err)
 		}
+		sampleHash.Write(schemaData)
 
 		var schema Schema
 		if err := json.Unmarshal(schemaData, &schema); err != nil {
 			return fmt.Errorf("error parsing schema %s: %w", schemaFile, err)
 		}
+		merger.observe(schemaFile, &schema)
 
 		if i == 0 {
 			mergedSchema = &schema
 		} else {
-			if err := mergeTwoSchemas(mergedSchema, &schema, config.ForceOptional); err != nil {
+			if err := mergeTwoSchemas(mergedSchema, &schema, merger, "$", schemaFile); err != nil {
 				return fmt.Errorf("error merging schema %s: %w", schemaFile, err)
 			}
 		}
@@ -567,19 +797,56 @@ func mergeSchemas(config Config) error {
 	if mergedSchema == nil {
 		return fmt.Errorf("no schemas to merge")
 	}
+	merger.checkProperties(mergedSchema)
+	if err := merger.report(); err != nil {
+		return err
+	}
 
 	// Update version
-	mergedSchema.Version = config.Version
+	if err := recordVersion(mergedSchema, config, "merge", len(schemaFiles), sampleHash); err != nil {
+		return err
+	}
 
 	// Output the merged schema
 	return outputSchema(mergedSchema, config)
 }
 
-// mergeTwoSchemas merges two schemas together
-func mergeTwoSchemas(target, source *Schema, forceOptional bool) error {
+// mergeTwoSchemas merges a source schema into the target, reporting conflicts
+// at the JSON path of the data they describe
+func mergeTwoSchemas(target, source *Schema, merger *schemaMerger, path, file string) error {
+	// A schema that is only ever null makes the other one nullable
+	if target.Type != source.Type && (target.Type == TypeNull || source.Type == TypeNull) {
+		examples := append(target.Examples, source.Examples...)
+		if target.Type == TypeNull {
+			if !source.Nullable {
+				merger.conflict(file, path, ConflictType, "null is not allowed here but is in earlier inputs; allowing null")
+			}
+			*target = *source
+		} else if !target.Nullable {
+			merger.conflict(file, path, ConflictType, "null is allowed here but not in earlier inputs; allowing null")
+		}
+		target.Nullable, target.Examples = true, examples
+		return nil
+	}
+
 	// Merge types - use more general type if different
-	if target.Type != source.Type {
-		target.Type = getCompatibleType(target.Type, source.Type)
+	typeChanged := target.Type != source.Type
+	if typeChanged {
+		merged := getCompatibleType(target.Type, source.Type)
+		merger.typeConflict(file, path, target.Type, source.Type, merged)
+		target.Type = merged
+	}
+	if target.Nullable != source.Nullable {
+		if source.Nullable {
+			merger.conflict(file, path, ConflictType, "null is allowed here but not in earlier inputs; allowing null")
+		} else {
+			merger.conflict(file, path, ConflictType, "null is not allowed here but is in earlier inputs; allowing null")
+		}
+	}
+	target.Nullable = target.Nullable || source.Nullable
+	mergeConstraints(target, source, merger, path, file)
+	if typeChanged {
+		dropStaleConstraints(target)
 	}
 
 	// Merge object properties
@@ -587,12 +854,16 @@ func mergeTwoSchemas(target, source *Schema, forceOptional bool) error {
 		if target.Properties == nil {
 			target.Properties = make(map[string]*Schema)
 		}
+		if !merger.forceOptional {
+			merger.requiredConflicts(target, source, path, file)
+		}
 
 		// Add properties from source
-		for key, sourceProp := range source.Properties {
+		for _, key := range sortedPropertyNames(source) {
+			sourceProp := source.Properties[key]
 			if targetProp, exists := target.Properties[key]; exists {
 				// Merge existing property
-				if err := mergeTwoSchemas(targetProp, sourceProp, forceOptional); err != nil {
+				if err := mergeTwoSchemas(targetProp, sourceProp, merger, path+"."+key, file); err != nil {
 					return err
 				}
 			} else {
@@ -602,7 +873,7 @@ func mergeTwoSchemas(target, source *Schema, forceOptional bool) error {
 		}
 
 		// Merge required fields
-		if forceOptional {
+		if merger.forceOptional {
 			// If force optional, clear required fields
 			target.Required = nil
 		} else {
@@ -614,7 +885,7 @@ func mergeTwoSchemas(target, source *Schema, forceOptional bool) error {
 	// Merge array items
 	if target.Type == TypeArray {
 		if target.Items != nil && source.Items != nil {
-			if err := mergeTwoSchemas(target.Items, source.Items, forceOptional); err != nil {
+			if err := mergeTwoSchemas(target.Items, source.Items, merger, path+"[*]", file); err != nil {
 				return err
 			}
 		} else if source.Items != nil {
@@ -630,6 +901,9 @@ func mergeTwoSchemas(target, source *Schema, forceOptional bool) error {
 		target.Metadata = make(map[string]interface{})
 	}
 	for key, value := range source.Metadata {
+		if key == "inferred" && typeChanged {
+			continue // sample statistics of the old type
+		}
 		target.Metadata[key] = value
 	}
 
@@ -652,4 +926,4 @@ func intersectStringSlices(a, b []string) []string {
 
 	sort.Strings(result)
 	return result
-}
\ No newline at end of file
+}
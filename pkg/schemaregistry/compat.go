@@ -0,0 +1,114 @@
+package schemaregistry
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/avro"
+)
+
+// checker reports why data written with writer cannot be read with reader
+type checker func(reader, writer string) ([]string, error)
+
+// checkers holds the compatibility checkers by schema type; other types are not checked
+var checkers = map[string]checker{
+	"AVRO": checkAvro,
+}
+
+// RegisterChecker adds the compatibility checker for a schema type. The check
+// returns the reasons data written with the writer schema cannot be read with
+// the reader schema.
+func RegisterChecker(schemaType string, check func(reader, writer string) ([]string, error)) {
+	checkers[normalizeType(schemaType)] = check
+}
+
+func checkAvro(reader, writer string) ([]string, error) {
+	readerSchema, err := avro.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+	writerSchema, err := avro.Parse(writer)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []string
+	for _, problem := range avro.CheckCompatibility(readerSchema, writerSchema) {
+		messages = append(messages, problem.String())
+	}
+	return messages, nil
+}
+
+// CanCheck reports whether compatibility of the schema type can be checked locally
+func CanCheck(schemaType string) bool {
+	_, ok := checkers[normalizeType(schemaType)]
+	return ok
+}
+
+// CheckLevel checks a candidate schema against previous versions (oldest first)
+// at a compatibility level, returning the reasons it is incompatible
+func CheckLevel(level string, previous []*Schema, candidate *Schema) ([]string, error) {
+	level = strings.ToUpper(level)
+	if err := ValidateCompatibilityLevel(level); err != nil {
+		return nil, err
+	}
+	if level == "NONE" || len(previous) == 0 {
+		return nil, nil
+	}
+
+	check, ok := checkers[normalizeType(candidate.Type)]
+	if !ok {
+		return nil, fmt.Errorf("compatibility of %s schemas cannot be checked", normalizeType(candidate.Type))
+	}
+
+	against := previous
+	if !strings.HasSuffix(level, "_TRANSITIVE") {
+		against = previous[len(previous)-1:]
+	}
+	backward := strings.HasPrefix(level, "BACKWARD") || strings.HasPrefix(level, "FULL")
+	forward := strings.HasPrefix(level, "FORWARD") || strings.HasPrefix(level, "FULL")
+
+	var messages []string
+	for i := len(against) - 1; i >= 0; i-- {
+		old := against[i]
+		if normalizeType(old.Type) != normalizeType(candidate.Type) {
+			messages = append(messages, fmt.Sprintf("%s: schema type changed from %s to %s",
+				versionLabel(old), normalizeType(old.Type), normalizeType(candidate.Type)))
+			continue
+		}
+		if backward {
+			problems, err := check(candidate.Schema, old.Schema)
+			if err != nil {
+				return nil, err
+			}
+			for _, problem := range problems {
+				messages = append(messages, fmt.Sprintf("cannot read data written with %s: %s", versionLabel(old), problem))
+			}
+		}
+		if forward {
+			problems, err := check(old.Schema, candidate.Schema)
+			if err != nil {
+				return nil, err
+			}
+			for _, problem := range problems {
+				messages = append(messages, fmt.Sprintf("%s cannot read data written with the new schema: %s", versionLabel(old), problem))
+			}
+		}
+	}
+	return messages, nil
+}
+
+func versionLabel(schema *Schema) string {
+	if schema.Version > 0 {
+		return fmt.Sprintf("version %d", schema.Version)
+	}
+	return schema.Subject
+}
+
+// normalizeType returns the upper-case schema type, AVRO when unset
+func normalizeType(schemaType string) string {
+	if schemaType == "" {
+		return "AVRO"
+	}
+	return strings.ToUpper(schemaType)
+}
@@ -2,6 +2,7 @@ package schemaregistry
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,29 +10,43 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/og-dim9/dimutils/pkg/restclient"
 )
 
 // Client represents a Confluent Schema Registry client
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
-	auth       *AuthConfig
-}
+	rest *restclient.Client
 
-// AuthConfig holds authentication configuration for Schema Registry
-type AuthConfig struct {
-	Username string
-	Password string
+	mu       sync.RWMutex
+	byID     map[int]*Schema    // schemas by global ID, which never change
+	versions map[string]*Schema // subject versions by subject and number
 }
 
+// AuthConfig holds authentication configuration for Schema Registry:
+// basic auth with Username and Password, or a BearerToken
+type AuthConfig = restclient.AuthConfig
+
+// TLSConfig holds client TLS settings for an https registry URL
+type TLSConfig = restclient.TLSConfig
+
 // Schema represents a schema in the registry
 type Schema struct {
-	ID       int    `json:"id"`
-	Version  int    `json:"version"`
-	Schema   string `json:"schema"`
-	Type     string `json:"schemaType,omitempty"`
-	Subject  string `json:"subject,omitempty"`
+	ID         int         `json:"id"`
+	Version    int         `json:"version,omitempty"` // 0 when fetched by ID
+	Schema     string      `json:"schema"`
+	Type       string      `json:"schemaType,omitempty"`
+	Subject    string      `json:"subject,omitempty"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// Reference is another registered schema that a schema refers to by name
+type Reference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
 }
 
 // Subject represents a subject in the registry
@@ -44,46 +59,76 @@ type Subject struct {
 // CompatibilityLevel represents schema compatibility settings
 type CompatibilityLevel struct {
 	Compatibility string `json:"compatibility"`
+	Level         string `json:"compatibilityLevel,omitempty"` // name used by GET /config
+}
+
+// CompatibilityResult is the outcome of a compatibility check
+type CompatibilityResult struct {
+	Compatible bool     `json:"is_compatible"`
+	Messages   []string `json:"messages,omitempty"`
+}
+
+// APIError is an error response from the schema registry
+type APIError = restclient.APIError
+
+// IsNotFound reports whether err is a 404 from the schema registry
+func IsNotFound(err error) bool {
+	return restclient.IsNotFound(err)
 }
 
 // Config holds schema registry configuration
 type Config struct {
-	URL     string
-	Timeout time.Duration
-	Auth    *AuthConfig
+	URL          string
+	Timeout      time.Duration
+	Auth         *AuthConfig
+	TLS          *TLSConfig
+	Retries      int           // retries of requests that fail with 429, a 5xx or a connection error
+	RetryBackoff time.Duration // delay before the first retry, doubled for each one after
 }
 
 // DefaultConfig returns default schema registry configuration
 func DefaultConfig() Config {
 	return Config{
-		URL:     "http://localhost:8081",
-		Timeout: 30 * time.Second,
+		URL:          "http://localhost:8081",
+		Timeout:      30 * time.Second,
+		Retries:      3,
+		RetryBackoff: 250 * time.Millisecond,
 	}
 }
 
-// NewClient creates a new Schema Registry client
+// NewClient creates a new Schema Registry client. Problems loading the TLS
+// files are reported by the client's first request.
 func NewClient(config Config) *Client {
-	httpClient := &http.Client{
-		Timeout: config.Timeout,
-	}
-
 	return &Client{
-		baseURL:    strings.TrimSuffix(config.URL, "/"),
-		httpClient: httpClient,
-		auth:       config.Auth,
+		rest: restclient.New(restclient.Config{
+			URL:          config.URL,
+			Timeout:      config.Timeout,
+			Auth:         config.Auth,
+			TLS:          config.TLS,
+			ContentType:  "application/vnd.schemaregistry.v1+json",
+			Retries:      config.Retries,
+			RetryBackoff: config.RetryBackoff,
+		}),
+		byID:     make(map[int]*Schema),
+		versions: make(map[string]*Schema),
 	}
 }
 
 // GetSubjects returns all subjects in the registry
 func (c *Client) GetSubjects() ([]string, error) {
-	resp, err := c.makeRequest("GET", "/subjects", nil)
+	return c.GetSubjectsContext(context.Background())
+}
+
+// GetSubjectsContext is like GetSubjects but uses ctx for its requests
+func (c *Client) GetSubjectsContext(ctx context.Context) ([]string, error) {
+	resp, err := c.makeRequest(ctx, "GET", "/subjects", nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get subjects: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to get subjects: %w", restclient.NewAPIError(resp))
 	}
 
 	var subjects []string
@@ -96,15 +141,20 @@ func (c *Client) GetSubjects() ([]string, error) {
 
 // GetSubjectVersions returns all versions for a subject
 func (c *Client) GetSubjectVersions(subject string) ([]int, error) {
+	return c.GetSubjectVersionsContext(context.Background(), subject)
+}
+
+// GetSubjectVersionsContext is like GetSubjectVersions but uses ctx for its requests
+func (c *Client) GetSubjectVersionsContext(ctx context.Context, subject string) ([]int, error) {
 	path := fmt.Sprintf("/subjects/%s/versions", url.PathEscape(subject))
-	resp, err := c.makeRequest("GET", path, nil)
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get subject versions: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to get subject versions: %w", restclient.NewAPIError(resp))
 	}
 
 	var versions []int
@@ -117,6 +167,11 @@ func (c *Client) GetSubjectVersions(subject string) ([]int, error) {
 
 // GetSchema returns a schema by subject and version
 func (c *Client) GetSchema(subject string, version interface{}) (*Schema, error) {
+	return c.GetSchemaContext(context.Background(), subject, version)
+}
+
+// GetSchemaContext is like GetSchema but uses ctx for its requests
+func (c *Client) GetSchemaContext(ctx context.Context, subject string, version interface{}) (*Schema, error) {
 	var versionStr string
 	switch v := version.(type) {
 	case int:
@@ -127,16 +182,23 @@ func (c *Client) GetSchema(subject string, version interface{}) (*Schema, error)
 		return nil, fmt.Errorf("version must be int or string")
 	}
 
+	// Numbered versions never change; "latest" has to be asked for each time
+	if number, err := strconv.Atoi(versionStr); err == nil && number > 0 {
+		if cached := c.cachedVersion(versionKey(subject, number)); cached != nil {
+			return cached, nil
+		}
+	}
+
 	path := fmt.Sprintf("/subjects/%s/versions/%s", 
 		url.PathEscape(subject), url.PathEscape(versionStr))
-	resp, err := c.makeRequest("GET", path, nil)
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get schema: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to get schema: %w", restclient.NewAPIError(resp))
 	}
 
 	var schema Schema
@@ -145,20 +207,30 @@ func (c *Client) GetSchema(subject string, version interface{}) (*Schema, error)
 	}
 
 	schema.Subject = subject
+	c.cache(&schema)
 	return &schema, nil
 }
 
 // GetSchemaByID returns a schema by its ID
 func (c *Client) GetSchemaByID(id int) (*Schema, error) {
+	return c.GetSchemaByIDContext(context.Background(), id)
+}
+
+// GetSchemaByIDContext is like GetSchemaByID but uses ctx for its requests
+func (c *Client) GetSchemaByIDContext(ctx context.Context, id int) (*Schema, error) {
+	if cached := c.cachedByID(id); cached != nil {
+		return cached, nil
+	}
+
 	path := fmt.Sprintf("/schemas/ids/%d", id)
-	resp, err := c.makeRequest("GET", path, nil)
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get schema by ID: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to get schema by ID: %w", restclient.NewAPIError(resp))
 	}
 
 	var schema Schema
@@ -167,11 +239,17 @@ func (c *Client) GetSchemaByID(id int) (*Schema, error) {
 	}
 
 	schema.ID = id
+	c.cache(&schema)
 	return &schema, nil
 }
 
-// RegisterSchema registers a new schema for a subject
-func (c *Client) RegisterSchema(subject, schema, schemaType string) (*Schema, error) {
+// RegisterSchema registers a new schema for a subject, with the schemas it refers to
+func (c *Client) RegisterSchema(subject, schema, schemaType string, references ...Reference) (*Schema, error) {
+	return c.RegisterSchemaContext(context.Background(), subject, schema, schemaType, references...)
+}
+
+// RegisterSchemaContext is like RegisterSchema but uses ctx for its requests
+func (c *Client) RegisterSchemaContext(ctx context.Context, subject, schema, schemaType string, references ...Reference) (*Schema, error) {
 	if schemaType == "" {
 		schemaType = "AVRO"
 	}
@@ -180,6 +258,9 @@ func (c *Client) RegisterSchema(subject, schema, schemaType string) (*Schema, er
 		"schema":     schema,
 		"schemaType": schemaType,
 	}
+	if len(references) > 0 {
+		payload["references"] = references
+	}
 
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
@@ -187,14 +268,14 @@ func (c *Client) RegisterSchema(subject, schema, schemaType string) (*Schema, er
 	}
 
 	path := fmt.Sprintf("/subjects/%s/versions", url.PathEscape(subject))
-	resp, err := c.makeRequest("POST", path, bytes.NewReader(payloadBytes))
+	resp, err := c.makeRequest(ctx, "POST", path, bytes.NewReader(payloadBytes))
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to register schema: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to register schema: %w", restclient.NewAPIError(resp))
 	}
 
 	var result map[string]interface{}
@@ -208,29 +289,36 @@ func (c *Client) RegisterSchema(subject, schema, schemaType string) (*Schema, er
 	}
 
 	return &Schema{
-		ID:      int(id),
-		Schema:  schema,
-		Type:    schemaType,
-		Subject: subject,
+		ID:         int(id),
+		Schema:     schema,
+		Type:       schemaType,
+		Subject:    subject,
+		References: references,
 	}, nil
 }
 
 // DeleteSubject deletes a subject and all its versions
 func (c *Client) DeleteSubject(subject string, permanent bool) ([]int, error) {
+	return c.DeleteSubjectContext(context.Background(), subject, permanent)
+}
+
+// DeleteSubjectContext is like DeleteSubject but uses ctx for its requests
+func (c *Client) DeleteSubjectContext(ctx context.Context, subject string, permanent bool) ([]int, error) {
 	path := fmt.Sprintf("/subjects/%s", url.PathEscape(subject))
 	if permanent {
 		path += "?permanent=true"
 	}
 
-	resp, err := c.makeRequest("DELETE", path, nil)
+	resp, err := c.makeRequest(ctx, "DELETE", path, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to delete subject: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to delete subject: %w", restclient.NewAPIError(resp))
 	}
+	c.forget(subject)
 
 	var versions []int
 	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
@@ -242,48 +330,67 @@ func (c *Client) DeleteSubject(subject string, permanent bool) ([]int, error) {
 
 // DeleteSubjectVersion deletes a specific version of a subject
 func (c *Client) DeleteSubjectVersion(subject string, version int, permanent bool) error {
+	return c.DeleteSubjectVersionContext(context.Background(), subject, version, permanent)
+}
+
+// DeleteSubjectVersionContext is like DeleteSubjectVersion but uses ctx for its requests
+func (c *Client) DeleteSubjectVersionContext(ctx context.Context, subject string, version int, permanent bool) error {
 	path := fmt.Sprintf("/subjects/%s/versions/%d", 
 		url.PathEscape(subject), version)
 	if permanent {
 		path += "?permanent=true"
 	}
 
-	resp, err := c.makeRequest("DELETE", path, nil)
+	resp, err := c.makeRequest(ctx, "DELETE", path, nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to delete subject version: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("failed to delete subject version: %w", restclient.NewAPIError(resp))
 	}
+	c.forget(subject)
 
 	return nil
 }
 
 // GetCompatibility returns the compatibility level for a subject
 func (c *Client) GetCompatibility(subject string) (*CompatibilityLevel, error) {
+	return c.GetCompatibilityContext(context.Background(), subject)
+}
+
+// GetCompatibilityContext is like GetCompatibility but uses ctx for its requests
+func (c *Client) GetCompatibilityContext(ctx context.Context, subject string) (*CompatibilityLevel, error) {
 	path := fmt.Sprintf("/config/%s", url.PathEscape(subject))
-	resp, err := c.makeRequest("GET", path, nil)
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get compatibility: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to get compatibility: %w", restclient.NewAPIError(resp))
 	}
 
 	var compat CompatibilityLevel
 	if err := json.NewDecoder(resp.Body).Decode(&compat); err != nil {
 		return nil, fmt.Errorf("failed to decode compatibility response: %w", err)
 	}
+	if compat.Compatibility == "" {
+		compat.Compatibility = compat.Level
+	}
 
 	return &compat, nil
 }
 
 // SetCompatibility sets the compatibility level for a subject
 func (c *Client) SetCompatibility(subject, level string) error {
+	return c.SetCompatibilityContext(context.Background(), subject, level)
+}
+
+// SetCompatibilityContext is like SetCompatibility but uses ctx for its requests
+func (c *Client) SetCompatibilityContext(ctx context.Context, subject, level string) error {
 	payload := map[string]string{
 		"compatibility": level,
 	}
@@ -294,21 +401,41 @@ func (c *Client) SetCompatibility(subject, level string) error {
 	}
 
 	path := fmt.Sprintf("/config/%s", url.PathEscape(subject))
-	resp, err := c.makeRequest("PUT", path, bytes.NewReader(payloadBytes))
+	resp, err := c.makeRequest(ctx, "PUT", path, bytes.NewReader(payloadBytes))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to set compatibility: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("failed to set compatibility: %w", restclient.NewAPIError(resp))
 	}
 
 	return nil
 }
 
 // TestCompatibility tests if a schema is compatible with the latest version
-func (c *Client) TestCompatibility(subject, schema, schemaType string) (bool, error) {
+func (c *Client) TestCompatibility(subject, schema, schemaType string, references ...Reference) (bool, error) {
+	return c.TestCompatibilityContext(context.Background(), subject, schema, schemaType, references...)
+}
+
+// TestCompatibilityContext is like TestCompatibility but uses ctx for its requests
+func (c *Client) TestCompatibilityContext(ctx context.Context, subject, schema, schemaType string, references ...Reference) (bool, error) {
+	result, err := c.CheckCompatibilityContext(ctx, subject, "latest", schema, schemaType, references...)
+	if err != nil {
+		return false, err
+	}
+	return result.Compatible, nil
+}
+
+// CheckCompatibility tests a schema against a version of a subject ("latest" or
+// a number), returning the registry's reasons when it is incompatible
+func (c *Client) CheckCompatibility(subject, version, schema, schemaType string, references ...Reference) (*CompatibilityResult, error) {
+	return c.CheckCompatibilityContext(context.Background(), subject, version, schema, schemaType, references...)
+}
+
+// CheckCompatibilityContext is like CheckCompatibility but uses ctx for its requests
+func (c *Client) CheckCompatibilityContext(ctx context.Context, subject, version, schema, schemaType string, references ...Reference) (*CompatibilityResult, error) {
 	if schemaType == "" {
 		schemaType = "AVRO"
 	}
@@ -317,54 +444,70 @@ func (c *Client) TestCompatibility(subject, schema, schemaType string) (bool, er
 		"schema":     schema,
 		"schemaType": schemaType,
 	}
+	if len(references) > 0 {
+		payload["references"] = references
+	}
 
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		return false, fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	path := fmt.Sprintf("/compatibility/subjects/%s/versions/latest", 
-		url.PathEscape(subject))
-	resp, err := c.makeRequest("POST", path, bytes.NewReader(payloadBytes))
+	path := fmt.Sprintf("/compatibility/subjects/%s/versions/%s?verbose=true",
+		url.PathEscape(subject), url.PathEscape(version))
+	resp, err := c.makeRequest(ctx, "POST", path, bytes.NewReader(payloadBytes))
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusOK {
-		var result map[string]interface{}
-		if err := json.NewDecoder(resp.Body).Decode(&result); err == nil {
-			if compatible, ok := result["is_compatible"].(bool); ok {
-				return compatible, nil
-			}
-		}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to test compatibility: %w", restclient.NewAPIError(resp))
+	}
+
+	var result CompatibilityResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode compatibility response: %w", err)
 	}
 
-	return false, fmt.Errorf("failed to test compatibility: HTTP %d", resp.StatusCode)
+	return &result, nil
 }
 
 // GetGlobalCompatibility returns the global compatibility level
 func (c *Client) GetGlobalCompatibility() (*CompatibilityLevel, error) {
-	resp, err := c.makeRequest("GET", "/config", nil)
+	return c.GetGlobalCompatibilityContext(context.Background())
+}
+
+// GetGlobalCompatibilityContext is like GetGlobalCompatibility but uses ctx for its requests
+func (c *Client) GetGlobalCompatibilityContext(ctx context.Context) (*CompatibilityLevel, error) {
+	resp, err := c.makeRequest(ctx, "GET", "/config", nil)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to get global compatibility: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("failed to get global compatibility: %w", restclient.NewAPIError(resp))
 	}
 
 	var compat CompatibilityLevel
 	if err := json.NewDecoder(resp.Body).Decode(&compat); err != nil {
 		return nil, fmt.Errorf("failed to decode compatibility response: %w", err)
 	}
+	if compat.Compatibility == "" {
+		compat.Compatibility = compat.Level
+	}
 
 	return &compat, nil
 }
 
 // SetGlobalCompatibility sets the global compatibility level
 func (c *Client) SetGlobalCompatibility(level string) error {
+	return c.SetGlobalCompatibilityContext(context.Background(), level)
+}
+
+// SetGlobalCompatibilityContext is like SetGlobalCompatibility but uses ctx for its requests
+func (c *Client) SetGlobalCompatibilityContext(ctx context.Context, level string) error {
 	payload := map[string]string{
 		"compatibility": level,
 	}
@@ -374,58 +517,297 @@ func (c *Client) SetGlobalCompatibility(level string) error {
 		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
 
-	resp, err := c.makeRequest("PUT", "/config", bytes.NewReader(payloadBytes))
+	resp, err := c.makeRequest(ctx, "PUT", "/config", bytes.NewReader(payloadBytes))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to set global compatibility: %w", restclient.NewAPIError(resp))
+	}
+
+	return nil
+}
+
+// LookupSchema returns the version of a subject that holds a schema
+func (c *Client) LookupSchema(subject, schema, schemaType string, references []Reference) (*Schema, error) {
+	return c.LookupSchemaContext(context.Background(), subject, schema, schemaType, references)
+}
+
+// LookupSchemaContext is like LookupSchema but uses ctx for its requests
+func (c *Client) LookupSchemaContext(ctx context.Context, subject, schema, schemaType string, references []Reference) (*Schema, error) {
+	payloadBytes, err := json.Marshal(map[string]interface{}{
+		"schema":     schema,
+		"schemaType": schemaType,
+		"references": references,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	path := fmt.Sprintf("/subjects/%s", url.PathEscape(subject))
+	resp, err := c.makeRequest(ctx, "POST", path, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to look up schema: %w", restclient.NewAPIError(resp))
+	}
+
+	var found Schema
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return nil, fmt.Errorf("failed to decode lookup response: %w", err)
+	}
+	found.Subject = subject
+	return &found, nil
+}
+
+// ImportSchema registers a schema with its references, keeping its ID and
+// version when they are set; those need the subject to be in IMPORT mode
+func (c *Client) ImportSchema(schema *Schema) (int, error) {
+	return c.ImportSchemaContext(context.Background(), schema)
+}
+
+// ImportSchemaContext is like ImportSchema but uses ctx for its requests
+func (c *Client) ImportSchemaContext(ctx context.Context, schema *Schema) (int, error) {
+	payload := map[string]interface{}{
+		"schema":     schema.Schema,
+		"schemaType": schema.Type,
+	}
+	if len(schema.References) > 0 {
+		payload["references"] = schema.References
+	}
+	if schema.ID > 0 {
+		payload["id"] = schema.ID
+	}
+	if schema.Version > 0 {
+		payload["version"] = schema.Version
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	path := fmt.Sprintf("/subjects/%s/versions", url.PathEscape(schema.Subject))
+	resp, err := c.makeRequest(ctx, "POST", path, bytes.NewReader(payloadBytes))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to import schema: %w", restclient.NewAPIError(resp))
+	}
+
+	var result struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode registration response: %w", err)
+	}
+	return result.ID, nil
+}
+
+// GetMode returns the mode of a subject, or the global mode when subject is empty
+func (c *Client) GetMode(subject string) (string, error) {
+	return c.GetModeContext(context.Background(), subject)
+}
+
+// GetModeContext is like GetMode but uses ctx for its requests
+func (c *Client) GetModeContext(ctx context.Context, subject string) (string, error) {
+	path := "/mode"
+	if subject != "" {
+		path += "/" + url.PathEscape(subject)
+	}
+	resp, err := c.makeRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get mode: %w", restclient.NewAPIError(resp))
+	}
+
+	var result struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode mode response: %w", err)
+	}
+	return result.Mode, nil
+}
+
+// SetMode sets the mode (READWRITE, READONLY or IMPORT) of a subject, or the
+// global mode when subject is empty; force allows IMPORT on non-empty subjects
+func (c *Client) SetMode(subject, mode string, force bool) error {
+	return c.SetModeContext(context.Background(), subject, mode, force)
+}
+
+// SetModeContext is like SetMode but uses ctx for its requests
+func (c *Client) SetModeContext(ctx context.Context, subject, mode string, force bool) error {
+	payloadBytes, err := json.Marshal(map[string]string{"mode": mode})
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	path := "/mode"
+	if subject != "" {
+		path += "/" + url.PathEscape(subject)
+	}
+	if force {
+		path += "?force=true"
+	}
+	resp, err := c.makeRequest(ctx, "PUT", path, bytes.NewReader(payloadBytes))
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to set global compatibility: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("failed to set mode: %w", restclient.NewAPIError(resp))
+	}
+	return nil
+}
+
+// DeleteMode removes a subject's mode so the global mode applies again
+func (c *Client) DeleteMode(subject string) error {
+	return c.DeleteModeContext(context.Background(), subject)
+}
+
+// DeleteModeContext is like DeleteMode but uses ctx for its requests
+func (c *Client) DeleteModeContext(ctx context.Context, subject string) error {
+	path := fmt.Sprintf("/mode/%s", url.PathEscape(subject))
+	resp, err := c.makeRequest(ctx, "DELETE", path, nil)
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete mode: %w", restclient.NewAPIError(resp))
+	}
 	return nil
 }
 
 // HealthCheck performs a health check on the schema registry
 func (c *Client) HealthCheck() error {
-	resp, err := c.makeRequest("GET", "/", nil)
+	return c.HealthCheckContext(context.Background())
+}
+
+// HealthCheckContext is like HealthCheck but uses ctx for its requests
+func (c *Client) HealthCheckContext(ctx context.Context) error {
+	resp, err := c.makeRequest(ctx, "GET", "/", nil)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("schema registry health check failed: HTTP %d", resp.StatusCode)
+		return fmt.Errorf("schema registry health check failed: %w", restclient.NewAPIError(resp))
 	}
 
 	return nil
 }
 
-// makeRequest makes an HTTP request to the schema registry
-func (c *Client) makeRequest(method, path string, body io.Reader) (*http.Response, error) {
-	url := c.baseURL + path
+// ResolveReferences fetches the schemas a schema refers to, and the ones
+// those refer to, keyed by reference name (e.g. the import path of a .proto file)
+func (c *Client) ResolveReferences(schema *Schema) (map[string]*Schema, error) {
+	return c.ResolveReferencesContext(context.Background(), schema)
+}
 
-	req, err := http.NewRequest(method, url, body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+// ResolveReferencesContext is like ResolveReferences but uses ctx for its requests
+func (c *Client) ResolveReferencesContext(ctx context.Context, schema *Schema) (map[string]*Schema, error) {
+	resolved := make(map[string]*Schema)
+	if err := c.resolveReferences(ctx, schema, resolved, map[string]bool{}); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+func (c *Client) resolveReferences(ctx context.Context, schema *Schema, resolved map[string]*Schema, visiting map[string]bool) error {
+	for _, ref := range schema.References {
+		if _, done := resolved[ref.Name]; done {
+			continue
+		}
+		key := versionKey(ref.Subject, ref.Version)
+		if visiting[key] {
+			return fmt.Errorf("schema reference cycle at %s version %d", ref.Subject, ref.Version)
+		}
+		visiting[key] = true
+
+		referenced, err := c.GetSchemaContext(ctx, ref.Subject, ref.Version)
+		if err != nil {
+			return fmt.Errorf("failed to resolve reference %s: %w", ref.Name, err)
+		}
+		resolved[ref.Name] = referenced
+		if err := c.resolveReferences(ctx, referenced, resolved, visiting); err != nil {
+			return err
+		}
+		delete(visiting, key)
 	}
+	return nil
+}
+
+// cachedByID returns a copy of a cached schema, or nil
+func (c *Client) cachedByID(id int) *Schema {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copySchema(c.byID[id])
+}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
-	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+// cachedVersion returns a copy of a cached subject version, or nil
+func (c *Client) cachedVersion(key string) *Schema {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return copySchema(c.versions[key])
+}
 
-	// Add authentication if configured
-	if c.auth != nil {
-		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+// cache remembers a fetched schema by ID and, when it has one, subject version
+func (c *Client) cache(schema *Schema) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if schema.ID > 0 {
+		byID := copySchema(schema)
+		byID.Subject, byID.Version = "", 0
+		c.byID[schema.ID] = byID
+	}
+	if schema.Subject != "" && schema.Version > 0 {
+		c.versions[versionKey(schema.Subject, schema.Version)] = copySchema(schema)
 	}
+}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+func copySchema(schema *Schema) *Schema {
+	if schema == nil {
+		return nil
 	}
+	copied := *schema
+	copied.References = append([]Reference(nil), schema.References...)
+	return &copied
+}
+
+func versionKey(subject string, version int) string {
+	return fmt.Sprintf("%s\x00%d", subject, version)
+}
+
+// forget drops the cached versions of a subject after it changes
+func (c *Client) forget(subject string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.versions {
+		if strings.HasPrefix(key, subject+"\x00") {
+			delete(c.versions, key)
+		}
+	}
+}
 
-	return resp, nil
+// makeRequest makes an HTTP request to the schema registry, retrying when the
+// registry is unavailable or rate limiting; every registry call is safe to repeat
+func (c *Client) makeRequest(ctx context.Context, method, path string, body io.Reader) (*http.Response, error) {
+	return c.rest.Do(ctx, method, path, body)
 }
 
 // ValidateSchemaType validates if the schema type is supported
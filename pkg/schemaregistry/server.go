@@ -0,0 +1,387 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server serves the Confluent Schema Registry REST API from a local directory.
+// Schema IDs are global, identical schemas share an ID, and registrations are
+// checked against the subject's compatibility level. Types without a checker
+// can only be added to a subject whose level is NONE.
+type Server struct {
+	Verbose bool
+
+	mu    sync.Mutex
+	store *store
+	mux   *http.ServeMux
+}
+
+// schemaRequest is the body of register, lookup and compatibility requests
+type schemaRequest struct {
+	Schema     string      `json:"schema"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	References []Reference `json:"references,omitempty"`
+	ID         int         `json:"id,omitempty"`      // IMPORT mode only
+	Version    int         `json:"version,omitempty"` // IMPORT mode only
+}
+
+// candidate returns the schema a request carries
+func (r *schemaRequest) candidate() *Schema {
+	return &Schema{Schema: r.Schema, Type: r.SchemaType, References: r.References}
+}
+
+// schemaResponse is a schema as the registry returns it; AVRO is the implied type
+type schemaResponse struct {
+	Subject    string      `json:"subject,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	ID         int         `json:"id,omitempty"`
+	SchemaType string      `json:"schemaType,omitempty"`
+	References []Reference `json:"references,omitempty"`
+	Schema     string      `json:"schema"`
+}
+
+func newSchemaResponse(schema *Schema) schemaResponse {
+	response := schemaResponse{Subject: schema.Subject, Version: schema.Version, ID: schema.ID,
+		References: schema.References, Schema: schema.Schema}
+	if schema.Type != "AVRO" {
+		response.SchemaType = schema.Type
+	}
+	return response
+}
+
+// NewServer opens (or creates) the registry stored in dir
+func NewServer(dir string) (*Server, error) {
+	st, err := openStore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{store: st, mux: http.NewServeMux()}
+	s.routes()
+	return s, nil
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{})
+	})
+	s.mux.HandleFunc("GET /schemas/types", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, []string{"JSON", "PROTOBUF", "AVRO"})
+	})
+
+	s.handle("GET /subjects", s.listSubjects)
+	s.handle("GET /subjects/{subject}/versions", s.listVersions)
+	s.handle("POST /subjects/{subject}/versions", s.register)
+	s.handle("GET /subjects/{subject}/versions/{version}", s.getVersion)
+	s.handle("GET /subjects/{subject}/versions/{version}/schema", s.getVersionSchema)
+	s.handle("DELETE /subjects/{subject}/versions/{version}", s.deleteVersion)
+	s.handle("POST /subjects/{subject}", s.lookup)
+	s.handle("DELETE /subjects/{subject}", s.deleteSubject)
+
+	s.handle("GET /schemas/ids/{id}", s.getSchema)
+	s.handle("GET /schemas/ids/{id}/schema", s.getRawSchema)
+	s.handle("GET /schemas/ids/{id}/versions", s.getSchemaVersions)
+	s.handle("GET /schemas/ids/{id}/subjects", s.getSchemaSubjects)
+
+	s.handle("GET /config", s.getConfig)
+	s.handle("PUT /config", s.setConfig)
+	s.handle("DELETE /config", s.deleteConfig)
+	s.handle("GET /config/{subject}", s.getConfig)
+	s.handle("PUT /config/{subject}", s.setConfig)
+	s.handle("DELETE /config/{subject}", s.deleteConfig)
+
+	s.handle("GET /mode", s.getMode)
+	s.handle("PUT /mode", s.setMode)
+	s.handle("DELETE /mode", s.deleteMode)
+	s.handle("GET /mode/{subject}", s.getMode)
+	s.handle("PUT /mode/{subject}", s.setMode)
+	s.handle("DELETE /mode/{subject}", s.deleteMode)
+
+	s.handle("POST /compatibility/subjects/{subject}/versions/{version}", s.testCompatibility)
+	s.handle("POST /compatibility/subjects/{subject}/versions", s.testCompatibility)
+
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, newAPIErrorf(http.StatusNotFound, http.StatusNotFound, "HTTP 404 Not Found"))
+	})
+}
+
+// handle registers a handler that runs with the store locked and writes its result or error
+func (s *Server) handle(pattern string, handler func(r *http.Request) (int, interface{}, *APIError)) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		status, result, apiErr := handler(r)
+		s.mu.Unlock()
+
+		if apiErr != nil {
+			writeError(w, apiErr)
+			return
+		}
+		if raw, ok := result.(string); ok {
+			// Raw schema text
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.WriteHeader(status)
+			w.Write([]byte(raw))
+			return
+		}
+		writeJSON(w, status, result)
+	})
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.Verbose {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	s.mux.ServeHTTP(recorder, r)
+	log.Printf("%s %s -> %d", r.Method, r.URL.RequestURI(), recorder.status)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, apiErr *APIError) {
+	writeJSON(w, apiErr.StatusCode, apiErr)
+}
+
+func boolParam(r *http.Request, name string) bool {
+	value, _ := strconv.ParseBool(r.URL.Query().Get(name))
+	return value
+}
+
+// readSchemaRequest decodes a request body and normalizes its schema type
+func readSchemaRequest(r *http.Request) (*schemaRequest, *APIError) {
+	var request schemaRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema, "Invalid request body: %v", err)
+	}
+	request.SchemaType = normalizeType(request.SchemaType)
+	if err := ValidateSchemaType(request.SchemaType); err != nil {
+		return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema, "%v", err)
+	}
+	return &request, nil
+}
+
+func schemaID(r *http.Request) (int, *APIError) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, newAPIErrorf(http.StatusNotFound, errSchemaNotFound, "Schema %s not found", r.PathValue("id"))
+	}
+	return id, nil
+}
+
+func (s *Server) listSubjects(r *http.Request) (int, interface{}, *APIError) {
+	return http.StatusOK, s.store.subjects(boolParam(r, "deleted")), nil
+}
+
+func (s *Server) listVersions(r *http.Request) (int, interface{}, *APIError) {
+	versions, apiErr := s.store.versions(r.PathValue("subject"), boolParam(r, "deleted"))
+	return http.StatusOK, versions, apiErr
+}
+
+func (s *Server) register(r *http.Request) (int, interface{}, *APIError) {
+	request, apiErr := readSchemaRequest(r)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	id, apiErr := s.store.register(r.PathValue("subject"), request.candidate(), request.ID, request.Version)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]int{"id": id}, nil
+}
+
+func (s *Server) getVersion(r *http.Request) (int, interface{}, *APIError) {
+	schema, apiErr := s.store.version(r.PathValue("subject"), r.PathValue("version"), boolParam(r, "deleted"))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, newSchemaResponse(schema), nil
+}
+
+func (s *Server) getVersionSchema(r *http.Request) (int, interface{}, *APIError) {
+	schema, apiErr := s.store.version(r.PathValue("subject"), r.PathValue("version"), boolParam(r, "deleted"))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, schema.Schema, nil
+}
+
+func (s *Server) deleteVersion(r *http.Request) (int, interface{}, *APIError) {
+	version, apiErr := s.store.deleteVersion(r.PathValue("subject"), r.PathValue("version"), boolParam(r, "permanent"))
+	return http.StatusOK, version, apiErr
+}
+
+func (s *Server) lookup(r *http.Request) (int, interface{}, *APIError) {
+	request, apiErr := readSchemaRequest(r)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	schema, apiErr := s.store.lookup(r.PathValue("subject"), request.candidate())
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, newSchemaResponse(schema), nil
+}
+
+func (s *Server) deleteSubject(r *http.Request) (int, interface{}, *APIError) {
+	versions, apiErr := s.store.deleteSubject(r.PathValue("subject"), boolParam(r, "permanent"))
+	return http.StatusOK, versions, apiErr
+}
+
+func (s *Server) getSchema(r *http.Request) (int, interface{}, *APIError) {
+	id, apiErr := schemaID(r)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	stored, apiErr := s.store.schemaByID(id)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, newSchemaResponse(stored.version("", 0)), nil
+}
+
+func (s *Server) getRawSchema(r *http.Request) (int, interface{}, *APIError) {
+	id, apiErr := schemaID(r)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	stored, apiErr := s.store.schemaByID(id)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, stored.Schema, nil
+}
+
+func (s *Server) getSchemaVersions(r *http.Request) (int, interface{}, *APIError) {
+	id, apiErr := schemaID(r)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	if _, apiErr := s.store.schemaByID(id); apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, s.store.usages(id), nil
+}
+
+func (s *Server) getSchemaSubjects(r *http.Request) (int, interface{}, *APIError) {
+	id, apiErr := schemaID(r)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	if _, apiErr := s.store.schemaByID(id); apiErr != nil {
+		return 0, nil, apiErr
+	}
+	subjects := []string{}
+	for _, usage := range s.store.usages(id) {
+		subject := usage["subject"].(string)
+		if len(subjects) == 0 || subjects[len(subjects)-1] != subject {
+			subjects = append(subjects, subject)
+		}
+	}
+	return http.StatusOK, subjects, nil
+}
+
+func (s *Server) getConfig(r *http.Request) (int, interface{}, *APIError) {
+	subject := r.PathValue("subject")
+	level, apiErr := s.store.getConfig(subject)
+	if apiErr != nil && subject != "" && boolParam(r, "defaultToGlobal") {
+		level, apiErr = s.store.getConfig("")
+	}
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]string{"compatibilityLevel": level}, nil
+}
+
+func (s *Server) setConfig(r *http.Request) (int, interface{}, *APIError) {
+	var request CompatibilityLevel
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return 0, nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidCompatibility, "Invalid request body: %v", err)
+	}
+	level, apiErr := s.store.setConfig(r.PathValue("subject"), strings.TrimSpace(request.Compatibility))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]string{"compatibility": level}, nil
+}
+
+func (s *Server) deleteConfig(r *http.Request) (int, interface{}, *APIError) {
+	level, apiErr := s.store.deleteConfig(r.PathValue("subject"))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]string{"compatibilityLevel": level}, nil
+}
+
+func (s *Server) getMode(r *http.Request) (int, interface{}, *APIError) {
+	subject := r.PathValue("subject")
+	mode, apiErr := s.store.getMode(subject)
+	if apiErr != nil && subject != "" && boolParam(r, "defaultToGlobal") {
+		mode, apiErr = s.store.getMode("")
+	}
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]string{"mode": mode}, nil
+}
+
+func (s *Server) setMode(r *http.Request) (int, interface{}, *APIError) {
+	var request struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return 0, nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidMode, "Invalid request body: %v", err)
+	}
+	mode, apiErr := s.store.setMode(r.PathValue("subject"), strings.TrimSpace(request.Mode), boolParam(r, "force"))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]string{"mode": mode}, nil
+}
+
+func (s *Server) deleteMode(r *http.Request) (int, interface{}, *APIError) {
+	mode, apiErr := s.store.deleteMode(r.PathValue("subject"))
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	return http.StatusOK, map[string]string{"mode": mode}, nil
+}
+
+func (s *Server) testCompatibility(r *http.Request) (int, interface{}, *APIError) {
+	request, apiErr := readSchemaRequest(r)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	version := r.PathValue("version")
+	if version == "" {
+		version = "latest"
+	}
+	result, apiErr := s.store.compatibility(r.PathValue("subject"), version, request.candidate())
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	if !boolParam(r, "verbose") {
+		result.Messages = nil
+	}
+	return http.StatusOK, result, nil
+}
@@ -0,0 +1,165 @@
+package schemaregistry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	avroV1      = `{"type":"record","name":"User","fields":[{"name":"name","type":"string"}]}`
+	avroAdded   = `{"type":"record","name":"User","fields":[{"name":"name","type":"string"},{"name":"age","type":"int"}]}`
+	avroDefault = `{"type":"record","name":"User","fields":[{"name":"name","type":"string"},{"name":"age","type":"int","default":0}]}`
+)
+
+// newTestRegistry starts a registry server on a temporary directory and
+// returns a client for it
+func newTestRegistry(t *testing.T) *Client {
+	t.Helper()
+	registry, err := NewServer(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(registry)
+	t.Cleanup(server.Close)
+
+	config := DefaultConfig()
+	config.URL = server.URL
+	config.Retries = 0
+	return NewClient(config)
+}
+
+// apiError returns the registry error wrapped in err, failing the test when there is none
+func apiError(t *testing.T, err error) *APIError {
+	t.Helper()
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected a registry error, got %v", err)
+	}
+	return apiErr
+}
+
+func TestRegisterRejectsIncompatibleAvro(t *testing.T) {
+	client := newTestRegistry(t)
+	if _, err := client.RegisterSchema("users-value", avroV1, "AVRO"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.RegisterSchema("users-value", avroAdded, "AVRO")
+	apiErr := apiError(t, err)
+	if apiErr.StatusCode != http.StatusConflict || apiErr.ErrorCode != errIncompatibleSchema {
+		t.Fatalf("expected 409 incompatible schema, got %v", apiErr)
+	}
+
+	versions, err := client.GetSubjectVersions("users-value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 1 {
+		t.Fatalf("expected the rejected schema not to be stored, got versions %v", versions)
+	}
+
+	if _, err := client.RegisterSchema("users-value", avroDefault, "AVRO"); err != nil {
+		t.Fatalf("expected a field with a default to be compatible: %v", err)
+	}
+}
+
+func TestRegisterHonoursSubjectLevel(t *testing.T) {
+	client := newTestRegistry(t)
+	if _, err := client.RegisterSchema("users-value", avroAdded, "AVRO"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing a field without a default is backward but not forward compatible
+	if err := client.SetCompatibility("users-value", "FORWARD"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.RegisterSchema("users-value", avroV1, "AVRO")
+	if apiErr := apiError(t, err); apiErr.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 under FORWARD, got %v", apiErr)
+	}
+
+	if err := client.SetCompatibility("users-value", "NONE"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.RegisterSchema("users-value", avroV1, "AVRO"); err != nil {
+		t.Fatalf("expected any schema to be accepted under NONE: %v", err)
+	}
+}
+
+func TestCheckCompatibilityReportsMessages(t *testing.T) {
+	client := newTestRegistry(t)
+	if _, err := client.RegisterSchema("users-value", avroV1, "AVRO"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.CheckCompatibility("users-value", "latest", avroAdded, "AVRO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Compatible || len(result.Messages) == 0 {
+		t.Fatalf("expected an incompatible result with messages, got %+v", result)
+	}
+
+	result, err = client.CheckCompatibility("users-value", "latest", avroDefault, "AVRO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Compatible {
+		t.Fatalf("expected a compatible result, got %+v", result)
+	}
+}
+
+func TestRegisterRejectsUncheckableType(t *testing.T) {
+	client := newTestRegistry(t)
+	proto := `syntax = "proto3"; message User { string name = 1; }`
+	if _, err := client.RegisterSchema("users-value", proto, "PROTOBUF"); err != nil {
+		t.Fatalf("expected the first version to need no check: %v", err)
+	}
+
+	_, err := client.RegisterSchema("users-value", `syntax = "proto3"; message User { int32 name = 1; }`, "PROTOBUF")
+	apiErr := apiError(t, err)
+	if apiErr.StatusCode != http.StatusUnprocessableEntity || apiErr.ErrorCode != errNotPermitted {
+		t.Fatalf("expected 422 for a type that cannot be checked, got %v", apiErr)
+	}
+
+	if err := client.SetCompatibility("users-value", "NONE"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.RegisterSchema("users-value", `syntax = "proto3"; message User { int32 name = 1; }`, "PROTOBUF"); err != nil {
+		t.Fatalf("expected the schema to be accepted under NONE: %v", err)
+	}
+}
+
+func TestSchemaByIDHasNoVersion(t *testing.T) {
+	client := newTestRegistry(t)
+	registered, err := client.RegisterSchema("users-value", avroV1, "AVRO")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := client.GetSchemaByID(registered.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	json.Unmarshal(data, &fields)
+	if _, ok := fields["version"]; ok {
+		t.Fatalf("expected a schema fetched by ID to have no version, got %s", data)
+	}
+
+	latest, err := client.GetSchema("users-value", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := json.Marshal(latest); !strings.Contains(string(data), `"version":1`) {
+		t.Fatalf("expected a subject version to keep its number, got %s", data)
+	}
+}
@@ -0,0 +1,685 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/og-dim9/dimutils/pkg/avro"
+)
+
+// storeFile is the file in the registry directory that holds its state
+const storeFile = "registry.json"
+
+// store is the state of a local schema registry, saved as JSON after every change
+type store struct {
+	path  string
+	state storeState
+}
+
+type storeState struct {
+	NextID        int                       `json:"next_id"`
+	Compatibility string                    `json:"compatibility"`
+	Mode          string                    `json:"mode,omitempty"`
+	Schemas       map[int]*storedSchema     `json:"schemas"`
+	Subjects      map[string]*storedSubject `json:"subjects"`
+}
+
+// storedSchema is a schema with its global ID; identical schemas share one ID across subjects
+type storedSchema struct {
+	ID         int         `json:"id"`
+	Type       string      `json:"type"`
+	Schema     string      `json:"schema"`
+	References []Reference `json:"references,omitempty"`
+}
+
+// version returns the stored schema as a subject version
+func (st *storedSchema) version(subject string, version int) *Schema {
+	return &Schema{ID: st.ID, Version: version, Schema: st.Schema, Type: st.Type, Subject: subject, References: st.References}
+}
+
+type storedSubject struct {
+	Compatibility string           `json:"compatibility,omitempty"`
+	Mode          string           `json:"mode,omitempty"`
+	Versions      []*storedVersion `json:"versions"`
+}
+
+type storedVersion struct {
+	Version int  `json:"version"`
+	ID      int  `json:"id"`
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Confluent error codes
+const (
+	errSubjectNotFound       = 40401
+	errVersionNotFound       = 40402
+	errSchemaNotFound        = 40403
+	errSubjectNotSoftDeleted = 40405
+	errVersionSoftDeleted    = 40406
+	errVersionNotSoftDeleted = 40407
+	errSubjectLevelNotSet    = 40408
+	errSubjectModeNotSet     = 40409
+	errIncompatibleSchema    = 409
+	errInvalidSchema         = 42201
+	errInvalidVersion        = 42202
+	errInvalidCompatibility  = 42203
+	errInvalidMode           = 42204
+	errNotPermitted          = 42205
+	errStore                 = 50001
+)
+
+func newAPIErrorf(status, code int, format string, args ...interface{}) *APIError {
+	return &APIError{StatusCode: status, ErrorCode: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func openStore(dir string) (*store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create registry directory: %w", err)
+	}
+
+	s := &store{
+		path: filepath.Join(dir, storeFile),
+		state: storeState{
+			NextID:        1,
+			Compatibility: "BACKWARD",
+			Schemas:       make(map[int]*storedSchema),
+			Subjects:      make(map[string]*storedSubject),
+		},
+	}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", s.path, err)
+	}
+	if err := json.Unmarshal(data, &s.state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.path, err)
+	}
+	if s.state.Schemas == nil {
+		s.state.Schemas = make(map[int]*storedSchema)
+	}
+	if s.state.Subjects == nil {
+		s.state.Subjects = make(map[string]*storedSubject)
+	}
+	return s, nil
+}
+
+// LocalSchemas returns the latest version of every subject in a local
+// registry directory, sorted by subject; a directory without a registry has none
+func LocalSchemas(dir string) ([]*Schema, error) {
+	if _, err := os.Stat(filepath.Join(dir, storeFile)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	s, err := openStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	var schemas []*Schema
+	for _, name := range s.subjects(false) {
+		schema, apiErr := s.version(name, "latest", false)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, nil
+}
+
+// save writes the state through a temporary file so a crash never leaves it half written
+func (s *store) save() *APIError {
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return newAPIErrorf(http.StatusInternalServerError, errStore, "failed to encode registry: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return newAPIErrorf(http.StatusInternalServerError, errStore, "failed to write registry: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return newAPIErrorf(http.StatusInternalServerError, errStore, "failed to write registry: %v", err)
+	}
+	return nil
+}
+
+// live returns a subject's versions, optionally including soft-deleted ones
+func (sub *storedSubject) live(deleted bool) []*storedVersion {
+	var versions []*storedVersion
+	for _, version := range sub.Versions {
+		if deleted || !version.Deleted {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+func (s *store) subjects(deleted bool) []string {
+	names := []string{}
+	for name, sub := range s.state.Subjects {
+		if len(sub.live(deleted)) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// subject returns a subject that has versions, optionally counting soft-deleted ones
+func (s *store) subject(name string, deleted bool) (*storedSubject, *APIError) {
+	sub, ok := s.state.Subjects[name]
+	if !ok || len(sub.live(deleted)) == 0 {
+		return nil, newAPIErrorf(http.StatusNotFound, errSubjectNotFound, "Subject '%s' not found.", name)
+	}
+	return sub, nil
+}
+
+func (s *store) versions(name string, deleted bool) ([]int, *APIError) {
+	sub, apiErr := s.subject(name, deleted)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	numbers := []int{}
+	for _, version := range sub.live(deleted) {
+		numbers = append(numbers, version.Version)
+	}
+	return numbers, nil
+}
+
+// version resolves "latest", "-1" or a version number of a subject
+func (s *store) version(name, ref string, deleted bool) (*Schema, *APIError) {
+	sub, apiErr := s.subject(name, deleted)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	versions := sub.live(deleted)
+
+	var found *storedVersion
+	if ref == "latest" || ref == "-1" {
+		found = versions[len(versions)-1]
+	} else {
+		number, err := strconv.Atoi(ref)
+		if err != nil || number < 1 {
+			return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidVersion,
+				"The specified version '%s' is not a valid version id. Allowed values are between [1, 2^31-1] and the string \"latest\"", ref)
+		}
+		for _, version := range versions {
+			if version.Version == number {
+				found = version
+			}
+		}
+		if found == nil {
+			return nil, newAPIErrorf(http.StatusNotFound, errVersionNotFound, "Version %d not found.", number)
+		}
+	}
+
+	return s.state.Schemas[found.ID].version(name, found.Version), nil
+}
+
+func (s *store) schemaByID(id int) (*storedSchema, *APIError) {
+	stored, ok := s.state.Schemas[id]
+	if !ok {
+		return nil, newAPIErrorf(http.StatusNotFound, errSchemaNotFound, "Schema %d not found", id)
+	}
+	return stored, nil
+}
+
+// usages lists the subject versions that use a schema ID
+func (s *store) usages(id int) []map[string]interface{} {
+	usages := []map[string]interface{}{}
+	for _, name := range s.subjects(false) {
+		for _, version := range s.state.Subjects[name].live(false) {
+			if version.ID == id {
+				usages = append(usages, map[string]interface{}{"subject": name, "version": version.Version})
+			}
+		}
+	}
+	return usages
+}
+
+// normalize validates a schema and its references and returns the form it is
+// stored and compared in. Avro schemas that use types from references are only
+// checked to be JSON.
+func (s *store) normalize(candidate *Schema) (*Schema, *APIError) {
+	for _, ref := range candidate.References {
+		if ref.Name == "" {
+			return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema: a reference has no name")
+		}
+		if _, apiErr := s.version(ref.Subject, strconv.Itoa(ref.Version), false); apiErr != nil {
+			return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema,
+				"Invalid schema: reference %s to %s version %d: %s", ref.Name, ref.Subject, ref.Version, apiErr.Message)
+		}
+	}
+
+	normalized := *candidate
+	switch candidate.Type {
+	case "AVRO", "JSON":
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(candidate.Schema)); err != nil {
+			return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema: %v", err)
+		}
+		if candidate.Type == "AVRO" && len(candidate.References) == 0 {
+			if _, err := avro.Parse(buf.String()); err != nil {
+				return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema: %v", err)
+			}
+		}
+		normalized.Schema = buf.String()
+	case "PROTOBUF":
+		if strings.TrimSpace(candidate.Schema) == "" {
+			return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema: empty")
+		}
+	default:
+		return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema type %s", candidate.Type)
+	}
+	return &normalized, nil
+}
+
+// find returns the ID of an identical schema with the same references
+func (s *store) find(candidate *Schema) (int, bool) {
+	for id, stored := range s.state.Schemas {
+		if stored.Type == candidate.Type && stored.Schema == candidate.Schema &&
+			sameReferences(stored.References, candidate.References) {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func sameReferences(a, b []Reference) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// lookup finds the version of a subject that has the schema
+func (s *store) lookup(name string, candidate *Schema) (*Schema, *APIError) {
+	sub, apiErr := s.subject(name, false)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	normalized, apiErr := s.normalize(candidate)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if id, ok := s.find(normalized); ok {
+		for _, version := range sub.live(false) {
+			if version.ID == id {
+				return s.state.Schemas[id].version(name, version.Version), nil
+			}
+		}
+	}
+	return nil, newAPIErrorf(http.StatusNotFound, errSchemaNotFound, "Schema not found")
+}
+
+// level returns the compatibility level that applies to a subject
+func (s *store) level(name string) string {
+	if sub, ok := s.state.Subjects[name]; ok && sub.Compatibility != "" {
+		return sub.Compatibility
+	}
+	return s.state.Compatibility
+}
+
+// previous returns the live versions of a subject as schemas, oldest first
+func (s *store) previous(name string) []*Schema {
+	sub, ok := s.state.Subjects[name]
+	if !ok {
+		return nil
+	}
+	var schemas []*Schema
+	for _, version := range sub.live(false) {
+		schemas = append(schemas, s.state.Schemas[version.ID].version(name, version.Version))
+	}
+	return schemas
+}
+
+// checkLevel checks a schema against a subject at its compatibility level
+func (s *store) checkLevel(name string, previous []*Schema, candidate *Schema) ([]string, *APIError) {
+	level := s.level(name)
+	if level == "NONE" || len(previous) == 0 || len(candidate.References) > 0 {
+		return nil, nil
+	}
+	if !CanCheck(candidate.Type) {
+		return nil, newAPIErrorf(http.StatusUnprocessableEntity, errNotPermitted,
+			"Compatibility of %s schemas cannot be checked; set the compatibility of subject %s to NONE", normalizeType(candidate.Type), name)
+	}
+	messages, err := CheckLevel(level, previous, candidate)
+	if err != nil {
+		return nil, newAPIErrorf(http.StatusUnprocessableEntity, errInvalidSchema, "Invalid schema: %v", err)
+	}
+	return messages, nil
+}
+
+// register adds a schema to a subject, returning the ID of an identical existing version when there is one.
+// An explicit ID or version is only accepted in IMPORT mode.
+func (s *store) register(name string, candidate *Schema, id, version int) (int, *APIError) {
+	normalized, apiErr := s.normalize(candidate)
+	if apiErr != nil {
+		return 0, apiErr
+	}
+
+	switch s.mode(name) {
+	case "READONLY":
+		return 0, newAPIErrorf(http.StatusUnprocessableEntity, errNotPermitted, "Subject %s is in read-only mode", name)
+	case "IMPORT":
+		return s.importSchema(name, normalized, id, version)
+	}
+	if id > 0 || version > 0 {
+		return 0, newAPIErrorf(http.StatusUnprocessableEntity, errNotPermitted,
+			"Subject %s is not in import mode; an ID or version can only be given when importing", name)
+	}
+
+	id, exists := s.find(normalized)
+	sub := s.state.Subjects[name]
+	if sub != nil && exists {
+		for _, version := range sub.live(false) {
+			if version.ID == id {
+				return id, nil
+			}
+		}
+	}
+
+	messages, apiErr := s.checkLevel(name, s.previous(name), normalized)
+	if apiErr != nil {
+		return 0, apiErr
+	}
+	if len(messages) > 0 {
+		return 0, newAPIErrorf(http.StatusConflict, errIncompatibleSchema,
+			"Schema being registered is incompatible with an earlier schema for subject \"%s\", details: %s",
+			name, strings.Join(messages, "; "))
+	}
+
+	if !exists {
+		id = s.state.NextID
+		s.state.NextID++
+		s.state.Schemas[id] = &storedSchema{ID: id, Type: normalized.Type, Schema: normalized.Schema, References: normalized.References}
+	}
+	if sub == nil {
+		sub = &storedSubject{}
+		s.state.Subjects[name] = sub
+	}
+	next := 1
+	if len(sub.Versions) > 0 {
+		next = sub.Versions[len(sub.Versions)-1].Version + 1
+	}
+	sub.Versions = append(sub.Versions, &storedVersion{Version: next, ID: id})
+
+	return id, s.save()
+}
+
+// importSchema registers a schema under a given ID and version without checking compatibility
+func (s *store) importSchema(name string, schema *Schema, id, version int) (int, *APIError) {
+	if id <= 0 {
+		var exists bool
+		if id, exists = s.find(schema); !exists {
+			id = s.state.NextID
+		}
+	}
+	if stored, ok := s.state.Schemas[id]; ok && (stored.Schema != schema.Schema || stored.Type != schema.Type ||
+		!sameReferences(stored.References, schema.References)) {
+		return 0, newAPIErrorf(http.StatusUnprocessableEntity, errNotPermitted,
+			"Overwrite new schema with id %d is not permitted.", id)
+	}
+
+	sub := s.state.Subjects[name]
+	if sub == nil {
+		sub = &storedSubject{}
+		s.state.Subjects[name] = sub
+	}
+	for _, existing := range sub.Versions {
+		if existing.ID == id && !existing.Deleted && (version <= 0 || existing.Version == version) {
+			return id, nil
+		}
+		if existing.Version == version {
+			return 0, newAPIErrorf(http.StatusUnprocessableEntity, errNotPermitted,
+				"Version %d of subject %s already holds another schema", version, name)
+		}
+	}
+	if version <= 0 {
+		version = 1
+		if len(sub.Versions) > 0 {
+			version = sub.Versions[len(sub.Versions)-1].Version + 1
+		}
+	}
+
+	s.state.Schemas[id] = &storedSchema{ID: id, Type: schema.Type, Schema: schema.Schema, References: schema.References}
+	if id >= s.state.NextID {
+		s.state.NextID = id + 1
+	}
+	sub.Versions = append(sub.Versions, &storedVersion{Version: version, ID: id})
+	sort.Slice(sub.Versions, func(i, j int) bool { return sub.Versions[i].Version < sub.Versions[j].Version })
+	return id, s.save()
+}
+
+// compatibility tests a schema against one version of a subject, or against the
+// versions its level requires when the version is "latest"
+func (s *store) compatibility(name, ref string, schema *Schema) (*CompatibilityResult, *APIError) {
+	candidate, apiErr := s.normalize(schema)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	previous := s.previous(name)
+	if ref != "latest" && ref != "-1" {
+		version, apiErr := s.version(name, ref, false)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		previous = []*Schema{version}
+	} else if len(previous) == 0 {
+		return nil, newAPIErrorf(http.StatusNotFound, errSubjectNotFound, "Subject '%s' not found.", name)
+	}
+
+	messages, apiErr := s.checkLevel(name, previous, candidate)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &CompatibilityResult{Compatible: len(messages) == 0, Messages: messages}, nil
+}
+
+// deleteSubject soft-deletes a subject's versions, or permanently removes an already soft-deleted subject
+func (s *store) deleteSubject(name string, permanent bool) ([]int, *APIError) {
+	sub, apiErr := s.subject(name, permanent)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	numbers := []int{}
+	if permanent {
+		if len(sub.live(false)) > 0 {
+			return nil, newAPIErrorf(http.StatusNotFound, errSubjectNotSoftDeleted,
+				"Subject '%s' was not deleted first before being permanently deleted", name)
+		}
+		for _, version := range sub.Versions {
+			numbers = append(numbers, version.Version)
+		}
+		delete(s.state.Subjects, name)
+		s.dropUnused()
+	} else {
+		for _, version := range sub.live(false) {
+			version.Deleted = true
+			numbers = append(numbers, version.Version)
+		}
+	}
+	return numbers, s.save()
+}
+
+// deleteVersion soft-deletes a version, or permanently removes an already soft-deleted one
+func (s *store) deleteVersion(name, ref string, permanent bool) (int, *APIError) {
+	version, apiErr := s.version(name, ref, permanent)
+	if apiErr != nil {
+		return 0, apiErr
+	}
+	sub := s.state.Subjects[name]
+
+	for i, stored := range sub.Versions {
+		if stored.Version != version.Version {
+			continue
+		}
+		if !permanent {
+			if stored.Deleted {
+				return 0, newAPIErrorf(http.StatusNotFound, errVersionSoftDeleted,
+					"Subject '%s' Version %d was soft deleted. Set permanent=true to delete permanently", name, stored.Version)
+			}
+			stored.Deleted = true
+			break
+		}
+		if !stored.Deleted {
+			return 0, newAPIErrorf(http.StatusNotFound, errVersionNotSoftDeleted,
+				"Subject '%s' Version %d was not deleted first before being permanently deleted", name, stored.Version)
+		}
+		sub.Versions = append(sub.Versions[:i], sub.Versions[i+1:]...)
+		if len(sub.Versions) == 0 {
+			delete(s.state.Subjects, name)
+		}
+		s.dropUnused()
+		break
+	}
+	return version.Version, s.save()
+}
+
+// dropUnused removes schemas no subject version refers to; their IDs are never reused
+func (s *store) dropUnused() {
+	used := make(map[int]bool)
+	for _, sub := range s.state.Subjects {
+		for _, version := range sub.Versions {
+			used[version.ID] = true
+		}
+	}
+	for id := range s.state.Schemas {
+		if !used[id] {
+			delete(s.state.Schemas, id)
+		}
+	}
+}
+
+func (s *store) getConfig(name string) (string, *APIError) {
+	if name == "" {
+		return s.state.Compatibility, nil
+	}
+	if sub, ok := s.state.Subjects[name]; ok && sub.Compatibility != "" {
+		return sub.Compatibility, nil
+	}
+	return "", newAPIErrorf(http.StatusNotFound, errSubjectLevelNotSet,
+		"Subject '%s' does not have subject-level compatibility configured", name)
+}
+
+func (s *store) setConfig(name, level string) (string, *APIError) {
+	level = strings.ToUpper(level)
+	if err := ValidateCompatibilityLevel(level); err != nil {
+		return "", newAPIErrorf(http.StatusUnprocessableEntity, errInvalidCompatibility, "Invalid compatibility level. Valid values are none, backward, forward, full, and their transitive variants")
+	}
+	if name == "" {
+		s.state.Compatibility = level
+	} else {
+		sub, ok := s.state.Subjects[name]
+		if !ok {
+			sub = &storedSubject{}
+			s.state.Subjects[name] = sub
+		}
+		sub.Compatibility = level
+	}
+	return level, s.save()
+}
+
+func (s *store) deleteConfig(name string) (string, *APIError) {
+	previous, apiErr := s.getConfig(name)
+	if apiErr != nil {
+		return "", apiErr
+	}
+	if name == "" {
+		s.state.Compatibility = "BACKWARD"
+		return previous, s.save()
+	}
+	sub := s.state.Subjects[name]
+	sub.Compatibility = ""
+	if len(sub.Versions) == 0 && sub.Mode == "" {
+		delete(s.state.Subjects, name)
+	}
+	return previous, s.save()
+}
+
+// mode returns the mode that applies to a subject
+func (s *store) mode(name string) string {
+	if sub, ok := s.state.Subjects[name]; ok && sub.Mode != "" {
+		return sub.Mode
+	}
+	if s.state.Mode != "" {
+		return s.state.Mode
+	}
+	return "READWRITE"
+}
+
+func (s *store) getMode(name string) (string, *APIError) {
+	if name == "" {
+		return s.mode(""), nil
+	}
+	if sub, ok := s.state.Subjects[name]; ok && sub.Mode != "" {
+		return sub.Mode, nil
+	}
+	return "", newAPIErrorf(http.StatusNotFound, errSubjectModeNotSet,
+		"Subject '%s' does not have subject-level mode configured", name)
+}
+
+// setMode changes the global or a subject's mode; IMPORT needs the subjects
+// it covers to be empty unless forced
+func (s *store) setMode(name, mode string, force bool) (string, *APIError) {
+	mode = strings.ToUpper(mode)
+	switch mode {
+	case "READWRITE", "READONLY", "IMPORT":
+	default:
+		return "", newAPIErrorf(http.StatusUnprocessableEntity, errInvalidMode,
+			"Invalid mode. Valid values are READWRITE, READONLY and IMPORT")
+	}
+	if mode == "IMPORT" && !force {
+		if name == "" && len(s.subjects(true)) > 0 {
+			return "", newAPIErrorf(http.StatusUnprocessableEntity, errNotPermitted,
+				"Cannot import since found existing subjects")
+		}
+		if sub, ok := s.state.Subjects[name]; ok && name != "" && len(sub.live(true)) > 0 {
+			return "", newAPIErrorf(http.StatusUnprocessableEntity, errNotPermitted,
+				"Cannot import since found existing subject %s", name)
+		}
+	}
+
+	if name == "" {
+		s.state.Mode = mode
+	} else {
+		sub, ok := s.state.Subjects[name]
+		if !ok {
+			sub = &storedSubject{}
+			s.state.Subjects[name] = sub
+		}
+		sub.Mode = mode
+	}
+	return mode, s.save()
+}
+
+func (s *store) deleteMode(name string) (string, *APIError) {
+	previous, apiErr := s.getMode(name)
+	if apiErr != nil {
+		return "", apiErr
+	}
+	if name == "" {
+		s.state.Mode = ""
+		return previous, s.save()
+	}
+	sub := s.state.Subjects[name]
+	sub.Mode = ""
+	if len(sub.Versions) == 0 && sub.Compatibility == "" {
+		delete(s.state.Subjects, name)
+	}
+	return previous, s.save()
+}
@@ -0,0 +1,782 @@
+package validate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// inputRecord is one record read from the input. NDJSON lines are kept raw
+// and decoded by the workers; the other formats are decoded while reading.
+type inputRecord struct {
+	lineNumber int
+	format     string
+	raw        []byte
+	value      interface{}
+	err        error
+	errColumn  int
+	columns    map[string]int // CSV: column name -> 1-based column
+}
+
+// decode returns the record's value, or the error that made it unreadable
+func (r *inputRecord) decode() (interface{}, []ValidationError) {
+	if r.err == nil && r.raw != nil {
+		var value interface{}
+		if err := json.Unmarshal(r.raw, &value); err != nil {
+			return nil, invalidJSON(r.lineNumber, err)
+		}
+		return value, nil
+	}
+	if r.err != nil {
+		return nil, []ValidationError{{
+			Path:        fmt.Sprintf("line %d", r.lineNumber),
+			Message:     fmt.Sprintf("Invalid %s: %v", strings.ToUpper(r.format), r.err),
+			Rule:        r.format + "_format",
+			Severity:    "error",
+			LineNumber:  r.lineNumber,
+			ColumnIndex: r.errColumn,
+		}}
+	}
+	return r.value, nil
+}
+
+// recordSource reads records one at a time; it returns io.EOF at the end
+type recordSource interface {
+	next() (*inputRecord, error)
+}
+
+// inputFormat returns the configured input format, or one from the file extension
+func inputFormat(config ValidationConfig) (string, error) {
+	format := strings.ToLower(config.InputFormat)
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(config.InputFile)) {
+		case ".csv":
+			format = "csv"
+		case ".tsv", ".tab":
+			format = "tsv"
+		case ".yaml", ".yml":
+			format = "yaml"
+		case ".xml":
+			format = "xml"
+		default:
+			format = "json"
+		}
+	}
+	switch format {
+	case "json", "ndjson", "jsonl":
+		return "json", nil
+	case "yml":
+		return "yaml", nil
+	case "csv", "tsv", "yaml", "xml":
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown input format %q: use json, csv, tsv, yaml or xml", config.InputFormat)
+}
+
+// newRecordSource reads records of the configured input format; CSV and XML
+// values are strings, converted to the types the schema expects when there
+// is one
+func newRecordSource(r io.Reader, config ValidationConfig) (recordSource, error) {
+	format, err := inputFormat(config)
+	if err != nil {
+		return nil, err
+	}
+	var coerce *schemaCoercer
+	if config.SchemaFile != "" && (format == "csv" || format == "tsv" || format == "xml") {
+		if coerce, err = loadSchemaCoercer(config.SchemaFile); err != nil {
+			return nil, err
+		}
+	}
+	reader := bufio.NewReaderSize(r, 1<<20)
+
+	switch format {
+	case "csv", "tsv":
+		delimiter, quote := ',', '"'
+		if format == "tsv" {
+			delimiter = '\t'
+		}
+		if config.Delimiter != "" {
+			if delimiter, err = optionRune("delimiter", config.Delimiter); err != nil {
+				return nil, err
+			}
+		}
+		if config.Quote != "" {
+			if quote, err = optionRune("quote", config.Quote); err != nil {
+				return nil, err
+			}
+		}
+		if delimiter == quote && delimiter != 0 {
+			return nil, fmt.Errorf("delimiter and quote must differ")
+		}
+		return &csvSource{r: reader, format: format, delimiter: delimiter, quote: quote,
+			header: !config.NoHeader, columns: config.Columns, coerce: coerce}, nil
+	case "yaml":
+		return &yamlSource{r: reader}, nil
+	case "xml":
+		pattern, err := parseRecordPath(config.RecordPath)
+		if err != nil {
+			return nil, err
+		}
+		decoder := xml.NewDecoder(reader)
+		decoder.Strict = true
+		return &xmlSource{decoder: decoder, pattern: pattern, coerce: coerce}, nil
+	}
+	return &ndjsonSource{r: reader}, nil
+}
+
+// optionRune reads a single-character option; "tab" and "\t" name a tab and
+// "none" turns quoting off
+func optionRune(name, value string) (rune, error) {
+	switch value {
+	case "tab", `\t`:
+		return '\t', nil
+	case "none":
+		if name == "quote" {
+			return 0, nil
+		}
+	}
+	if utf8.RuneCountInString(value) != 1 || value == "\n" || value == "\r" {
+		return 0, fmt.Errorf("invalid --%s %q: must be a single character", name, value)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
+}
+
+// ndjsonSource reads one JSON record per non-blank line; lines may be any length
+type ndjsonSource struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (s *ndjsonSource) next() (*inputRecord, error) {
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if len(line) > 0 || err == nil {
+			s.line++
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			return &inputRecord{lineNumber: s.line, format: "json", raw: trimmed}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// CSV and TSV
+
+// csvSource reads delimited rows into objects keyed by the header, or by
+// --columns. Column names with dots build nested objects.
+type csvSource struct {
+	r           *bufio.Reader
+	format      string
+	delimiter   rune
+	quote       rune // 0: no quoting
+	header      bool
+	columns     []string // names, or header=name renames
+	coerce      *schemaCoercer
+	names       []string
+	columnIndex map[string]int // shared by every record; read only
+	line        int
+}
+
+func (s *csvSource) next() (*inputRecord, error) {
+	for {
+		fields, line, err := s.readRow()
+		if err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return &inputRecord{lineNumber: line, format: s.format, err: err}, nil
+		}
+		if s.names == nil {
+			if err := s.setNames(fields); err != nil {
+				return nil, err
+			}
+			if s.header {
+				continue
+			}
+		}
+		// A short row would silently leave out fields; the error points at
+		// the first missing or extra column
+		if len(fields) != len(s.names) {
+			return &inputRecord{lineNumber: line, format: s.format, errColumn: min(len(fields), len(s.names)) + 1,
+				err: fmt.Errorf("row has %d fields, the header has %d", len(fields), len(s.names))}, nil
+		}
+
+		record := make(map[string]interface{}, len(fields))
+		for i, field := range fields {
+			setField(record, s.names[i], field)
+		}
+		var value interface{} = record
+		if s.coerce != nil {
+			value, _ = s.coerce.coerce(value, s.coerce.root)
+		}
+		return &inputRecord{lineNumber: line, format: s.format, value: value, columns: s.columnIndex}, nil
+	}
+}
+
+// setNames takes the column names from the header row or --columns
+func (s *csvSource) setNames(first []string) error {
+	renames := make(map[string]string)
+	var positional []string
+	for _, column := range s.columns {
+		if from, to, ok := strings.Cut(column, "="); ok {
+			renames[strings.TrimSpace(from)] = strings.TrimSpace(to)
+		} else {
+			positional = append(positional, strings.TrimSpace(column))
+		}
+	}
+	switch {
+	case len(positional) > 0:
+		s.names = positional
+	case s.header:
+		s.names = make([]string, len(first))
+		for i, name := range first {
+			name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+			if to, ok := renames[name]; ok {
+				name = to
+			}
+			s.names[i] = name
+		}
+	default:
+		s.names = make([]string, len(first))
+		for i := range first {
+			s.names[i] = fmt.Sprintf("column%d", i+1)
+		}
+	}
+	s.columnIndex = make(map[string]int, len(s.names))
+	for i, name := range s.names {
+		if name == "" {
+			return fmt.Errorf("column %d has no name", i+1)
+		}
+		if s.columnIndex[name] != 0 {
+			return fmt.Errorf("duplicate column name %q", name)
+		}
+		s.columnIndex[name] = i + 1
+	}
+	return nil
+}
+
+// setField stores a value at a dotted name, creating nested objects
+func setField(record map[string]interface{}, name string, value interface{}) {
+	parts := strings.Split(name, ".")
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := record[part].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			record[part] = child
+		}
+		record = child
+	}
+	record[parts[len(parts)-1]] = value
+}
+
+// readRow reads one row, which may span lines inside quotes; blank lines
+// are skipped. It returns the line the row starts on.
+func (s *csvSource) readRow() ([]string, int, error) {
+	for {
+		text, err := s.r.ReadString('\n')
+		if text == "" && err != nil {
+			return nil, s.line + 1, err
+		}
+		s.line++
+		start := s.line
+		if strings.TrimRight(text, "\r\n") == "" {
+			continue
+		}
+
+		var fields []string
+		var field strings.Builder
+		quoted, inQuotes := false, false
+		for {
+			runes := []rune(strings.TrimRight(text, "\r\n"))
+			for i := 0; i < len(runes); i++ {
+				r := runes[i]
+				switch {
+				case inQuotes && r == s.quote:
+					if i+1 < len(runes) && runes[i+1] == s.quote {
+						field.WriteRune(r)
+						i++
+					} else {
+						inQuotes = false
+					}
+				case inQuotes:
+					field.WriteRune(r)
+				case r == s.delimiter:
+					fields = append(fields, field.String())
+					field.Reset()
+					quoted = false
+				case r == s.quote && s.quote != 0 && field.Len() == 0 && !quoted:
+					inQuotes, quoted = true, true
+				default:
+					field.WriteRune(r)
+				}
+			}
+			if !inQuotes {
+				break
+			}
+			// A quoted field continues on the next line
+			if err != nil {
+				return nil, start, fmt.Errorf("unterminated quoted field")
+			}
+			field.WriteString("\n")
+			if text, err = s.r.ReadString('\n'); text == "" && err != nil {
+				return nil, start, fmt.Errorf("unterminated quoted field")
+			}
+			s.line++
+		}
+		return append(fields, field.String()), start, nil
+	}
+}
+
+// YAML
+
+// yamlSource reads each YAML document as a record; a document holding a
+// list gives one record per item
+type yamlSource struct {
+	r         *bufio.Reader
+	line      int
+	pending   []*inputRecord
+	done      bool
+	carry     string // content after the --- that ended the last document
+	carryLine int
+}
+
+func (s *yamlSource) next() (*inputRecord, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return nil, io.EOF
+		}
+		if err := s.readDocument(); err != nil {
+			return nil, err
+		}
+	}
+	record := s.pending[0]
+	s.pending = s.pending[1:]
+	return record, nil
+}
+
+// readDocument reads up to the next --- or ... line and queues its records
+func (s *yamlSource) readDocument() error {
+	var doc bytes.Buffer
+	start := 0
+	var itemLines []int // lines of top-level "- " items
+	if s.carry != "" {
+		doc.WriteString(s.carry + "\n")
+		start, s.carry = s.carryLine, ""
+	}
+	for {
+		text, err := s.r.ReadString('\n')
+		if text == "" && err != nil {
+			if err != io.EOF {
+				return err
+			}
+			s.done = true
+			break
+		}
+		s.line++
+		trimmed := strings.TrimRight(text, "\r\n")
+		if trimmed == "---" || strings.HasPrefix(trimmed, "--- ") || trimmed == "..." {
+			// Content after --- belongs to the next document
+			rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "---"))
+			if trimmed == "..." || strings.HasPrefix(rest, "#") {
+				rest = ""
+			}
+			if start > 0 {
+				s.carry, s.carryLine = rest, s.line
+				break
+			}
+			if rest != "" {
+				doc.WriteString(rest + "\n")
+				start = s.line
+			}
+			continue
+		}
+		content := strings.TrimSpace(trimmed)
+		if start == 0 && content != "" && !strings.HasPrefix(content, "#") && !strings.HasPrefix(content, "%") {
+			start = s.line
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			itemLines = append(itemLines, s.line)
+		}
+		doc.WriteString(text)
+		if err != nil {
+			s.done = true
+			break
+		}
+	}
+	if start == 0 {
+		return nil
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(doc.Bytes(), &value); err != nil {
+		s.pending = append(s.pending, &inputRecord{lineNumber: start, format: "yaml", err: err})
+		return nil
+	}
+	value = fromYAML(value)
+	if items, ok := value.([]interface{}); ok {
+		for i, item := range items {
+			line := start
+			if len(itemLines) == len(items) {
+				line = itemLines[i]
+			}
+			s.pending = append(s.pending, &inputRecord{lineNumber: line, format: "yaml", value: item})
+		}
+		return nil
+	}
+	s.pending = append(s.pending, &inputRecord{lineNumber: start, format: "yaml", value: value})
+	return nil
+}
+
+// fromYAML converts decoded YAML to the types encoding/json produces
+func fromYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = fromYAML(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = fromYAML(item)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return value
+}
+
+// XML
+
+// xmlSource reads the elements matching the record path as records.
+// Attributes and child elements become fields, repeated children become
+// lists, and text is the value of a plain element (or #text next to
+// attributes and children).
+type xmlSource struct {
+	decoder *xml.Decoder
+	pattern []string
+	coerce  *schemaCoercer
+	stack   []string
+	done    bool
+}
+
+// parseRecordPath reads a simple XPath: /a/b from the root, //b anywhere,
+// * for any element. The default is every child of the root element.
+func parseRecordPath(path string) ([]string, error) {
+	if path == "" {
+		path = "/*/*"
+	}
+	var pattern []string
+	if !strings.HasPrefix(path, "/") {
+		pattern = append(pattern, "**")
+	}
+	for i, step := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		if step == "" {
+			if i == len(strings.Split(strings.TrimPrefix(path, "/"), "/"))-1 {
+				return nil, fmt.Errorf("invalid record path %q", path)
+			}
+			pattern = append(pattern, "**")
+			continue
+		}
+		if strings.ContainsAny(step, "[]()@=") {
+			return nil, fmt.Errorf("invalid record path %q: only element names, * and // are supported", path)
+		}
+		pattern = append(pattern, step)
+	}
+	return pattern, nil
+}
+
+// matchPath reports whether an element stack matches a record path
+func matchPath(pattern, stack []string) bool {
+	if len(pattern) == 0 {
+		return len(stack) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(stack); i++ {
+			if matchPath(pattern[1:], stack[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(stack) > 0 && (pattern[0] == "*" || pattern[0] == stack[0]) && matchPath(pattern[1:], stack[1:])
+}
+
+func (s *xmlSource) next() (*inputRecord, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+	for {
+		token, err := s.decoder.Token()
+		line, column := s.decoder.InputPos()
+		if err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			// The document cannot be read past a syntax error
+			s.done = true
+			return &inputRecord{lineNumber: line, format: "xml", err: err, errColumn: column}, nil
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			s.stack = append(s.stack, t.Name.Local)
+			if !matchPath(s.pattern, s.stack) {
+				continue
+			}
+			value, err := s.element(t)
+			s.stack = s.stack[:len(s.stack)-1]
+			if err != nil {
+				s.done = true
+				line, column = s.decoder.InputPos()
+				return &inputRecord{lineNumber: line, format: "xml", err: err, errColumn: column}, nil
+			}
+			if s.coerce != nil {
+				value, _ = s.coerce.coerce(value, s.coerce.root)
+			}
+			return &inputRecord{lineNumber: line, format: "xml", value: value}, nil
+		case xml.EndElement:
+			s.stack = s.stack[:len(s.stack)-1]
+		}
+	}
+}
+
+// element reads an element up to its end tag
+func (s *xmlSource) element(start xml.StartElement) (interface{}, error) {
+	fields := make(map[string]interface{})
+	add := func(name string, value interface{}) {
+		switch existing := fields[name].(type) {
+		case nil:
+			fields[name] = value
+		case []interface{}:
+			fields[name] = append(existing, value)
+		default:
+			fields[name] = []interface{}{existing, value}
+		}
+	}
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+			continue
+		}
+		add(attr.Name.Local, attr.Value)
+	}
+
+	var text strings.Builder
+	for {
+		token, err := s.decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("unexpected end of document in <%s>", start.Name.Local)
+			}
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := s.element(t)
+			if err != nil {
+				return nil, err
+			}
+			add(t.Name.Local, child)
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(fields) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				fields["#text"] = content
+			}
+			return fields, nil
+		}
+	}
+}
+
+// Schema-driven type coercion
+
+// schemaCoercer converts string values to the types a JSON schema declares
+// for them, following local $refs
+type schemaCoercer struct {
+	root map[string]interface{}
+}
+
+func loadSchemaCoercer(path string) (*schemaCoercer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading schema file: %w", err)
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		// Boolean schemas declare no types
+		return &schemaCoercer{}, nil
+	}
+	return &schemaCoercer{root: root}, nil
+}
+
+// resolve follows local $refs such as #/$defs/address
+func (c *schemaCoercer) resolve(node map[string]interface{}) map[string]interface{} {
+	for i := 0; i < 32 && node != nil; i++ {
+		ref, ok := node["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#") {
+			return node
+		}
+		var target interface{} = c.root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			m, ok := target.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			target = m[part]
+		}
+		node, _ = target.(map[string]interface{})
+	}
+	return node
+}
+
+func schemaTypes(node map[string]interface{}) map[string]bool {
+	types := make(map[string]bool)
+	switch t := node["type"].(type) {
+	case string:
+		types[t] = true
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types[name] = true
+			}
+		}
+	}
+	return types
+}
+
+// coerce converts a value for a schema node; keep is false for an empty
+// cell that should count as missing
+func (c *schemaCoercer) coerce(value interface{}, node map[string]interface{}) (result interface{}, keep bool) {
+	node = c.resolve(node)
+	if node == nil {
+		return value, true
+	}
+	types := schemaTypes(node)
+
+	// A single repeated XML element or a lone value for a list
+	if _, isList := value.([]interface{}); !isList && types["array"] && len(types) <= 2 && (len(types) == 1 || types["null"]) {
+		if s, ok := value.(string); !ok || !strings.HasPrefix(strings.TrimSpace(s), "[") {
+			if s == "" && ok {
+				return c.empty(types)
+			}
+			value = []interface{}{value}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := node["properties"].(map[string]interface{})
+		additional, _ := node["additionalProperties"].(map[string]interface{})
+		for key, item := range v {
+			sub, _ := properties[key].(map[string]interface{})
+			if sub == nil {
+				sub = additional
+			}
+			if converted, keep := c.coerce(item, sub); keep {
+				v[key] = converted
+			} else {
+				delete(v, key)
+			}
+		}
+		return v, true
+	case []interface{}:
+		items, _ := node["items"].(map[string]interface{})
+		kept := v[:0]
+		for _, item := range v {
+			if converted, keep := c.coerce(item, items); keep {
+				kept = append(kept, converted)
+			}
+		}
+		return kept, true
+	case string:
+		// An empty cell is missing whatever the type, so required catches it
+		if v == "" {
+			return c.empty(types)
+		}
+		if len(types) == 0 || types["string"] {
+			return v, true
+		}
+		text := strings.TrimSpace(v)
+		if types["integer"] || types["number"] {
+			if n, err := strconv.ParseFloat(text, 64); err == nil {
+				return n, true
+			}
+		}
+		if types["boolean"] {
+			if b, err := strconv.ParseBool(text); err == nil {
+				return b, true
+			}
+		}
+		if types["null"] && text == "null" {
+			return nil, true
+		}
+		if types["array"] || types["object"] {
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+				return c.coerce(decoded, node)
+			}
+		}
+	}
+	return value, true
+}
+
+// empty is an empty cell: null where allowed, otherwise missing
+func (c *schemaCoercer) empty(types map[string]bool) (interface{}, bool) {
+	if types["null"] {
+		return nil, true
+	}
+	return nil, false
+}
+
+// fieldColumns fills in ColumnIndex for errors on CSV fields
+func fieldColumns(errors []ValidationError, record *inputRecord) {
+	if record.columns == nil {
+		return
+	}
+	for i := range errors {
+		if errors[i].ColumnIndex != 0 || errors[i].Field == "" {
+			continue
+		}
+		// JSON pointers and rule paths both become dotted names
+		var parts []string
+		if field := errors[i].Field; strings.HasPrefix(field, "/") {
+			for _, part := range strings.Split(field[1:], "/") {
+				parts = append(parts, strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~"))
+			}
+		} else {
+			parts = strings.FieldsFunc(field, func(r rune) bool { return r == '.' || r == '[' })
+		}
+		// The column of the field, or of the nearest enclosing one
+		for n := len(parts); n > 0; n-- {
+			if column, ok := record.columns[strings.Join(parts[:n], ".")]; ok {
+				errors[i].ColumnIndex = column
+				break
+			}
+		}
+	}
+}
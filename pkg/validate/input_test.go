@@ -0,0 +1,84 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/og-dim9/dimutils/pkg/jsonschema"
+)
+
+func validateCSV(t *testing.T, schema, data string) ValidationResult {
+	t.Helper()
+	schemaFile := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(schemaFile, []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := ValidationConfig{SchemaFile: schemaFile, InputFormat: "csv", Workers: 2}
+	source, err := newRecordSource(strings.NewReader(data), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := jsonschema.Load(schemaFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result ValidationResult
+	if err := validateStream(source, config, &result, schemaCheck(compiled)); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestEmptyRequiredStringCellIsMissing(t *testing.T) {
+	schema := `{"type": "object", "required": ["id", "name"], "properties": {
+		"id": {"type": "integer"}, "name": {"type": "string"}}}`
+	result := validateCSV(t, schema, "id,name\n1,Bob\n2,\n")
+
+	if result.Statistics.ValidRecords != 1 || result.Statistics.InvalidRecords != 1 {
+		t.Fatalf("got %d valid and %d invalid records, want 1 and 1", result.Statistics.ValidRecords, result.Statistics.InvalidRecords)
+	}
+	if len(result.Errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %+v", len(result.Errors), result.Errors)
+	}
+	err := result.Errors[0]
+	if err.Rule != "required" || err.LineNumber != 3 || !strings.Contains(err.Message, "name") {
+		t.Errorf("got %+v, want a required error for name on line 3", err)
+	}
+}
+
+func TestEmptyNullableCellIsNull(t *testing.T) {
+	schema := `{"type": "object", "required": ["note"], "properties": {
+		"note": {"type": ["string", "null"]}}}`
+	result := validateCSV(t, schema, "note\n\n\"\"\n")
+
+	if result.Statistics.TotalRecords != 1 || len(result.Errors) != 0 {
+		t.Fatalf("got %d records and errors %+v, want 1 valid record", result.Statistics.TotalRecords, result.Errors)
+	}
+}
+
+func TestCSVRowFieldCount(t *testing.T) {
+	schema := `{"type": "object"}`
+	result := validateCSV(t, schema, "id,name,email\n1,Ann,a@example.com\n2,Bob\n3,Cy,c@example.com,extra\n")
+
+	if result.Statistics.ValidRecords != 1 || result.Statistics.InvalidRecords != 2 {
+		t.Fatalf("got %d valid and %d invalid records, want 1 and 2", result.Statistics.ValidRecords, result.Statistics.InvalidRecords)
+	}
+	want := []struct {
+		line, column int
+		message      string
+	}{
+		{3, 3, "row has 2 fields, the header has 3"},
+		{4, 4, "row has 4 fields, the header has 3"},
+	}
+	if len(result.Errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %+v", len(result.Errors), len(want), result.Errors)
+	}
+	for i, w := range want {
+		err := result.Errors[i]
+		if err.Rule != "csv_format" || err.LineNumber != w.line || err.ColumnIndex != w.column || !strings.Contains(err.Message, w.message) {
+			t.Errorf("got %+v, want %q on line %d column %d", err, w.message, w.line, w.column)
+		}
+	}
+}
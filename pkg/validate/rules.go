@@ -0,0 +1,714 @@
+package validate
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// compiledRule is a ValidationRule with its field path and expressions parsed
+type compiledRule struct {
+	ValidationRule
+	path       []pathSegment
+	when       exprNode
+	expression exprNode
+}
+
+// compileRules parses the field paths and expressions of the rules so that
+// mistakes are reported before any data is read
+func compileRules(rules []ValidationRule) ([]*compiledRule, error) {
+	compiled := make([]*compiledRule, 0, len(rules))
+	for i, rule := range rules {
+		c := &compiledRule{ValidationRule: rule}
+		name := rule.Field
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		var err error
+		if rule.Field != "" {
+			if c.path, err = parsePath(rule.Field); err != nil {
+				return nil, fmt.Errorf("rule %s: %w", name, err)
+			}
+		}
+		if rule.When != "" {
+			if c.when, err = parseExpression(rule.When); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid when %q: %w", name, rule.When, err)
+			}
+		}
+		if rule.Expression != "" {
+			if c.expression, err = parseExpression(rule.Expression); err != nil {
+				return nil, fmt.Errorf("rule %s: invalid expression %q: %w", name, rule.Expression, err)
+			}
+		}
+		if rule.Field == "" && rule.Expression == "" {
+			return nil, fmt.Errorf("rule %s: a field or an expression is required", name)
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// Field paths
+
+// pathSegment is one step of a field path: a key, an index or [*]
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// pathValue is a value found at a concrete path such as items[2].sku
+type pathValue struct {
+	path  string
+	value interface{}
+}
+
+// parsePath reads paths such as address.city, items[*].sku and tags[0]
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	rest := path
+	for rest != "" {
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid field path %q: missing ]", path)
+			}
+			inner := rest[1:end]
+			if inner == "*" {
+				segments = append(segments, pathSegment{wildcard: true})
+			} else if index, err := strconv.Atoi(inner); err == nil && index >= 0 {
+				segments = append(segments, pathSegment{index: index, isIndex: true})
+			} else {
+				return nil, fmt.Errorf("invalid field path %q: index must be a number or *", path)
+			}
+			rest = rest[end+1:]
+			if strings.HasPrefix(rest, ".") {
+				rest = rest[1:]
+			}
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid field path %q: empty name", path)
+			}
+			segments = append(segments, pathSegment{key: rest[:end]})
+			rest = rest[end:]
+			if strings.HasPrefix(rest, ".") {
+				rest = rest[1:]
+				if rest == "" {
+					return nil, fmt.Errorf("invalid field path %q: empty name", path)
+				}
+			}
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty field path")
+	}
+	return segments, nil
+}
+
+// resolvePath returns the values a path selects and the concrete paths that
+// are missing; [*] selects every element of an array
+func resolvePath(root interface{}, segments []pathSegment) (found []pathValue, missing []string) {
+	var walk func(value interface{}, i int, path string)
+	walk = func(value interface{}, i int, path string) {
+		if i == len(segments) {
+			found = append(found, pathValue{path: path, value: value})
+			return
+		}
+		segment := segments[i]
+		switch {
+		case segment.wildcard:
+			items, ok := value.([]interface{})
+			if !ok {
+				missing = append(missing, path+"[*]")
+				return
+			}
+			for index, item := range items {
+				walk(item, i+1, fmt.Sprintf("%s[%d]", path, index))
+			}
+		case segment.isIndex:
+			items, ok := value.([]interface{})
+			itemPath := fmt.Sprintf("%s[%d]", path, segment.index)
+			if !ok || segment.index >= len(items) {
+				missing = append(missing, itemPath)
+				return
+			}
+			walk(items[segment.index], i+1, itemPath)
+		default:
+			fieldPath := segment.key
+			if path != "" {
+				fieldPath = path + "." + segment.key
+			}
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				missing = append(missing, fieldPath)
+				return
+			}
+			item, exists := obj[segment.key]
+			if !exists {
+				missing = append(missing, fieldPath)
+				return
+			}
+			walk(item, i+1, fieldPath)
+		}
+	}
+	walk(root, 0, "")
+	return found, missing
+}
+
+func hasWildcard(segments []pathSegment) bool {
+	for _, segment := range segments {
+		if segment.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Expressions
+//
+// Conditions and cross-field checks are small expressions over field paths:
+//
+//	country == "US"
+//	end_date > start_date
+//	sum(items[*].price) == total
+//	status == "shipped" && shipped_at != null
+//
+// Operands are paths, numbers, quoted strings, true, false and null, and
+// the functions sum, count, min, max and len. Strings that are both dates
+// or timestamps compare as times. Arithmetic on [*] paths works element by
+// element, and a comparison with a [*] path holds when it holds for every
+// element.
+
+// exprNode is a parsed expression
+type exprNode interface {
+	eval(root map[string]interface{}) interface{}
+}
+
+type literalExpr struct{ value interface{} }
+
+type pathExpr struct {
+	segments []pathSegment
+	list     bool // has [*]: evaluates to every match
+}
+
+type callExpr struct {
+	name string
+	arg  exprNode
+}
+
+type unaryExpr struct {
+	op      string
+	operand exprNode
+}
+
+type binaryExpr struct {
+	op          string
+	left, right exprNode
+}
+
+func (e literalExpr) eval(map[string]interface{}) interface{} { return e.value }
+
+func (e pathExpr) eval(root map[string]interface{}) interface{} {
+	found, _ := resolvePath(root, e.segments)
+	if e.list {
+		values := make([]interface{}, len(found))
+		for i, match := range found {
+			values[i] = match.value
+		}
+		return values
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	return found[0].value
+}
+
+func (e callExpr) eval(root map[string]interface{}) interface{} {
+	value := e.arg.eval(root)
+	items, isList := value.([]interface{})
+	switch e.name {
+	case "len":
+		switch v := value.(type) {
+		case string:
+			return float64(len([]rune(v)))
+		case []interface{}:
+			return float64(len(v))
+		case map[string]interface{}:
+			return float64(len(v))
+		}
+		return nil
+	case "count":
+		if !isList {
+			if value == nil {
+				return float64(0)
+			}
+			return float64(1)
+		}
+		return float64(len(items))
+	}
+	if !isList {
+		items = []interface{}{value}
+	}
+	var result float64
+	for i, item := range items {
+		n, ok := toNumber(item)
+		if !ok {
+			return nil
+		}
+		switch {
+		case e.name == "sum":
+			result += n
+		case i == 0:
+			result = n
+		case e.name == "min":
+			result = math.Min(result, n)
+		default:
+			result = math.Max(result, n)
+		}
+	}
+	if len(items) == 0 && e.name != "sum" {
+		return nil
+	}
+	return result
+}
+
+func (e unaryExpr) eval(root map[string]interface{}) interface{} {
+	value := e.operand.eval(root)
+	if e.op == "!" {
+		return !truthy(value)
+	}
+	if n, ok := toNumber(value); ok {
+		return -n
+	}
+	return nil
+}
+
+func (e binaryExpr) eval(root map[string]interface{}) interface{} {
+	switch e.op {
+	case "&&":
+		return truthy(e.left.eval(root)) && truthy(e.right.eval(root))
+	case "||":
+		return truthy(e.left.eval(root)) || truthy(e.right.eval(root))
+	}
+	left, right := e.left.eval(root), e.right.eval(root)
+	switch e.op {
+	case "+", "-", "*", "/":
+		return arithmetic(e.op, left, right)
+	}
+
+	// Comparisons hold for a list when they hold for every element
+	if items, ok := left.([]interface{}); ok {
+		for _, item := range items {
+			if !compare(e.op, item, right) {
+				return false
+			}
+		}
+		return true
+	}
+	if items, ok := right.([]interface{}); ok {
+		for _, item := range items {
+			if !compare(e.op, left, item) {
+				return false
+			}
+		}
+		return true
+	}
+	return compare(e.op, left, right)
+}
+
+// arithmetic applies an operator to numbers; lists of the same length are
+// combined element by element and a number is applied to every element
+func arithmetic(op string, left, right interface{}) interface{} {
+	leftItems, leftList := left.([]interface{})
+	rightItems, rightList := right.([]interface{})
+	if leftList || rightList {
+		n := len(leftItems)
+		if !leftList {
+			n = len(rightItems)
+		} else if rightList && len(rightItems) != n {
+			return nil
+		}
+		results := make([]interface{}, n)
+		for i := range results {
+			a, b := left, right
+			if leftList {
+				a = leftItems[i]
+			}
+			if rightList {
+				b = rightItems[i]
+			}
+			results[i] = arithmetic(op, a, b)
+		}
+		return results
+	}
+
+	a, okA := toNumber(left)
+	b, okB := toNumber(right)
+	if !okA || !okB {
+		return nil
+	}
+	switch op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	}
+	if b == 0 {
+		return nil
+	}
+	return a / b
+}
+
+// compare applies a comparison operator; values of different kinds are
+// only ever unequal
+func compare(op string, a, b interface{}) bool {
+	if op == "==" {
+		return valuesEqual(a, b)
+	}
+	if op == "!=" {
+		return !valuesEqual(a, b)
+	}
+	order, ok := orderValues(a, b)
+	if !ok {
+		return false
+	}
+	switch op {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if order, ok := orderValues(a, b); ok {
+		return order == 0
+	}
+	if x, ok := a.(bool); ok {
+		y, ok := b.(bool)
+		return ok && x == y
+	}
+	return false
+}
+
+// orderValues compares numbers (allowing for rounding in sums), times and
+// strings
+func orderValues(a, b interface{}) (int, bool) {
+	if x, ok := a.(float64); ok {
+		y, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		if math.Abs(x-y) <= 1e-9*math.Max(1, math.Max(math.Abs(x), math.Abs(y))) {
+			return 0, true
+		}
+		if x < y {
+			return -1, true
+		}
+		return 1, true
+	}
+	x, ok := a.(string)
+	if !ok {
+		return 0, false
+	}
+	y, ok := b.(string)
+	if !ok {
+		return 0, false
+	}
+	if tx, ok := parseTime(x); ok {
+		if ty, ok := parseTime(y); ok {
+			return tx.Compare(ty), true
+		}
+	}
+	return strings.Compare(x, y), true
+}
+
+func parseTime(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// truthy treats false, null and missing values as false
+func truthy(value interface{}) bool {
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return value != nil
+}
+
+// describe formats the operands of a failed comparison, e.g. "2024-01-01 > 2024-02-01"
+func describe(node exprNode, root map[string]interface{}) string {
+	if b, ok := node.(binaryExpr); ok && b.op != "&&" && b.op != "||" {
+		return fmt.Sprintf("%s %s %s", formatValue(b.left.eval(root)), b.op, formatValue(b.right.eval(root)))
+	}
+	return ""
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// Expression parser
+
+type exprToken struct {
+	kind string // ident, number, string, op, end
+	text string
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func parseExpression(text string) (exprNode, error) {
+	tokens, err := tokenizeExpression(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != "end" {
+		return nil, fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	return node, nil
+}
+
+func tokenizeExpression(text string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				b.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, exprToken{kind: "string", text: b.String()})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || runes[j] == 'e' || runes[j] == 'E') {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: "number", text: string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			j := i
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || strings.ContainsRune("_$.[]*", runes[j])) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: "ident", text: string(runes[i:j])})
+			i = j
+		default:
+			op := ""
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if op == "" && strings.ContainsRune("<>!+-*/(),", r) {
+				op = string(r)
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, exprToken{kind: "op", text: op})
+			i += len([]rune(op))
+		}
+	}
+	return append(tokens, exprToken{kind: "end"}), nil
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.pos] }
+
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	token := p.peek()
+	if token.kind != "op" {
+		return "", false
+	}
+	for _, op := range ops {
+		if token.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	return p.binary(p.and, "||")
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	return p.binary(p.comparison, "&&")
+}
+
+func (p *exprParser) comparison() (exprNode, error) {
+	left, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.accept("==", "!=", "<=", ">=", "<", ">"); ok {
+		right, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		return binaryExpr{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) additive() (exprNode, error) {
+	return p.binary(p.multiplicative, "+", "-")
+}
+
+func (p *exprParser) multiplicative() (exprNode, error) {
+	return p.binary(p.unary, "*", "/")
+}
+
+// binary parses a left-associative chain of operators
+func (p *exprParser) binary(next func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryExpr{op: op, operand: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (exprNode, error) {
+	token := p.peek()
+	switch token.kind {
+	case "number":
+		p.pos++
+		n, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token.text)
+		}
+		return literalExpr{n}, nil
+	case "string":
+		p.pos++
+		return literalExpr{token.text}, nil
+	case "ident":
+		p.pos++
+		switch token.text {
+		case "true":
+			return literalExpr{true}, nil
+		case "false":
+			return literalExpr{false}, nil
+		case "null":
+			return literalExpr{nil}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			switch token.text {
+			case "sum", "count", "min", "max", "len":
+			default:
+				return nil, fmt.Errorf("unknown function %s", token.text)
+			}
+			arg, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing ) after %s(", token.text)
+			}
+			return callExpr{name: token.text, arg: arg}, nil
+		}
+		segments, err := parsePath(token.text)
+		if err != nil {
+			return nil, err
+		}
+		return pathExpr{segments: segments, list: hasWildcard(segments)}, nil
+	case "op":
+		if token.text == "(" {
+			p.pos++
+			node, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, fmt.Errorf("missing )")
+			}
+			return node, nil
+		}
+	case "end":
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q", token.text)
+}
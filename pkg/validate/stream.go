@@ -0,0 +1,138 @@
+package validate
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// progressInterval is how many records pass between progress reports in verbose mode
+const progressInterval = 100000
+
+// recordCheck validates one record and returns its errors; it must be safe
+// to call from several goroutines
+type recordCheck func(record *inputRecord) []ValidationError
+
+// streamRecord is one input record handed to a worker
+type streamRecord struct {
+	seq    int
+	record *inputRecord
+}
+
+// streamResult is the outcome of one record
+type streamResult struct {
+	seq    int
+	errors []ValidationError
+}
+
+// validateStream reads records from source and checks them on a pool of
+// workers. Results are collected in input order, so errors and
+// statistics match a sequential run; at most a fixed window of records is in
+// flight, so memory does not grow with the input. Reading stops once
+// MaxErrors errors have been collected.
+func validateStream(source recordSource, config ValidationConfig, result *ValidationResult, check recordCheck) error {
+	workers := config.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	window := workers * 64
+
+	records := make(chan streamRecord, window)
+	results := make(chan streamResult, window)
+	slots := make(chan struct{}, window) // taken by the reader, returned as results are collected in order
+	done := make(chan struct{})
+	readErr := make(chan error, 1)
+
+	// Reader
+	go func() {
+		defer close(records)
+		for seq := 0; ; seq++ {
+			record, err := source.next()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				readErr <- err
+				return
+			}
+			select {
+			case <-done:
+				readErr <- nil
+				return
+			default:
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				readErr <- nil
+				return
+			}
+			records <- streamRecord{seq: seq, record: record}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for record := range records {
+				select {
+				case <-done:
+					continue // stopped: skip what was already read
+				default:
+				}
+				errors := check(record.record)
+				fieldColumns(errors, record.record)
+				results <- streamResult{seq: record.seq, errors: errors}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Collector: reorder results and update the statistics as records complete
+	pending := make(map[int]streamResult)
+	next := 0
+	stopped := false
+	for res := range results {
+		if stopped {
+			continue
+		}
+		pending[res.seq] = res
+		for {
+			res, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-slots
+
+			result.Statistics.TotalRecords++
+			if len(res.errors) > 0 {
+				result.Errors = append(result.Errors, res.errors...)
+				result.Statistics.InvalidRecords++
+			} else {
+				result.Statistics.ValidRecords++
+			}
+			if config.Verbose && result.Statistics.TotalRecords%progressInterval == 0 {
+				fmt.Fprintf(os.Stderr, "Validated %d records, %d invalid\n", result.Statistics.TotalRecords, result.Statistics.InvalidRecords)
+			}
+			if config.MaxErrors > 0 && len(result.Errors) >= config.MaxErrors {
+				// Stop the reader and the workers
+				stopped = true
+				close(done)
+				break
+			}
+		}
+	}
+	if err := <-readErr; err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	return nil
+}
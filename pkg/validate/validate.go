@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/og-dim9/dimutils/pkg/jsonschema"
 )
 
 // ValidationResult represents the result of a validation operation
@@ -51,6 +54,13 @@ type ValidationConfig struct {
 	Format          string            `json:"format"` // json, csv, text
 	StrictMode      bool              `json:"strict_mode"`
 	MaxErrors       int               `json:"max_errors"`
+	Workers         int               `json:"workers,omitempty"`
+	InputFormat     string            `json:"input_format,omitempty"` // json, csv, tsv, yaml, xml
+	Delimiter       string            `json:"delimiter,omitempty"`
+	Quote           string            `json:"quote,omitempty"`
+	Columns         []string          `json:"columns,omitempty"`
+	NoHeader        bool              `json:"no_header,omitempty"`
+	RecordPath      string            `json:"record_path,omitempty"`
 	IgnoreFields    []string          `json:"ignore_fields,omitempty"`
 	RequiredFields  []string          `json:"required_fields,omitempty"`
 	CustomRules     map[string]string `json:"custom_rules,omitempty"`
@@ -69,6 +79,8 @@ type ValidationRule struct {
 	MinValue    *float64 `json:"min_value,omitempty"`
 	MaxValue    *float64 `json:"max_value,omitempty"`
 	AllowedValues []string `json:"allowed_values,omitempty"`
+	When        string `json:"when,omitempty"`       // condition for the rule to apply, e.g. country == "US"
+	Expression  string `json:"expression,omitempty"` // cross-field check, e.g. end_date > start_date
 	Message     string `json:"message,omitempty"`
 }
 
@@ -124,9 +136,9 @@ func printHelp() error {
 Data validation and schema checking tool.
 
 Commands:
-  json <file>                     Validate JSON format and structure
-  schema <schema> <data>          Validate data against JSON schema
-  rules <rules> <data>            Validate data against custom rules
+  json [file]                     Validate JSON format and structure
+  schema <schema> [data]          Validate data against a JSON Schema (draft 2020-12)
+  rules <rules> [data]            Validate data against custom rules
   generate-schema <data>          Generate JSON schema from data
   create-rules                    Interactively create validation rules
 
@@ -134,7 +146,16 @@ Options:
   --format FORMAT                 Output format: json, text, csv (default: text)
   --output, -o FILE               Output file for results
   --strict                        Strict validation mode
-  --max-errors N                  Maximum number of errors to report (default: 100)
+  --max-errors N                  Stop after N errors (default: 100, 0 for no limit)
+  --workers, -w N                 Records validated in parallel (default: CPU count)
+  --input-format, -i FORMAT       Input format: json, csv, tsv, yaml, xml
+                                  (default: from the file extension, else json)
+  --delimiter, -d CHAR            CSV/TSV field delimiter (default: , or tab)
+  --quote CHAR                    CSV/TSV quote character, or none (default: ")
+  --columns LIST                  CSV/TSV column names, or header=name renames
+  --no-header                     CSV/TSV input has no header row
+  --record-path PATH              XML record elements: /root/item, //item
+                                  (default: /*/*, the children of the root)
   --ignore-fields FIELDS          Comma-separated list of fields to ignore
   --required-fields FIELDS        Comma-separated list of required fields
   --verbose, -v                   Verbose output
@@ -146,7 +167,33 @@ Examples:
   validate schema schema.json data.json
   validate rules validation-rules.json data.json
   validate generate-schema sample-data.json > schema.json
-  validate json --format json --output results.json data.json`
+  validate json --format json --output results.json data.json
+  cat events.ndjson | validate schema schema.json
+
+Rules files hold a JSON array of rules. A rule's field may be a nested path
+(address.city, items[*].sku, tags[0]); each rule can set:
+  required, type (string, email, url, regex), pattern, min_length,
+  max_length, min_value, max_value, allowed_values, message,
+  when         apply the rule only if this condition holds
+  expression   a cross-field condition every record must meet
+Conditions compare paths, numbers, quoted strings, true, false and null with
+== != < <= > >=, combine them with && || !, and may use + - * / and the
+functions sum, count, min, max and len. Dates and timestamps compare as
+times; a comparison with a [*] path must hold for every element:
+  [{"field": "zip", "when": "country == \"US\"", "type": "regex", "pattern": "^[0-9]{5}$"},
+   {"expression": "end_date > start_date"},
+   {"expression": "sum(items[*].price) == total"},
+   {"field": "items[*].qty", "min_value": 1}]
+
+Input is read as a stream, so files of any size can be validated; with no
+data file (or "-") it is read from stdin. JSON input has one record per line.
+CSV and TSV rows become objects keyed by the header (dotted names nest); a row
+with more or fewer fields than the header is an input error. YAML
+documents (or the items of a top-level list) and XML record elements become
+records too. XML attributes and child elements become fields, and repeated
+children become lists. With a schema, CSV and XML text is converted to the
+types the schema declares; empty cells count as missing, or null where the
+schema allows it. Errors carry the line and, for CSV and TSV, the column.`
 
 	fmt.Println(help)
 	return nil
@@ -159,59 +206,73 @@ func validateJSON(args []string) error {
 		ShowWarnings: true,
 	}
 
-	// Parse arguments
-	var inputFile string
-	for i, arg := range args {
-		switch arg {
-		case "--format", "-f":
-			if i+1 < len(args) {
-				config.Format = args[i+1]
-			}
-		case "--output", "-o":
-			if i+1 < len(args) {
-				config.OutputFile = args[i+1]
-			}
-		case "--strict":
-			config.StrictMode = true
-		case "--max-errors":
-			if i+1 < len(args) {
-				if maxErrors, err := strconv.Atoi(args[i+1]); err == nil {
-					config.MaxErrors = maxErrors
-				}
-			}
-		case "--verbose", "-v":
-			config.Verbose = true
-		case "--show-warnings":
-			config.ShowWarnings = true
-		default:
-			if !strings.HasPrefix(arg, "-") && inputFile == "" {
-				inputFile = arg
-			}
-		}
+	files, err := parseValidationOptions(args, &config)
+	if err != nil {
+		return err
 	}
-
-	if inputFile == "" {
-		return fmt.Errorf("input file required")
+	if len(files) > 1 {
+		return fmt.Errorf("only one input file is allowed")
+	}
+	if len(files) == 1 {
+		config.InputFile = files[0]
 	}
-
-	config.InputFile = inputFile
 	return performValidation(config)
 }
 
 func validateWithSchema(args []string) error {
-	if len(args) < 2 {
+	config := ValidationConfig{
+		Format:    "text",
+		MaxErrors: 100,
+	}
+
+	files, err := parseValidationOptions(args, &config)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 || len(files) > 2 {
 		return fmt.Errorf("schema file and data file required")
 	}
+	config.SchemaFile = files[0]
+	if len(files) == 2 {
+		config.InputFile = files[1]
+	}
+	return performValidation(config)
+}
 
+func validateWithRules(args []string) error {
 	config := ValidationConfig{
-		SchemaFile: args[0],
-		InputFile:  args[1],
-		Format:     "text",
-		MaxErrors:  100,
+		Format:    "text",
+		MaxErrors: 100,
+	}
+
+	files, err := parseValidationOptions(args, &config)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 || len(files) > 2 {
+		return fmt.Errorf("rules file and data file required")
+	}
+
+	// Load validation rules
+	rulesData, err := os.ReadFile(files[0])
+	if err != nil {
+		return fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	if err := json.Unmarshal(rulesData, &config.Rules); err != nil {
+		return fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	if len(files) == 2 {
+		config.InputFile = files[1]
 	}
+	return performValidation(config)
+}
 
-	// Parse additional arguments
-	for i := 2; i < len(args); i++ {
+// parseValidationOptions applies the shared options to config and returns
+// the file arguments; "-" stands for stdin
+func parseValidationOptions(args []string, config *ValidationConfig) ([]string, error) {
+	var files []string
+	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		switch arg {
 		case "--format", "-f":
@@ -226,38 +287,73 @@ func validateWithSchema(args []string) error {
 			}
 		case "--strict":
 			config.StrictMode = true
+		case "--max-errors":
+			if i+1 < len(args) {
+				maxErrors, err := strconv.Atoi(args[i+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid --max-errors value: %s", args[i+1])
+				}
+				config.MaxErrors = maxErrors
+				i++
+			}
+		case "--workers", "-w":
+			if i+1 < len(args) {
+				workers, err := strconv.Atoi(args[i+1])
+				if err != nil || workers < 1 {
+					return nil, fmt.Errorf("invalid --workers value: %s", args[i+1])
+				}
+				config.Workers = workers
+				i++
+			}
+		case "--input-format", "-i":
+			if i+1 < len(args) {
+				config.InputFormat = args[i+1]
+				i++
+			}
+		case "--delimiter", "-d":
+			if i+1 < len(args) {
+				config.Delimiter = args[i+1]
+				i++
+			}
+		case "--quote":
+			if i+1 < len(args) {
+				config.Quote = args[i+1]
+				i++
+			}
+		case "--columns":
+			if i+1 < len(args) {
+				config.Columns = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "--no-header":
+			config.NoHeader = true
+		case "--record-path":
+			if i+1 < len(args) {
+				config.RecordPath = args[i+1]
+				i++
+			}
+		case "--ignore-fields":
+			if i+1 < len(args) {
+				config.IgnoreFields = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "--required-fields":
+			if i+1 < len(args) {
+				config.RequiredFields = strings.Split(args[i+1], ",")
+				i++
+			}
 		case "--verbose", "-v":
 			config.Verbose = true
+		case "--show-warnings":
+			config.ShowWarnings = true
+		default:
+			if strings.HasPrefix(arg, "-") && arg != "-" {
+				return nil, fmt.Errorf("unknown option: %s", arg)
+			}
+			files = append(files, arg)
 		}
 	}
-
-	return performValidation(config)
-}
-
-func validateWithRules(args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("rules file and data file required")
-	}
-
-	// Load validation rules
-	rulesData, err := os.ReadFile(args[0])
-	if err != nil {
-		return fmt.Errorf("failed to read rules file: %w", err)
-	}
-
-	var rules []ValidationRule
-	if err := json.Unmarshal(rulesData, &rules); err != nil {
-		return fmt.Errorf("failed to parse rules file: %w", err)
-	}
-
-	config := ValidationConfig{
-		Rules:     rules,
-		InputFile: args[1],
-		Format:    "text",
-		MaxErrors: 100,
-	}
-
-	return performValidation(config)
+	return files, nil
 }
 
 func generateSchema(args []string) error {
@@ -338,6 +434,31 @@ func createRules(args []string) error {
 			}
 		}
 
+		if rule.Type == "number" {
+			fmt.Print("Min value (optional): ")
+			if scanner.Scan() {
+				if minValue, err := strconv.ParseFloat(strings.TrimSpace(scanner.Text()), 64); err == nil {
+					rule.MinValue = &minValue
+				}
+			}
+
+			fmt.Print("Max value (optional): ")
+			if scanner.Scan() {
+				if maxValue, err := strconv.ParseFloat(strings.TrimSpace(scanner.Text()), 64); err == nil {
+					rule.MaxValue = &maxValue
+				}
+			}
+		}
+
+		fmt.Print("Allowed values, comma-separated (optional): ")
+		if scanner.Scan() {
+			for _, value := range strings.Split(scanner.Text(), ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					rule.AllowedValues = append(rule.AllowedValues, value)
+				}
+			}
+		}
+
 		fmt.Print("Custom error message (optional): ")
 		if scanner.Scan() {
 			message := strings.TrimSpace(scanner.Text())
@@ -371,32 +492,38 @@ func performValidation(config ValidationConfig) error {
 		Statistics: ValidationStats{},
 	}
 
-	// Read input data
-	var data []byte
-	var err error
-	
-	if config.InputFile == "-" || config.InputFile == "" {
-		data, err = readStdin()
-	} else {
-		data, err = os.ReadFile(config.InputFile)
-	}
-	
-	if err != nil {
-		return fmt.Errorf("failed to read input: %w", err)
-	}
-
-	// Validate data
+	// Choose the check applied to each record
+	var check recordCheck
 	if config.SchemaFile != "" {
-		err = validateAgainstSchema(data, config, &result)
+		schema, err := jsonschema.Load(config.SchemaFile)
+		if err != nil {
+			return err
+		}
+		check = schemaCheck(schema)
 	} else if len(config.Rules) > 0 {
-		err = validateAgainstRules(data, config, &result)
+		rules, err := compileRules(config.Rules)
+		if err != nil {
+			return err
+		}
+		check = rulesCheck(rules)
 	} else {
-		err = validateJSONFormat(data, config, &result)
+		check = jsonFormatCheck
 	}
 
+	// Stream the input record by record
+	input, err := openInput(config.InputFile)
 	if err != nil {
 		return err
 	}
+	defer input.Close()
+	source, err := newRecordSource(input, config)
+	if err != nil {
+		return err
+	}
+
+	if err := validateStream(source, config, &result, check); err != nil {
+		return err
+	}
 
 	// Calculate statistics
 	result.Statistics.ProcessingTime = time.Since(startTime).String()
@@ -408,356 +535,265 @@ func performValidation(config ValidationConfig) error {
 	return outputResults(result, config)
 }
 
-func validateJSONFormat(data []byte, config ValidationConfig, result *ValidationResult) error {
-	lines := strings.Split(string(data), "\n")
-	
-	for lineNum, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+// openInput opens the input file, or stdin for "-" or no file when data is piped in
+func openInput(inputFile string) (io.ReadCloser, error) {
+	if inputFile != "" && inputFile != "-" {
+		file, err := os.Open(inputFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input: %w", err)
 		}
-
-		result.Statistics.TotalRecords++
-		
-		var jsonData interface{}
-		if err := json.Unmarshal([]byte(line), &jsonData); err != nil {
-			result.Errors = append(result.Errors, ValidationError{
-				Path:       fmt.Sprintf("line %d", lineNum+1),
-				Message:    fmt.Sprintf("Invalid JSON: %v", err),
-				Rule:       "json_format",
-				Severity:   "error",
-				LineNumber: lineNum + 1,
-			})
-			result.Statistics.InvalidRecords++
-		} else {
-			result.Statistics.ValidRecords++
-		}
-
-		if len(result.Errors) >= config.MaxErrors {
-			break
+		return file, nil
+	}
+	if inputFile == "" {
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return nil, fmt.Errorf("input file required (or pipe data to stdin)")
 		}
 	}
-
-	return nil
+	return io.NopCloser(os.Stdin), nil
 }
 
-func validateAgainstSchema(data []byte, config ValidationConfig, result *ValidationResult) error {
-	// Load schema
-	schemaData, err := os.ReadFile(config.SchemaFile)
-	if err != nil {
-		return fmt.Errorf("failed to read schema file: %w", err)
-	}
+func jsonFormatCheck(record *inputRecord) []ValidationError {
+	_, errors := record.decode()
+	return errors
+}
 
-	var schema Schema
-	if err := json.Unmarshal(schemaData, &schema); err != nil {
-		return fmt.Errorf("failed to parse schema: %w", err)
+func schemaCheck(schema *jsonschema.Schema) recordCheck {
+	return func(record *inputRecord) []ValidationError {
+		jsonData, errors := record.decode()
+		if errors != nil {
+			return errors
+		}
+		return schemaErrors(schema.Errors(jsonData), record.lineNumber)
 	}
+}
 
-	// Validate each JSON object
-	lines := strings.Split(string(data), "\n")
-	
-	for lineNum, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+func rulesCheck(rules []*compiledRule) recordCheck {
+	return func(record *inputRecord) []ValidationError {
+		value, errors := record.decode()
+		if errors != nil {
+			return errors
 		}
-
-		result.Statistics.TotalRecords++
-
-		var jsonData map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &jsonData); err != nil {
-			result.Errors = append(result.Errors, ValidationError{
-				Path:       fmt.Sprintf("line %d", lineNum+1),
-				Message:    fmt.Sprintf("Invalid JSON: %v", err),
-				Rule:       "json_format",
+		jsonData, ok := value.(map[string]interface{})
+		if !ok {
+			return []ValidationError{{
+				Path:       fmt.Sprintf("line %d", record.lineNumber),
+				Message:    "Record is not an object",
+				Rule:       "type",
 				Severity:   "error",
-				LineNumber: lineNum + 1,
-			})
-			result.Statistics.InvalidRecords++
-			continue
-		}
-
-		// Validate against schema
-		errors := validateObjectAgainstSchema(jsonData, schema, fmt.Sprintf("line %d", lineNum+1))
-		if len(errors) > 0 {
-			result.Errors = append(result.Errors, errors...)
-			result.Statistics.InvalidRecords++
-		} else {
-			result.Statistics.ValidRecords++
+				LineNumber: record.lineNumber,
+			}}
 		}
-
-		if len(result.Errors) >= config.MaxErrors {
-			break
+		errors = validateObjectAgainstRules(jsonData, rules, fmt.Sprintf("line %d", record.lineNumber))
+		for i := range errors {
+			errors[i].LineNumber = record.lineNumber
 		}
+		return errors
 	}
+}
 
-	return nil
+func invalidJSON(lineNumber int, err error) []ValidationError {
+	return []ValidationError{{
+		Path:       fmt.Sprintf("line %d", lineNumber),
+		Message:    fmt.Sprintf("Invalid JSON: %v", err),
+		Rule:       "json_format",
+		Severity:   "error",
+		LineNumber: lineNumber,
+	}}
 }
 
-func validateAgainstRules(data []byte, config ValidationConfig, result *ValidationResult) error {
-	lines := strings.Split(string(data), "\n")
-	
-	for lineNum, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
+// schemaErrors converts JSON schema errors for a line; Field is the JSON
+// pointer to the failing value
+func schemaErrors(errs []jsonschema.Error, lineNumber int) []ValidationError {
+	var errors []ValidationError
+	for _, e := range errs {
+		errors = append(errors, ValidationError{
+			Path:       fmt.Sprintf("line %d", lineNumber),
+			Field:      e.InstancePath,
+			Message:    e.Message,
+			Rule:       e.Keyword,
+			Severity:   "error",
+			LineNumber: lineNumber,
+		})
+	}
+	return errors
+}
 
-		result.Statistics.TotalRecords++
+// validateObjectAgainstRules checks a record against the rules; rule fields
+// are paths such as address.city or items[*].sku
+func validateObjectAgainstRules(obj map[string]interface{}, rules []*compiledRule, path string) []ValidationError {
+	var errors []ValidationError
 
-		var jsonData map[string]interface{}
-		if err := json.Unmarshal([]byte(line), &jsonData); err != nil {
-			result.Errors = append(result.Errors, ValidationError{
-				Path:       fmt.Sprintf("line %d", lineNum+1),
-				Message:    fmt.Sprintf("Invalid JSON: %v", err),
-				Rule:       "json_format",
-				Severity:   "error",
-				LineNumber: lineNum + 1,
-			})
-			result.Statistics.InvalidRecords++
+	for _, rule := range rules {
+		// Conditional rules
+		if rule.when != nil && !truthy(rule.when.eval(obj)) {
 			continue
 		}
 
-		// Validate against rules
-		errors := validateObjectAgainstRules(jsonData, config.Rules, fmt.Sprintf("line %d", lineNum+1))
-		if len(errors) > 0 {
-			result.Errors = append(result.Errors, errors...)
-			result.Statistics.InvalidRecords++
-		} else {
-			result.Statistics.ValidRecords++
-		}
-
-		if len(result.Errors) >= config.MaxErrors {
-			break
-		}
-	}
+		if rule.path != nil {
+			values, missing := resolvePath(obj, rule.path)
 
-	return nil
-}
+			// Required field check
+			if rule.Required {
+				for _, field := range missing {
+					message := rule.Message
+					if message == "" {
+						message = fmt.Sprintf("Required field '%s' is missing", field)
+					}
+					errors = append(errors, ValidationError{
+						Path:     path,
+						Field:    field,
+						Message:  message,
+						Rule:     "required",
+						Severity: "error",
+					})
+				}
+			}
 
-func validateObjectAgainstSchema(obj map[string]interface{}, schema Schema, path string) []ValidationError {
-	var errors []ValidationError
+			for _, match := range values {
+				errors = append(errors, validateRuleValue(rule.ValidationRule, match.path, match.value, path)...)
+			}
+		}
 
-	// Check required fields
-	for _, required := range schema.Required {
-		if _, exists := obj[required]; !exists {
+		// Cross-field checks
+		if rule.expression != nil && !truthy(rule.expression.eval(obj)) {
 			errors = append(errors, ValidationError{
 				Path:     path,
-				Field:    required,
-				Message:  fmt.Sprintf("Required field '%s' is missing", required),
-				Rule:     "required",
+				Field:    rule.Field,
+				Value:    describe(rule.expression, obj),
+				Message:  getErrorMessage(rule.ValidationRule, fmt.Sprintf("Condition '%s' is not met", rule.Expression)),
+				Rule:     "expression",
 				Severity: "error",
 			})
 		}
 	}
 
-	// Validate each field
-	for fieldName, value := range obj {
-		if schemaField, exists := schema.Properties[fieldName]; exists {
-			fieldErrors := validateFieldAgainstSchema(fieldName, value, schemaField, path)
-			errors = append(errors, fieldErrors...)
-		}
-	}
-
 	return errors
 }
 
-func validateFieldAgainstSchema(fieldName string, value interface{}, field SchemaField, path string) []ValidationError {
+// validateRuleValue checks one value selected by a rule's field
+func validateRuleValue(rule ValidationRule, field string, value interface{}, path string) []ValidationError {
 	var errors []ValidationError
 
 	// Type validation
-	if !isValidType(value, field.Type) {
-		errors = append(errors, ValidationError{
-			Path:     path,
-			Field:    fieldName,
-			Value:    fmt.Sprintf("%v", value),
-			Message:  fmt.Sprintf("Expected type '%s', got '%T'", field.Type, value),
-			Rule:     "type",
-			Severity: "error",
-		})
-		return errors
-	}
-
-	// String validations
-	if field.Type == "string" {
-		str := fmt.Sprintf("%v", value)
-		
-		if field.MinLength != nil && len(str) < *field.MinLength {
+	switch rule.Type {
+	case "email":
+		if !isValidEmail(fmt.Sprintf("%v", value)) {
 			errors = append(errors, ValidationError{
 				Path:     path,
-				Field:    fieldName,
-				Value:    str,
-				Message:  fmt.Sprintf("String length %d is less than minimum %d", len(str), *field.MinLength),
-				Rule:     "minLength",
+				Field:    field,
+				Value:    fmt.Sprintf("%v", value),
+				Message:  getErrorMessage(rule, "Invalid email format"),
+				Rule:     "email",
 				Severity: "error",
 			})
 		}
-		
-		if field.MaxLength != nil && len(str) > *field.MaxLength {
+	case "url":
+		if !isValidURL(fmt.Sprintf("%v", value)) {
 			errors = append(errors, ValidationError{
 				Path:     path,
-				Field:    fieldName,
-				Value:    str,
-				Message:  fmt.Sprintf("String length %d exceeds maximum %d", len(str), *field.MaxLength),
-				Rule:     "maxLength",
+				Field:    field,
+				Value:    fmt.Sprintf("%v", value),
+				Message:  getErrorMessage(rule, "Invalid URL format"),
+				Rule:     "url",
 				Severity: "error",
 			})
 		}
-		
-		if field.Pattern != "" {
-			if matched, _ := regexp.MatchString(field.Pattern, str); !matched {
+	case "regex":
+		if rule.Pattern != "" {
+			if matched, _ := regexp.MatchString(rule.Pattern, fmt.Sprintf("%v", value)); !matched {
 				errors = append(errors, ValidationError{
 					Path:     path,
-					Field:    fieldName,
-					Value:    str,
-					Message:  fmt.Sprintf("String does not match pattern '%s'", field.Pattern),
-					Rule:     "pattern",
-					Severity: "error",
-				})
-			}
-		}
-	}
-
-	// Number validations
-	if field.Type == "number" || field.Type == "integer" {
-		if num, ok := value.(float64); ok {
-			if field.Minimum != nil && num < *field.Minimum {
-				errors = append(errors, ValidationError{
-					Path:     path,
-					Field:    fieldName,
+					Field:    field,
 					Value:    fmt.Sprintf("%v", value),
-					Message:  fmt.Sprintf("Value %g is less than minimum %g", num, *field.Minimum),
-					Rule:     "minimum",
-					Severity: "error",
-				})
-			}
-			
-			if field.Maximum != nil && num > *field.Maximum {
-				errors = append(errors, ValidationError{
-					Path:     path,
-					Field:    fieldName,
-					Value:    fmt.Sprintf("%v", value),
-					Message:  fmt.Sprintf("Value %g exceeds maximum %g", num, *field.Maximum),
-					Rule:     "maximum",
+					Message:  getErrorMessage(rule, fmt.Sprintf("Does not match pattern '%s'", rule.Pattern)),
+					Rule:     "regex",
 					Severity: "error",
 				})
 			}
 		}
 	}
 
-	// Enum validation
-	if len(field.Enum) > 0 {
+	// Length validation for strings
+	if rule.Type == "string" {
 		str := fmt.Sprintf("%v", value)
-		valid := false
-		for _, enumValue := range field.Enum {
-			if str == enumValue {
-				valid = true
-				break
-			}
+		if rule.MinLength > 0 && len(str) < rule.MinLength {
+			errors = append(errors, ValidationError{
+				Path:     path,
+				Field:    field,
+				Value:    str,
+				Message:  getErrorMessage(rule, fmt.Sprintf("Length %d is less than minimum %d", len(str), rule.MinLength)),
+				Rule:     "minLength",
+				Severity: "error",
+			})
 		}
-		if !valid {
+		if rule.MaxLength > 0 && len(str) > rule.MaxLength {
 			errors = append(errors, ValidationError{
 				Path:     path,
-				Field:    fieldName,
+				Field:    field,
 				Value:    str,
-				Message:  fmt.Sprintf("Value '%s' is not in allowed values: %v", str, field.Enum),
-				Rule:     "enum",
+				Message:  getErrorMessage(rule, fmt.Sprintf("Length %d exceeds maximum %d", len(str), rule.MaxLength)),
+				Rule:     "maxLength",
 				Severity: "error",
 			})
 		}
 	}
 
-	return errors
-}
-
-func validateObjectAgainstRules(obj map[string]interface{}, rules []ValidationRule, path string) []ValidationError {
-	var errors []ValidationError
-
-	for _, rule := range rules {
-		value, exists := obj[rule.Field]
-		
-		// Required field check
-		if rule.Required && !exists {
-			message := rule.Message
-			if message == "" {
-				message = fmt.Sprintf("Required field '%s' is missing", rule.Field)
-			}
+	// Numeric range; numbers in strings count
+	if rule.MinValue != nil || rule.MaxValue != nil {
+		number, ok := toNumber(value)
+		switch {
+		case !ok:
 			errors = append(errors, ValidationError{
 				Path:     path,
-				Field:    rule.Field,
-				Message:  message,
-				Rule:     "required",
+				Field:    field,
+				Value:    fmt.Sprintf("%v", value),
+				Message:  getErrorMessage(rule, "Value is not a number"),
+				Rule:     "range",
+				Severity: "error",
+			})
+		case rule.MinValue != nil && number < *rule.MinValue:
+			errors = append(errors, ValidationError{
+				Path:     path,
+				Field:    field,
+				Value:    formatValue(number),
+				Message:  getErrorMessage(rule, fmt.Sprintf("Value %s is less than minimum %s", formatValue(number), formatValue(*rule.MinValue))),
+				Rule:     "minValue",
+				Severity: "error",
+			})
+		case rule.MaxValue != nil && number > *rule.MaxValue:
+			errors = append(errors, ValidationError{
+				Path:     path,
+				Field:    field,
+				Value:    formatValue(number),
+				Message:  getErrorMessage(rule, fmt.Sprintf("Value %s exceeds maximum %s", formatValue(number), formatValue(*rule.MaxValue))),
+				Rule:     "maxValue",
 				Severity: "error",
 			})
-			continue
 		}
+	}
 
-		if !exists {
-			continue
+	// Allowed values, compared as text
+	if len(rule.AllowedValues) > 0 {
+		text := fmt.Sprintf("%v", value)
+		if n, ok := value.(float64); ok {
+			text = formatValue(n)
 		}
-
-		// Type validation
-		switch rule.Type {
-		case "email":
-			if !isValidEmail(fmt.Sprintf("%v", value)) {
-				errors = append(errors, ValidationError{
-					Path:     path,
-					Field:    rule.Field,
-					Value:    fmt.Sprintf("%v", value),
-					Message:  getErrorMessage(rule, "Invalid email format"),
-					Rule:     "email",
-					Severity: "error",
-				})
-			}
-		case "url":
-			if !isValidURL(fmt.Sprintf("%v", value)) {
-				errors = append(errors, ValidationError{
-					Path:     path,
-					Field:    rule.Field,
-					Value:    fmt.Sprintf("%v", value),
-					Message:  getErrorMessage(rule, "Invalid URL format"),
-					Rule:     "url",
-					Severity: "error",
-				})
-			}
-		case "regex":
-			if rule.Pattern != "" {
-				if matched, _ := regexp.MatchString(rule.Pattern, fmt.Sprintf("%v", value)); !matched {
-					errors = append(errors, ValidationError{
-						Path:     path,
-						Field:    rule.Field,
-						Value:    fmt.Sprintf("%v", value),
-						Message:  getErrorMessage(rule, fmt.Sprintf("Does not match pattern '%s'", rule.Pattern)),
-						Rule:     "regex",
-						Severity: "error",
-					})
-				}
+		allowed := false
+		for _, candidate := range rule.AllowedValues {
+			if candidate == text {
+				allowed = true
+				break
 			}
 		}
-
-		// Length validation for strings
-		if rule.Type == "string" {
-			str := fmt.Sprintf("%v", value)
-			if rule.MinLength > 0 && len(str) < rule.MinLength {
-				errors = append(errors, ValidationError{
-					Path:     path,
-					Field:    rule.Field,
-					Value:    str,
-					Message:  getErrorMessage(rule, fmt.Sprintf("Length %d is less than minimum %d", len(str), rule.MinLength)),
-					Rule:     "minLength",
-					Severity: "error",
-				})
-			}
-			if rule.MaxLength > 0 && len(str) > rule.MaxLength {
-				errors = append(errors, ValidationError{
-					Path:     path,
-					Field:    rule.Field,
-					Value:    str,
-					Message:  getErrorMessage(rule, fmt.Sprintf("Length %d exceeds maximum %d", len(str), rule.MaxLength)),
-					Rule:     "maxLength",
-					Severity: "error",
-				})
-			}
+		if !allowed {
+			errors = append(errors, ValidationError{
+				Path:     path,
+				Field:    field,
+				Value:    text,
+				Message:  getErrorMessage(rule, fmt.Sprintf("Value must be one of: %s", strings.Join(rule.AllowedValues, ", "))),
+				Rule:     "allowed_values",
+				Severity: "error",
+			})
 		}
 	}
 
@@ -809,33 +845,6 @@ func generateSchemaField(value interface{}) SchemaField {
 	return field
 }
 
-func isValidType(value interface{}, expectedType string) bool {
-	switch expectedType {
-	case "string":
-		_, ok := value.(string)
-		return ok
-	case "number":
-		_, ok := value.(float64)
-		return ok
-	case "integer":
-		if num, ok := value.(float64); ok {
-			return num == float64(int64(num))
-		}
-		return false
-	case "boolean":
-		_, ok := value.(bool)
-		return ok
-	case "array":
-		_, ok := value.([]interface{})
-		return ok
-	case "object":
-		_, ok := value.(map[string]interface{})
-		return ok
-	default:
-		return true
-	}
-}
-
 func isValidEmail(email string) bool {
 	pattern := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
 	matched, _ := regexp.MatchString(pattern, email)
@@ -855,15 +864,6 @@ func getErrorMessage(rule ValidationRule, defaultMessage string) string {
 	return defaultMessage
 }
 
-func readStdin() ([]byte, error) {
-	scanner := bufio.NewScanner(os.Stdin)
-	var lines []string
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	return []byte(strings.Join(lines, "\n")), scanner.Err()
-}
-
 func outputResults(result ValidationResult, config ValidationConfig) error {
 	switch config.Format {
 	case "json":
@@ -891,18 +891,18 @@ func outputJSON(result ValidationResult, config ValidationConfig) error {
 
 func outputCSV(result ValidationResult, config ValidationConfig) error {
 	var lines []string
-	lines = append(lines, "Path,Field,Value,Message,Rule,Severity,LineNumber")
+	lines = append(lines, "Path,Field,Value,Message,Rule,Severity,LineNumber,ColumnIndex")
 
 	for _, err := range result.Errors {
-		line := fmt.Sprintf("%s,%s,%s,%s,%s,%s,%d",
-			err.Path, err.Field, err.Value, err.Message, err.Rule, err.Severity, err.LineNumber)
+		line := fmt.Sprintf("%s,%s,%s,%s,%s,%s,%d,%d",
+			err.Path, err.Field, err.Value, err.Message, err.Rule, err.Severity, err.LineNumber, err.ColumnIndex)
 		lines = append(lines, line)
 	}
 
 	if config.ShowWarnings {
 		for _, warn := range result.Warnings {
-			line := fmt.Sprintf("%s,%s,%s,%s,%s,%s,%d",
-				warn.Path, warn.Field, warn.Value, warn.Message, warn.Rule, warn.Severity, warn.LineNumber)
+			line := fmt.Sprintf("%s,%s,%s,%s,%s,%s,%d,%d",
+				warn.Path, warn.Field, warn.Value, warn.Message, warn.Rule, warn.Severity, warn.LineNumber, warn.ColumnIndex)
 			lines = append(lines, line)
 		}
 	}
@@ -935,9 +935,8 @@ func outputText(result ValidationResult, config ValidationConfig) error {
 		output.WriteString("Errors:\n")
 		for _, err := range result.Errors {
 			output.WriteString(fmt.Sprintf("  [ERROR] %s", err.Path))
-			if err.Field != "" {
-				output.WriteString(fmt.Sprintf(".%s", err.Field))
-			}
+			output.WriteString(columnSuffix(err.ColumnIndex))
+			output.WriteString(fieldSuffix(err.Field))
 			if err.Value != "" {
 				output.WriteString(fmt.Sprintf(" (value: %s)", err.Value))
 			}
@@ -951,9 +950,8 @@ func outputText(result ValidationResult, config ValidationConfig) error {
 		output.WriteString("Warnings:\n")
 		for _, warn := range result.Warnings {
 			output.WriteString(fmt.Sprintf("  [WARN] %s", warn.Path))
-			if warn.Field != "" {
-				output.WriteString(fmt.Sprintf(".%s", warn.Field))
-			}
+			output.WriteString(columnSuffix(warn.ColumnIndex))
+			output.WriteString(fieldSuffix(warn.Field))
 			if warn.Value != "" {
 				output.WriteString(fmt.Sprintf(" (value: %s)", warn.Value))
 			}
@@ -972,6 +970,26 @@ func outputText(result ValidationResult, config ValidationConfig) error {
 	return nil
 }
 
+// columnSuffix formats the column of a CSV or XML error after the line
+func columnSuffix(column int) string {
+	if column == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", column %d", column)
+}
+
+// fieldSuffix formats a field after the path: JSON pointers from schema
+// validation as "at /a/b", field names as ".name"
+func fieldSuffix(field string) string {
+	switch {
+	case field == "":
+		return ""
+	case strings.HasPrefix(field, "/"):
+		return " at " + field
+	}
+	return "." + field
+}
+
 func getStatusText(valid bool) string {
 	if valid {
 		return "VALID"